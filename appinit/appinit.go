@@ -0,0 +1,336 @@
+// Package appinit coordinates the initialization of a process's components (e.g. the
+// logger, config, AWS clients, and Slack), running each one only after the components
+// it depends on, and registering their teardowns with the shutdown package so that
+// they're torn down in the reverse order.
+package appinit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/gensync"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/shutdown"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Func is a function that initializes or tears down a component.
+type Func func(ctx context.Context) stackerr.Error
+
+type Component struct {
+	// The unique name of the component, used for dependencies, logging, and errors.
+	Name string
+	// OPTIONAL. The names of the components that must be initialized before this one.
+	DependsOn []string
+	// The function that initializes the component.
+	Init Func
+	// OPTIONAL. The maximum amount of time the initialization may run for. If not
+	// provided, the initializer's default timeout will be used.
+	Timeout time.Duration
+	// OPTIONAL. A function that tears down the component. If the component is
+	// initialized, it's registered as a shutdown hook, and run before the teardowns
+	// of the components that this one depends on.
+	Teardown Func
+	// OPTIONAL. The maximum amount of time the teardown may run for. If not provided,
+	// the shutdown manager's default hook timeout will be used.
+	TeardownTimeout time.Duration
+}
+
+// Initializer initializes a set of registered components in dependency order.
+type Initializer interface {
+	// Register will register a component to be initialized by Run. It
+	// returns an error if a component with the same name is already
+	// registered, or if Run has already been called.
+	Register(component Component) stackerr.Error
+
+	// Run initializes all registered components. Each component is initialized once all
+	// of its dependencies have been, and components that don't depend on each other are
+	// initialized in parallel. If a component fails, the components that depend on it
+	// (directly or indirectly) are skipped, but all others are still initialized. All
+	// failures are combined into the returned error.
+	//
+	// The teardowns of all components that were initialized are registered with the
+	// shutdown manager, even if Run fails, so that a shutdown cleans them up.
+	//
+	// It only runs once; subsequent calls wait for the first to finish and return
+	// the same result.
+	Run(ctx context.Context) stackerr.Error
+}
+
+type NewInitializerInput struct {
+	// OPTIONAL. The default maximum amount of time that each component's initialization
+	// may run for. Defaults to 30 seconds.
+	DefaultTimeout time.Duration
+	// OPTIONAL. The maximum number of components that can be initialized at once. If 0
+	// or less, there is no limit.
+	MaxParallelism int
+	// OPTIONAL. The shutdown manager to register teardowns with. If not provided, the
+	// default shutdown manager is used.
+	ShutdownManager shutdown.Manager
+	// OPTIONAL. The shutdown priority of the teardowns of the components that nothing
+	// else depends on. Each layer of dependencies below them gets the next priority,
+	// so that they're torn down afterwards. Defaults to 0.
+	TeardownPriority int
+}
+
+type initializer struct {
+	input      NewInitializerInput
+	lock       sync.Mutex
+	components map[string]Component
+	// The order that components were registered in, so that errors and
+	// teardowns are deterministic
+	order []string
+	once  sync.Once
+	ran   bool
+	err   stackerr.Error
+}
+
+// NewInitializer creates a new initializer.
+func NewInitializer(input NewInitializerInput) Initializer {
+	if input.DefaultTimeout <= 0 {
+		input.DefaultTimeout = 30 * time.Second
+	}
+	return &initializer{
+		input:      input,
+		components: map[string]Component{},
+	}
+}
+
+func (in *initializer) Register(component Component) stackerr.Error {
+	if component.Name == "" {
+		return stackerr.Errorf("the `component.Name` field must not be empty")
+	}
+	if component.Init == nil {
+		return stackerr.Errorf("the `component.Init` field must not be nil")
+	}
+	in.lock.Lock()
+	defer in.lock.Unlock()
+	if in.ran {
+		return stackerr.Errorf("cannot register component '%s', initialization has already run", component.Name)
+	}
+	if _, ok := in.components[component.Name]; ok {
+		return stackerr.Errorf("a component named '%s' is already registered", component.Name)
+	}
+	in.components[component.Name] = component
+	in.order = append(in.order, component.Name)
+	return nil
+}
+
+// depths validates the dependencies of the components, and gets the depth of each
+// component in the dependency graph (0 for components with no dependencies, otherwise
+// one more than the deepest dependency).
+func (in *initializer) depths() (map[string]int, stackerr.Error) {
+	depths := map[string]int{}
+	// The components that are currently being visited, to detect cycles
+	visiting := map[string]bool{}
+	var visit func(name string, path []string) stackerr.Error
+	visit = func(name string, path []string) stackerr.Error {
+		if _, ok := depths[name]; ok {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			return stackerr.Errorf("components have a circular dependency: %s", strings.Join(path, " -> "))
+		}
+		visiting[name] = true
+		depth := 0
+		for _, dependency := range in.components[name].DependsOn {
+			if _, ok := in.components[dependency]; !ok {
+				return stackerr.Errorf("component '%s' depends on '%s', which is not registered", name, dependency)
+			}
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+			if depths[dependency]+1 > depth {
+				depth = depths[dependency] + 1
+			}
+		}
+		visiting[name] = false
+		depths[name] = depth
+		return nil
+	}
+	for _, name := range in.order {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return depths, nil
+}
+
+// runInit runs a single component's initialization with its timeout.
+func (in *initializer) runInit(ctx context.Context, component Component) (err stackerr.Error) {
+	timeout := component.Timeout
+	if timeout <= 0 {
+		timeout = in.input.DefaultTimeout
+	}
+	initCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan stackerr.Error, 1)
+	go func() {
+		var err stackerr.Error
+		defer func() {
+			if r := recover(); r != nil {
+				err = stackerr.FromRecover(r)
+			}
+			result <- err
+		}()
+		err = component.Init(initCtx)
+	}()
+
+	// Don't wait on an initialization that ignores its context
+	select {
+	case err = <-result:
+	case <-initCtx.Done():
+		err = stackerr.Errorf("component initialization timed out after %s", timeout)
+	}
+	if err != nil {
+		return err.WithSingle("component", component.Name)
+	}
+	log.Debugw("Initialized component", "component", component.Name, "duration", time.Since(start).String())
+	return nil
+}
+
+// registerTeardowns registers the teardowns of the initialized components with the shutdown
+// manager, with the components that have the deepest dependencies torn down first.
+func (in *initializer) registerTeardowns(initialized []string, depths map[string]int) stackerr.Error {
+	register := shutdown.Register
+	if in.input.ShutdownManager != nil {
+		register = in.input.ShutdownManager.Register
+	}
+	maxDepth := 0
+	for _, name := range initialized {
+		if depths[name] > maxDepth {
+			maxDepth = depths[name]
+		}
+	}
+	// Register in descending order of depth, so that components in the
+	// same layer are torn down in the reverse order they were registered
+	sort.SliceStable(initialized, func(i, j int) bool {
+		return depths[initialized[i]] > depths[initialized[j]]
+	})
+	for _, name := range initialized {
+		component := in.components[name]
+		if component.Teardown == nil {
+			continue
+		}
+		if _, err := register(shutdown.HookInput{
+			Name:     "teardown: " + name,
+			Priority: in.input.TeardownPriority + maxDepth - depths[name],
+			Timeout:  component.TeardownTimeout,
+			Hook:     shutdown.Hook(component.Teardown),
+		}); err != nil {
+			return err.WithSingle("component", name)
+		}
+	}
+	return nil
+}
+
+func (in *initializer) Run(ctx context.Context) stackerr.Error {
+	in.once.Do(func() {
+		in.lock.Lock()
+		in.ran = true
+		in.lock.Unlock()
+
+		depths, err := in.depths()
+		if err != nil {
+			in.err = err
+			return
+		}
+
+		// A channel for each component that's closed once it's done, and
+		// whether it succeeded (which is set before the channel is closed)
+		done := map[string]chan struct{}{}
+		succeeded := map[string]bool{}
+		for _, name := range in.order {
+			done[name] = make(chan struct{})
+		}
+
+		var slots chan struct{}
+		if in.input.MaxParallelism > 0 {
+			slots = make(chan struct{}, in.input.MaxParallelism)
+		}
+
+		resultLock := sync.Mutex{}
+		initialized := []string{}
+		skipped := []string{}
+
+		group := gensync.NewErrGroup(gensync.NewErrGroupInput{
+			CollectAllErrors: true,
+		})
+		for _, name := range in.order {
+			component := in.components[name]
+			group.Go(func() stackerr.Error {
+				defer close(done[component.Name])
+				// Wait for all dependencies to finish, and skip this component if any failed
+				for _, dependency := range component.DependsOn {
+					<-done[dependency]
+					resultLock.Lock()
+					ok := succeeded[dependency]
+					resultLock.Unlock()
+					if !ok {
+						resultLock.Lock()
+						skipped = append(skipped, component.Name)
+						resultLock.Unlock()
+						return nil
+					}
+				}
+				// Only take a slot once the dependencies are done, so that
+				// waiting components can't block the ones they wait on
+				if slots != nil {
+					slots <- struct{}{}
+					defer func() { <-slots }()
+				}
+				if err := in.runInit(ctx, component); err != nil {
+					return err
+				}
+				resultLock.Lock()
+				succeeded[component.Name] = true
+				initialized = append(initialized, component.Name)
+				resultLock.Unlock()
+				return nil
+			})
+		}
+		in.err = group.Wait()
+
+		// Keep the teardown order deterministic, regardless of the order of completion
+		sort.SliceStable(initialized, func(i, j int) bool {
+			return in.indexOf(initialized[i]) > in.indexOf(initialized[j])
+		})
+		if err := in.registerTeardowns(initialized, depths); err != nil && in.err == nil {
+			in.err = err
+		}
+
+		if in.err != nil && len(skipped) > 0 {
+			sort.Strings(skipped)
+			in.err = in.err.WithSingle("skipped_components", skipped)
+		}
+	})
+	return in.err
+}
+
+// indexOf gets the index of a component in the registration order.
+func (in *initializer) indexOf(name string) int {
+	for i, n := range in.order {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+var defaultInitializer = NewInitializer(NewInitializerInput{})
+
+// Register will register a component with the default initializer.
+func Register(component Component) stackerr.Error {
+	return defaultInitializer.Register(component)
+}
+
+// Run will initialize the components registered with the default initializer.
+func Run(ctx context.Context) stackerr.Error {
+	return defaultInitializer.Run(ctx)
+}
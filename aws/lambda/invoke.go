@@ -167,3 +167,29 @@ func Invoke(ctx context.Context, arn string, payload []byte) (responsePayload []
 	}
 	return invokeOutput.Payload, nil
 }
+
+// InvokeAsync invokes a Lambda function asynchronously (the "Event" invocation type), so
+// it returns as soon as the invocation has been queued, without waiting for the result.
+func InvokeAsync(ctx context.Context, arn string, payload []byte) stackerr.Error {
+	parsedArn, cerr := awsarn.Parse(arn)
+	if cerr != nil {
+		return stackerr.Wrap(cerr)
+	}
+	client, err := getLambdaClient(ctx, parsedArn.Region)
+	if err != nil {
+		return err
+	}
+	invokeOutput, cerr := client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   conversions.GetPtr(arn),
+		InvocationType: types.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	if cerr != nil {
+		return stackerr.Wrap(cerr).WithSingle("arn", arn)
+	}
+	// Asynchronous invocations return 202 (Accepted) once they're queued
+	if invokeOutput.StatusCode != 202 {
+		return stackerr.Errorf("asynchronous Lambda invocation was not accepted (status %d)", invokeOutput.StatusCode).WithSingle("arn", arn)
+	}
+	return nil
+}
@@ -88,7 +88,10 @@ func PutObject[ContentType string | *string | []byte | *bytes.Reader | *strings.
 		return err
 	}
 
-	parts := strings.SplitN(parsedArn.Resource, "/", 1)
+	parts := strings.SplitN(parsedArn.Resource, "/", 2)
+	if len(parts) != 2 {
+		return stackerr.Errorf("the S3 ARN (%s) does not include an object key", arn)
+	}
 	bucket := parts[0]
 	key := parts[1]
 
@@ -149,6 +152,9 @@ func GetObject(ctx context.Context, arn string, disableChecksumVerification ...b
 	}
 
 	parts := strings.SplitN(parsedArn.Resource, "/", 2)
+	if len(parts) != 2 {
+		return nil, stackerr.Errorf("the S3 ARN (%s) does not include an object key", arn)
+	}
 	bucket := parts[0]
 	key := parts[1]
 
@@ -1,15 +1,16 @@
 package s3
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/Invicton-Labs/go-common/aws/credentials"
+	"github.com/Invicton-Labs/go-common/ioutils"
 	"github.com/Invicton-Labs/go-common/log"
 	"github.com/Invicton-Labs/go-stackerr"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
@@ -28,23 +29,40 @@ func GetSigner() *v4.Signer {
 	return signer
 }
 
-func getAndRewindHttpRequestBody(req *http.Request) ([]byte, stackerr.Error) {
-	if req == nil || req.Body == nil {
-		return nil, nil
+// hashHttpRequestBody gets the hex-encoded SHA-256 hash of the request body, leaving
+// the body ready to be read again.
+func hashHttpRequestBody(req *http.Request) (string, stackerr.Error) {
+	hash := sha256.New()
+	if req == nil || req.Body == nil || req.Body == http.NoBody {
+		return fmt.Sprintf("%x", hash.Sum(nil)), nil
 	}
-	b, err := io.ReadAll(req.Body)
-	req.Body.Close()
-	// Ensure that there's always a body, even if it's empty
-	if b == nil {
-		b = []byte{}
+
+	// If the body can be re-created (which http.NewRequest does for in-memory bodies),
+	// hash a fresh copy of it instead of buffering the original.
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", stackerr.Wrap(err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", stackerr.Wrap(err)
+		}
+		return fmt.Sprintf("%x", hash.Sum(nil)), nil
 	}
-	// Rewind the body. Always do this, even on an error,
-	// as an error does not necessarily mean we don't need the body later.
-	req.Body = io.NopCloser(bytes.NewBuffer(b))
-	if err != nil {
-		return nil, stackerr.Wrap(err)
+
+	// Otherwise, the whole body needs to be buffered so it can be rewound after hashing.
+	// Always replace the body, even on an error, as an error does not necessarily
+	// mean we don't need the body later.
+	body := ioutils.RewindableBody(req.Body, math.MaxInt64)
+	req.Body = body
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", stackerr.Wrap(err)
+	}
+	if err := body.Rewind(); err != nil {
+		return "", err
 	}
-	return b, nil
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
 // SignRequest will sign the given HTTP request (in-place modification) with the default AWS credentials
@@ -54,11 +72,10 @@ func SignRequest(ctx context.Context, req *http.Request) stackerr.Error {
 		return err
 	}
 
-	body, err := getAndRewindHttpRequestBody(req)
+	bodyHash, err := hashHttpRequestBody(req)
 	if err != nil {
 		return err
 	}
 
-	bodyHash := sha256.Sum256(body)
-	return stackerr.Wrap(signer.SignHTTP(ctx, creds, req, fmt.Sprintf("%x", bodyHash), "", "", time.Now()))
+	return stackerr.Wrap(signer.SignHTTP(ctx, creds, req, bodyHash, "", "", time.Now()))
 }
@@ -0,0 +1,113 @@
+// Package batchjob runs long batch jobs in a way that survives restarts and Lambda timeouts,
+// by periodically saving a checkpoint of the job's progress and resuming from it.
+package batchjob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/aws/s3"
+	"github.com/Invicton-Labs/go-common/conversions"
+	"github.com/Invicton-Labs/go-common/state"
+	"github.com/Invicton-Labs/go-stackerr"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Checkpoint is the saved progress of a job.
+type Checkpoint struct {
+	// The cursor of the page that's being processed (see Source). This is always
+	// empty for jobs over a slice.
+	Cursor string `json:"cursor,omitempty" dynamodbav:"cursor,omitempty"`
+	// The index (within the page) of the next item to process
+	Position int `json:"position" dynamodbav:"position"`
+	// The total number of items that have been processed, across all runs
+	Processed int64 `json:"processed" dynamodbav:"processed"`
+	// When the job was first started
+	Started time.Time `json:"started" dynamodbav:"started"`
+	// When the checkpoint was saved
+	Updated time.Time `json:"updated" dynamodbav:"updated"`
+	// Whether the job has finished. A finished job starts again from the
+	// beginning if it's run again with the same ID.
+	Done bool `json:"done" dynamodbav:"done"`
+}
+
+// CheckpointStore is where checkpoints are saved.
+type CheckpointStore interface {
+	// Load loads the checkpoint for the job. The boolean is false if there isn't one.
+	Load(ctx context.Context, jobId string) (Checkpoint, bool, stackerr.Error)
+	// Save saves the checkpoint for the job, replacing any existing one.
+	Save(ctx context.Context, jobId string, checkpoint Checkpoint) stackerr.Error
+}
+
+type stateCheckpointStore struct {
+	store state.Store[Checkpoint]
+}
+
+// NewStateCheckpointStore creates a checkpoint store that saves checkpoints in a
+// DynamoDB-backed state store (see state.NewStore), keyed by the job ID.
+func NewStateCheckpointStore(store state.Store[Checkpoint]) CheckpointStore {
+	return &stateCheckpointStore{
+		store: store,
+	}
+}
+
+func (scs *stateCheckpointStore) Load(ctx context.Context, jobId string) (Checkpoint, bool, stackerr.Error) {
+	item, found, err := scs.store.Get(ctx, jobId)
+	return item.Value, found, err
+}
+
+func (scs *stateCheckpointStore) Save(ctx context.Context, jobId string, checkpoint Checkpoint) stackerr.Error {
+	_, err := scs.store.Put(ctx, jobId, checkpoint)
+	return err
+}
+
+type s3CheckpointStore struct {
+	arnPrefix string
+}
+
+// NewS3CheckpointStore creates a checkpoint store that saves each job's checkpoint as a
+// JSON object in S3, with the job ID and ".json" appended to the given ARN prefix (e.g.
+// "arn:aws:s3:::bucket/checkpoints/").
+func NewS3CheckpointStore(arnPrefix string) CheckpointStore {
+	return &s3CheckpointStore{
+		arnPrefix: arnPrefix,
+	}
+}
+
+func (scs *s3CheckpointStore) arn(jobId string) string {
+	return scs.arnPrefix + jobId + ".json"
+}
+
+func (scs *s3CheckpointStore) Load(ctx context.Context, jobId string) (Checkpoint, bool, stackerr.Error) {
+	var checkpoint Checkpoint
+	arn := scs.arn(jobId)
+	data, err := s3.GetObject(ctx, arn)
+	if err != nil {
+		var notFound *s3types.NotFound
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			return checkpoint, false, nil
+		}
+		return checkpoint, false, err.WithSingle("s3_arn", arn)
+	}
+	if cerr := json.Unmarshal(data, &checkpoint); cerr != nil {
+		return checkpoint, false, stackerr.Wrap(cerr).WithSingle("s3_arn", arn)
+	}
+	return checkpoint, true, nil
+}
+
+func (scs *s3CheckpointStore) Save(ctx context.Context, jobId string, checkpoint Checkpoint) stackerr.Error {
+	data, cerr := json.Marshal(checkpoint)
+	if cerr != nil {
+		return stackerr.Wrap(cerr)
+	}
+	arn := scs.arn(jobId)
+	if err := s3.PutObject(ctx, arn, data, &s3.PutObjectArgs{
+		ContentType: conversions.GetPtr("application/json"),
+	}); err != nil {
+		return err.WithSingle("s3_arn", arn)
+	}
+	return nil
+}
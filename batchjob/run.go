@@ -0,0 +1,322 @@
+package batchjob
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/aws/lambda"
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/numbers"
+	goslack "github.com/Invicton-Labs/go-common/slack"
+	"github.com/Invicton-Labs/go-common/textutils"
+	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/slack-go/slack"
+)
+
+// Source gets a page of items to process. It's called with an empty cursor for the first
+// page, and with the returned next cursor for each page after that. An empty next cursor
+// means that the page is the last one. The same cursor must always return the same items
+// (in the same order), so that a job can resume part way through a page.
+type Source[T any] func(ctx context.Context, cursor string) (items []T, nextCursor string, err stackerr.Error)
+
+// Progress is the progress of a job, which is reported at each checkpoint.
+type Progress struct {
+	// The ID of the job
+	JobId string
+	// The total number of items that have been processed, across all runs
+	Processed int64
+	// The total number of items, or 0 if it isn't known
+	Total int64
+	// The time since the job was first started, across all runs
+	Elapsed time.Duration
+	// The estimated time until the job is finished, based on the rate of the current
+	// run. It's 0 if the total isn't known or no items have been processed yet.
+	ETA time.Duration
+}
+
+type ReinvokeInput struct {
+	// OPTIONAL. The ARN of the Lambda function to invoke. Defaults to the
+	// function that's running (from the Lambda context).
+	FunctionArn string
+	// The payload to invoke the function with. This is usually the event
+	// that started the job, so that the new invocation resumes it.
+	Payload []byte
+}
+
+type RunInput[T any] struct {
+	// The ID of the job, which its checkpoint is saved under. Running a
+	// job with the same ID resumes it from its checkpoint.
+	JobId string
+	// Where the job's checkpoints are saved.
+	Store CheckpointStore
+	// The function that processes each item. If it returns an error, the job stops
+	// and is checkpointed at that item, so the item is retried when the job resumes.
+	Process func(ctx context.Context, item T) stackerr.Error
+	// OPTIONAL. How many items to process between checkpoints. Defaults to 100.
+	CheckpointEvery int
+	// OPTIONAL. The maximum time between checkpoints. Defaults to 30 seconds.
+	CheckpointInterval time.Duration
+	// OPTIONAL. The total number of items, for progress reporting. For
+	// RunSlice, it's always the length of the slice.
+	Total int64
+	// OPTIONAL. A function to call with the job's progress at each checkpoint.
+	OnProgress func(ctx context.Context, progress Progress)
+	// OPTIONAL. A Slack client to report the job's progress to the status
+	// message with, at each checkpoint.
+	StatusClient *goslack.Client
+	// OPTIONAL. If the context has a deadline (e.g. in Lambda), the job stops (and is
+	// checkpointed) once the deadline is this close. Defaults to 30 seconds.
+	DeadlineMargin time.Duration
+	// OPTIONAL. If provided, a Lambda function is invoked asynchronously to resume the
+	// job when it stops because of the deadline.
+	Reinvoke *ReinvokeInput
+	// OPTIONAL. The clock to use. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+}
+
+// Result is the outcome of running a job.
+type Result struct {
+	// The total number of items that have been processed, across all runs
+	Processed int64
+	// Whether all items have been processed
+	Done bool
+	// Whether the job stopped early because the context's deadline was approaching
+	Stopped bool
+	// Whether a Lambda function was invoked to resume the job (see RunInput.Reinvoke)
+	Reinvoked bool
+}
+
+// job is the state of a single run of a job.
+type job[T any] struct {
+	input          RunInput[T]
+	clock          dateutils.Clock
+	checkpoint     Checkpoint
+	runStart       time.Time
+	runProcessed   int64
+	lastCheckpoint time.Time
+	// The number of items processed since the last checkpoint
+	sinceCheckpoint int
+}
+
+// RunSlice runs a job over the items of a slice. See Run for details.
+func RunSlice[T any](ctx context.Context, items []T, input RunInput[T]) (Result, stackerr.Error) {
+	input.Total = int64(len(items))
+	return Run(ctx, func(ctx context.Context, cursor string) ([]T, string, stackerr.Error) {
+		return items, "", nil
+	}, input)
+}
+
+// Run runs a job over the items from the source, processing them one at a time, in order.
+// It resumes from the job's checkpoint (if there is one), and saves a new checkpoint
+// periodically, when it stops early, and when it's done.
+//
+// If the context has a deadline, the job stops once the deadline is approaching (see
+// RunInput.DeadlineMargin), and optionally invokes a Lambda function to resume it.
+func Run[T any](ctx context.Context, source Source[T], input RunInput[T]) (Result, stackerr.Error) {
+	if input.JobId == "" {
+		return Result{}, stackerr.Errorf("the `input.JobId` field must not be empty")
+	}
+	if input.Store == nil {
+		return Result{}, stackerr.Errorf("the `input.Store` field must not be nil")
+	}
+	if input.Process == nil {
+		return Result{}, stackerr.Errorf("the `input.Process` field must not be nil")
+	}
+	if input.CheckpointEvery <= 0 {
+		input.CheckpointEvery = 100
+	}
+	if input.CheckpointInterval <= 0 {
+		input.CheckpointInterval = 30 * time.Second
+	}
+	if input.DeadlineMargin <= 0 {
+		input.DeadlineMargin = 30 * time.Second
+	}
+
+	j := &job[T]{
+		input: input,
+		clock: dateutils.ClockOrDefault(input.Clock),
+	}
+	j.runStart = j.clock.Now()
+	j.lastCheckpoint = j.runStart
+
+	checkpoint, found, err := input.Store.Load(ctx, input.JobId)
+	if err != nil {
+		return Result{}, err.WithSingle("job_id", input.JobId)
+	}
+	if found && !checkpoint.Done {
+		j.checkpoint = checkpoint
+		log.FromContext(ctx).Infow("Resuming batch job from checkpoint",
+			"job_id", input.JobId,
+			"job_processed", checkpoint.Processed,
+		)
+	} else {
+		j.checkpoint = Checkpoint{
+			Started: j.runStart,
+		}
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+
+	for {
+		items, nextCursor, err := source(ctx, j.checkpoint.Cursor)
+		if err != nil {
+			return j.result(), err.With(map[string]any{
+				"job_id":     input.JobId,
+				"job_cursor": j.checkpoint.Cursor,
+			})
+		}
+
+		for j.checkpoint.Position < len(items) {
+			if hasDeadline && j.clock.Until(deadline) <= input.DeadlineMargin {
+				return j.stop(ctx)
+			}
+			if ctx.Err() != nil {
+				return j.result(), j.saveAfterError(ctx, stackerr.Wrap(ctx.Err()))
+			}
+
+			if err := input.Process(ctx, items[j.checkpoint.Position]); err != nil {
+				return j.result(), j.saveAfterError(ctx, err.With(map[string]any{
+					"job_id":       input.JobId,
+					"job_cursor":   j.checkpoint.Cursor,
+					"job_position": j.checkpoint.Position,
+				}))
+			}
+			j.checkpoint.Position++
+			j.checkpoint.Processed++
+			j.runProcessed++
+			j.sinceCheckpoint++
+
+			if j.sinceCheckpoint >= input.CheckpointEvery || j.clock.Since(j.lastCheckpoint) >= input.CheckpointInterval {
+				if err := j.save(ctx); err != nil {
+					return j.result(), err
+				}
+			}
+		}
+
+		if nextCursor == "" {
+			j.checkpoint.Done = true
+			if err := j.save(ctx); err != nil {
+				return j.result(), err
+			}
+			return j.result(), nil
+		}
+		j.checkpoint.Cursor = nextCursor
+		j.checkpoint.Position = 0
+	}
+}
+
+func (j *job[T]) result() Result {
+	return Result{
+		Processed: j.checkpoint.Processed,
+		Done:      j.checkpoint.Done,
+	}
+}
+
+// save saves the checkpoint and reports the progress.
+func (j *job[T]) save(ctx context.Context) stackerr.Error {
+	now := j.clock.Now()
+	j.checkpoint.Updated = now
+	if err := j.input.Store.Save(ctx, j.input.JobId, j.checkpoint); err != nil {
+		return err.WithSingle("job_id", j.input.JobId)
+	}
+	j.lastCheckpoint = now
+	j.sinceCheckpoint = 0
+	j.reportProgress(ctx)
+	return nil
+}
+
+// saveAfterError saves the checkpoint after the job failed, so that it resumes from the
+// failed item, and returns the original error. A failure to save is only logged, since
+// the original error is more important.
+func (j *job[T]) saveAfterError(ctx context.Context, err stackerr.Error) stackerr.Error {
+	saveCtx := ctx
+	if ctx.Err() != nil {
+		// The context is done, but the checkpoint should still be saved
+		var cancel context.CancelFunc
+		saveCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+	}
+	if saveErr := j.save(saveCtx); saveErr != nil {
+		log.FromContext(ctx).Error(saveErr)
+	}
+	return err
+}
+
+// stop saves the checkpoint when the deadline is approaching, and then
+// invokes the function to resume the job (if configured).
+func (j *job[T]) stop(ctx context.Context) (Result, stackerr.Error) {
+	logger := log.FromContext(ctx)
+	logger.Infow("Stopping batch job before the deadline",
+		"job_id", j.input.JobId,
+		"job_processed", j.checkpoint.Processed,
+	)
+	if err := j.save(ctx); err != nil {
+		return j.result(), err
+	}
+	result := j.result()
+	result.Stopped = true
+	if j.input.Reinvoke == nil {
+		return result, nil
+	}
+
+	functionArn := j.input.Reinvoke.FunctionArn
+	if functionArn == "" {
+		meta, err := lambda.MetaFromContext(ctx)
+		if err != nil {
+			return result, err.WithSingle("job_id", j.input.JobId)
+		}
+		functionArn = meta.LambdaArn
+	}
+	if err := lambda.InvokeAsync(ctx, functionArn, j.input.Reinvoke.Payload); err != nil {
+		return result, err.WithSingle("job_id", j.input.JobId)
+	}
+	logger.Infow("Invoked Lambda function to resume batch job", "job_id", j.input.JobId, "function_arn", functionArn)
+	result.Reinvoked = true
+	return result, nil
+}
+
+// progress gets the current progress of the job.
+func (j *job[T]) progress() Progress {
+	progress := Progress{
+		JobId:     j.input.JobId,
+		Processed: j.checkpoint.Processed,
+		Total:     j.input.Total,
+		Elapsed:   j.clock.Since(j.checkpoint.Started),
+	}
+	if progress.Total > 0 {
+		remaining := progress.Total - progress.Processed
+		if eta, ok := dateutils.EstimateETA(j.runProcessed, j.runProcessed+remaining, j.clock.Since(j.runStart)); ok {
+			progress.ETA = eta
+		}
+	}
+	return progress
+}
+
+// reportProgress reports the job's progress to the callback and the Slack status
+// message. Failing to update the status message is logged, but doesn't fail the job.
+func (j *job[T]) reportProgress(ctx context.Context) {
+	progress := j.progress()
+	if j.input.OnProgress != nil {
+		j.input.OnProgress(ctx, progress)
+	}
+	if j.input.StatusClient == nil {
+		return
+	}
+	text := fmt.Sprintf("*Batch job:* %s\n*Processed:* %s", textutils.SlackEscape(progress.JobId), numbers.FormatCount(progress.Processed))
+	if progress.Total > 0 {
+		text += fmt.Sprintf(" of %s (%.1f%%)", numbers.FormatCount(progress.Total), 100*float64(progress.Processed)/float64(progress.Total))
+	}
+	text += "\n*Elapsed:* " + dateutils.FormatDuration(progress.Elapsed)
+	if j.checkpoint.Done {
+		text += "\n*Status:* Done"
+	} else if progress.ETA > 0 {
+		text += "\n*ETA:* " + dateutils.FormatDuration(progress.ETA)
+	}
+	if err := j.input.StatusClient.UpdateStatusMessage(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	); err != nil {
+		log.FromContext(ctx).Error(err.WithSingle("job_id", j.input.JobId))
+	}
+}
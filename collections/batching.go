@@ -1,6 +1,7 @@
 package collections
 
 import (
+	"github.com/Invicton-Labs/go-common/constraints"
 	"github.com/Invicton-Labs/go-common/numbers"
 )
 
@@ -51,3 +52,76 @@ func BatchesIntoChanWithRemainder[T any](values []T, batchSize int, channel chan
 	// Never reached, but the compiler doesn't know that
 	return nil
 }
+
+// BatchesByWeight splits values into batches, in order, where the total weight of each batch
+// (the sum of the weightFunc results for its values) is at most maxWeight. A value that's heavier
+// than maxWeight by itself is put in a batch on its own.
+func BatchesByWeight[T any, W constraints.Simple](values []T, weightFunc func(value T) (weight W), maxWeight W) (batches [][]T) {
+	batches = [][]T{}
+	start := 0
+	var batchWeight W
+	for i, v := range values {
+		weight := weightFunc(v)
+		if i > start && batchWeight+weight > maxWeight {
+			batches = append(batches, values[start:i])
+			start = i
+			batchWeight = 0
+		}
+		batchWeight += weight
+	}
+	if start < len(values) {
+		batches = append(batches, values[start:])
+	}
+	return batches
+}
+
+// Windows returns the windows of a slice with the given size, where each window starts
+// `step` elements after the previous one (so they overlap if step is less than size).
+// Only full windows are returned. The windows share the backing array of the input
+// slice. The size and step must be at least 1.
+func Windows[T any](values []T, size int, step int) (windows [][]T) {
+	if size < 1 || step < 1 {
+		panic("window size and step must be at least 1")
+	}
+	windows = [][]T{}
+	for i := 0; i+size <= len(values); i += step {
+		windows = append(windows, values[i:i+size:i+size])
+	}
+	return windows
+}
+
+// Page is a page of values from Paginate.
+type Page[T any] struct {
+	// The values on the page, which share the backing array of the input slice
+	Items []T
+	// The page number, starting at 1
+	Page       int
+	PageSize   int
+	TotalItems int
+	TotalPages int
+	HasPrev    bool
+	HasNext    bool
+}
+
+// Paginate gets a page of values from a slice, where page numbers start at 1. If the
+// page is out of range, it has no items. The page size must be at least 1.
+func Paginate[T any](values []T, page int, pageSize int) Page[T] {
+	if pageSize < 1 {
+		panic("page size must be at least 1")
+	}
+	totalPages := (len(values) + pageSize - 1) / pageSize
+	p := Page[T]{
+		Items:      []T{},
+		Page:       page,
+		PageSize:   pageSize,
+		TotalItems: len(values),
+		TotalPages: totalPages,
+		HasPrev:    page > 1,
+		HasNext:    page < totalPages,
+	}
+	if page >= 1 && page <= totalPages {
+		start := (page - 1) * pageSize
+		p.Items = values[start:numbers.Min(len(values), start+pageSize)]
+	}
+	return p
+}
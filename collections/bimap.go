@@ -0,0 +1,124 @@
+package collections
+
+import (
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// BiMapCollision is what a BiMap does when a pair is set, and
+// its key or value is already in another pair.
+type BiMapCollision int
+
+const (
+	// Remove the existing pairs that have the key or the value, and set the new pair
+	BiMapCollisionOverwrite BiMapCollision = iota
+	// Leave the map unchanged, and return an error
+	BiMapCollisionError
+)
+
+// BiMap is a bidirectional map, where each key maps to one value and each value maps
+// back to one key, so that pairs can be looked up by either.
+type BiMap[K comparable, V comparable] interface {
+	// Set sets a key/value pair. If the key or value is already in another pair, the
+	// collision is handled according to the map's collision policy.
+	Set(key K, value V) stackerr.Error
+	// GetByKey gets the value for a key, and a bool of whether the key exists.
+	GetByKey(key K) (V, bool)
+	// GetByValue gets the key for a value, and a bool of whether the value exists.
+	GetByValue(value V) (K, bool)
+	// DeleteByKey will delete the pair with a key if it exists, and returns a bool of
+	// whether it existed and was deleted.
+	DeleteByKey(key K) bool
+	// DeleteByValue will delete the pair with a value if it exists, and returns a bool
+	// of whether it existed and was deleted.
+	DeleteByValue(value V) bool
+	// Len returns the number of pairs.
+	Len() int
+	// ToMap returns a map of each key to its value.
+	ToMap() map[K]V
+}
+
+type NewBiMapInput struct {
+	// OPTIONAL. What to do when a pair is set, and its key or value is already
+	// in another pair. Defaults to BiMapCollisionOverwrite.
+	OnCollision BiMapCollision
+}
+
+type biMap[K comparable, V comparable] struct {
+	byKey       map[K]V
+	byValue     map[V]K
+	onCollision BiMapCollision
+}
+
+func NewBiMap[K comparable, V comparable](input NewBiMapInput) BiMap[K, V] {
+	return &biMap[K, V]{
+		byKey:       map[K]V{},
+		byValue:     map[V]K{},
+		onCollision: input.OnCollision,
+	}
+}
+
+func (m *biMap[K, V]) Set(key K, value V) stackerr.Error {
+	existingValue, keyExists := m.byKey[key]
+	existingKey, valueExists := m.byValue[value]
+	if keyExists && valueExists && existingValue == value {
+		// The pair already exists
+		return nil
+	}
+	if m.onCollision == BiMapCollisionError {
+		if keyExists {
+			return stackerr.Errorf("key %v is already mapped to value %v", key, existingValue)
+		}
+		if valueExists {
+			return stackerr.Errorf("value %v is already mapped to key %v", value, existingKey)
+		}
+	}
+	if keyExists {
+		delete(m.byValue, existingValue)
+	}
+	if valueExists {
+		delete(m.byKey, existingKey)
+	}
+	m.byKey[key] = value
+	m.byValue[value] = key
+	return nil
+}
+
+func (m *biMap[K, V]) GetByKey(key K) (V, bool) {
+	value, ok := m.byKey[key]
+	return value, ok
+}
+
+func (m *biMap[K, V]) GetByValue(value V) (K, bool) {
+	key, ok := m.byValue[value]
+	return key, ok
+}
+
+func (m *biMap[K, V]) DeleteByKey(key K) bool {
+	value, ok := m.byKey[key]
+	if ok {
+		delete(m.byKey, key)
+		delete(m.byValue, value)
+	}
+	return ok
+}
+
+func (m *biMap[K, V]) DeleteByValue(value V) bool {
+	key, ok := m.byValue[value]
+	if ok {
+		delete(m.byKey, key)
+		delete(m.byValue, value)
+	}
+	return ok
+}
+
+func (m *biMap[K, V]) Len() int {
+	return len(m.byKey)
+}
+
+func (m *biMap[K, V]) ToMap() map[K]V {
+	out := make(map[K]V, len(m.byKey))
+	for k, v := range m.byKey {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package collections
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// filterKeyBytes converts a value to the bytes that are hashed for a membership filter. The
+// bytes must be the same in every process, so that serialized filters can be shared.
+func filterKeyBytes[T any](value T) []byte {
+	switch v := any(value).(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	if data, err := json.Marshal(value); err == nil {
+		return data
+	}
+	return []byte(fmt.Sprintf("%#v", value))
+}
+
+// filterHash hashes the bytes of a value into two 64-bit hashes, for double hashing.
+func filterHash(data []byte) (h1 uint64, h2 uint64) {
+	h := fnv.New128a()
+	h.Write(data)
+	sum := h.Sum(nil)
+	return mixHash(binary.BigEndian.Uint64(sum[:8])), mixHash(binary.BigEndian.Uint64(sum[8:]))
+}
+
+// mixHash is the splitmix64 finalizer, which spreads the bits of FNV hashes of short
+// inputs (which are poorly distributed in the high bits) across the whole hash.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+type BloomFilterConfig[T any] struct {
+	// The number of items that the filter is sized for. Must be at least 1.
+	ExpectedItems uint
+	// The chance of Contains returning true for an item that wasn't added, when the filter
+	// has the expected number of items. Must be greater than 0 and less than 1.
+	FalsePositiveRate float64
+	// OPTIONAL. A function that converts an item to the bytes that are hashed. It must return
+	// the same bytes for equal items, in every process that shares the filter. If not provided,
+	// strings and byte slices are used as-is, and other types are marshaled to JSON.
+	KeyFunc func(item T) []byte
+}
+
+// BloomFilter is a probabilistic set, which can have false positives (Contains may return
+// true for items that weren't added) but no false negatives. It uses far less memory than
+// a regular set, but items can't be removed.
+type BloomFilter[T any] interface {
+	// Add adds an item.
+	Add(item T)
+	// Contains returns false if the item definitely wasn't added, and true if it probably was.
+	Contains(item T) bool
+	// Merge adds all items of another filter to this one. The filters must have been
+	// created with the same configuration.
+	Merge(other BloomFilter[T]) stackerr.Error
+	// MarshalBinary serializes the filter to bytes, which can be loaded with NewBloomFilterFromBytes.
+	MarshalBinary() ([]byte, error)
+}
+
+const bloomFilterMagic = "BF1"
+
+type bloomFilter[T any] struct {
+	bits      []uint64
+	numBits   uint64
+	numHashes uint32
+	keyFunc   func(item T) []byte
+}
+
+func NewBloomFilter[T any](config BloomFilterConfig[T]) (BloomFilter[T], stackerr.Error) {
+	if config.ExpectedItems < 1 {
+		return nil, stackerr.Errorf("the `config.ExpectedItems` field must be at least 1")
+	}
+	if config.FalsePositiveRate <= 0 || config.FalsePositiveRate >= 1 {
+		return nil, stackerr.Errorf("the `config.FalsePositiveRate` field must be greater than 0 and less than 1, got %v", config.FalsePositiveRate)
+	}
+	n := float64(config.ExpectedItems)
+	// The optimal number of bits and hashes for the expected items and false positive rate
+	numBits := uint64(math.Ceil(-n * math.Log(config.FalsePositiveRate) / (math.Ln2 * math.Ln2)))
+	numHashes := uint32(math.Max(1, math.Round(float64(numBits)/n*math.Ln2)))
+	return newBloomFilter(numBits, numHashes, config.KeyFunc), nil
+}
+
+func newBloomFilter[T any](numBits uint64, numHashes uint32, keyFunc func(item T) []byte) *bloomFilter[T] {
+	if keyFunc == nil {
+		keyFunc = filterKeyBytes[T]
+	}
+	return &bloomFilter[T]{
+		bits:      make([]uint64, (numBits+63)/64),
+		numBits:   numBits,
+		numHashes: numHashes,
+		keyFunc:   keyFunc,
+	}
+}
+
+// NewBloomFilterFromBytes loads a filter that was serialized with MarshalBinary. The
+// key function must be the same as the one that the filter was created with.
+func NewBloomFilterFromBytes[T any](data []byte, keyFunc func(item T) []byte) (BloomFilter[T], stackerr.Error) {
+	header := len(bloomFilterMagic) + 12
+	if len(data) < header || string(data[:len(bloomFilterMagic)]) != bloomFilterMagic {
+		return nil, stackerr.Errorf("the data is not a serialized bloom filter")
+	}
+	numBits := binary.BigEndian.Uint64(data[len(bloomFilterMagic):])
+	numHashes := binary.BigEndian.Uint32(data[len(bloomFilterMagic)+8:])
+	bf := newBloomFilter(numBits, numHashes, keyFunc)
+	if uint64(len(data)-header) != uint64(len(bf.bits))*8 {
+		return nil, stackerr.Errorf("the serialized bloom filter has %d bytes of bits, expected %d", len(data)-header, len(bf.bits)*8)
+	}
+	for i := range bf.bits {
+		bf.bits[i] = binary.BigEndian.Uint64(data[header+i*8:])
+	}
+	return bf, nil
+}
+
+// indexes calls a function with the index of each bit for an item.
+func (bf *bloomFilter[T]) indexes(item T, f func(index uint64)) {
+	h1, h2 := filterHash(bf.keyFunc(item))
+	for i := uint64(0); i < uint64(bf.numHashes); i++ {
+		f((h1 + i*h2) % bf.numBits)
+	}
+}
+
+func (bf *bloomFilter[T]) Add(item T) {
+	bf.indexes(item, func(index uint64) {
+		bf.bits[index/64] |= 1 << (index % 64)
+	})
+}
+
+func (bf *bloomFilter[T]) Contains(item T) bool {
+	contains := true
+	bf.indexes(item, func(index uint64) {
+		if bf.bits[index/64]&(1<<(index%64)) == 0 {
+			contains = false
+		}
+	})
+	return contains
+}
+
+func (bf *bloomFilter[T]) Merge(other BloomFilter[T]) stackerr.Error {
+	o, ok := other.(*bloomFilter[T])
+	if !ok {
+		return stackerr.Errorf("cannot merge a bloom filter of type %T", other)
+	}
+	if o.numBits != bf.numBits || o.numHashes != bf.numHashes {
+		return stackerr.Errorf("cannot merge bloom filters with different configurations (%d bits and %d hashes, vs. %d bits and %d hashes)", bf.numBits, bf.numHashes, o.numBits, o.numHashes)
+	}
+	for i := range bf.bits {
+		bf.bits[i] |= o.bits[i]
+	}
+	return nil
+}
+
+func (bf *bloomFilter[T]) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, len(bloomFilterMagic)+12+len(bf.bits)*8)
+	data = append(data, bloomFilterMagic...)
+	data = binary.BigEndian.AppendUint64(data, bf.numBits)
+	data = binary.BigEndian.AppendUint32(data, bf.numHashes)
+	for _, word := range bf.bits {
+		data = binary.BigEndian.AppendUint64(data, word)
+	}
+	return data, nil
+}
@@ -0,0 +1,413 @@
+package collections
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type EvictionReason string
+
+const (
+	// The entry was evicted to make room for a new entry
+	EvictionReasonCapacity EvictionReason = "capacity"
+	// The entry's TTL passed
+	EvictionReasonExpired EvictionReason = "expired"
+)
+
+// CacheStats are the statistics of a cache, since it was created.
+type CacheStats struct {
+	// The number of times Get found a value
+	Hits uint64
+	// The number of times Get didn't find a value (including expired values)
+	Misses uint64
+	// The number of entries that were evicted to make room for new entries
+	Evictions uint64
+	// The number of entries that were removed because their TTL passed
+	Expirations uint64
+}
+
+// Cache is a fixed-capacity cache, which evicts entries according to its policy when it's
+// full. It's safe for concurrent use.
+type Cache[K comparable, V any] interface {
+	// Get gets the value for a key, and a bool of whether it was found. It counts as
+	// a use of the entry for the eviction policy.
+	Get(key K) (V, bool)
+	// Peek is the same as Get, except that it doesn't count as a use of the entry
+	// and doesn't affect the hit/miss statistics.
+	Peek(key K) (V, bool)
+	// Set sets the value for a key, with the cache's default TTL. If the cache is full,
+	// an entry is evicted to make room for it.
+	Set(key K, value V)
+	// SetWithTTL is the same as Set, except that the entry expires after the given TTL
+	// instead of the default one. A TTL of 0 or less means that it doesn't expire.
+	SetWithTTL(key K, value V, ttl time.Duration)
+	// Remove will remove the entry for a key if it exists, and returns a bool of whether
+	// it existed and was removed. The eviction callback isn't called for removed entries.
+	Remove(key K) bool
+	// Len returns the number of entries, which may include expired entries that haven't
+	// been removed yet (see RemoveExpired).
+	Len() int
+	// RemoveExpired removes all entries that have expired, and returns the number that were
+	// removed. Otherwise, expired entries are only removed when they're accessed or evicted.
+	RemoveExpired() int
+	// Purge removes all entries. The eviction callback isn't called for them.
+	Purge()
+	// Stats gets the statistics of the cache.
+	Stats() CacheStats
+}
+
+type NewCacheInput[K comparable, V any] struct {
+	// The maximum number of entries in the cache. Must be at least 1.
+	Capacity int
+	// OPTIONAL. How long entries are kept for after they're set. If not provided,
+	// entries don't expire.
+	TTL time.Duration
+	// OPTIONAL. A function to call with each entry that's evicted from the cache (because
+	// it's full or the entry expired). It's called after the cache is unlocked, so it
+	// may use the cache.
+	OnEvict func(key K, value V, reason EvictionReason)
+	// OPTIONAL. A function that gets the current time, for entry expiry. If not
+	// provided, time.Now is used.
+	Now func() time.Time
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+	// The number of times the entry has been used, for the LFU policy
+	frequency int
+	// The list that the entry is in, and its neighbours in that list
+	list       *cacheList[K, V]
+	prev, next *cacheEntry[K, V]
+}
+
+// cacheList is a doubly linked list of cache entries, with the most
+// recently used at the front.
+type cacheList[K comparable, V any] struct {
+	front, back *cacheEntry[K, V]
+	len         int
+}
+
+func (l *cacheList[K, V]) pushFront(e *cacheEntry[K, V]) {
+	e.list = l
+	e.prev = nil
+	e.next = l.front
+	if l.front == nil {
+		l.back = e
+	} else {
+		l.front.prev = e
+	}
+	l.front = e
+	l.len++
+}
+
+func (l *cacheList[K, V]) remove(e *cacheEntry[K, V]) {
+	if e.prev == nil {
+		l.front = e.next
+	} else {
+		e.prev.next = e.next
+	}
+	if e.next == nil {
+		l.back = e.prev
+	} else {
+		e.next.prev = e.prev
+	}
+	e.list, e.prev, e.next = nil, nil, nil
+	l.len--
+}
+
+// cachePolicy decides which entry of a cache to evict.
+type cachePolicy[K comparable, V any] interface {
+	// added is called when an entry is added to the cache.
+	added(e *cacheEntry[K, V])
+	// used is called when an entry is used.
+	used(e *cacheEntry[K, V])
+	// removed is called when an entry is removed from the cache.
+	removed(e *cacheEntry[K, V])
+	// victim gets the entry to evict next.
+	victim() *cacheEntry[K, V]
+	// reset removes all entries.
+	reset()
+}
+
+type cacheEviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
+type cache[K comparable, V any] struct {
+	lock    sync.Mutex
+	input   NewCacheInput[K, V]
+	entries map[K]*cacheEntry[K, V]
+	policy  cachePolicy[K, V]
+	stats   CacheStats
+}
+
+func newCache[K comparable, V any](input NewCacheInput[K, V], policy cachePolicy[K, V]) (*cache[K, V], stackerr.Error) {
+	if input.Capacity < 1 {
+		return nil, stackerr.Errorf("the `input.Capacity` field must be at least 1, got %d", input.Capacity)
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	return &cache[K, V]{
+		input:   input,
+		entries: make(map[K]*cacheEntry[K, V], input.Capacity),
+		policy:  policy,
+	}, nil
+}
+
+// NewLRUCache creates a cache that evicts the least recently used entry when it's full.
+func NewLRUCache[K comparable, V any](input NewCacheInput[K, V]) (Cache[K, V], stackerr.Error) {
+	return newCache[K, V](input, &lruPolicy[K, V]{})
+}
+
+// NewLFUCache creates a cache that evicts the least frequently used entry when it's full,
+// with ties broken by evicting the least recently used of them.
+func NewLFUCache[K comparable, V any](input NewCacheInput[K, V]) (Cache[K, V], stackerr.Error) {
+	return newCache[K, V](input, &lfuPolicy[K, V]{
+		frequencies: map[int]*cacheList[K, V]{},
+	})
+}
+
+// notify calls the eviction callback for the evicted entries. The lock must not be held.
+func (c *cache[K, V]) notify(evictions []cacheEviction[K, V]) {
+	if c.input.OnEvict == nil {
+		return
+	}
+	for _, eviction := range evictions {
+		c.input.OnEvict(eviction.key, eviction.value, eviction.reason)
+	}
+}
+
+// remove removes an entry. The lock must be held.
+func (c *cache[K, V]) remove(e *cacheEntry[K, V]) {
+	c.policy.removed(e)
+	delete(c.entries, e.key)
+}
+
+// expired checks whether an entry has expired. The lock must be held.
+func (c *cache[K, V]) expired(e *cacheEntry[K, V], now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// get gets the entry for a key, removing it if it has expired. The lock must be held.
+func (c *cache[K, V]) get(key K) (*cacheEntry[K, V], []cacheEviction[K, V]) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if c.expired(e, c.input.Now()) {
+		c.remove(e)
+		c.stats.Expirations++
+		return nil, []cacheEviction[K, V]{{key: e.key, value: e.value, reason: EvictionReasonExpired}}
+	}
+	return e, nil
+}
+
+func (c *cache[K, V]) Get(key K) (V, bool) {
+	c.lock.Lock()
+	e, evictions := c.get(key)
+	var value V
+	if e == nil {
+		c.stats.Misses++
+	} else {
+		c.stats.Hits++
+		c.policy.used(e)
+		value = e.value
+	}
+	c.lock.Unlock()
+	c.notify(evictions)
+	return value, e != nil
+}
+
+func (c *cache[K, V]) Peek(key K) (V, bool) {
+	c.lock.Lock()
+	e, evictions := c.get(key)
+	var value V
+	if e != nil {
+		value = e.value
+	}
+	c.lock.Unlock()
+	c.notify(evictions)
+	return value, e != nil
+}
+
+func (c *cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.input.TTL)
+}
+
+func (c *cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.lock.Lock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.input.Now().Add(ttl)
+	}
+	evictions := []cacheEviction[K, V]{}
+	if e, ok := c.entries[key]; ok {
+		e.value = value
+		e.expires = expires
+		c.policy.used(e)
+	} else {
+		if len(c.entries) >= c.input.Capacity {
+			victim := c.policy.victim()
+			c.remove(victim)
+			reason := EvictionReasonCapacity
+			if c.expired(victim, c.input.Now()) {
+				reason = EvictionReasonExpired
+				c.stats.Expirations++
+			} else {
+				c.stats.Evictions++
+			}
+			evictions = append(evictions, cacheEviction[K, V]{key: victim.key, value: victim.value, reason: reason})
+		}
+		e := &cacheEntry[K, V]{
+			key:     key,
+			value:   value,
+			expires: expires,
+		}
+		c.entries[key] = e
+		c.policy.added(e)
+	}
+	c.lock.Unlock()
+	c.notify(evictions)
+}
+
+func (c *cache[K, V]) Remove(key K) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if ok {
+		c.remove(e)
+	}
+	return ok
+}
+
+func (c *cache[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
+
+func (c *cache[K, V]) RemoveExpired() int {
+	c.lock.Lock()
+	now := c.input.Now()
+	evictions := []cacheEviction[K, V]{}
+	for _, e := range c.entries {
+		if c.expired(e, now) {
+			c.remove(e)
+			c.stats.Expirations++
+			evictions = append(evictions, cacheEviction[K, V]{key: e.key, value: e.value, reason: EvictionReasonExpired})
+		}
+	}
+	c.lock.Unlock()
+	c.notify(evictions)
+	return len(evictions)
+}
+
+func (c *cache[K, V]) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[K]*cacheEntry[K, V], c.input.Capacity)
+	c.policy.reset()
+}
+
+func (c *cache[K, V]) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
+
+// lruPolicy keeps entries in order of use, and evicts the least recently used.
+type lruPolicy[K comparable, V any] struct {
+	list cacheList[K, V]
+}
+
+func (p *lruPolicy[K, V]) added(e *cacheEntry[K, V]) {
+	p.list.pushFront(e)
+}
+
+func (p *lruPolicy[K, V]) used(e *cacheEntry[K, V]) {
+	p.list.remove(e)
+	p.list.pushFront(e)
+}
+
+func (p *lruPolicy[K, V]) removed(e *cacheEntry[K, V]) {
+	p.list.remove(e)
+}
+
+func (p *lruPolicy[K, V]) victim() *cacheEntry[K, V] {
+	return p.list.back
+}
+
+func (p *lruPolicy[K, V]) reset() {
+	p.list = cacheList[K, V]{}
+}
+
+// lfuPolicy keeps a list of entries (in order of use) for each use frequency, and
+// evicts the least recently used entry with the lowest frequency. All operations are O(1).
+type lfuPolicy[K comparable, V any] struct {
+	frequencies  map[int]*cacheList[K, V]
+	minFrequency int
+}
+
+// insert adds an entry to the list for its frequency.
+func (p *lfuPolicy[K, V]) insert(e *cacheEntry[K, V]) {
+	list, ok := p.frequencies[e.frequency]
+	if !ok {
+		list = &cacheList[K, V]{}
+		p.frequencies[e.frequency] = list
+	}
+	list.pushFront(e)
+}
+
+// detach removes an entry from the list for its frequency.
+func (p *lfuPolicy[K, V]) detach(e *cacheEntry[K, V]) {
+	list := e.list
+	list.remove(e)
+	if list.len == 0 {
+		delete(p.frequencies, e.frequency)
+	}
+}
+
+func (p *lfuPolicy[K, V]) added(e *cacheEntry[K, V]) {
+	e.frequency = 1
+	p.minFrequency = 1
+	p.insert(e)
+}
+
+func (p *lfuPolicy[K, V]) used(e *cacheEntry[K, V]) {
+	p.detach(e)
+	if e.frequency == p.minFrequency && p.frequencies[e.frequency] == nil {
+		p.minFrequency++
+	}
+	e.frequency++
+	p.insert(e)
+}
+
+func (p *lfuPolicy[K, V]) removed(e *cacheEntry[K, V]) {
+	p.detach(e)
+	if e.frequency == p.minFrequency && p.frequencies[e.frequency] == nil {
+		// Find the new lowest frequency. This is only needed when an entry is removed other
+		// than by eviction (which is always followed by adding an entry with frequency 1).
+		p.minFrequency = 0
+		for frequency := range p.frequencies {
+			if p.minFrequency == 0 || frequency < p.minFrequency {
+				p.minFrequency = frequency
+			}
+		}
+	}
+}
+
+func (p *lfuPolicy[K, V]) victim() *cacheEntry[K, V] {
+	return p.frequencies[p.minFrequency].back
+}
+
+func (p *lfuPolicy[K, V]) reset() {
+	p.frequencies = map[int]*cacheList[K, V]{}
+	p.minFrequency = 0
+}
@@ -0,0 +1,96 @@
+package collections
+
+import (
+	"context"
+
+	"github.com/Invicton-Labs/go-stackerr"
+	"golang.org/x/sync/errgroup"
+)
+
+// runConcurrent calls a function with each index from 0 to n-1, with at most `workers` calls
+// running at once (no limit if workers is 0 or less). It stops starting new calls once one
+// returns an error or the context is done, and returns the first error. Panics in the
+// function are recovered and returned as errors.
+func runConcurrent(ctx context.Context, n int, workers int, f func(ctx context.Context, i int) stackerr.Error) stackerr.Error {
+	group, groupCtx := errgroup.WithContext(ctx)
+	if workers > 0 {
+		group.SetLimit(workers)
+	}
+	for i := 0; i < n && groupCtx.Err() == nil; i++ {
+		i := i
+		group.Go(func() (err error) {
+			// The context may have been cancelled while this call was waiting for a worker
+			if groupCtx.Err() != nil {
+				return groupCtx.Err()
+			}
+			defer func() {
+				if r := recover(); r != nil {
+					err = stackerr.FromRecover(r)
+				}
+			}()
+			if err := f(groupCtx, i); err != nil {
+				return err
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		if serr, ok := err.(stackerr.Error); ok {
+			return serr
+		}
+		return stackerr.Wrap(err)
+	}
+	// The loop may have stopped early because the parent context was done
+	if ctx.Err() != nil {
+		return stackerr.Wrap(ctx.Err())
+	}
+	return nil
+}
+
+// TransformSliceConcurrent maps an input slice to an output slice using a transformation function,
+// which is run concurrently by up to `workers` goroutines (no limit if workers is 0 or less). The
+// output is in the same order as the input. If the transformation function returns an error (or
+// panics), or the context is done, the context given to the other calls is cancelled, no new calls
+// are started, and the first error is returned.
+func TransformSliceConcurrent[In any, Out any](ctx context.Context, in []In, workers int, transformationFunc func(ctx context.Context, value In) (transformed Out, err stackerr.Error)) (out []Out, err stackerr.Error) {
+	if in == nil {
+		return nil, nil
+	}
+	out = make([]Out, len(in))
+	err = runConcurrent(ctx, len(in), workers, func(ctx context.Context, i int) stackerr.Error {
+		transformed, err := transformationFunc(ctx, in[i])
+		if err != nil {
+			return err
+		}
+		out[i] = transformed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TransformMapConcurrent maps an input map to an output map using a transformation function, which
+// is run concurrently by up to `workers` goroutines (no limit if workers is 0 or less). If the
+// transformation function returns an error (or panics), or the context is done, the context given
+// to the other calls is cancelled, no new calls are started, and the first error is returned.
+// If multiple entries are transformed to the same key, which value is kept is undefined.
+func TransformMapConcurrent[InKey comparable, InValue any, OutKey comparable, OutValue any](ctx context.Context, in map[InKey]InValue, workers int, transformationFunc func(ctx context.Context, key InKey, value InValue) (transformedKey OutKey, transformedValue OutValue, err stackerr.Error)) (map[OutKey]OutValue, stackerr.Error) {
+	if in == nil {
+		return nil, nil
+	}
+	keys := MapKeys(in)
+	transformed, err := TransformSliceConcurrent(ctx, keys, workers, func(ctx context.Context, key InKey) (Pair[OutKey, OutValue], stackerr.Error) {
+		k, v, err := transformationFunc(ctx, key, in[key])
+		return Pair[OutKey, OutValue]{First: k, Second: v}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[OutKey]OutValue, len(transformed))
+	for _, pair := range transformed {
+		out[pair.First] = pair.Second
+	}
+	return out, nil
+}
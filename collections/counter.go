@@ -0,0 +1,128 @@
+package collections
+
+import "sort"
+
+// CounterEntry is a value and the number of times it was counted.
+type CounterEntry[T comparable] struct {
+	Value T
+	Count int
+}
+
+// Counter is a multiset, which counts the number of times each value
+// has been added to it.
+type Counter[T comparable] interface {
+	// Add will add 1 to the count of each value.
+	Add(values ...T)
+	// AddN will add n to the count of a value, and returns the new count.
+	AddN(value T, n int) int
+	// Remove will subtract 1 from the count of each value. Values whose
+	// count reaches 0 are removed.
+	Remove(values ...T)
+	// RemoveN will subtract n from the count of a value (to a minimum of 0),
+	// and returns the new count. Values whose count reaches 0 are removed.
+	RemoveN(value T, n int) int
+	// Count returns the count of a value, which is 0 if it hasn't been counted.
+	Count(value T) int
+	// Len returns the number of distinct values that have been counted.
+	Len() int
+	// Total returns the sum of the counts of all values.
+	Total() int
+	// MostCommon returns the n values with the highest counts, from highest to
+	// lowest. Values with equal counts are in no particular order. If n is less
+	// than 0, all values are returned.
+	MostCommon(n int) []CounterEntry[T]
+	// Merge will add the counts of another counter to this one.
+	Merge(other Counter[T])
+	// ToMap returns a map of each value to its count.
+	ToMap() map[T]int
+}
+
+type counter[T comparable] struct {
+	counts map[T]int
+	total  int
+}
+
+func NewCounter[T comparable](initial []T) Counter[T] {
+	c := &counter[T]{
+		counts: map[T]int{},
+	}
+	c.Add(initial...)
+	return c
+}
+
+func (c *counter[T]) Add(values ...T) {
+	for _, v := range values {
+		c.AddN(v, 1)
+	}
+}
+
+func (c *counter[T]) AddN(value T, n int) int {
+	if n < 0 {
+		return c.RemoveN(value, -n)
+	}
+	c.counts[value] += n
+	c.total += n
+	return c.counts[value]
+}
+
+func (c *counter[T]) Remove(values ...T) {
+	for _, v := range values {
+		c.RemoveN(v, 1)
+	}
+}
+
+func (c *counter[T]) RemoveN(value T, n int) int {
+	if n < 0 {
+		return c.AddN(value, -n)
+	}
+	count := c.counts[value]
+	if n >= count {
+		delete(c.counts, value)
+		c.total -= count
+		return 0
+	}
+	c.counts[value] = count - n
+	c.total -= n
+	return count - n
+}
+
+func (c *counter[T]) Count(value T) int {
+	return c.counts[value]
+}
+
+func (c *counter[T]) Len() int {
+	return len(c.counts)
+}
+
+func (c *counter[T]) Total() int {
+	return c.total
+}
+
+func (c *counter[T]) MostCommon(n int) []CounterEntry[T] {
+	entries := make([]CounterEntry[T], 0, len(c.counts))
+	for v, count := range c.counts {
+		entries = append(entries, CounterEntry[T]{
+			Value: v,
+			Count: count,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Count > entries[j].Count })
+	if n >= 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+func (c *counter[T]) Merge(other Counter[T]) {
+	for v, count := range other.ToMap() {
+		c.AddN(v, count)
+	}
+}
+
+func (c *counter[T]) ToMap() map[T]int {
+	out := make(map[T]int, len(c.counts))
+	for v, count := range c.counts {
+		out[v] = count
+	}
+	return out
+}
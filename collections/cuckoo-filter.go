@@ -0,0 +1,248 @@
+package collections
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type CuckooFilterConfig[T any] struct {
+	// The maximum number of items that the filter needs to hold. Must be at least 1.
+	Capacity uint
+	// The chance of Contains returning true for an item that wasn't added. Must
+	// be at least 0.0002 (since fingerprints are at most 16 bits) and less than 1.
+	FalsePositiveRate float64
+	// OPTIONAL. A function that converts an item to the bytes that are hashed. It must return
+	// the same bytes for equal items, in every process that shares the filter. If not provided,
+	// strings and byte slices are used as-is, and other types are marshaled to JSON.
+	KeyFunc func(item T) []byte
+}
+
+// CuckooFilter is a probabilistic set, which can have false positives (Contains may return
+// true for items that weren't added) but no false negatives. Unlike a BloomFilter, items can
+// be removed, but it has a fixed capacity.
+type CuckooFilter[T any] interface {
+	// Add adds an item. It returns an error if the filter is full, in which
+	// case the filter is unchanged.
+	Add(item T) stackerr.Error
+	// Contains returns false if the item definitely wasn't added, and true if it probably was.
+	Contains(item T) bool
+	// Delete will remove an item, and returns a bool of whether it was (probably) in the
+	// filter. Only items that were added may be deleted, or other items may be removed.
+	Delete(item T) bool
+	// Len returns the number of items in the filter.
+	Len() int
+	// Merge adds all items of another filter to this one. The filters must have been created
+	// with the same configuration. It returns an error if this filter becomes full, in which
+	// case some of the other filter's items may have been added.
+	Merge(other CuckooFilter[T]) stackerr.Error
+	// MarshalBinary serializes the filter to bytes, which can be loaded with NewCuckooFilterFromBytes.
+	MarshalBinary() ([]byte, error)
+}
+
+const (
+	cuckooFilterMagic      = "CF1"
+	cuckooFilterBucketSize = 4
+	// The number of times to move a fingerprint to its other bucket before giving up
+	cuckooFilterMaxKicks = 500
+)
+
+type cuckooFilter[T any] struct {
+	// The fingerprints in each bucket, where 0 is an empty slot
+	buckets         [][cuckooFilterBucketSize]uint16
+	fingerprintBits uint8
+	len             int
+	keyFunc         func(item T) []byte
+}
+
+func NewCuckooFilter[T any](config CuckooFilterConfig[T]) (CuckooFilter[T], stackerr.Error) {
+	if config.Capacity < 1 {
+		return nil, stackerr.Errorf("the `config.Capacity` field must be at least 1")
+	}
+	// The false positive rate is about 2 * bucket size / 2^bits
+	bits := math.Ceil(math.Log2(2 * cuckooFilterBucketSize / config.FalsePositiveRate))
+	if config.FalsePositiveRate <= 0 || config.FalsePositiveRate >= 1 || bits > 16 {
+		return nil, stackerr.Errorf("the `config.FalsePositiveRate` field must be at least 0.0002 and less than 1, got %v", config.FalsePositiveRate)
+	}
+	// Filters can reliably be filled to about 95% with buckets of 4
+	numBuckets := uint64(1)
+	for float64(numBuckets*cuckooFilterBucketSize)*0.95 < float64(config.Capacity) {
+		numBuckets *= 2
+	}
+	return newCuckooFilter(numBuckets, uint8(math.Max(4, bits)), config.KeyFunc), nil
+}
+
+func newCuckooFilter[T any](numBuckets uint64, fingerprintBits uint8, keyFunc func(item T) []byte) *cuckooFilter[T] {
+	if keyFunc == nil {
+		keyFunc = filterKeyBytes[T]
+	}
+	return &cuckooFilter[T]{
+		buckets:         make([][cuckooFilterBucketSize]uint16, numBuckets),
+		fingerprintBits: fingerprintBits,
+		keyFunc:         keyFunc,
+	}
+}
+
+// NewCuckooFilterFromBytes loads a filter that was serialized with MarshalBinary. The
+// key function must be the same as the one that the filter was created with.
+func NewCuckooFilterFromBytes[T any](data []byte, keyFunc func(item T) []byte) (CuckooFilter[T], stackerr.Error) {
+	header := len(cuckooFilterMagic) + 9
+	if len(data) < header || string(data[:len(cuckooFilterMagic)]) != cuckooFilterMagic {
+		return nil, stackerr.Errorf("the data is not a serialized cuckoo filter")
+	}
+	numBuckets := binary.BigEndian.Uint64(data[len(cuckooFilterMagic):])
+	fingerprintBits := data[len(cuckooFilterMagic)+8]
+	if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 || fingerprintBits < 1 || fingerprintBits > 16 {
+		return nil, stackerr.Errorf("the serialized cuckoo filter has an invalid configuration")
+	}
+	if uint64(len(data)-header) != numBuckets*cuckooFilterBucketSize*2 {
+		return nil, stackerr.Errorf("the serialized cuckoo filter has %d bytes of buckets, expected %d", len(data)-header, numBuckets*cuckooFilterBucketSize*2)
+	}
+	cf := newCuckooFilter(numBuckets, fingerprintBits, keyFunc)
+	offset := header
+	for i := range cf.buckets {
+		for j := range cf.buckets[i] {
+			cf.buckets[i][j] = binary.BigEndian.Uint16(data[offset:])
+			offset += 2
+			if cf.buckets[i][j] != 0 {
+				cf.len++
+			}
+		}
+	}
+	return cf, nil
+}
+
+// locate gets the fingerprint and the two possible buckets of an item.
+func (cf *cuckooFilter[T]) locate(item T) (fingerprint uint16, i1 uint64, i2 uint64) {
+	h1, h2 := filterHash(cf.keyFunc(item))
+	fingerprint = uint16(h2 & (1<<cf.fingerprintBits - 1))
+	// 0 marks an empty slot, so it can't be a fingerprint
+	if fingerprint == 0 {
+		fingerprint = 1
+	}
+	i1 = h1 & uint64(len(cf.buckets)-1)
+	return fingerprint, i1, cf.altIndex(i1, fingerprint)
+}
+
+// altIndex gets the other bucket of a fingerprint, from one of its buckets. It's its
+// own inverse, so that either bucket can be found from the other.
+func (cf *cuckooFilter[T]) altIndex(index uint64, fingerprint uint16) uint64 {
+	return (index ^ (uint64(fingerprint) * 0x5bd1e995)) & uint64(len(cf.buckets)-1)
+}
+
+// insert adds a fingerprint to an empty slot in a bucket, and returns a bool of whether it was added.
+func (cf *cuckooFilter[T]) insert(index uint64, fingerprint uint16) bool {
+	for j, slot := range cf.buckets[index] {
+		if slot == 0 {
+			cf.buckets[index][j] = fingerprint
+			cf.len++
+			return true
+		}
+	}
+	return false
+}
+
+// add adds a fingerprint to one of its buckets, moving other fingerprints to their other
+// buckets to make room if needed. If there's no room, the filter is left unchanged.
+func (cf *cuckooFilter[T]) add(fingerprint uint16, i1 uint64, i2 uint64) stackerr.Error {
+	if cf.insert(i1, fingerprint) || cf.insert(i2, fingerprint) {
+		return nil
+	}
+	type kick struct {
+		index uint64
+		slot  int
+	}
+	kicks := make([]kick, 0, cuckooFilterMaxKicks)
+	index := i1
+	if rand.Intn(2) == 0 {
+		index = i2
+	}
+	for n := 0; n < cuckooFilterMaxKicks; n++ {
+		// Swap the fingerprint with a random one in the bucket, and try to put that one in its other bucket
+		slot := rand.Intn(cuckooFilterBucketSize)
+		kicks = append(kicks, kick{index: index, slot: slot})
+		fingerprint, cf.buckets[index][slot] = cf.buckets[index][slot], fingerprint
+		index = cf.altIndex(index, fingerprint)
+		if cf.insert(index, fingerprint) {
+			return nil
+		}
+	}
+	// Undo the moves, so that no fingerprint is lost
+	for n := len(kicks) - 1; n >= 0; n-- {
+		k := kicks[n]
+		fingerprint, cf.buckets[k.index][k.slot] = cf.buckets[k.index][k.slot], fingerprint
+	}
+	return stackerr.Errorf("the cuckoo filter is full (%d items)", cf.len)
+}
+
+func (cf *cuckooFilter[T]) Add(item T) stackerr.Error {
+	return cf.add(cf.locate(item))
+}
+
+func (cf *cuckooFilter[T]) Contains(item T) bool {
+	fingerprint, i1, i2 := cf.locate(item)
+	for j := range cf.buckets[i1] {
+		if cf.buckets[i1][j] == fingerprint || cf.buckets[i2][j] == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *cuckooFilter[T]) Delete(item T) bool {
+	fingerprint, i1, i2 := cf.locate(item)
+	for _, index := range []uint64{i1, i2} {
+		for j, slot := range cf.buckets[index] {
+			if slot == fingerprint {
+				cf.buckets[index][j] = 0
+				cf.len--
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cf *cuckooFilter[T]) Len() int {
+	return cf.len
+}
+
+func (cf *cuckooFilter[T]) Merge(other CuckooFilter[T]) stackerr.Error {
+	o, ok := other.(*cuckooFilter[T])
+	if !ok {
+		return stackerr.Errorf("cannot merge a cuckoo filter of type %T", other)
+	}
+	if len(o.buckets) != len(cf.buckets) || o.fingerprintBits != cf.fingerprintBits {
+		return stackerr.Errorf("cannot merge cuckoo filters with different configurations (%d buckets and %d-bit fingerprints, vs. %d buckets and %d-bit fingerprints)", len(cf.buckets), cf.fingerprintBits, len(o.buckets), o.fingerprintBits)
+	}
+	// Copy the buckets first, in case the other filter is this one
+	buckets := make([][cuckooFilterBucketSize]uint16, len(o.buckets))
+	copy(buckets, o.buckets)
+	for i, bucket := range buckets {
+		for _, fingerprint := range bucket {
+			if fingerprint == 0 {
+				continue
+			}
+			// Since the filters have the same configuration, the fingerprint's buckets are the same
+			if err := cf.add(fingerprint, uint64(i), cf.altIndex(uint64(i), fingerprint)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cf *cuckooFilter[T]) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, len(cuckooFilterMagic)+9+len(cf.buckets)*cuckooFilterBucketSize*2)
+	data = append(data, cuckooFilterMagic...)
+	data = binary.BigEndian.AppendUint64(data, uint64(len(cf.buckets)))
+	data = append(data, cf.fingerprintBits)
+	for _, bucket := range cf.buckets {
+		for _, fingerprint := range bucket {
+			data = binary.BigEndian.AppendUint16(data, fingerprint)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,307 @@
+package collections
+
+// Deque is a double-ended queue, which values can be pushed to and popped
+// from at both ends in amortized O(1) time. It's backed by a ring buffer.
+type Deque[T any] interface {
+	// PushFront adds a value to the front.
+	PushFront(value T)
+	// PushBack adds a value to the back.
+	PushBack(value T)
+	// PopFront removes and returns the value at the front, and a bool of
+	// whether there was one (false if the deque is empty).
+	PopFront() (T, bool)
+	// PopBack removes and returns the value at the back, and a bool of
+	// whether there was one (false if the deque is empty).
+	PopBack() (T, bool)
+	// PeekFront returns the value at the front without removing it, and a
+	// bool of whether there was one (false if the deque is empty).
+	PeekFront() (T, bool)
+	// PeekBack returns the value at the back without removing it, and a
+	// bool of whether there was one (false if the deque is empty).
+	PeekBack() (T, bool)
+	// At returns the value at an index, counting from the front. It panics
+	// if the index is out of range.
+	At(index int) T
+	// Len returns the number of values.
+	Len() int
+	// Clear removes all values.
+	Clear()
+	// ToSlice returns a slice of all values, from front to back.
+	ToSlice() []T
+	// Iterator will return a closure (iterator) that will return the next value each time
+	// it's called, from front to back. After the last value has been returned, the closure
+	// will return a zero-value and false for 'ok'. The deque must not be modified while
+	// it's being iterated over.
+	Iterator() func() (v T, ok bool)
+	// All returns an iterator over the index and value of each value, from front to back.
+	// The deque must not be modified while it's being iterated over.
+	All() func(yield func(int, T) bool)
+}
+
+const dequeMinCapacity = 8
+
+type deque[T any] struct {
+	// The ring buffer, whose length is always 0 or a power of 2
+	buf []T
+	// The index of the front value in the buffer
+	head int
+	len  int
+}
+
+func NewDeque[T any]() Deque[T] {
+	return &deque[T]{}
+}
+
+// NewDequePreallocated creates a deque with space for at least the given
+// number of values before it needs to grow.
+func NewDequePreallocated[T any](size int) Deque[T] {
+	capacity := dequeMinCapacity
+	for capacity < size {
+		capacity *= 2
+	}
+	return &deque[T]{
+		buf: make([]T, capacity),
+	}
+}
+
+// index gets the buffer index of the value at an index from the front.
+func (d *deque[T]) index(i int) int {
+	return (d.head + i) & (len(d.buf) - 1)
+}
+
+// grow doubles the size of the buffer if it's full.
+func (d *deque[T]) grow() {
+	if d.len < len(d.buf) {
+		return
+	}
+	capacity := len(d.buf) * 2
+	if capacity == 0 {
+		capacity = dequeMinCapacity
+	}
+	buf := make([]T, capacity)
+	// Copy the values so that the front is at the start of the new buffer
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+	d.buf = buf
+	d.head = 0
+}
+
+// shrink halves the size of the buffer if it's a quarter full, so
+// that a deque that was large once doesn't hold the memory forever.
+func (d *deque[T]) shrink() {
+	if len(d.buf) <= dequeMinCapacity || d.len > len(d.buf)/4 {
+		return
+	}
+	buf := make([]T, len(d.buf)/2)
+	if d.head+d.len <= len(d.buf) {
+		copy(buf, d.buf[d.head:d.head+d.len])
+	} else {
+		n := copy(buf, d.buf[d.head:])
+		copy(buf[n:], d.buf[:d.len-n])
+	}
+	d.buf = buf
+	d.head = 0
+}
+
+func (d *deque[T]) PushFront(value T) {
+	d.grow()
+	d.head = d.index(len(d.buf) - 1)
+	d.buf[d.head] = value
+	d.len++
+}
+
+func (d *deque[T]) PushBack(value T) {
+	d.grow()
+	d.buf[d.index(d.len)] = value
+	d.len++
+}
+
+func (d *deque[T]) PopFront() (T, bool) {
+	var zero T
+	if d.len == 0 {
+		return zero, false
+	}
+	value := d.buf[d.head]
+	// Clear the slot so that it doesn't keep the value from being garbage collected
+	d.buf[d.head] = zero
+	d.head = d.index(1)
+	d.len--
+	d.shrink()
+	return value, true
+}
+
+func (d *deque[T]) PopBack() (T, bool) {
+	var zero T
+	if d.len == 0 {
+		return zero, false
+	}
+	i := d.index(d.len - 1)
+	value := d.buf[i]
+	d.buf[i] = zero
+	d.len--
+	d.shrink()
+	return value, true
+}
+
+func (d *deque[T]) PeekFront() (T, bool) {
+	if d.len == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.head], true
+}
+
+func (d *deque[T]) PeekBack() (T, bool) {
+	if d.len == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.index(d.len-1)], true
+}
+
+func (d *deque[T]) At(index int) T {
+	if index < 0 || index >= d.len {
+		panic("deque index out of range")
+	}
+	return d.buf[d.index(index)]
+}
+
+func (d *deque[T]) Len() int {
+	return d.len
+}
+
+func (d *deque[T]) Clear() {
+	d.buf = nil
+	d.head = 0
+	d.len = 0
+}
+
+func (d *deque[T]) ToSlice() []T {
+	values := make([]T, d.len)
+	for i := 0; i < d.len; i++ {
+		values[i] = d.buf[d.index(i)]
+	}
+	return values
+}
+
+func (d *deque[T]) Iterator() func() (v T, ok bool) {
+	i := 0
+	return func() (v T, ok bool) {
+		if i >= d.len {
+			return v, false
+		}
+		v = d.buf[d.index(i)]
+		i++
+		return v, true
+	}
+}
+
+func (d *deque[T]) All() func(yield func(int, T) bool) {
+	return func(yield func(int, T) bool) {
+		for i := 0; i < d.len; i++ {
+			if !yield(i, d.buf[d.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+// Stack is a last-in-first-out stack of values.
+type Stack[T any] interface {
+	// Push adds a value to the top.
+	Push(value T)
+	// Pop removes and returns the value at the top, and a bool of
+	// whether there was one (false if the stack is empty).
+	Pop() (T, bool)
+	// Peek returns the value at the top without removing it, and a bool
+	// of whether there was one (false if the stack is empty).
+	Peek() (T, bool)
+	// Len returns the number of values.
+	Len() int
+	// Clear removes all values.
+	Clear()
+	// ToSlice returns a slice of all values, from the bottom to the top.
+	ToSlice() []T
+	// Iterator will return a closure (iterator) that will return the next value each time
+	// it's called, from the top to the bottom. After the last value has been returned, the
+	// closure will return a zero-value and false for 'ok'. The stack must not be modified
+	// while it's being iterated over.
+	Iterator() func() (v T, ok bool)
+	// All returns an iterator over the values, from the top to the bottom.
+	// The stack must not be modified while it's being iterated over.
+	All() func(yield func(T) bool)
+}
+
+type stack[T any] struct {
+	values []T
+}
+
+func NewStack[T any]() Stack[T] {
+	return &stack[T]{}
+}
+
+func NewStackPreallocated[T any](size int) Stack[T] {
+	return &stack[T]{
+		values: make([]T, 0, size),
+	}
+}
+
+func (s *stack[T]) Push(value T) {
+	s.values = append(s.values, value)
+}
+
+func (s *stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.values) == 0 {
+		return zero, false
+	}
+	last := len(s.values) - 1
+	value := s.values[last]
+	// Clear the slot so that it doesn't keep the value from being garbage collected
+	s.values[last] = zero
+	s.values = s.values[:last]
+	return value, true
+}
+
+func (s *stack[T]) Peek() (T, bool) {
+	if len(s.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.values[len(s.values)-1], true
+}
+
+func (s *stack[T]) Len() int {
+	return len(s.values)
+}
+
+func (s *stack[T]) Clear() {
+	s.values = nil
+}
+
+func (s *stack[T]) ToSlice() []T {
+	values := make([]T, len(s.values))
+	copy(values, s.values)
+	return values
+}
+
+func (s *stack[T]) Iterator() func() (v T, ok bool) {
+	i := len(s.values)
+	return func() (v T, ok bool) {
+		if i <= 0 {
+			return v, false
+		}
+		i--
+		return s.values[i], true
+	}
+}
+
+func (s *stack[T]) All() func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		for i := len(s.values) - 1; i >= 0; i-- {
+			if !yield(s.values[i]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package collections
+
+// MapDiffResult is the difference between two maps, from MapDiff.
+type MapDiffResult[K comparable] struct {
+	// The keys that are in the new map but not the old one
+	Added Set[K]
+	// The keys that are in the old map but not the new one
+	Removed Set[K]
+	// The keys that are in both maps, with different values
+	Changed Set[K]
+}
+
+// MapDiff gets the keys that were added, removed, and changed between an old and a new map.
+func MapDiff[K comparable, V comparable](oldMap map[K]V, newMap map[K]V) MapDiffResult[K] {
+	return MapDiffFunc(oldMap, newMap, func(oldValue V, newValue V) bool { return oldValue == newValue })
+}
+
+// MapDiffFunc is the same as MapDiff, except that it uses a function to check whether
+// values are equal, for values that aren't comparable.
+func MapDiffFunc[K comparable, V any](oldMap map[K]V, newMap map[K]V, equalFunc func(oldValue V, newValue V) bool) MapDiffResult[K] {
+	result := MapDiffResult[K]{
+		Added:   NewSet[K](nil),
+		Removed: NewSet[K](nil),
+		Changed: NewSet[K](nil),
+	}
+	for k, oldValue := range oldMap {
+		newValue, ok := newMap[k]
+		if !ok {
+			result.Removed.Add(k)
+		} else if !equalFunc(oldValue, newValue) {
+			result.Changed.Add(k)
+		}
+	}
+	for k := range newMap {
+		if _, ok := oldMap[k]; !ok {
+			result.Added.Add(k)
+		}
+	}
+	return result
+}
+
+// SliceChange is a change to an element between an old and a new slice.
+type SliceChange[T any] struct {
+	Value T
+	// The index of the element in the old slice, or -1 for an insertion
+	OldIndex int
+	// The index of the element in the new slice, or -1 for a deletion
+	NewIndex int
+}
+
+// SliceDiffResult is the difference between two slices, from SliceDiffDetailed.
+type SliceDiffResult[T any] struct {
+	// The elements that are only in the new slice, in order
+	Insertions []SliceChange[T]
+	// The elements that are only in the old slice, in order
+	Deletions []SliceChange[T]
+	// The elements that are in both slices, but in a different position relative to the others
+	Moves []SliceChange[T]
+}
+
+// SliceDiffDetailed gets a minimal set of changes to turn an old slice into a new one. The
+// elements that stay in place are the longest common subsequence of the slices. Of the other
+// elements, those that are in both slices are moves, and the rest are insertions and deletions.
+// It takes O(n*m) time and memory for slices of lengths n and m.
+func SliceDiffDetailed[T comparable](oldSlice []T, newSlice []T) SliceDiffResult[T] {
+	n, m := len(oldSlice), len(newSlice)
+	// lcs[i][j] is the length of the longest common subsequence of oldSlice[i:] and newSlice[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldSlice[i] == newSlice[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to find the elements that aren't in the common subsequence
+	deleted := []int{}
+	inserted := []int{}
+	i, j := 0, 0
+	for i < n && j < m {
+		if oldSlice[i] == newSlice[j] {
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			deleted = append(deleted, i)
+			i++
+		} else {
+			inserted = append(inserted, j)
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		deleted = append(deleted, i)
+	}
+	for ; j < m; j++ {
+		inserted = append(inserted, j)
+	}
+
+	// Pair up deletions and insertions of equal elements as moves, in order
+	deletedByValue := map[T][]int{}
+	for _, oldIndex := range deleted {
+		deletedByValue[oldSlice[oldIndex]] = append(deletedByValue[oldSlice[oldIndex]], oldIndex)
+	}
+	moved := map[int]struct{}{}
+	result := SliceDiffResult[T]{
+		Insertions: []SliceChange[T]{},
+		Deletions:  []SliceChange[T]{},
+		Moves:      []SliceChange[T]{},
+	}
+	for _, newIndex := range inserted {
+		value := newSlice[newIndex]
+		if candidates := deletedByValue[value]; len(candidates) > 0 {
+			deletedByValue[value] = candidates[1:]
+			moved[candidates[0]] = struct{}{}
+			result.Moves = append(result.Moves, SliceChange[T]{Value: value, OldIndex: candidates[0], NewIndex: newIndex})
+		} else {
+			result.Insertions = append(result.Insertions, SliceChange[T]{Value: value, OldIndex: -1, NewIndex: newIndex})
+		}
+	}
+	for _, oldIndex := range deleted {
+		if _, ok := moved[oldIndex]; !ok {
+			result.Deletions = append(result.Deletions, SliceChange[T]{Value: oldSlice[oldIndex], OldIndex: oldIndex, NewIndex: -1})
+		}
+	}
+	return result
+}
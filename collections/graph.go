@@ -0,0 +1,258 @@
+package collections
+
+import (
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Graph is a directed graph. Nodes and edges are kept in the order that they were
+// added, so that the results of all operations are deterministic.
+type Graph[N comparable] interface {
+	// AddNode adds nodes, if they don't already exist.
+	AddNode(nodes ...N)
+	// RemoveNode will remove a node and all of its edges if it exists, and returns
+	// a bool of whether it existed and was removed.
+	RemoveNode(node N) bool
+	// HasNode returns a bool of whether a node exists.
+	HasNode(node N) bool
+	// AddEdge adds an edge from one node to another, adding the nodes if they don't
+	// already exist.
+	AddEdge(from N, to N)
+	// RemoveEdge will remove an edge if it exists, and returns a bool of whether
+	// it existed and was removed. The nodes are not removed.
+	RemoveEdge(from N, to N) bool
+	// HasEdge returns a bool of whether there's an edge from one node to another.
+	HasEdge(from N, to N) bool
+	// Nodes returns all nodes.
+	Nodes() []N
+	// Successors returns the nodes that a node has edges to.
+	Successors(node N) []N
+	// Predecessors returns the nodes that have edges to a node.
+	Predecessors(node N) []N
+	// Len returns the number of nodes.
+	Len() int
+	// FindCycle returns the nodes of a cycle (where each node has an edge to the next, and the
+	// last has an edge to the first), and a bool of whether there's a cycle.
+	FindCycle() (cycle []N, found bool)
+	// TopologicalSort returns all nodes in an order where each node is before all of the nodes
+	// that it has edges to. It returns an error if the graph has a cycle.
+	TopologicalSort() ([]N, stackerr.Error)
+	// StronglyConnectedComponents returns the groups of nodes where every node in a group can
+	// reach every other node in the group. The groups are in reverse topological order (a group
+	// only has edges to groups before it), and nodes that aren't in a cycle are in groups alone.
+	StronglyConnectedComponents() [][]N
+}
+
+type graphNode[N comparable] struct {
+	successors   OrderedMap[N, struct{}]
+	predecessors OrderedMap[N, struct{}]
+}
+
+type graph[N comparable] struct {
+	nodes OrderedMap[N, *graphNode[N]]
+}
+
+func NewGraph[N comparable]() Graph[N] {
+	return &graph[N]{
+		nodes: NewOrderedMap[N, *graphNode[N]](),
+	}
+}
+
+// node gets a node, adding it if it doesn't already exist.
+func (g *graph[N]) node(node N) *graphNode[N] {
+	n, ok := g.nodes.Get(node)
+	if !ok {
+		n = &graphNode[N]{
+			successors:   NewOrderedMap[N, struct{}](),
+			predecessors: NewOrderedMap[N, struct{}](),
+		}
+		g.nodes.Set(node, n)
+	}
+	return n
+}
+
+func (g *graph[N]) AddNode(nodes ...N) {
+	for _, node := range nodes {
+		g.node(node)
+	}
+}
+
+func (g *graph[N]) RemoveNode(node N) bool {
+	n, ok := g.nodes.Get(node)
+	if !ok {
+		return false
+	}
+	for _, successor := range n.successors.Keys() {
+		s, _ := g.nodes.Get(successor)
+		s.predecessors.Delete(node)
+	}
+	for _, predecessor := range n.predecessors.Keys() {
+		p, _ := g.nodes.Get(predecessor)
+		p.successors.Delete(node)
+	}
+	g.nodes.Delete(node)
+	return true
+}
+
+func (g *graph[N]) HasNode(node N) bool {
+	return g.nodes.Has(node)
+}
+
+func (g *graph[N]) AddEdge(from N, to N) {
+	g.node(from).successors.Set(to, struct{}{})
+	g.node(to).predecessors.Set(from, struct{}{})
+}
+
+func (g *graph[N]) RemoveEdge(from N, to N) bool {
+	f, ok := g.nodes.Get(from)
+	if !ok || !f.successors.Delete(to) {
+		return false
+	}
+	t, _ := g.nodes.Get(to)
+	t.predecessors.Delete(from)
+	return true
+}
+
+func (g *graph[N]) HasEdge(from N, to N) bool {
+	f, ok := g.nodes.Get(from)
+	return ok && f.successors.Has(to)
+}
+
+func (g *graph[N]) Nodes() []N {
+	return g.nodes.Keys()
+}
+
+func (g *graph[N]) Successors(node N) []N {
+	n, ok := g.nodes.Get(node)
+	if !ok {
+		return []N{}
+	}
+	return n.successors.Keys()
+}
+
+func (g *graph[N]) Predecessors(node N) []N {
+	n, ok := g.nodes.Get(node)
+	if !ok {
+		return []N{}
+	}
+	return n.predecessors.Keys()
+}
+
+func (g *graph[N]) Len() int {
+	return g.nodes.Len()
+}
+
+func (g *graph[N]) FindCycle() (cycle []N, found bool) {
+	// The state of each node: not in the map if it hasn't been visited, true if it's on
+	// the current path, and false if it has been visited and isn't in a cycle
+	onPath := map[N]bool{}
+	path := []N{}
+	var visit func(node N) bool
+	visit = func(node N) bool {
+		onPath[node] = true
+		path = append(path, node)
+		n, _ := g.nodes.Get(node)
+		for _, successor := range n.successors.Keys() {
+			current, visited := onPath[successor]
+			if current {
+				// The cycle is the part of the path from the successor onwards
+				for i, p := range path {
+					if p == successor {
+						cycle = CopySlice(path[i:])
+						return true
+					}
+				}
+			}
+			if !visited && visit(successor) {
+				return true
+			}
+		}
+		onPath[node] = false
+		path = path[:len(path)-1]
+		return false
+	}
+	for _, node := range g.nodes.Keys() {
+		if _, visited := onPath[node]; !visited && visit(node) {
+			return cycle, true
+		}
+	}
+	return nil, false
+}
+
+func (g *graph[N]) TopologicalSort() ([]N, stackerr.Error) {
+	// Kahn's algorithm: repeatedly take the nodes with no remaining predecessors
+	remaining := make(map[N]int, g.nodes.Len())
+	queue := NewDeque[N]()
+	for _, node := range g.nodes.Keys() {
+		n, _ := g.nodes.Get(node)
+		remaining[node] = n.predecessors.Len()
+		if remaining[node] == 0 {
+			queue.PushBack(node)
+		}
+	}
+	sorted := make([]N, 0, g.nodes.Len())
+	for queue.Len() > 0 {
+		node, _ := queue.PopFront()
+		sorted = append(sorted, node)
+		n, _ := g.nodes.Get(node)
+		for _, successor := range n.successors.Keys() {
+			remaining[successor]--
+			if remaining[successor] == 0 {
+				queue.PushBack(successor)
+			}
+		}
+	}
+	if len(sorted) < g.nodes.Len() {
+		cycle, _ := g.FindCycle()
+		return nil, stackerr.Errorf("the graph has a cycle: %v", cycle)
+	}
+	return sorted, nil
+}
+
+func (g *graph[N]) StronglyConnectedComponents() [][]N {
+	// Tarjan's algorithm
+	index := 0
+	indexes := map[N]int{}
+	lowLinks := map[N]int{}
+	onStack := map[N]bool{}
+	stack := []N{}
+	components := [][]N{}
+	var connect func(node N)
+	connect = func(node N) {
+		indexes[node] = index
+		lowLinks[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+		n, _ := g.nodes.Get(node)
+		for _, successor := range n.successors.Keys() {
+			if _, visited := indexes[successor]; !visited {
+				connect(successor)
+				if lowLinks[successor] < lowLinks[node] {
+					lowLinks[node] = lowLinks[successor]
+				}
+			} else if onStack[successor] && indexes[successor] < lowLinks[node] {
+				lowLinks[node] = indexes[successor]
+			}
+		}
+		// If this node is the root of a component, pop the component off the stack
+		if lowLinks[node] == indexes[node] {
+			component := []N{}
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	for _, node := range g.nodes.Keys() {
+		if _, visited := indexes[node]; !visited {
+			connect(node)
+		}
+	}
+	return components
+}
@@ -15,6 +15,8 @@ type HashMap[T comparable] interface {
 	Length() int
 	// Keys returns a slice of all keys in the hash map.
 	Keys() []T
+	// All returns an iterator over the keys, in no particular order.
+	All() func(yield func(T) bool)
 }
 
 type hashMap[T comparable] map[T]struct{}
@@ -63,3 +65,13 @@ func (hm hashMap[T]) Keys() []T {
 	}
 	return keys
 }
+
+func (hm hashMap[T]) All() func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		for k := range hm {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package collections
+
+import (
+	"math/rand"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Interval is a closed interval (it includes both its start and end) with a value.
+type Interval[P constraints.Ordered, V any] struct {
+	Start P
+	End   P
+	Value V
+}
+
+// IntervalTree stores intervals, and finds the ones that contain a point or overlap another
+// interval in O(log n + k) time (where k is the number of intervals found). Intervals are closed,
+// so they include both their start and end. The same interval can be inserted more than once.
+type IntervalTree[P constraints.Ordered, V any] interface {
+	// Insert adds an interval. It returns an error if the start is after the end.
+	Insert(start P, end P, value V) stackerr.Error
+	// Delete will delete all intervals with the given start and end, and returns the
+	// number that were deleted.
+	Delete(start P, end P) int
+	// At returns all intervals that contain a point, in order of their start.
+	At(point P) []Interval[P, V]
+	// Overlapping returns all intervals that overlap the interval from start to end (including
+	// ones that only touch it at one point), in order of their start.
+	Overlapping(start P, end P) []Interval[P, V]
+	// Len returns the number of intervals.
+	Len() int
+	// Intervals returns all intervals, in order of their start.
+	Intervals() []Interval[P, V]
+}
+
+// intervalTreeNode is a node of a treap that's ordered by start, where each node
+// also has the maximum end of all intervals in its subtree.
+type intervalTreeNode[P constraints.Ordered, V any] struct {
+	interval    Interval[P, V]
+	maxEnd      P
+	priority    uint32
+	left, right *intervalTreeNode[P, V]
+}
+
+// update recalculates the maximum end of the node's subtree.
+func (n *intervalTreeNode[P, V]) update() {
+	n.maxEnd = n.interval.End
+	if n.left != nil && n.left.maxEnd > n.maxEnd {
+		n.maxEnd = n.left.maxEnd
+	}
+	if n.right != nil && n.right.maxEnd > n.maxEnd {
+		n.maxEnd = n.right.maxEnd
+	}
+}
+
+type intervalTree[P constraints.Ordered, V any] struct {
+	root *intervalTreeNode[P, V]
+	len  int
+}
+
+func NewIntervalTree[P constraints.Ordered, V any]() IntervalTree[P, V] {
+	return &intervalTree[P, V]{}
+}
+
+// splitIntervalTree splits a subtree into the nodes that start before the key (or at
+// the key, if inclusive is true) and the rest.
+func splitIntervalTree[P constraints.Ordered, V any](n *intervalTreeNode[P, V], key P, inclusive bool) (left *intervalTreeNode[P, V], right *intervalTreeNode[P, V]) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.interval.Start < key || (inclusive && n.interval.Start == key) {
+		n.right, right = splitIntervalTree(n.right, key, inclusive)
+		n.update()
+		return n, right
+	}
+	left, n.left = splitIntervalTree(n.left, key, inclusive)
+	n.update()
+	return left, n
+}
+
+// mergeIntervalTree merges two subtrees, where all nodes in the
+// left one start before or at the same point as the right one.
+func mergeIntervalTree[P constraints.Ordered, V any](left *intervalTreeNode[P, V], right *intervalTreeNode[P, V]) *intervalTreeNode[P, V] {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		left.right = mergeIntervalTree(left.right, right)
+		left.update()
+		return left
+	}
+	right.left = mergeIntervalTree(left, right.left)
+	right.update()
+	return right
+}
+
+func (t *intervalTree[P, V]) Insert(start P, end P, value V) stackerr.Error {
+	if start > end {
+		return stackerr.Errorf("the interval start (%v) is after its end (%v)", start, end)
+	}
+	n := &intervalTreeNode[P, V]{
+		interval: Interval[P, V]{
+			Start: start,
+			End:   end,
+			Value: value,
+		},
+		priority: rand.Uint32(),
+	}
+	n.update()
+	// Insert it after any intervals with the same start, so they stay in insertion order
+	left, right := splitIntervalTree(t.root, start, true)
+	t.root = mergeIntervalTree(mergeIntervalTree(left, n), right)
+	t.len++
+	return nil
+}
+
+func (t *intervalTree[P, V]) Delete(start P, end P) int {
+	// Split out the subtree of intervals with the same start
+	left, rest := splitIntervalTree(t.root, start, false)
+	same, right := splitIntervalTree(rest, start, true)
+	deleted := 0
+	var kept *intervalTreeNode[P, V]
+	walkIntervalTree(same, func(n *intervalTreeNode[P, V]) {
+		if n.interval.End == end {
+			deleted++
+			return
+		}
+		n.left, n.right = nil, nil
+		n.update()
+		kept = mergeIntervalTree(kept, n)
+	})
+	t.root = mergeIntervalTree(mergeIntervalTree(left, kept), right)
+	t.len -= deleted
+	return deleted
+}
+
+// walkIntervalTree calls a function with each node of a subtree, in order. The function
+// may modify the node's children, since they're read before it's called.
+func walkIntervalTree[P constraints.Ordered, V any](n *intervalTreeNode[P, V], f func(n *intervalTreeNode[P, V])) {
+	if n == nil {
+		return
+	}
+	left, right := n.left, n.right
+	walkIntervalTree(left, f)
+	f(n)
+	walkIntervalTree(right, f)
+}
+
+func (t *intervalTree[P, V]) At(point P) []Interval[P, V] {
+	return t.Overlapping(point, point)
+}
+
+func (t *intervalTree[P, V]) Overlapping(start P, end P) []Interval[P, V] {
+	out := []Interval[P, V]{}
+	var search func(n *intervalTreeNode[P, V])
+	search = func(n *intervalTreeNode[P, V]) {
+		// If nothing in the subtree ends at or after the start, nothing in it overlaps
+		if n == nil || n.maxEnd < start {
+			return
+		}
+		search(n.left)
+		// If this node starts after the end, so does everything to its right
+		if n.interval.Start > end {
+			return
+		}
+		if n.interval.End >= start {
+			out = append(out, n.interval)
+		}
+		search(n.right)
+	}
+	search(t.root)
+	return out
+}
+
+func (t *intervalTree[P, V]) Len() int {
+	return t.len
+}
+
+func (t *intervalTree[P, V]) Intervals() []Interval[P, V] {
+	out := make([]Interval[P, V], 0, t.len)
+	walkIntervalTree(t.root, func(n *intervalTreeNode[P, V]) {
+		out = append(out, n.interval)
+	})
+	return out
+}
@@ -16,6 +16,8 @@ type LinkedListElement[T any] interface {
 	Prev() LinkedListElement[T]
 	Next() LinkedListElement[T]
 	Value() T
+	next() LinkedListElement[T]
+	prev() LinkedListElement[T]
 	setNext(element LinkedListElement[T])
 	setPrev(element LinkedListElement[T])
 	setList(list *linkedList[T])
@@ -43,6 +45,16 @@ func (e *linkedListElement[T]) List() LinkedList[T] {
 	return e.list
 }
 
+// next returns the next element in the ring, which may be the root.
+func (e *linkedListElement[T]) next() LinkedListElement[T] {
+	return e.nextElement
+}
+
+// prev returns the previous element in the ring, which may be the root.
+func (e *linkedListElement[T]) prev() LinkedListElement[T] {
+	return e.prevElement
+}
+
 func (e *linkedListElement[T]) setNext(element LinkedListElement[T]) {
 	e.nextElement = element
 }
@@ -55,7 +67,7 @@ func (e *linkedListElement[T]) setList(list *linkedList[T]) {
 
 // Next returns the next list element or nil.
 func (e *linkedListElement[T]) Next() LinkedListElement[T] {
-	if p := e.nextElement; e.list != nil && p != e.list.Front().Prev() {
+	if p := e.nextElement; e.list != nil && p != LinkedListElement[T](e.list.root) {
 		return p
 	}
 	return nil
@@ -63,7 +75,7 @@ func (e *linkedListElement[T]) Next() LinkedListElement[T] {
 
 // Prev returns the previous list element or nil.
 func (e *linkedListElement[T]) Prev() LinkedListElement[T] {
-	if p := e.prevElement; e.list != nil && p != e.list.root {
+	if p := e.prevElement; e.list != nil && p != LinkedListElement[T](e.list.root) {
 		return p
 	}
 	return nil
@@ -122,6 +134,10 @@ type LinkedList[T any] interface {
 	// PushFrontList inserts a copy of another list at the front of list l.
 	// The lists l and other may be the same. They must not be nil.
 	PushFrontList(other LinkedList[T])
+	// All returns an iterator over the index and value of each element, from front to back.
+	All() func(yield func(int, T) bool)
+	// Values returns an iterator over the value of each element, from front to back.
+	Values() func(yield func(T) bool)
 }
 
 // linkedList[T] represents a doubly linked list.
@@ -132,6 +148,9 @@ type linkedList[T any] struct {
 }
 
 func (l *linkedList[T]) Init() LinkedList[T] {
+	if l.root == nil {
+		l.root = &linkedListElement[T]{}
+	}
 	l.root.setNext(l.root)
 	l.root.setPrev(l.root)
 	l.len = 0
@@ -152,7 +171,7 @@ func (l *linkedList[T]) Front() LinkedListElement[T] {
 	if l.len == 0 {
 		return nil
 	}
-	return l.root.Next()
+	return l.root.next()
 }
 
 // Back returns the last element of list l or nil if the list is empty.
@@ -160,12 +179,12 @@ func (l *linkedList[T]) Back() LinkedListElement[T] {
 	if l.len == 0 {
 		return nil
 	}
-	return l.root.Prev()
+	return l.root.prev()
 }
 
 // lazyInit lazily initializes a zero List[T] value.
 func (l *linkedList[T]) lazyInit() {
-	if l.root.Next() == nil {
+	if l.root == nil || l.root.next() == nil {
 		l.Init()
 	}
 }
@@ -173,9 +192,9 @@ func (l *linkedList[T]) lazyInit() {
 // insert inserts e after at, increments l.len, and returns e.
 func (l *linkedList[T]) insert(e, at LinkedListElement[T]) LinkedListElement[T] {
 	e.setPrev(at)
-	e.setNext(at.Next())
-	e.Prev().setNext(e)
-	e.Next().setPrev(e)
+	e.setNext(at.next())
+	e.prev().setNext(e)
+	e.next().setPrev(e)
 	e.setList(l)
 	l.len++
 	return e
@@ -188,8 +207,8 @@ func (l *linkedList[T]) insertValue(v T, at LinkedListElement[T]) LinkedListElem
 
 // remove removes e from its list, decrements l.len
 func (l *linkedList[T]) remove(e LinkedListElement[T]) {
-	e.Prev().setNext(e.Next())
-	e.Next().setPrev(e.Prev())
+	e.prev().setNext(e.next())
+	e.next().setPrev(e.prev())
 	e.setNext(nil) // avoid memory leaks
 	e.setPrev(nil) // avoid memory leaks
 	e.setList(nil)
@@ -201,12 +220,12 @@ func (l *linkedList[T]) move(e, at LinkedListElement[T]) {
 	if e == at {
 		return
 	}
-	e.Prev().setNext(e.Next())
-	e.Next().setPrev(e.Prev())
+	e.prev().setNext(e.next())
+	e.next().setPrev(e.prev())
 	e.setPrev(at)
-	e.setNext(at.Next())
-	e.Prev().setNext(e)
-	e.Next().setPrev(e)
+	e.setNext(at.next())
+	e.prev().setNext(e)
+	e.next().setPrev(e)
 }
 
 // Remove removes e from l if e is an element of list l.
@@ -230,7 +249,7 @@ func (l *linkedList[T]) PushFront(v T) LinkedListElement[T] {
 // PushBack inserts a new element e with value v at the back of list l and returns e.
 func (l *linkedList[T]) PushBack(v T) LinkedListElement[T] {
 	l.lazyInit()
-	return l.insertValue(v, l.root.Prev())
+	return l.insertValue(v, l.root.prev())
 }
 
 // InsertBefore inserts a new element e with value v immediately before mark and returns e.
@@ -241,7 +260,7 @@ func (l *linkedList[T]) InsertBefore(v T, mark LinkedListElement[T]) LinkedListE
 		return nil
 	}
 	// see comment in List.Remove about initialization of l
-	return l.insertValue(v, mark.Prev())
+	return l.insertValue(v, mark.prev())
 }
 
 // InsertAfter inserts a new element e with value v immediately after mark and returns e.
@@ -259,7 +278,7 @@ func (l *linkedList[T]) InsertAfter(v T, mark LinkedListElement[T]) LinkedListEl
 // If e is not an element of l, the list is not modified.
 // The element must not be nil.
 func (l *linkedList[T]) MoveToFront(e LinkedListElement[T]) {
-	if e.List() != l || l.root.Next() == e {
+	if e.List() != l || l.root.next() == e {
 		return
 	}
 	// see comment in List.Remove about initialization of l
@@ -270,11 +289,11 @@ func (l *linkedList[T]) MoveToFront(e LinkedListElement[T]) {
 // If e is not an element of l, the list is not modified.
 // The element must not be nil.
 func (l *linkedList[T]) MoveToBack(e LinkedListElement[T]) {
-	if e.List() != l || l.root.Prev() == e {
+	if e.List() != l || l.root.prev() == e {
 		return
 	}
 	// see comment in List.Remove about initialization of l
-	l.move(e, l.root.Prev())
+	l.move(e, l.root.prev())
 }
 
 // MoveBefore moves element e to its new position before mark.
@@ -284,7 +303,7 @@ func (l *linkedList[T]) MoveBefore(e, mark LinkedListElement[T]) {
 	if e.List() != l || e == mark || mark.List() != l {
 		return
 	}
-	l.move(e, mark.Prev())
+	l.move(e, mark.prev())
 }
 
 // MoveAfter moves element e to its new position after mark.
@@ -302,7 +321,7 @@ func (l *linkedList[T]) MoveAfter(e, mark LinkedListElement[T]) {
 func (l *linkedList[T]) PushBackList(other LinkedList[T]) {
 	l.lazyInit()
 	for i, e := other.Len(), other.Front(); i > 0; i, e = i-1, e.Next() {
-		l.insertValue(e.Value(), l.root.Prev())
+		l.insertValue(e.Value(), l.root.prev())
 	}
 }
 
@@ -314,3 +333,25 @@ func (l *linkedList[T]) PushFrontList(other LinkedList[T]) {
 		l.insertValue(e.Value(), l.root)
 	}
 }
+
+func (l *linkedList[T]) All() func(yield func(int, T) bool) {
+	return func(yield func(int, T) bool) {
+		i := 0
+		for e := l.Front(); e != nil; e = e.Next() {
+			if !yield(i, e.Value()) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+func (l *linkedList[T]) Values() func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		for e := l.Front(); e != nil; e = e.Next() {
+			if !yield(e.Value()) {
+				return
+			}
+		}
+	}
+}
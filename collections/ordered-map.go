@@ -0,0 +1,277 @@
+package collections
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// OrderedMap is a map that remembers the order that keys were first inserted in, for
+// deterministic iteration and serialization.
+type OrderedMap[K comparable, V any] interface {
+	// Set sets the value for a key. A new key is added to the end of the order, and
+	// an existing key keeps its position.
+	Set(key K, value V)
+	// Get gets the value for a key, and a bool of whether the key exists.
+	Get(key K) (V, bool)
+	// Has returns a bool of whether the key exists.
+	Has(key K) bool
+	// Delete will delete a key if it exists, and returns a bool of whether
+	// the key existed and was deleted.
+	Delete(key K) bool
+	// Len returns the number of keys.
+	Len() int
+	// Keys returns a slice of all keys, in order.
+	Keys() []K
+	// Values returns a slice of all values, in the order of their keys.
+	Values() []V
+	// Iterator will return a closure (iterator) that will return the next key and value
+	// each time it's called, in order. After the last element has been returned, the
+	// closure will return zero-values and false for 'ok'. It iterates over the keys that
+	// existed when it was created, skipping any that are deleted before they're reached.
+	Iterator() func() (k K, v V, ok bool)
+	// All returns an iterator over the keys and values, in order. Keys that are
+	// deleted during iteration, before they're reached, are skipped.
+	All() func(yield func(K, V) bool)
+	// MarshalJSON marshals the map to a JSON object with the keys in order. The keys must
+	// be strings, integers, or implement encoding.TextMarshaler, as with a regular map.
+	MarshalJSON() ([]byte, error)
+	// UnmarshalJSON unmarshals a JSON object into the map, adding its keys in the order
+	// that they appear in the object.
+	UnmarshalJSON(data []byte) error
+}
+
+type orderedMapEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *orderedMapEntry[K, V]
+}
+
+// orderedMap keeps its entries in a doubly linked list, in insertion
+// order, with a map from each key to its entry.
+type orderedMap[K comparable, V any] struct {
+	entries     map[K]*orderedMapEntry[K, V]
+	front, back *orderedMapEntry[K, V]
+}
+
+func NewOrderedMap[K comparable, V any]() OrderedMap[K, V] {
+	return &orderedMap[K, V]{
+		entries: map[K]*orderedMapEntry[K, V]{},
+	}
+}
+
+func (om *orderedMap[K, V]) Set(key K, value V) {
+	if entry, ok := om.entries[key]; ok {
+		entry.value = value
+		return
+	}
+	entry := &orderedMapEntry[K, V]{
+		key:   key,
+		value: value,
+		prev:  om.back,
+	}
+	if om.back == nil {
+		om.front = entry
+	} else {
+		om.back.next = entry
+	}
+	om.back = entry
+	om.entries[key] = entry
+}
+
+func (om *orderedMap[K, V]) Get(key K) (V, bool) {
+	entry, ok := om.entries[key]
+	if !ok {
+		var v V
+		return v, false
+	}
+	return entry.value, true
+}
+
+func (om *orderedMap[K, V]) Has(key K) bool {
+	_, ok := om.entries[key]
+	return ok
+}
+
+func (om *orderedMap[K, V]) Delete(key K) bool {
+	entry, ok := om.entries[key]
+	if !ok {
+		return false
+	}
+	if entry.prev == nil {
+		om.front = entry.next
+	} else {
+		entry.prev.next = entry.next
+	}
+	if entry.next == nil {
+		om.back = entry.prev
+	} else {
+		entry.next.prev = entry.prev
+	}
+	delete(om.entries, key)
+	return true
+}
+
+func (om *orderedMap[K, V]) Len() int {
+	return len(om.entries)
+}
+
+func (om *orderedMap[K, V]) Keys() []K {
+	keys := make([]K, 0, len(om.entries))
+	for e := om.front; e != nil; e = e.next {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+func (om *orderedMap[K, V]) Values() []V {
+	values := make([]V, 0, len(om.entries))
+	for e := om.front; e != nil; e = e.next {
+		values = append(values, e.value)
+	}
+	return values
+}
+
+func (om *orderedMap[K, V]) Iterator() func() (k K, v V, ok bool) {
+	keys := om.Keys()
+	i := 0
+	return func() (k K, v V, ok bool) {
+		for i < len(keys) {
+			key := keys[i]
+			i++
+			if entry, ok := om.entries[key]; ok {
+				return key, entry.value, true
+			}
+		}
+		return k, v, false
+	}
+}
+
+func (om *orderedMap[K, V]) All() func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		for entry := om.front; entry != nil; entry = entry.next {
+			if !yield(entry.key, entry.value) {
+				return
+			}
+			// If the entry was deleted during the yield, continue from the next entry
+			// that's still in the map
+			for entry.next != nil && !om.has(entry.next) {
+				entry = entry.next
+			}
+		}
+	}
+}
+
+// has returns a bool of whether the entry is still in the map.
+func (om *orderedMap[K, V]) has(entry *orderedMapEntry[K, V]) bool {
+	current, ok := om.entries[entry.key]
+	return ok && current == entry
+}
+
+// marshalJSONKey converts a key to the string that it has in a JSON object.
+func marshalJSONKey[K comparable](key K) (string, error) {
+	if k, ok := any(key).(string); ok {
+		return k, nil
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	// Strings and TextMarshalers are marshaled as JSON strings
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	// Integers are marshaled as numbers
+	if _, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+		return string(data), nil
+	}
+	if _, err := strconv.ParseUint(string(data), 10, 64); err == nil {
+		return string(data), nil
+	}
+	return "", fmt.Errorf("unsupported JSON object key type: %T", key)
+}
+
+// unmarshalJSONKey converts the string of a key in a JSON object to a key.
+func unmarshalJSONKey[K comparable](s string) (K, error) {
+	var key K
+	// Strings and TextUnmarshalers are unmarshaled from JSON strings
+	quoted, err := json.Marshal(s)
+	if err != nil {
+		return key, err
+	}
+	if err := json.Unmarshal(quoted, &key); err == nil {
+		return key, nil
+	}
+	// Integers are unmarshaled from numbers
+	if err := json.Unmarshal([]byte(s), &key); err != nil {
+		return key, fmt.Errorf("cannot unmarshal JSON object key %q into %T", s, key)
+	}
+	return key, nil
+}
+
+func (om *orderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for entry := om.front; entry != nil; entry = entry.next {
+		if entry != om.front {
+			buf.WriteByte(',')
+		}
+		key, err := marshalJSONKey(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		keyData, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueData, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		buf.Write(valueData)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (om *orderedMap[K, V]) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		// A JSON null leaves the map unchanged, as with a regular map
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("cannot unmarshal JSON %v into an ordered map", token)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, err := unmarshalJSONKey[K](token.(string))
+		if err != nil {
+			return err
+		}
+		var value V
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		om.Set(key, value)
+	}
+	// Consume the closing brace
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	return nil
+}
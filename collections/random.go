@@ -0,0 +1,104 @@
+package collections
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Shuffle will randomly reorder the given slice in place.
+func Shuffle[T any](in []T) {
+	rand.Shuffle(len(in), func(i, j int) { in[i], in[j] = in[j], in[i] })
+}
+
+// ShuffleCopy will return a randomly reordered copy of the given slice. The
+// original slice will not be modified.
+func ShuffleCopy[T any](in []T) []T {
+	if in == nil {
+		return nil
+	}
+	out := CopySlice(in)
+	Shuffle(out)
+	return out
+}
+
+// SampleN returns n randomly chosen elements of a slice, without replacement (no element
+// is chosen more than once), in random order. If n is at least the length of the slice,
+// all elements are returned in random order. The original slice will not be modified.
+func SampleN[T any](in []T, n int) []T {
+	if n > len(in) {
+		n = len(in)
+	}
+	if n < 0 {
+		n = 0
+	}
+	// A partial Fisher-Yates shuffle of a copy, which only needs to shuffle the first n elements
+	out := CopySlice(in)
+	for i := 0; i < n; i++ {
+		j := i + rand.Intn(len(out)-i)
+		out[i], out[j] = out[j], out[i]
+	}
+	return out[:n]
+}
+
+// WeightedItem is an item for a WeightedChooser, with the weight
+// that determines how likely it is to be picked.
+type WeightedItem[T any] struct {
+	Item   T
+	Weight float64
+}
+
+// WeightedChooser randomly picks items, where the chance of each item being picked
+// is proportional to its weight.
+type WeightedChooser[T any] interface {
+	// Pick picks an item, in O(log n) time.
+	Pick() T
+	// Len returns the number of items.
+	Len() int
+}
+
+type weightedChooser[T any] struct {
+	items []T
+	// The cumulative weights of the items, where each is the total
+	// weight of the item and all items before it
+	cumulative []float64
+}
+
+// NewWeightedChooser creates a WeightedChooser from items with their weights. It returns an error
+// if there are no items, any weight is negative, or the weights are all 0. Items with a weight of
+// 0 are never picked.
+func NewWeightedChooser[T any](items []WeightedItem[T]) (WeightedChooser[T], stackerr.Error) {
+	if len(items) == 0 {
+		return nil, stackerr.Errorf("at least one item is required")
+	}
+	wc := &weightedChooser[T]{
+		items:      make([]T, len(items)),
+		cumulative: make([]float64, len(items)),
+	}
+	total := 0.0
+	for i, item := range items {
+		if item.Weight < 0 {
+			return nil, stackerr.Errorf("item %d has a negative weight (%v)", i, item.Weight)
+		}
+		total += item.Weight
+		wc.items[i] = item.Item
+		wc.cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, stackerr.Errorf("at least one item must have a weight greater than 0")
+	}
+	return wc, nil
+}
+
+func (wc *weightedChooser[T]) Pick() T {
+	target := rand.Float64() * wc.cumulative[len(wc.cumulative)-1]
+	// Find the first item whose cumulative weight is greater than the target, which skips items
+	// with a weight of 0 (since their cumulative weight is the same as the item before them)
+	i := sort.Search(len(wc.cumulative), func(i int) bool { return wc.cumulative[i] > target })
+	return wc.items[i]
+}
+
+func (wc *weightedChooser[T]) Len() int {
+	return len(wc.items)
+}
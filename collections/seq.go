@@ -0,0 +1,48 @@
+package collections
+
+// The iterator functions in this package use unnamed function types that are identical to
+// iter.Seq and iter.Seq2, so they can be used with range-over-func and the iter package
+// on Go 1.23+ without this package requiring it.
+
+// ToSeq returns an iterator over the values of a slice, in order.
+func ToSeq[T any](in []T) func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		for _, v := range in {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ToSeq2 returns an iterator over the keys and values of a map, in no particular order.
+func ToSeq2[K comparable, V any](in map[K]V) func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		for k, v := range in {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// FromSeq collects the values of an iterator into a slice.
+func FromSeq[T any](seq func(yield func(T) bool)) []T {
+	out := []T{}
+	seq(func(v T) bool {
+		out = append(out, v)
+		return true
+	})
+	return out
+}
+
+// FromSeq2 collects the keys and values of an iterator into a map. If a key
+// appears more than once, the last value for it is used.
+func FromSeq2[K comparable, V any](seq func(yield func(K, V) bool)) map[K]V {
+	out := map[K]V{}
+	seq(func(k K, v V) bool {
+		out[k] = v
+		return true
+	})
+	return out
+}
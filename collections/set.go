@@ -0,0 +1,153 @@
+package collections
+
+// Set is an interface that represents a set of unique values, with set
+// algebra operations. The operations that return a set always return a
+// new set, and don't modify the sets they're called on.
+type Set[T comparable] interface {
+	// Add will add values to the set.
+	Add(values ...T)
+	// Remove will remove values from the set if they exist, and returns the
+	// number of values that existed and were removed.
+	Remove(values ...T) int
+	// Contains returns a bool of whether the value is in the set.
+	Contains(value T) bool
+	// Len returns the number of values in the set.
+	Len() int
+	// ToSlice returns a slice of all values in the set, in no particular order.
+	ToSlice() []T
+	// Clone returns a copy of the set.
+	Clone() Set[T]
+	// Union returns a set of the values that are in either set.
+	Union(other Set[T]) Set[T]
+	// Intersect returns a set of the values that are in both sets.
+	Intersect(other Set[T]) Set[T]
+	// Difference returns a set of the values that are in this set, but not the other.
+	Difference(other Set[T]) Set[T]
+	// SymmetricDifference returns a set of the values that are in exactly one of the sets.
+	SymmetricDifference(other Set[T]) Set[T]
+	// Subset returns a bool of whether all values in this set are in the other set.
+	Subset(other Set[T]) bool
+	// Equal returns a bool of whether both sets have the same values.
+	Equal(other Set[T]) bool
+	// All returns an iterator over the values, in no particular order.
+	All() func(yield func(T) bool)
+}
+
+type set[T comparable] map[T]struct{}
+
+// NewSet creates a set containing the unique values of a slice.
+func NewSet[T comparable](initial []T) Set[T] {
+	s := make(set[T], len(initial))
+	for _, v := range initial {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+func NewSetPreallocated[T comparable](size int) Set[T] {
+	return make(set[T], size)
+}
+
+func (s set[T]) Add(values ...T) {
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+}
+
+func (s set[T]) Remove(values ...T) int {
+	removed := 0
+	for _, v := range values {
+		if _, ok := s[v]; ok {
+			delete(s, v)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s set[T]) Contains(value T) bool {
+	_, ok := s[value]
+	return ok
+}
+
+func (s set[T]) Len() int {
+	return len(s)
+}
+
+func (s set[T]) ToSlice() []T {
+	values := make([]T, 0, len(s))
+	for v := range s {
+		values = append(values, v)
+	}
+	return values
+}
+
+func (s set[T]) Clone() Set[T] {
+	out := make(set[T], len(s))
+	for v := range s {
+		out[v] = struct{}{}
+	}
+	return out
+}
+
+func (s set[T]) Union(other Set[T]) Set[T] {
+	out := s.Clone()
+	out.Add(other.ToSlice()...)
+	return out
+}
+
+func (s set[T]) Intersect(other Set[T]) Set[T] {
+	out := set[T]{}
+	for v := range s {
+		if other.Contains(v) {
+			out[v] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (s set[T]) Difference(other Set[T]) Set[T] {
+	out := set[T]{}
+	for v := range s {
+		if !other.Contains(v) {
+			out[v] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (s set[T]) SymmetricDifference(other Set[T]) Set[T] {
+	out := s.Difference(other)
+	for _, v := range other.ToSlice() {
+		if !s.Contains(v) {
+			out.Add(v)
+		}
+	}
+	return out
+}
+
+func (s set[T]) Subset(other Set[T]) bool {
+	if len(s) > other.Len() {
+		return false
+	}
+	for v := range s {
+		if !other.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s set[T]) Equal(other Set[T]) bool {
+	return len(s) == other.Len() && s.Subset(other)
+}
+
+func (s set[T]) All() func(yield func(T) bool) {
+	return func(yield func(T) bool) {
+		for v := range s {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
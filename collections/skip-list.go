@@ -0,0 +1,235 @@
+package collections
+
+import (
+	"math/rand"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+)
+
+// SkipListEntry is a key and value from a SkipList.
+type SkipListEntry[K constraints.Ordered, V any] struct {
+	Key   K
+	Value V
+}
+
+// SkipList is a map that keeps its keys in sorted order, with O(log n) expected time
+// for lookups, insertions, deletions, floor/ceiling lookups, and rank queries.
+type SkipList[K constraints.Ordered, V any] interface {
+	// Set sets the value for a key.
+	Set(key K, value V)
+	// Get gets the value for a key, and a bool of whether the key exists.
+	Get(key K) (V, bool)
+	// Delete will delete a key if it exists, and returns a bool of whether
+	// the key existed and was deleted.
+	Delete(key K) bool
+	// Len returns the number of keys.
+	Len() int
+	// Floor returns the entry with the greatest key that's less than or equal to
+	// the given key, and a bool of whether there is one.
+	Floor(key K) (SkipListEntry[K, V], bool)
+	// Ceiling returns the entry with the smallest key that's greater than or equal
+	// to the given key, and a bool of whether there is one.
+	Ceiling(key K) (SkipListEntry[K, V], bool)
+	// Range returns the entries with keys from `from` (inclusive) to `to` (exclusive),
+	// in sorted order.
+	Range(from K, to K) []SkipListEntry[K, V]
+	// Rank returns the number of keys that are less than the given key (which is its
+	// index in sorted order, if it exists), and a bool of whether the key exists.
+	Rank(key K) (rank int, found bool)
+	// AtRank returns the entry at an index in sorted order, and a bool of whether
+	// the index is in range.
+	AtRank(rank int) (SkipListEntry[K, V], bool)
+	// All returns an iterator over the keys and values, in sorted order.
+	All() func(yield func(K, V) bool)
+}
+
+const (
+	skipListMaxLevel = 32
+	// The chance of a node having each level above the first
+	skipListP = 0.25
+)
+
+type skipListNode[K constraints.Ordered, V any] struct {
+	key   K
+	value V
+	next  []*skipListNode[K, V]
+	// The number of nodes that each link skips over (including the node it links to),
+	// for rank queries
+	span []int
+}
+
+type skipList[K constraints.Ordered, V any] struct {
+	head  *skipListNode[K, V]
+	level int
+	len   int
+}
+
+func NewSkipList[K constraints.Ordered, V any]() SkipList[K, V] {
+	return &skipList[K, V]{
+		head: &skipListNode[K, V]{
+			next: make([]*skipListNode[K, V], skipListMaxLevel),
+			span: make([]int, skipListMaxLevel),
+		},
+		level: 1,
+	}
+}
+
+func randomSkipListLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// findLess gets the last node with a key less than the given key (which may be the head)
+// at each level, and the rank (position, where the head is 0) of each of those nodes.
+func (sl *skipList[K, V]) findLess(key K) (update []*skipListNode[K, V], ranks []int) {
+	update = make([]*skipListNode[K, V], skipListMaxLevel)
+	ranks = make([]int, skipListMaxLevel)
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			ranks[i] = ranks[i+1]
+		}
+		for x.next[i] != nil && x.next[i].key < key {
+			ranks[i] += x.span[i]
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	return update, ranks
+}
+
+func (sl *skipList[K, V]) Set(key K, value V) {
+	update, ranks := sl.findLess(key)
+	if next := update[0].next[0]; next != nil && next.key == key {
+		next.value = value
+		return
+	}
+	level := randomSkipListLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			ranks[i] = 0
+			update[i] = sl.head
+			sl.head.span[i] = sl.len
+		}
+		sl.level = level
+	}
+	n := &skipListNode[K, V]{
+		key:   key,
+		value: value,
+		next:  make([]*skipListNode[K, V], level),
+		span:  make([]int, level),
+	}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+		n.span[i] = update[i].span[i] - (ranks[0] - ranks[i])
+		update[i].span[i] = ranks[0] - ranks[i] + 1
+	}
+	// The links above the new node's levels now skip over it too
+	for i := level; i < sl.level; i++ {
+		update[i].span[i]++
+	}
+	sl.len++
+}
+
+func (sl *skipList[K, V]) Get(key K) (V, bool) {
+	update, _ := sl.findLess(key)
+	if next := update[0].next[0]; next != nil && next.key == key {
+		return next.value, true
+	}
+	var v V
+	return v, false
+}
+
+func (sl *skipList[K, V]) Delete(key K) bool {
+	update, _ := sl.findLess(key)
+	x := update[0].next[0]
+	if x == nil || x.key != key {
+		return false
+	}
+	for i := 0; i < sl.level; i++ {
+		if update[i].next[i] == x {
+			update[i].span[i] += x.span[i] - 1
+			update[i].next[i] = x.next[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+	for sl.level > 1 && sl.head.next[sl.level-1] == nil {
+		sl.level--
+	}
+	sl.len--
+	return true
+}
+
+func (sl *skipList[K, V]) Len() int {
+	return sl.len
+}
+
+func (sl *skipList[K, V]) Floor(key K) (SkipListEntry[K, V], bool) {
+	update, _ := sl.findLess(key)
+	if next := update[0].next[0]; next != nil && next.key == key {
+		return SkipListEntry[K, V]{Key: next.key, Value: next.value}, true
+	}
+	if update[0] == sl.head {
+		return SkipListEntry[K, V]{}, false
+	}
+	return SkipListEntry[K, V]{Key: update[0].key, Value: update[0].value}, true
+}
+
+func (sl *skipList[K, V]) Ceiling(key K) (SkipListEntry[K, V], bool) {
+	update, _ := sl.findLess(key)
+	next := update[0].next[0]
+	if next == nil {
+		return SkipListEntry[K, V]{}, false
+	}
+	return SkipListEntry[K, V]{Key: next.key, Value: next.value}, true
+}
+
+func (sl *skipList[K, V]) Range(from K, to K) []SkipListEntry[K, V] {
+	out := []SkipListEntry[K, V]{}
+	update, _ := sl.findLess(from)
+	for x := update[0].next[0]; x != nil && x.key < to; x = x.next[0] {
+		out = append(out, SkipListEntry[K, V]{Key: x.key, Value: x.value})
+	}
+	return out
+}
+
+func (sl *skipList[K, V]) Rank(key K) (rank int, found bool) {
+	update, ranks := sl.findLess(key)
+	next := update[0].next[0]
+	return ranks[0], next != nil && next.key == key
+}
+
+func (sl *skipList[K, V]) AtRank(rank int) (SkipListEntry[K, V], bool) {
+	if rank < 0 || rank >= sl.len {
+		return SkipListEntry[K, V]{}, false
+	}
+	// Positions start at 1 for the first node, since the head is at 0
+	target := rank + 1
+	traversed := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && traversed+x.span[i] <= target {
+			traversed += x.span[i]
+			x = x.next[i]
+		}
+		if traversed == target {
+			break
+		}
+	}
+	return SkipListEntry[K, V]{Key: x.key, Value: x.value}, true
+}
+
+func (sl *skipList[K, V]) All() func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		for x := sl.head.next[0]; x != nil; x = x.next[0] {
+			if !yield(x.key, x.value) {
+				return
+			}
+		}
+	}
+}
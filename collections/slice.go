@@ -4,6 +4,7 @@ import (
 	"sort"
 
 	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-common/numbers"
 	"github.com/Invicton-Labs/go-stackerr"
 )
 
@@ -17,73 +18,147 @@ func CopySlice[T any](src []T) []T {
 	return dst
 }
 
+// ReverseInPlace will reverse the order of the elements in the given slice.
+func ReverseInPlace[T any](in []T) {
+	for i, j := 0, len(in)-1; i < j; i, j = i+1, j-1 {
+		in[i], in[j] = in[j], in[i]
+	}
+}
+
+// ReverseCopy will return a copy of the given slice with the elements in reverse
+// order. The original slice will not be modified.
+func ReverseCopy[T any](in []T) []T {
+	if in == nil {
+		return nil
+	}
+	out := make([]T, len(in))
+	for i, v := range in {
+		out[len(in)-1-i] = v
+	}
+	return out
+}
+
+// RotateLeft will rotate the elements of the given slice n places to the left in place, so
+// that the element at index n becomes the first element and the first n elements move to the
+// end. The number of places can be more than the length of the slice, or negative to rotate
+// to the right.
+func RotateLeft[T any](in []T, n int) {
+	if len(in) == 0 {
+		return
+	}
+	n %= len(in)
+	if n < 0 {
+		n += len(in)
+	}
+	// Rotating is the same as reversing each part, then reversing the whole slice
+	ReverseInPlace(in[:n])
+	ReverseInPlace(in[n:])
+	ReverseInPlace(in)
+}
+
+// RotateRight will rotate the elements of the given slice n places to the right in place, so
+// that the last n elements move to the start. The number of places can be more than the length
+// of the slice, or negative to rotate to the left.
+func RotateRight[T any](in []T, n int) {
+	if len(in) == 0 {
+		return
+	}
+	RotateLeft(in, len(in)-n%len(in))
+}
+
+// PadTo will return a copy of the given slice with fill values appended to it until it has
+// the given length. If the slice is already at least that long, the copy is unchanged.
+func PadTo[T any](in []T, length int, fillValue T) []T {
+	out := make([]T, len(in), numbers.Max(len(in), length))
+	copy(out, in)
+	for len(out) < length {
+		out = append(out, fillValue)
+	}
+	return out
+}
+
 // IntersectionUnique returns the set of unique values (no duplicates) that are
-// present in each of the given slices.
+// present in each of the given slices, in the order that they first appear in
+// the first slice.
 func IntersectionUnique[T comparable](slices ...[]T) []T {
+	return IntersectionBy(func(value T) T { return value }, slices...)
+}
+
+// IntersectionBy returns the values whose keys (from a key function) are present in each of the
+// given slices, with one value per key (the first one in the first slice with that key), in the
+// order that they first appear in the first slice. It's useful for values that aren't comparable.
+func IntersectionBy[T any, K comparable](keyFunc func(value T) (key K), slices ...[]T) []T {
 	// If no input is provided, return an empty slice
 	if len(slices) == 0 {
 		return []T{}
 	}
 
-	// If only one input is provided, return a copy of that slice
-	if len(slices) == 1 {
-		return SliceUnique(slices[0])
-	}
-
-	// Create a hashmap
-	h := map[T]struct{}{}
-
-	// Put each value from the first slice into a hashmap
+	// Count the number of slices that each key of the first slice is in
+	counts := map[K]int{}
 	for _, v := range slices[0] {
-		h[v] = struct{}{}
+		counts[keyFunc(v)] = 1
 	}
 
-	// Loop through each slice after the first one, since we preloaded our hashmap with it
-	for i := 1; i > len(slices); i++ {
-
-		// Create a new hashmap for storing the results of this intersection
-		r := map[T]struct{}{}
-
-		// Intersect the current slice with the existing hashmap
+	// Loop through each slice after the first one, since we preloaded our counts with it
+	for i := 1; i < len(slices); i++ {
+		matched := 0
 		for _, v := range slices[i] {
-			if _, ok := h[v]; ok {
-				r[v] = struct{}{}
+			key := keyFunc(v)
+			// Only count each key once per slice, and only if it's in all previous slices
+			if count, ok := counts[key]; ok && count == i {
+				counts[key]++
+				matched++
 			}
 		}
 
 		// If our intersection so far has no values, we may as well stop
 		// since it will never gain values.
-		if len(r) == 0 {
+		if matched == 0 {
 			return []T{}
 		}
-
-		// Assign the new hashmap to the hashmap variable
-		h = r
 	}
 
-	return MapKeys(h)
+	// Collect the values from the first slice whose keys are in every slice
+	out := []T{}
+	for _, v := range slices[0] {
+		key := keyFunc(v)
+		if counts[key] == len(slices) {
+			out = append(out, v)
+			// Remove the key, so that it's only added once
+			delete(counts, key)
+		}
+	}
+	return out
 }
 
 // UnionUnique returns the set of unique values (no duplicates) that are
-// present in at least one of the given slices.
+// present in at least one of the given slices, in the order that they
+// first appear.
 func UnionUnique[T comparable](slices ...[]T) []T {
-	// If no input is provided, return an empty slice
-	if len(slices) == 0 {
-		return []T{}
-	}
+	return UnionBy(func(value T) T { return value }, slices...)
+}
 
-	// Create a hashmap
-	h := map[T]struct{}{}
+// UnionBy returns the values whose keys (from a key function) are present in at least one of
+// the given slices, with one value per key (the first one with that key), in the order that they
+// first appear. It's useful for values that aren't comparable.
+func UnionBy[T any, K comparable](keyFunc func(value T) (key K), slices ...[]T) []T {
+	// Create a hashmap of the keys that have been added
+	h := map[K]struct{}{}
+	out := []T{}
 
 	// Loop through each slice
-	for i := 0; i > len(slices); i++ {
-		// Add each of the values to the hashmap
-		for _, v := range slices[i] {
-			h[v] = struct{}{}
+	for _, slice := range slices {
+		// Add each of the values whose key hasn't been added yet
+		for _, v := range slice {
+			key := keyFunc(v)
+			if _, ok := h[key]; !ok {
+				h[key] = struct{}{}
+				out = append(out, v)
+			}
 		}
 	}
 
-	return MapKeys(h)
+	return out
 }
 
 // Flatten2D flattens a 2-dimensional slice of type T into a 1-dimensional slice of type T
@@ -112,6 +187,37 @@ func Flatten3D[T any](slice [][][]T) []T {
 	return r
 }
 
+// Pair is a pair of values, of possibly different types.
+type Pair[A any, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs up the elements of two slices by index. If the slices are of
+// unequal length, the extra elements of the longer slice are ignored.
+func Zip[A any, B any](a []A, b []B) (pairs []Pair[A, B]) {
+	pairs = make([]Pair[A, B], numbers.Min(len(a), len(b)))
+	for i := range pairs {
+		pairs[i] = Pair[A, B]{
+			First:  a[i],
+			Second: b[i],
+		}
+	}
+	return pairs
+}
+
+// Unzip splits a slice of pairs into a slice of the first values
+// and a slice of the second values. It's the inverse of Zip.
+func Unzip[A any, B any](pairs []Pair[A, B]) (a []A, b []B) {
+	a = make([]A, len(pairs))
+	b = make([]B, len(pairs))
+	for i, pair := range pairs {
+		a[i] = pair.First
+		b[i] = pair.Second
+	}
+	return a, b
+}
+
 // FilterSlice creates a new slice of elements that meet a given condition function.
 func FilterSlice[T any](in []T, filterFunc func(value T) (include bool)) []T {
 	if in == nil {
@@ -154,17 +260,114 @@ func TransformSliceWithErr[In any, Out any](in []In, transformationFunc func(val
 	return out, nil
 }
 
+// GroupBy groups the elements of a slice by a key from a key function. The elements in each
+// group are in the order that they appear in the input slice.
+func GroupBy[T any, K comparable](in []T, keyFunc func(value T) (key K)) (groups map[K][]T) {
+	groups = map[K][]T{}
+	for _, v := range in {
+		key := keyFunc(v)
+		groups[key] = append(groups[key], v)
+	}
+	return groups
+}
+
+// GroupByWithErr groups the elements of a slice by a key from a key function, and allows
+// the key function to return an error that will cancel the execution.
+func GroupByWithErr[T any, K comparable](in []T, keyFunc func(value T) (key K, err stackerr.Error)) (groups map[K][]T, err stackerr.Error) {
+	groups = map[K][]T{}
+	for _, v := range in {
+		key, err := keyFunc(v)
+		if err != nil {
+			return nil, err
+		}
+		groups[key] = append(groups[key], v)
+	}
+	return groups, nil
+}
+
+// Partition splits a slice into the elements that meet a given condition function and the
+// elements that don't, each in the order that they appear in the input slice.
+func Partition[T any](in []T, predicate func(value T) (match bool)) (matched []T, unmatched []T) {
+	matched = []T{}
+	unmatched = []T{}
+	for _, v := range in {
+		if predicate(v) {
+			matched = append(matched, v)
+		} else {
+			unmatched = append(unmatched, v)
+		}
+	}
+	return matched, unmatched
+}
+
+// PartitionWithErr splits a slice into the elements that meet a given condition function and
+// the elements that don't, and allows the condition function to return an error that will cancel
+// the execution.
+func PartitionWithErr[T any](in []T, predicate func(value T) (match bool, err stackerr.Error)) (matched []T, unmatched []T, err stackerr.Error) {
+	matched = []T{}
+	unmatched = []T{}
+	for _, v := range in {
+		match, err := predicate(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if match {
+			matched = append(matched, v)
+		} else {
+			unmatched = append(unmatched, v)
+		}
+	}
+	return matched, unmatched, nil
+}
+
+// Reduce reduces a slice to a single value, by calling a reduce function with the
+// accumulated value (starting with the initial value) and each element in order.
+func Reduce[T any, Acc any](in []T, initial Acc, reduceFunc func(accumulated Acc, value T) Acc) Acc {
+	accumulated := initial
+	for _, v := range in {
+		accumulated = reduceFunc(accumulated, v)
+	}
+	return accumulated
+}
+
+// ReduceWithErr reduces a slice to a single value, and allows the reduce function
+// to return an error that will cancel the execution.
+func ReduceWithErr[T any, Acc any](in []T, initial Acc, reduceFunc func(accumulated Acc, value T) (Acc, stackerr.Error)) (Acc, stackerr.Error) {
+	accumulated := initial
+	for _, v := range in {
+		var err stackerr.Error
+		accumulated, err = reduceFunc(accumulated, v)
+		if err != nil {
+			var zero Acc
+			return zero, err
+		}
+	}
+	return accumulated, nil
+}
+
 // SliceUnique will get a new slice containing all unique/distinct values in the input slice,
-// in the order that they appear.
+// in the order that they first appear.
 func SliceUnique[T comparable](in []T) (out []T) {
+	return SliceUniqueBy(in, func(value T) T { return value })
+}
+
+// SliceUniqueBy will get a new slice containing the first value in the input slice for each
+// distinct key (from a key function), in the order that they appear. It's useful for
+// deduplicating values that aren't comparable, or by a field.
+func SliceUniqueBy[T any, K comparable](in []T, keyFunc func(value T) (key K)) (out []T) {
 	if in == nil {
 		return nil
 	}
-	m := make(map[T]struct{}, len(in))
+	seen := make(map[K]struct{}, len(in))
+	out = []T{}
 	for _, v := range in {
-		m[v] = struct{}{}
+		key := keyFunc(v)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			out = append(out, v)
+		}
 	}
-	return MapKeys(m)
+	return out
 }
 
 // TransformSliceToMap transforms a slice of elements to a map of elements using a given transformation function.
@@ -308,6 +511,121 @@ func SortSliceDescendingCopy[SliceType constraints.Ordered](in []SliceType) (sor
 	return sorted
 }
 
+// SortSliceBy will sort the given slice using a less function, leaving elements
+// that are equal (neither is less than the other) where they are (stable sort).
+func SortSliceBy[SliceType any](in []SliceType, less func(a SliceType, b SliceType) bool) {
+	if in == nil {
+		return
+	}
+	sort.SliceStable(in, func(i, j int) bool { return less(in[i], in[j]) })
+}
+
+// SortSliceByCopy will return a copy of the given slice, sorted using a less function, leaving
+// elements that are equal where they are (stable sort). The original slice will not be modified.
+func SortSliceByCopy[SliceType any](in []SliceType, less func(a SliceType, b SliceType) bool) (sorted []SliceType) {
+	if in == nil {
+		return nil
+	}
+	sorted = CopySlice(in)
+	SortSliceBy(sorted, less)
+	return sorted
+}
+
+// SortKey compares two elements by a single key, for sorting by multiple keys with
+// SortSliceByKeys. It returns a negative number if a sorts before b, a positive
+// number if a sorts after b, and 0 if they're equal by this key.
+type SortKey[SliceType any] func(a SliceType, b SliceType) int
+
+// SortKeyAscending creates a SortKey that sorts elements by a key in ascending order.
+func SortKeyAscending[SliceType any, KeyType constraints.Ordered](keyFunc func(value SliceType) KeyType) SortKey[SliceType] {
+	return func(a SliceType, b SliceType) int {
+		ka, kb := keyFunc(a), keyFunc(b)
+		if ka < kb {
+			return -1
+		}
+		if ka > kb {
+			return 1
+		}
+		return 0
+	}
+}
+
+// SortKeyDescending creates a SortKey that sorts elements by a key in descending order.
+func SortKeyDescending[SliceType any, KeyType constraints.Ordered](keyFunc func(value SliceType) KeyType) SortKey[SliceType] {
+	ascending := SortKeyAscending(keyFunc)
+	return func(a SliceType, b SliceType) int {
+		return -ascending(a, b)
+	}
+}
+
+// sortKeysLess creates a less function that compares elements by each key
+// in turn, until one of the keys isn't equal.
+func sortKeysLess[SliceType any](keys []SortKey[SliceType]) func(a SliceType, b SliceType) bool {
+	return func(a SliceType, b SliceType) bool {
+		for _, key := range keys {
+			if c := key(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}
+}
+
+// SortSliceByKeys will sort the given slice by multiple keys, where each key is only used for
+// elements that are equal by all previous keys. Elements that are equal by all keys are left
+// where they are (stable sort).
+func SortSliceByKeys[SliceType any](in []SliceType, keys ...SortKey[SliceType]) {
+	SortSliceBy(in, sortKeysLess(keys))
+}
+
+// SortSliceByKeysCopy will return a copy of the given slice, sorted by multiple keys (see
+// SortSliceByKeys). The original slice will not be modified.
+func SortSliceByKeysCopy[SliceType any](in []SliceType, keys ...SortKey[SliceType]) (sorted []SliceType) {
+	return SortSliceByCopy(in, sortKeysLess(keys))
+}
+
+// BinarySearch searches for a target value in a slice that's sorted in ascending order. It returns
+// the index where the target is, or where it would be inserted to keep the slice sorted if it's
+// not found, and a bool of whether it was found. If the target is in the slice more than once,
+// the index of the first one is returned.
+func BinarySearch[SliceType constraints.Ordered](sorted []SliceType, target SliceType) (index int, found bool) {
+	index = sort.Search(len(sorted), func(i int) bool { return sorted[i] >= target })
+	return index, index < len(sorted) && sorted[index] == target
+}
+
+// BinarySearchFunc is the same as BinarySearch, except that it uses a comparison function that
+// returns a negative number if an element sorts before the target, a positive number if it sorts
+// after the target, and 0 if it matches the target. The slice must be sorted by the same order.
+func BinarySearchFunc[SliceType any, TargetType any](sorted []SliceType, target TargetType, cmp func(element SliceType, target TargetType) int) (index int, found bool) {
+	index = sort.Search(len(sorted), func(i int) bool { return cmp(sorted[i], target) >= 0 })
+	return index, index < len(sorted) && cmp(sorted[index], target) == 0
+}
+
+// InsertSorted inserts a value into a slice that's sorted in ascending order, keeping it
+// sorted, and returns the updated slice. The value is inserted after any equal values. As
+// with append, the input slice may be modified, so the returned slice should be used instead.
+func InsertSorted[SliceType constraints.Ordered](sorted []SliceType, value SliceType) []SliceType {
+	index := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })
+	return insertAt(sorted, index, value)
+}
+
+// InsertSortedFunc is the same as InsertSorted, except that it uses a comparison function
+// that returns a negative number if a sorts before b, a positive number if a sorts after
+// b, and 0 if they're equal. The slice must be sorted by the same order.
+func InsertSortedFunc[SliceType any](sorted []SliceType, value SliceType, cmp func(a SliceType, b SliceType) int) []SliceType {
+	index := sort.Search(len(sorted), func(i int) bool { return cmp(sorted[i], value) > 0 })
+	return insertAt(sorted, index, value)
+}
+
+// insertAt inserts a value into a slice at an index, shifting the later elements along.
+func insertAt[SliceType any](in []SliceType, index int, value SliceType) []SliceType {
+	var zero SliceType
+	in = append(in, zero)
+	copy(in[index+1:], in[index:])
+	in[index] = value
+	return in
+}
+
 // SliceDiff will get a slice of all elements that are present in `a` but not in `b`.
 // If an element is in `a` N times and is not in `b`, it will appear in the output
 // N times as well.
@@ -337,6 +655,8 @@ func SliceDiffUnique[SliceType comparable](a []SliceType, b []SliceType) (inABut
 }
 
 // SliceConversion will convert a slice from one simple numeric type to another.
+// Values that don't fit in the new type are silently changed, like a regular
+// type conversion. Use SliceConversionChecked to detect this.
 func SliceConversion[OldType constraints.Simple, NewType constraints.Simple](in []OldType) []NewType {
 	s := make([]NewType, len(in))
 	for i := range in {
@@ -344,3 +664,20 @@ func SliceConversion[OldType constraints.Simple, NewType constraints.Simple](in
 	}
 	return s
 }
+
+// SliceConversionChecked will convert a slice from one simple numeric type to another,
+// returning an error if any value would overflow or lose precision in the new type.
+func SliceConversionChecked[OldType constraints.Simple, NewType constraints.Simple](in []OldType) ([]NewType, stackerr.Error) {
+	if in == nil {
+		return nil, nil
+	}
+	s := make([]NewType, len(in))
+	for i := range in {
+		v, err := numbers.ConvertChecked[OldType, NewType](in[i])
+		if err != nil {
+			return nil, err.WithSingle("index", i)
+		}
+		s[i] = v
+	}
+	return s, nil
+}
@@ -0,0 +1,99 @@
+package collections
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntersectionUnique(t *testing.T) {
+	tests := []struct {
+		name     string
+		slices   [][]int
+		expected []int
+	}{
+		{"no slices", nil, []int{}},
+		{"one slice", [][]int{{3, 1, 3, 2}}, []int{3, 1, 2}},
+		{"two slices", [][]int{{1, 2, 3, 4}, {4, 3, 5}}, []int{3, 4}},
+		{"more than two slices", [][]int{{5, 4, 3, 2, 1}, {1, 2, 3, 4}, {2, 4, 6}, {4, 2}}, []int{4, 2}},
+		{"duplicates within slices", [][]int{{2, 2, 1, 1, 3}, {1, 1, 2, 2}, {2, 1, 1}}, []int{2, 1}},
+		{"duplicates in a later slice only", [][]int{{1, 2}, {2, 2, 2}, {1, 2}}, []int{2}},
+		{"nil slice", [][]int{{1, 2}, nil}, []int{}},
+		{"empty slice", [][]int{{1, 2}, {}, {1, 2}}, []int{}},
+		{"no overlap", [][]int{{1, 2}, {3, 4}}, []int{}},
+	}
+	for _, test := range tests {
+		got := IntersectionUnique(test.slices...)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestUnionUnique(t *testing.T) {
+	tests := []struct {
+		name     string
+		slices   [][]int
+		expected []int
+	}{
+		{"no slices", nil, []int{}},
+		{"one slice", [][]int{{3, 1, 3, 2}}, []int{3, 1, 2}},
+		{"two slices", [][]int{{1, 2}, {3, 2, 1}}, []int{1, 2, 3}},
+		{"more than two slices", [][]int{{5, 4}, {1, 4}, {6, 5, 2}}, []int{5, 4, 1, 6, 2}},
+		{"duplicates within slices", [][]int{{2, 2, 1}, {3, 3, 1}}, []int{2, 1, 3}},
+		{"nil and empty slices", [][]int{nil, {}, {1}, nil}, []int{1}},
+		{"only nil slices", [][]int{nil, nil}, []int{}},
+	}
+	for _, test := range tests {
+		got := UnionUnique(test.slices...)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestIntersectionByAndUnionBy(t *testing.T) {
+	// Slices aren't comparable, so the key is the joined values
+	key := func(value []string) string {
+		return strings.Join(value, ",")
+	}
+	a := [][]string{{"x"}, {"y", "z"}, {"x"}, {"w"}}
+	b := [][]string{{"w"}, {"y", "z"}, {"y", "z"}}
+	c := [][]string{{"y", "z"}, {"w"}, {"v"}}
+
+	intersection := IntersectionBy(key, a, b, c)
+	expectedIntersection := [][]string{{"y", "z"}, {"w"}}
+	if !reflect.DeepEqual(intersection, expectedIntersection) {
+		t.Errorf("IntersectionBy: got %v, expected %v", intersection, expectedIntersection)
+	}
+
+	union := UnionBy(key, a, b, c)
+	expectedUnion := [][]string{{"x"}, {"y", "z"}, {"w"}, {"v"}}
+	if !reflect.DeepEqual(union, expectedUnion) {
+		t.Errorf("UnionBy: got %v, expected %v", union, expectedUnion)
+	}
+
+	if got := IntersectionBy(key, a, nil); len(got) != 0 {
+		t.Errorf("IntersectionBy with a nil slice: got %v, expected an empty slice", got)
+	}
+	if got := UnionBy(key); got == nil || len(got) != 0 {
+		t.Errorf("UnionBy with no slices: got %v, expected an empty slice", got)
+	}
+
+	// The first value with each key is kept
+	type item struct {
+		id    int
+		label string
+	}
+	itemKey := func(value item) int {
+		return value.id
+	}
+	first := []item{{1, "a"}, {2, "b"}, {1, "c"}}
+	second := []item{{2, "d"}, {1, "e"}, {3, "f"}}
+	if got, expected := IntersectionBy(itemKey, first, second), []item{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("IntersectionBy: got %v, expected %v", got, expected)
+	}
+	if got, expected := UnionBy(itemKey, first, second), []item{{1, "a"}, {2, "b"}, {3, "f"}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("UnionBy: got %v, expected %v", got, expected)
+	}
+}
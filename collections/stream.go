@@ -0,0 +1,124 @@
+package collections
+
+// Stream is a lazy pipeline of values. Transformations (Filter, Take, Skip, MapStream,
+// DistinctStream) don't do any work until the stream is consumed (by Collect, ForEach,
+// Count, or iterating over Seq), and values pass through all of them one at a time,
+// so no intermediate slices are allocated. A stream can be consumed more than once, in
+// which case the pipeline is run again from its source.
+type Stream[T any] interface {
+	// Filter returns a stream of the values that meet a given condition function.
+	Filter(filterFunc func(value T) (include bool)) Stream[T]
+	// Take returns a stream of the first n values.
+	Take(n int) Stream[T]
+	// Skip returns a stream of the values after the first n.
+	Skip(n int) Stream[T]
+	// Collect runs the pipeline, and returns a slice of the values.
+	Collect() []T
+	// ForEach runs the pipeline, and calls a function with each value. If the
+	// function returns false, the pipeline stops.
+	ForEach(f func(value T) (resume bool))
+	// Count runs the pipeline, and returns the number of values.
+	Count() int
+	// Seq returns an iterator over the values.
+	Seq() func(yield func(T) bool)
+}
+
+type stream[T any] struct {
+	seq func(yield func(T) bool)
+}
+
+// StreamFromSlice creates a stream of the values of a slice.
+func StreamFromSlice[T any](in []T) Stream[T] {
+	return &stream[T]{
+		seq: ToSeq(in),
+	}
+}
+
+// StreamFromSeq creates a stream of the values of an iterator (such as an iter.Seq).
+func StreamFromSeq[T any](seq func(yield func(T) bool)) Stream[T] {
+	return &stream[T]{
+		seq: seq,
+	}
+}
+
+func (s *stream[T]) Filter(filterFunc func(value T) (include bool)) Stream[T] {
+	return StreamFromSeq(func(yield func(T) bool) {
+		s.seq(func(v T) bool {
+			return !filterFunc(v) || yield(v)
+		})
+	})
+}
+
+func (s *stream[T]) Take(n int) Stream[T] {
+	return StreamFromSeq(func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		taken := 0
+		s.seq(func(v T) bool {
+			taken++
+			// Stop as soon as the last value is taken, so the source isn't read further
+			return yield(v) && taken < n
+		})
+	})
+}
+
+func (s *stream[T]) Skip(n int) Stream[T] {
+	return StreamFromSeq(func(yield func(T) bool) {
+		skipped := 0
+		s.seq(func(v T) bool {
+			if skipped < n {
+				skipped++
+				return true
+			}
+			return yield(v)
+		})
+	})
+}
+
+func (s *stream[T]) Collect() []T {
+	return FromSeq(s.seq)
+}
+
+func (s *stream[T]) ForEach(f func(value T) (resume bool)) {
+	s.seq(f)
+}
+
+func (s *stream[T]) Count() int {
+	count := 0
+	s.seq(func(T) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+func (s *stream[T]) Seq() func(yield func(T) bool) {
+	return s.seq
+}
+
+// MapStream returns a stream of the values of another stream, transformed by a
+// transformation function. It's a function rather than a method of Stream, since
+// methods can't have type parameters.
+func MapStream[In any, Out any](s Stream[In], transformationFunc func(value In) (transformed Out)) Stream[Out] {
+	return StreamFromSeq(func(yield func(Out) bool) {
+		s.Seq()(func(v In) bool {
+			return yield(transformationFunc(v))
+		})
+	})
+}
+
+// DistinctStream returns a stream of the distinct values of another stream, in the
+// order that they first appear. It keeps a set of the values that it has seen.
+func DistinctStream[T comparable](s Stream[T]) Stream[T] {
+	return StreamFromSeq(func(yield func(T) bool) {
+		seen := map[T]struct{}{}
+		s.Seq()(func(v T) bool {
+			if _, ok := seen[v]; ok {
+				return true
+			}
+			seen[v] = struct{}{}
+			return yield(v)
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package collections
+
+import "sort"
+
+// Trie is a prefix tree of string keys, for looking up keys by their prefixes.
+type Trie[V any] interface {
+	// Insert sets the value for a key.
+	Insert(key string, value V)
+	// Get gets the value for a key, and a bool of whether the key exists.
+	Get(key string) (V, bool)
+	// Delete will delete a key if it exists, and returns a bool of whether
+	// the key existed and was deleted.
+	Delete(key string) bool
+	// Len returns the number of keys.
+	Len() int
+	// LongestPrefixMatch finds the longest key that's a prefix of the given string (including
+	// the string itself), and returns it, its value, and a bool of whether a key was found.
+	LongestPrefixMatch(s string) (key string, value V, found bool)
+	// WalkPrefix calls a function with each key that starts with the given prefix (including
+	// the prefix itself), and its value, in sorted order. If the function returns false, the
+	// walk stops.
+	WalkPrefix(prefix string, walkFunc func(key string, value V) (resume bool))
+}
+
+type trieNode[V any] struct {
+	children map[byte]*trieNode[V]
+	value    V
+	hasValue bool
+}
+
+type trie[V any] struct {
+	root trieNode[V]
+	len  int
+}
+
+func NewTrie[V any]() Trie[V] {
+	return &trie[V]{}
+}
+
+// find gets the node for a key, or nil if there isn't one.
+func (t *trie[V]) find(key string) *trieNode[V] {
+	n := &t.root
+	for i := 0; i < len(key) && n != nil; i++ {
+		n = n.children[key[i]]
+	}
+	return n
+}
+
+func (t *trie[V]) Insert(key string, value V) {
+	n := &t.root
+	for i := 0; i < len(key); i++ {
+		if n.children == nil {
+			n.children = map[byte]*trieNode[V]{}
+		}
+		child, ok := n.children[key[i]]
+		if !ok {
+			child = &trieNode[V]{}
+			n.children[key[i]] = child
+		}
+		n = child
+	}
+	if !n.hasValue {
+		t.len++
+	}
+	n.value = value
+	n.hasValue = true
+}
+
+func (t *trie[V]) Get(key string) (V, bool) {
+	n := t.find(key)
+	if n == nil || !n.hasValue {
+		var v V
+		return v, false
+	}
+	return n.value, true
+}
+
+func (t *trie[V]) Delete(key string) bool {
+	// Track the path, so that nodes that are no longer needed can be removed
+	path := make([]*trieNode[V], 0, len(key)+1)
+	n := &t.root
+	path = append(path, n)
+	for i := 0; i < len(key); i++ {
+		n = n.children[key[i]]
+		if n == nil {
+			return false
+		}
+		path = append(path, n)
+	}
+	if !n.hasValue {
+		return false
+	}
+	var zero V
+	n.value = zero
+	n.hasValue = false
+	t.len--
+	// Remove the nodes that have no value and no children, from the end of the path
+	for i := len(path) - 1; i > 0; i-- {
+		if path[i].hasValue || len(path[i].children) > 0 {
+			break
+		}
+		delete(path[i-1].children, key[i-1])
+	}
+	return true
+}
+
+func (t *trie[V]) Len() int {
+	return t.len
+}
+
+func (t *trie[V]) LongestPrefixMatch(s string) (key string, value V, found bool) {
+	n := &t.root
+	for i := 0; ; i++ {
+		if n.hasValue {
+			key, value, found = s[:i], n.value, true
+		}
+		if i == len(s) {
+			break
+		}
+		n = n.children[s[i]]
+		if n == nil {
+			break
+		}
+	}
+	return key, value, found
+}
+
+func (t *trie[V]) WalkPrefix(prefix string, walkFunc func(key string, value V) (resume bool)) {
+	n := t.find(prefix)
+	if n == nil {
+		return
+	}
+	key := []byte(prefix)
+	var walk func(n *trieNode[V]) bool
+	walk = func(n *trieNode[V]) bool {
+		if n.hasValue && !walkFunc(string(key), n.value) {
+			return false
+		}
+		children := make([]byte, 0, len(n.children))
+		for b := range n.children {
+			children = append(children, b)
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+		for _, b := range children {
+			key = append(key, b)
+			if !walk(n.children[b]) {
+				return false
+			}
+			key = key[:len(key)-1]
+		}
+		return true
+	}
+	walk(n)
+}
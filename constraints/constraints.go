@@ -4,6 +4,11 @@
 
 // Package constraints defines a set of useful constraints to be used
 // with type parameters.
+//
+// Signed, Unsigned, Integer, Float, Complex, and Ordered are identical to
+// the constraints of the same names in golang.org/x/exp/constraints (and
+// Ordered to cmp.Ordered), so code can switch between them freely. The
+// other constraints are additions that aren't in that package.
 package constraints
 
 // Signed is a constraint that permits any signed integer type.
@@ -46,3 +51,54 @@ type Numeric interface {
 type Ordered interface {
 	Simple | ~string
 }
+
+// Number is a constraint that permits any simple numeric type. It is
+// the same as Simple.
+type Number interface {
+	Simple
+}
+
+// SignedNumber is a constraint that permits any numeric type that can
+// hold negative values: signed integers and floats.
+type SignedNumber interface {
+	Signed | Float
+}
+
+// UnsignedNumber is a constraint that permits any numeric type that can't
+// hold negative values. It is the same as Unsigned.
+type UnsignedNumber interface {
+	Unsigned
+}
+
+// Comparable is a constraint that permits any type that supports the
+// operators == and !=. It is the same as the built-in comparable.
+type Comparable interface {
+	comparable
+}
+
+// Slice is a constraint that permits any slice type with elements of type E.
+type Slice[E any] interface {
+	~[]E
+}
+
+// Map is a constraint that permits any map type with keys of type K and
+// values of type V.
+type Map[K comparable, V any] interface {
+	~map[K]V
+}
+
+// Chan is a constraint that permits any bidirectional channel type with
+// elements of type E.
+type Chan[E any] interface {
+	~chan E
+}
+
+// Stringish is a constraint that permits any string or byte slice type,
+// which can both be converted to and from a string.
+//
+// Note that Go doesn't allow interfaces with methods (such as fmt.Stringer)
+// in a union, so types that only implement fmt.Stringer aren't included.
+// Use fmt.Stringer as a separate constraint for those.
+type Stringish interface {
+	~string | ~[]byte
+}
@@ -0,0 +1,10 @@
+package conversions
+
+// If returns `a` if the condition is true, or `b` otherwise. Note that both
+// values are evaluated before the call, unlike with an if/else block.
+func If[T any](cond bool, a T, b T) T {
+	if cond {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package conversions
+
+import (
+	"reflect"
+	"strconv"
+	"unsafe"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// isSigned returns whether the integer type is signed.
+func isSigned[T constraints.Integer]() bool {
+	var v T
+	v--
+	return v < 0
+}
+
+// bitSize returns the size of the numeric type in bits.
+func bitSize[T constraints.Simple]() int {
+	var v T
+	return int(unsafe.Sizeof(v)) * 8
+}
+
+// ConvertInteger converts an integer from one type to another, returning an
+// error if the value cannot be represented in the new type (instead of silently
+// wrapping around like a regular type conversion). See numbers.ConvertChecked
+// for conversions that involve floats.
+func ConvertInteger[From constraints.Integer, To constraints.Integer](v From) (To, stackerr.Error) {
+	return numbers.ConvertChecked[From, To](v)
+}
+
+// ConvertIntegerSlice converts a slice of integers from one type to another,
+// returning an error if any of the values cannot be represented in the new type.
+func ConvertIntegerSlice[From constraints.Integer, To constraints.Integer](in []From) ([]To, stackerr.Error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make([]To, len(in))
+	for i, v := range in {
+		c, err := ConvertInteger[From, To](v)
+		if err != nil {
+			return nil, err.WithSingle("index", i)
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+// ParseInteger parses a base-10 string into an integer of the given type,
+// returning an error if it is not a valid integer or does not fit in the type.
+func ParseInteger[T constraints.Integer](s string) (T, stackerr.Error) {
+	if isSigned[T]() {
+		v, err := strconv.ParseInt(s, 10, bitSize[T]())
+		if err != nil {
+			return 0, stackerr.Wrap(err)
+		}
+		return T(v), nil
+	}
+	v, err := strconv.ParseUint(s, 10, bitSize[T]())
+	if err != nil {
+		return 0, stackerr.Wrap(err)
+	}
+	return T(v), nil
+}
+
+// ParseFloat parses a string into a float of the given type, returning
+// an error if it is not a valid float or does not fit in the type.
+func ParseFloat[T constraints.Float](s string) (T, stackerr.Error) {
+	v, err := strconv.ParseFloat(s, bitSize[T]())
+	if err != nil {
+		return 0, stackerr.Wrap(err)
+	}
+	return T(v), nil
+}
+
+// FormatNumber converts a number into its shortest base-10 string representation.
+func FormatNumber[T constraints.Simple](v T) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	default:
+		return strconv.FormatUint(rv.Uint(), 10)
+	}
+}
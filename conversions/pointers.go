@@ -3,3 +3,94 @@ package conversions
 func GetPtr[T any](v T) *T {
 	return &v
 }
+
+// FromPtr returns the value that the pointer points to, or the
+// default value if the pointer is nil.
+func FromPtr[T any](ptr *T, defaultValue T) T {
+	if ptr == nil {
+		return defaultValue
+	}
+	return *ptr
+}
+
+// FromPtrOrZero returns the value that the pointer points to, or the
+// zero value if the pointer is nil.
+func FromPtrOrZero[T any](ptr *T) T {
+	var zero T
+	return FromPtr(ptr, zero)
+}
+
+// FirstNonNil returns the first pointer that isn't nil, or nil if they all are.
+func FirstNonNil[T any](ptrs ...*T) *T {
+	for _, ptr := range ptrs {
+		if ptr != nil {
+			return ptr
+		}
+	}
+	return nil
+}
+
+// PtrSlice converts a slice of values into a slice of pointers to copies of those values.
+func PtrSlice[T any](in []T) []*T {
+	if in == nil {
+		return nil
+	}
+	out := make([]*T, len(in))
+	for i, v := range in {
+		out[i] = GetPtr(v)
+	}
+	return out
+}
+
+// ValueSlice converts a slice of pointers into a slice of the values they point to.
+// Nil pointers are converted to the zero value.
+func ValueSlice[T any](in []*T) []T {
+	if in == nil {
+		return nil
+	}
+	out := make([]T, len(in))
+	for i, v := range in {
+		out[i] = FromPtrOrZero(v)
+	}
+	return out
+}
+
+// ValueSliceNonNil converts a slice of pointers into a slice of the values they point to,
+// skipping any nil pointers.
+func ValueSliceNonNil[T any](in []*T) []T {
+	if in == nil {
+		return nil
+	}
+	out := make([]T, 0, len(in))
+	for _, v := range in {
+		if v != nil {
+			out = append(out, *v)
+		}
+	}
+	return out
+}
+
+// PtrMapValues converts a map of values into a map of pointers to copies of those values.
+func PtrMapValues[Key comparable, Value any](in map[Key]Value) map[Key]*Value {
+	if in == nil {
+		return nil
+	}
+	out := make(map[Key]*Value, len(in))
+	for k, v := range in {
+		out[k] = GetPtr(v)
+	}
+	return out
+}
+
+// ValueMapValues converts a map of pointers into a map of the values they point to.
+// Nil pointers are converted to the zero value.
+func ValueMapValues[Key comparable, Value any](in map[Key]*Value) map[Key]Value {
+	if in == nil {
+		return nil
+	}
+	out := make(map[Key]Value, len(in))
+	for k, v := range in {
+		out[k] = FromPtrOrZero(v)
+	}
+	return out
+}
@@ -0,0 +1,512 @@
+package conversions
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Invicton-Labs/go-common/zero"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// structField is a struct field with its key and options from the struct tag.
+type structField struct {
+	key       string
+	index     []int
+	omitEmpty bool
+	// Whether the key came from the struct tag
+	tagged bool
+}
+
+// structFields gets the fields of a struct type that should be included in a map, using
+// the same rules as encoding/json: unexported fields and fields tagged with "-" are
+// skipped, and the fields of untagged embedded structs are promoted. If several fields
+// have the same key, the shallowest one wins, then a tagged one; if that still leaves
+// more than one, they're all skipped.
+func structFields(t reflect.Type, tagName string) []structField {
+	type embeddedStruct struct {
+		typ   reflect.Type
+		index []int
+	}
+	fields := []structField{}
+	// Embedded structs are visited breadth-first, so shallower fields are found first,
+	// and each type is only visited once (at its shallowest depth), which also stops
+	// self-referential embedded pointers from recursing forever.
+	current := []embeddedStruct{}
+	next := []embeddedStruct{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	// The number of times each type is embedded at the current and next depths
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, es := range current {
+			if visited[es.typ] {
+				continue
+			}
+			visited[es.typ] = true
+			for i := 0; i < es.typ.NumField(); i++ {
+				f := es.typ.Field(i)
+				ft := f.Type
+				if f.Anonymous && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !f.IsExported() && !(f.Anonymous && ft.Kind() == reflect.Struct) {
+					continue
+				}
+				tag := f.Tag.Get(tagName)
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := make([]int, len(es.index)+1)
+				copy(index, es.index)
+				index[len(es.index)] = i
+				if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, embeddedStruct{typ: ft, index: index})
+					}
+					continue
+				}
+				if !f.IsExported() {
+					continue
+				}
+				field := structField{
+					key:       name,
+					index:     index,
+					omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+					tagged:    name != "",
+				}
+				if field.key == "" {
+					field.key = f.Name
+				}
+				fields = append(fields, field)
+				if count[es.typ] > 1 {
+					// The type is embedded more than once at this depth, so its fields
+					// conflict with each other. Add a duplicate so they're skipped.
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) < len(b.index)
+		}
+		return a.tagged && !b.tagged
+	})
+	dominant := make([]structField, 0, len(fields))
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].key == fields[i].key {
+			j++
+		}
+		// The fields are sorted by depth then tag, so the first one is dominant
+		// unless the next one is just as deep and just as tagged
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			dominant = append(dominant, fields[i])
+		}
+		i = j
+	}
+	// Keep the fields in struct order
+	sort.Slice(dominant, func(i, j int) bool {
+		a, b := dominant[i].index, dominant[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return dominant
+}
+
+// fieldByIndex gets a (possibly embedded) field, returning false if it's
+// inside a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
+
+type StructToMapInput struct {
+	// OPTIONAL. Whether to omit all fields that have zero values (see zero.IsZeroDeep),
+	// not just the ones tagged with `omitempty`.
+	OmitZero bool
+	// OPTIONAL. The struct tag to get field names and options from. Defaults to "json".
+	TagName string
+}
+
+// StructToMap converts a struct (or pointer to a struct) into a map, using the field names
+// and `omitempty` options from the struct tags. Nested structs are converted into nested maps,
+// and slices, arrays and maps that contain structs are converted into []any and map[string]any.
+// Other values (including any that implement json.Marshaler or encoding.TextMarshaler, such
+// as time.Time) are kept as-is, so numbers don't lose precision like they would in a JSON
+// round-trip.
+func StructToMap(v any, input StructToMapInput) (map[string]any, stackerr.Error) {
+	if input.TagName == "" {
+		input.TagName = "json"
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, stackerr.Errorf("cannot convert a nil %T into a map", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, stackerr.Errorf("cannot convert a %T into a map, it must be a struct", v)
+	}
+	return structToMap(rv, input), nil
+}
+
+func structToMap(v reflect.Value, input StructToMapInput) map[string]any {
+	out := map[string]any{}
+	for _, f := range structFields(v.Type(), input.TagName) {
+		fv, ok := fieldByIndex(v, f.index)
+		// Fields promoted from unexported embedded structs can't be read with reflection
+		if !ok || !fv.CanInterface() {
+			continue
+		}
+		if (f.omitEmpty || input.OmitZero) && zero.IsZeroDeep(fv.Interface()) {
+			continue
+		}
+		out[f.key] = toMapValue(fv, input)
+	}
+	return out
+}
+
+// toMapValue converts a value for StructToMap.
+func toMapValue(v reflect.Value, input StructToMapInput) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toMapValue(v.Elem(), input)
+	case reflect.Struct:
+		return structToMap(v, input)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return v.Interface()
+		}
+		if !containsStructs(v.Type().Elem()) {
+			return v.Interface()
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = toMapValue(v.Index(i), input)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String || !containsStructs(v.Type().Elem()) {
+			return v.Interface()
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = toMapValue(iter.Value(), input)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// containsStructs returns whether values of the type may contain structs that StructToMap converts.
+func containsStructs(t reflect.Type) bool {
+	for {
+		if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Struct, reflect.Interface:
+			return true
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+type MapToStructInput struct {
+	// OPTIONAL. The struct tag to get field names from. Defaults to "json".
+	TagName string
+	// OPTIONAL. Whether to return an error if the map has a key that doesn't match any field.
+	DisallowUnknownKeys bool
+}
+
+// MapToStruct sets the fields of a struct (which `out` must be a pointer to) from a map, matching
+// keys to fields by their struct tag names (or case-insensitively by field name, like encoding/json).
+// Values are converted to the field types where possible: numbers are converted between types (returning
+// an error if they don't fit, instead of losing precision), nested maps are converted into structs,
+// []any into typed slices, and strings into types that implement encoding.TextUnmarshaler (such as time.Time).
+func MapToStruct(m map[string]any, out any, input MapToStructInput) stackerr.Error {
+	if input.TagName == "" {
+		input.TagName = "json"
+	}
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return stackerr.Errorf("the output must be a non-nil pointer to a struct, got %T", out)
+	}
+	return mapToStruct(m, rv.Elem(), input, "")
+}
+
+func mapToStruct(m map[string]any, v reflect.Value, input MapToStructInput, path string) stackerr.Error {
+	fields := structFields(v.Type(), input.TagName)
+	byKey := make(map[string]structField, len(fields))
+	byFoldedKey := make(map[string]structField, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+		byFoldedKey[strings.ToLower(f.key)] = f
+	}
+	for key, value := range m {
+		f, ok := byKey[key]
+		if !ok {
+			f, ok = byFoldedKey[strings.ToLower(key)]
+		}
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		if !ok {
+			if input.DisallowUnknownKeys {
+				return stackerr.Errorf("the map key '%s' does not match any field", fieldPath)
+			}
+			continue
+		}
+		fv, err := settableFieldByIndex(v, f.index)
+		if err == nil && !fv.CanSet() {
+			err = stackerr.Errorf("cannot set a field of an unexported embedded struct")
+		}
+		if err != nil {
+			return err.WithSingle("field", fieldPath)
+		}
+		if err := setFromMapValue(fv, value, input, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settableFieldByIndex gets a (possibly embedded) field, allocating any nil embedded pointers.
+func settableFieldByIndex(v reflect.Value, index []int) (reflect.Value, stackerr.Error) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, stackerr.Errorf("cannot set a field of a nil unexported embedded struct pointer")
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
+// setFromMapValue sets a value from a map value, converting it as necessary.
+func setFromMapValue(v reflect.Value, value any, input MapToStructInput, path string) stackerr.Error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromMapValue(elem.Elem(), value, input, path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if s, ok := value.(string); ok && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return stackerr.Wrap(err).WithSingle("field", path)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if nested, ok := value.(map[string]any); ok {
+			return mapToStruct(nested, v, input, path)
+		}
+	case reflect.Slice:
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			slice := reflect.MakeSlice(v.Type(), rv.Len(), rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				if err := setFromMapValue(slice.Index(i), rv.Index(i).Interface(), input, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+	case reflect.Map:
+		if rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String && v.Type().Key().Kind() == reflect.String {
+			out := reflect.MakeMapWithSize(v.Type(), rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := setFromMapValue(elem, iter.Value().Interface(), input, path+"."+iter.Key().String()); err != nil {
+					return err
+				}
+				out.SetMapIndex(iter.Key().Convert(v.Type().Key()), elem)
+			}
+			v.Set(out)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if err := setNumber(v, rv); err != nil {
+			return err.WithSingle("field", path)
+		}
+		return nil
+	}
+	if rv.Type().ConvertibleTo(v.Type()) && rv.Kind() == v.Kind() {
+		// e.g. a string into a named string type
+		v.Set(rv.Convert(v.Type()))
+		return nil
+	}
+	return stackerr.Errorf("cannot convert a %T into a %s", value, v.Type()).WithSingle("field", path)
+}
+
+// setNumber sets a numeric value from another numeric value (or json.Number), returning
+// an error if it doesn't fit in the type.
+func setNumber(v reflect.Value, rv reflect.Value) stackerr.Error {
+	if n, ok := rv.Interface().(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			rv = reflect.ValueOf(i)
+		} else if f, err := n.Float64(); err == nil {
+			rv = reflect.ValueOf(f)
+		} else {
+			return stackerr.Wrap(err)
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(i))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if i < 0 || v.OverflowUint(uint64(i)) {
+				return stackerr.Errorf("the value %d does not fit in a %s", i, v.Type())
+			}
+			v.SetUint(uint64(i))
+			return nil
+		default:
+			if v.OverflowInt(i) {
+				return stackerr.Errorf("the value %d does not fit in a %s", i, v.Type())
+			}
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(u))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v.OverflowUint(u) {
+				return stackerr.Errorf("the value %d does not fit in a %s", u, v.Type())
+			}
+			v.SetUint(u)
+			return nil
+		default:
+			if u > math.MaxInt64 || v.OverflowInt(int64(u)) {
+				return stackerr.Errorf("the value %d does not fit in a %s", u, v.Type())
+			}
+			v.SetInt(int64(u))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if v.OverflowFloat(f) {
+				return stackerr.Errorf("the value %v does not fit in a %s", f, v.Type())
+			}
+			v.SetFloat(f)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || v.OverflowUint(uint64(f)) {
+				return stackerr.Errorf("the value %v does not fit in a %s", f, v.Type())
+			}
+			v.SetUint(uint64(f))
+			return nil
+		default:
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || v.OverflowInt(int64(f)) {
+				return stackerr.Errorf("the value %v does not fit in a %s", f, v.Type())
+			}
+			v.SetInt(int64(f))
+			return nil
+		}
+	}
+	return stackerr.Errorf("cannot convert a %s into a %s", rv.Type(), v.Type())
+}
+
+// FlattenMap flattens nested maps (of type map[string]any) into a single map, joining the keys
+// of nested values with the separator. For example, {"a": {"b": 1}} becomes {"a.b": 1} with a
+// separator of ".". Empty nested maps are omitted.
+func FlattenMap(m map[string]any, sep string) map[string]any {
+	out := map[string]any{}
+	flattenInto(out, m, "", sep)
+	return out
+}
+
+func flattenInto(out map[string]any, m map[string]any, prefix string, sep string) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + sep + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flattenInto(out, nested, key, sep)
+			continue
+		}
+		out[key] = v
+	}
+}
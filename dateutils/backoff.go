@@ -0,0 +1,209 @@
+package dateutils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrBackoffExhausted is returned when the maximum number of attempts
+// or the maximum elapsed time of a backoff has been reached.
+var ErrBackoffExhausted = errors.New("backoff limit reached")
+
+type JitterStrategy int
+
+const (
+	// NoJitter uses the exact exponential durations.
+	NoJitter JitterStrategy = iota
+	// FullJitter uses a random duration between the minimum and the exponential duration.
+	FullJitter
+	// EqualJitter uses half of the exponential duration, plus a random duration
+	// of up to the other half.
+	EqualJitter
+	// DecorrelatedJitter uses a random duration between the minimum and 3 times
+	// the previous duration.
+	DecorrelatedJitter
+)
+
+// BackoffDuration returns the exponential backoff duration (doubling each attempt, starting
+// at the minimum for attempt 0), limited to the maximum, with the given jitter applied.
+// Since it has no state, DecorrelatedJitter uses the un-jittered previous duration.
+func BackoffDuration(min, max time.Duration, attemptNum int, jitter JitterStrategy) time.Duration {
+	return backoffDuration(min, max, 2, attemptNum, jitter, -1)
+}
+
+// backoffDuration calculates a backoff duration. If previous is negative, the un-jittered
+// duration of the previous attempt is used for decorrelated jitter.
+func backoffDuration(min, max time.Duration, multiplier float64, attemptNum int, jitter JitterStrategy, previous time.Duration) time.Duration {
+	if max < min {
+		max = min
+	}
+	exponential := func(attempt int) time.Duration {
+		if attempt < 0 {
+			return min
+		}
+		d := math.Pow(multiplier, float64(attempt)) * float64(min)
+		if d > float64(max) || math.IsInf(d, 0) || math.IsNaN(d) {
+			return max
+		}
+		return time.Duration(d)
+	}
+	randomBetween := func(low, high time.Duration) time.Duration {
+		if high <= low {
+			return low
+		}
+		return numbers.RandomIntInRange(low, high-1)
+	}
+
+	b := exponential(attemptNum)
+	switch jitter {
+	case FullJitter:
+		return randomBetween(min, b)
+	case EqualJitter:
+		half := b / 2
+		if half < min {
+			return randomBetween(min, b)
+		}
+		return randomBetween(half, b)
+	case DecorrelatedJitter:
+		if previous < 0 {
+			previous = exponential(attemptNum - 1)
+		}
+		upper := 3 * previous
+		if upper > max || upper < previous {
+			upper = max
+		}
+		return randomBetween(min, upper)
+	default:
+		return b
+	}
+}
+
+// Backoff generates a schedule of increasing wait durations, for use when retrying operations.
+type Backoff interface {
+	// Duration returns the wait duration for the given attempt number (starting at 0),
+	// without considering the attempt or elapsed time limits.
+	Duration(attemptNum int) time.Duration
+	// Next returns the next wait duration in the schedule. The boolean is false if the
+	// maximum number of attempts or the maximum elapsed time has been reached.
+	Next() (wait time.Duration, ok bool)
+	// Wait waits for the duration of the given attempt number. If the context is done
+	// first, the context's error is returned. If the attempt or elapsed time limits would
+	// be exceeded, ErrBackoffExhausted is returned without waiting.
+	Wait(ctx context.Context, attemptNum int) stackerr.Error
+	// Reset resets the schedule to the first attempt and restarts the elapsed time.
+	Reset()
+}
+
+type NewBackoffInput struct {
+	// OPTIONAL. The wait duration for the first attempt. Defaults to 100ms.
+	Min time.Duration
+	// OPTIONAL. The maximum wait duration. Defaults to 10s.
+	Max time.Duration
+	// OPTIONAL. The factor that the wait duration increases by for each attempt. Defaults to 2.
+	Multiplier float64
+	// OPTIONAL. The jitter strategy. Defaults to NoJitter.
+	Jitter JitterStrategy
+	// OPTIONAL. The maximum number of waits. If 0 or less, there is no limit.
+	MaxAttempts int
+	// OPTIONAL. The maximum time since the backoff was created (or reset), after
+	// which no further waits are allowed. If 0 or less, there is no limit.
+	MaxElapsed time.Duration
+	// OPTIONAL. The clock to use. If not provided, the real clock will be used.
+	Clock Clock
+}
+
+type backoff struct {
+	input    NewBackoffInput
+	clock    Clock
+	lock     sync.Mutex
+	start    time.Time
+	attempt  int
+	previous time.Duration
+}
+
+// NewBackoff creates a new backoff schedule.
+func NewBackoff(input NewBackoffInput) Backoff {
+	if input.Min <= 0 {
+		input.Min = 100 * time.Millisecond
+	}
+	if input.Max <= 0 {
+		input.Max = 10 * time.Second
+	}
+	if input.Max < input.Min {
+		input.Max = input.Min
+	}
+	if input.Multiplier <= 1 {
+		input.Multiplier = 2
+	}
+	b := &backoff{
+		input: input,
+		clock: ClockOrDefault(input.Clock),
+	}
+	b.Reset()
+	return b
+}
+
+func (b *backoff) Duration(attemptNum int) time.Duration {
+	return backoffDuration(b.input.Min, b.input.Max, b.input.Multiplier, attemptNum, b.input.Jitter, -1)
+}
+
+// allowed checks whether another wait of the given duration is within the limits.
+func (b *backoff) allowed(attemptNum int, wait time.Duration) bool {
+	if b.input.MaxAttempts > 0 && attemptNum >= b.input.MaxAttempts {
+		return false
+	}
+	if b.input.MaxElapsed > 0 && b.clock.Since(b.start)+wait > b.input.MaxElapsed {
+		return false
+	}
+	return true
+}
+
+func (b *backoff) Next() (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	previous := time.Duration(-1)
+	if b.attempt > 0 {
+		previous = b.previous
+	}
+	wait := backoffDuration(b.input.Min, b.input.Max, b.input.Multiplier, b.attempt, b.input.Jitter, previous)
+	if !b.allowed(b.attempt, wait) {
+		return 0, false
+	}
+	b.attempt++
+	b.previous = wait
+	return wait, true
+}
+
+func (b *backoff) Wait(ctx context.Context, attemptNum int) stackerr.Error {
+	wait := b.Duration(attemptNum)
+	b.lock.Lock()
+	allowed := b.allowed(attemptNum, wait)
+	b.lock.Unlock()
+	if !allowed {
+		return stackerr.Wrap(ErrBackoffExhausted).WithSingle("attempt", attemptNum)
+	}
+	timer := b.clock.NewTimer(wait)
+	select {
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C()
+		}
+		return stackerr.Wrap(ctx.Err())
+	case <-timer.C():
+		return nil
+	}
+}
+
+func (b *backoff) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.start = b.clock.Now()
+	b.attempt = 0
+	b.previous = 0
+}
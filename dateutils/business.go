@@ -0,0 +1,188 @@
+package dateutils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// civilDate is a calendar date without a time or location.
+type civilDate struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func (d civilDate) dayNumber() int64 {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+// weekdayOf returns the day of the week for a day number. Day 0 (1970-01-01) was a Thursday.
+func weekdayOf(dayNumber int64) time.Weekday {
+	return time.Weekday(((dayNumber % 7) + 11) % 7)
+}
+
+// BusinessCalendar performs date calculations that skip weekends and holidays.
+// All dates are evaluated in the calendar's location, and functions that return
+// a time preserve the time of day of the input.
+type BusinessCalendar interface {
+	// IsBusinessDay returns whether the date is neither a weekend day nor a holiday.
+	IsBusinessDay(t time.Time) bool
+	// IsHoliday returns whether the date is a holiday.
+	IsHoliday(t time.Time) bool
+	// AddHolidays adds additional holidays to the calendar.
+	AddHolidays(holidays ...time.Time)
+	// AddBusinessDays adds the given number of business days to the time. If the
+	// number is negative, it moves backwards. If it's 0, the time is returned unchanged.
+	AddBusinessDays(t time.Time, days int) time.Time
+	// NextBusinessDay returns the first business day after the time's date.
+	NextBusinessDay(t time.Time) time.Time
+	// PreviousBusinessDay returns the last business day before the time's date.
+	PreviousBusinessDay(t time.Time) time.Time
+	// BusinessDaysBetween returns the number of business days in the range [start, end),
+	// by date. If end is before start, the result is negative.
+	BusinessDaysBetween(start time.Time, end time.Time) int
+}
+
+type NewBusinessCalendarInput struct {
+	// OPTIONAL. The days of the week that are not business days. Defaults to
+	// Saturday and Sunday.
+	WeekendDays []time.Weekday
+	// OPTIONAL. The holidays, which are not business days. Only the date
+	// (in the calendar's location) is used.
+	Holidays []time.Time
+	// OPTIONAL. The location to use for determining dates. Defaults to UTC.
+	Location *time.Location
+}
+
+type businessCalendar struct {
+	location *time.Location
+	weekend  [7]bool
+	// The number of business days in a week, excluding holidays
+	businessDaysPerWeek int
+	holidaysLock        sync.RWMutex
+	holidays            map[civilDate]struct{}
+}
+
+// NewBusinessCalendar creates a new business calendar.
+func NewBusinessCalendar(input NewBusinessCalendarInput) (BusinessCalendar, stackerr.Error) {
+	bc := &businessCalendar{
+		location: input.Location,
+		holidays: map[civilDate]struct{}{},
+	}
+	if bc.location == nil {
+		bc.location = time.UTC
+	}
+	weekendDays := input.WeekendDays
+	if weekendDays == nil {
+		weekendDays = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	for _, d := range weekendDays {
+		if d < time.Sunday || d > time.Saturday {
+			return nil, stackerr.Errorf("invalid weekend day: %d", d)
+		}
+		bc.weekend[d] = true
+	}
+	for _, isWeekend := range bc.weekend {
+		if !isWeekend {
+			bc.businessDaysPerWeek++
+		}
+	}
+	if bc.businessDaysPerWeek == 0 {
+		return nil, stackerr.Errorf("the `input.WeekendDays` field must not contain every day of the week")
+	}
+	bc.AddHolidays(input.Holidays...)
+	return bc, nil
+}
+
+func (bc *businessCalendar) date(t time.Time) civilDate {
+	y, m, d := t.In(bc.location).Date()
+	return civilDate{y, m, d}
+}
+
+func (bc *businessCalendar) AddHolidays(holidays ...time.Time) {
+	bc.holidaysLock.Lock()
+	defer bc.holidaysLock.Unlock()
+	for _, h := range holidays {
+		bc.holidays[bc.date(h)] = struct{}{}
+	}
+}
+
+func (bc *businessCalendar) isHoliday(d civilDate) bool {
+	bc.holidaysLock.RLock()
+	defer bc.holidaysLock.RUnlock()
+	_, ok := bc.holidays[d]
+	return ok
+}
+
+func (bc *businessCalendar) IsHoliday(t time.Time) bool {
+	return bc.isHoliday(bc.date(t))
+}
+
+func (bc *businessCalendar) IsBusinessDay(t time.Time) bool {
+	t = t.In(bc.location)
+	return !bc.weekend[t.Weekday()] && !bc.isHoliday(bc.date(t))
+}
+
+// step moves the time by one day at a time in the given direction until it
+// lands on a business day.
+func (bc *businessCalendar) step(t time.Time, direction int) time.Time {
+	t = t.In(bc.location)
+	for {
+		t = t.AddDate(0, 0, direction)
+		if bc.IsBusinessDay(t) {
+			return t
+		}
+	}
+}
+
+func (bc *businessCalendar) AddBusinessDays(t time.Time, days int) time.Time {
+	direction := 1
+	if days < 0 {
+		direction = -1
+		days = -days
+	}
+	for i := 0; i < days; i++ {
+		t = bc.step(t, direction)
+	}
+	return t
+}
+
+func (bc *businessCalendar) NextBusinessDay(t time.Time) time.Time {
+	return bc.step(t, 1)
+}
+
+func (bc *businessCalendar) PreviousBusinessDay(t time.Time) time.Time {
+	return bc.step(t, -1)
+}
+
+func (bc *businessCalendar) BusinessDaysBetween(start time.Time, end time.Time) int {
+	startDate, endDate := bc.date(start), bc.date(end)
+	startDay, endDay := startDate.dayNumber(), endDate.dayNumber()
+	sign := 1
+	if endDay < startDay {
+		startDay, endDay = endDay, startDay
+		sign = -1
+	}
+
+	// Count whole weeks at once, then the remaining days individually
+	totalDays := endDay - startDay
+	count := int(totalDays/7) * bc.businessDaysPerWeek
+	for day := startDay + (totalDays/7)*7; day < endDay; day++ {
+		if !bc.weekend[weekdayOf(day)] {
+			count++
+		}
+	}
+
+	// Remove any holidays that fall on business days in the range
+	bc.holidaysLock.RLock()
+	defer bc.holidaysLock.RUnlock()
+	for h := range bc.holidays {
+		day := h.dayNumber()
+		if day >= startDay && day < endDay && !bc.weekend[weekdayOf(day)] {
+			count--
+		}
+	}
+	return sign * count
+}
@@ -0,0 +1,310 @@
+package dateutils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer is an abstraction of time.Timer that can be backed by a fake clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer
+	// has already fired or been stopped.
+	Stop() bool
+	// Reset changes the timer to fire after the given duration. It returns
+	// true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is an abstraction of time.Ticker that can be backed by a fake clock.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset stops the ticker and resets its period to the given duration.
+	Reset(d time.Duration)
+}
+
+// Clock is an abstraction of the time package's functions, so that
+// time-dependent code can be tested without real sleeps.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Until(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+type realClock struct{}
+
+// RealClock is a Clock that uses the actual system time.
+var RealClock Clock = realClock{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Until(t time.Time) time.Duration        { return time.Until(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// ClockOrDefault returns the given clock, or RealClock if it is nil.
+func ClockOrDefault(clock Clock) Clock {
+	if clock == nil {
+		return RealClock
+	}
+	return clock
+}
+
+// FakeClock is a Clock whose time only changes when it is explicitly
+// advanced. Timers, tickers, and sleeps fire when the clock is advanced
+// past their deadlines.
+type FakeClock interface {
+	Clock
+	// Advance moves the clock forward by the given duration, firing any
+	// timers and tickers whose deadlines are reached (in deadline order).
+	Advance(d time.Duration)
+	// Set moves the clock to the given time, firing any timers and tickers
+	// whose deadlines are reached. Setting a time in the past only changes
+	// the value returned by Now.
+	Set(t time.Time)
+	// WaiterCount returns the number of active timers, tickers, and sleeps.
+	WaiterCount() int
+	// BlockUntilWaiters blocks until there are at least n active timers,
+	// tickers, and sleeps. This is useful for ensuring that code under test
+	// has started waiting before advancing the clock.
+	BlockUntilWaiters(n int)
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	// The period for tickers, 0 for timers
+	period time.Duration
+	c      chan time.Time
+	active bool
+}
+
+type fakeClock struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock creates a new fake clock that starts at the given time.
+func NewFakeClock(start time.Time) FakeClock {
+	fc := &fakeClock{
+		now: start,
+	}
+	fc.cond = sync.NewCond(&fc.lock)
+	return fc
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Since(t time.Time) time.Duration {
+	return fc.Now().Sub(t)
+}
+
+func (fc *fakeClock) Until(t time.Time) time.Duration {
+	return t.Sub(fc.Now())
+}
+
+func (fc *fakeClock) After(d time.Duration) <-chan time.Time {
+	return fc.NewTimer(d).C()
+}
+
+func (fc *fakeClock) Sleep(d time.Duration) {
+	<-fc.After(d)
+}
+
+// addWaiter adds a waiter and fires it immediately if it is already due.
+func (fc *fakeClock) addWaiter(d time.Duration, period time.Duration) *fakeWaiter {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	w := &fakeWaiter{
+		period: period,
+		c:      make(chan time.Time, 1),
+	}
+	fc.schedule(w, d)
+	return w
+}
+
+// schedule (re)schedules a waiter. The lock must be held.
+func (fc *fakeClock) schedule(w *fakeWaiter, d time.Duration) {
+	w.deadline = fc.now.Add(d)
+	if !w.active {
+		w.active = true
+		fc.waiters = append(fc.waiters, w)
+	}
+	fc.fire()
+	fc.cond.Broadcast()
+}
+
+// unschedule removes a waiter, returning whether it was active. The lock must be held.
+func (fc *fakeClock) unschedule(w *fakeWaiter) bool {
+	if !w.active {
+		return false
+	}
+	w.active = false
+	for i, other := range fc.waiters {
+		if other == w {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// fire sends on the channels of all waiters that are due. The lock must be held.
+func (fc *fakeClock) fire() {
+	sort.SliceStable(fc.waiters, func(i, j int) bool {
+		return fc.waiters[i].deadline.Before(fc.waiters[j].deadline)
+	})
+	remaining := fc.waiters[:0]
+	for _, w := range fc.waiters {
+		if w.deadline.After(fc.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+		// Like the real timers, drop the value if the previous one hasn't been received
+		select {
+		case w.c <- w.deadline:
+		default:
+		}
+		if w.period > 0 {
+			// Tickers skip any ticks that were missed
+			for !w.deadline.After(fc.now) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+			remaining = append(remaining, w)
+		} else {
+			w.active = false
+		}
+	}
+	fc.waiters = remaining
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.now = fc.now.Add(d)
+	fc.fire()
+}
+
+func (fc *fakeClock) Set(t time.Time) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.now = t
+	fc.fire()
+}
+
+func (fc *fakeClock) WaiterCount() int {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return len(fc.waiters)
+}
+
+func (fc *fakeClock) BlockUntilWaiters(n int) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	for len(fc.waiters) < n {
+		fc.cond.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	waiter *fakeWaiter
+}
+
+func (fc *fakeClock) NewTimer(d time.Duration) Timer {
+	return &fakeTimer{
+		clock:  fc,
+		waiter: fc.addWaiter(d, 0),
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	return t.clock.unschedule(t.waiter)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	wasActive := t.waiter.active
+	t.clock.schedule(t.waiter, d)
+	return wasActive
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	waiter *fakeWaiter
+}
+
+func (fc *fakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &fakeTicker{
+		clock:  fc,
+		waiter: fc.addWaiter(d, d),
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.clock.unschedule(t.waiter)
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.waiter.period = d
+	t.clock.schedule(t.waiter, d)
+}
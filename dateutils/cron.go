@@ -0,0 +1,358 @@
+package dateutils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// CronSchedule is a parsed cron expression.
+type CronSchedule interface {
+	// Next returns the first time strictly after the given time that matches the
+	// schedule. If no time matches within 5 years, the zero time is returned.
+	//
+	// Around daylight saving transitions, wall-clock times that are skipped (e.g. 02:30
+	// when clocks spring forward from 02:00 to 03:00) never match, so a schedule that
+	// only fires at such a time doesn't run that day. Wall-clock times that are repeated
+	// (when clocks fall back) only match once if the hour and minute fields aren't
+	// wildcards, so fixed-time schedules don't run twice; schedules with a wildcard hour
+	// or minute match during both occurrences.
+	Next(after time.Time) time.Time
+	// Prev returns the last time strictly before the given time that matches the
+	// schedule. If no time matches within 5 years, the zero time is returned.
+	Prev(before time.Time) time.Time
+	// Upcoming returns the next `count` times after the given time that match the schedule.
+	// If `count` is 0 or less, an empty slice is returned.
+	Upcoming(after time.Time, count int) []time.Time
+	// Iterator returns a function that returns each successive time after the given
+	// time that matches the schedule. The boolean is false once no more times match.
+	Iterator(after time.Time) func() (t time.Time, ok bool)
+	// Location returns the location that the schedule is evaluated in.
+	Location() *time.Location
+	// String returns the original cron expression.
+	String() string
+}
+
+// cronSchedule is a cron schedule, with each field stored as a bitmask.
+type cronSchedule struct {
+	expr                                  string
+	second, minute, hour, dom, month, dow uint64
+	location                              *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{min: 0, max: 59}
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// A bit that marks a field as having been a wildcard ("*"), which matters
+// for how day-of-month and day-of-week are combined.
+const starBit uint64 = 1 << 63
+
+// The maximum amount of time to search for a matching time
+const cronSearchYears = 5
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression, evaluated in UTC. See ParseCronInLocation.
+func ParseCron(expr string) (CronSchedule, stackerr.Error) {
+	return ParseCronInLocation(expr, time.UTC)
+}
+
+// ParseCronInLocation parses a cron expression that will be evaluated in the given
+// location. The expression can be a standard 5-field expression (minute, hour, day
+// of month, month, day of week), a 6-field expression with a leading seconds field,
+// or one of the common macros (e.g. "@hourly"). Fields support wildcards ("*" or "?"),
+// ranges ("1-5"), steps ("*/15", "5/10"), lists ("1,3,5"), and month/day names.
+func ParseCronInLocation(expr string, location *time.Location) (CronSchedule, stackerr.Error) {
+	if location == nil {
+		location = time.UTC
+	}
+	normalized := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(normalized)]; ok {
+		normalized = macro
+	}
+	parts := strings.Fields(normalized)
+	if len(parts) == 5 {
+		// No seconds field, so always run at the start of the minute
+		parts = append([]string{"0"}, parts...)
+	} else if len(parts) != 6 {
+		return nil, stackerr.Errorf("cron expression '%s' must have 5 or 6 fields, found %d", expr, len(parts))
+	}
+	fields := []cronField{secondField, minuteField, hourField, domField, monthField, dowField}
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		b, err := fields[i].parse(part)
+		if err != nil {
+			return nil, err.WithSingle("cron_expression", expr)
+		}
+		bits[i] = b
+	}
+	// Day of week 7 is an alias for Sunday
+	if bits[5]&(1<<7) > 0 {
+		bits[5] = (bits[5] &^ (1 << 7)) | 1
+	}
+	return &cronSchedule{
+		expr:     expr,
+		second:   bits[0],
+		minute:   bits[1],
+		hour:     bits[2],
+		dom:      bits[3],
+		month:    bits[4],
+		dow:      bits[5],
+		location: location,
+	}, nil
+}
+
+func (f cronField) value(s string) (int, stackerr.Error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, stackerr.Errorf("invalid cron value '%s'", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, stackerr.Errorf("cron value %d is out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (f cronField) parse(s string) (uint64, stackerr.Error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, stackerr.Errorf("invalid cron step in '%s'", item)
+			}
+			item = item[:idx]
+		}
+		var start, end int
+		isStar := false
+		switch {
+		case item == "*" || item == "?":
+			start, end = f.min, f.max
+			isStar = step == 1
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err stackerr.Error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if end < start {
+				return 0, stackerr.Errorf("invalid cron range '%s'", item)
+			}
+		default:
+			var err stackerr.Error
+			if start, err = f.value(item); err != nil {
+				return 0, err
+			}
+			end = start
+			// "5/10" means starting at 5, every 10
+			if step > 1 {
+				end = f.max
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+		if isStar {
+			bits |= starBit
+		}
+	}
+	return bits, nil
+}
+
+func (cs *cronSchedule) String() string {
+	return cs.expr
+}
+
+func (cs *cronSchedule) Location() *time.Location {
+	return cs.location
+}
+
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) > 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) > 0
+	// Standard cron behaviour: if either field is a wildcard, both must match
+	// (which is effectively just the other one). Otherwise, either may match.
+	if cs.dom&starBit > 0 || cs.dow&starBit > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// isRepeatedWallClock returns whether the time is the second occurrence of a wall-clock
+// time that occurs twice because clocks were set back (e.g. at the end of daylight saving).
+func isRepeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, previousOffset := t.Add(-24 * time.Hour).Zone()
+	if previousOffset <= offset {
+		return false
+	}
+	// If the clocks were set back, the first occurrence of this wall-clock
+	// time was the difference in offsets earlier.
+	earlier := t.Add(-time.Duration(previousOffset-offset) * time.Second)
+	if _, earlierOffset := earlier.Zone(); earlierOffset != previousOffset {
+		return false
+	}
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := earlier.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 && t.Hour() == earlier.Hour() && t.Minute() == earlier.Minute() && t.Second() == earlier.Second()
+}
+
+// skipRepeated returns whether a matching time should be skipped because it's the second
+// occurrence of a repeated wall-clock time and the schedule has a fixed hour and minute.
+func (cs *cronSchedule) skipRepeated(t time.Time) bool {
+	return cs.hour&starBit == 0 && cs.minute&starBit == 0 && isRepeatedWallClock(t)
+}
+
+// forward returns the candidate if it's after the current time. Around daylight saving
+// transitions, a wall-clock time that doesn't exist may be normalized to an earlier time,
+// in which case it falls back to the start of the next minute so progress is always made.
+func forward(current time.Time, candidate time.Time) time.Time {
+	if candidate.After(current) {
+		return candidate
+	}
+	return current.Truncate(time.Minute).Add(time.Minute)
+}
+
+// backward is the same as forward, but for moving backwards in time.
+func backward(current time.Time, candidate time.Time) time.Time {
+	if candidate.Before(current) {
+		return candidate
+	}
+	return current.Truncate(time.Minute).Add(-time.Second)
+}
+
+func (cs *cronSchedule) Next(after time.Time) time.Time {
+	originalLocation := after.Location()
+	t := after.In(cs.location).Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	loc := cs.location
+
+	for t.Before(limit) {
+		if cs.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !cs.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if cs.hour&(1<<uint(t.Hour())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+			continue
+		}
+		if cs.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if cs.second&(1<<uint(t.Second())) == 0 || cs.skipRepeated(t) {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t.In(originalLocation)
+	}
+	return time.Time{}
+}
+
+func (cs *cronSchedule) Prev(before time.Time) time.Time {
+	originalLocation := before.Location()
+	t := before.In(cs.location)
+	if truncated := t.Truncate(time.Second); truncated.Equal(t) {
+		t = t.Add(-time.Second)
+	} else {
+		t = truncated
+	}
+	limit := t.AddDate(-cronSearchYears, 0, 0)
+	loc := cs.location
+
+	// Each step moves to the last second of the previous period
+	for t.After(limit) {
+		if cs.month&(1<<uint(t.Month())) == 0 {
+			t = backward(t, time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).Add(-time.Second))
+			continue
+		}
+		if !cs.dayMatches(t) {
+			t = backward(t, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-time.Second))
+			continue
+		}
+		if cs.hour&(1<<uint(t.Hour())) == 0 {
+			t = backward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc).Add(-time.Second))
+			continue
+		}
+		if cs.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(-time.Second)
+			continue
+		}
+		if cs.second&(1<<uint(t.Second())) == 0 || cs.skipRepeated(t) {
+			t = t.Add(-time.Second)
+			continue
+		}
+		return t.In(originalLocation)
+	}
+	return time.Time{}
+}
+
+func (cs *cronSchedule) Upcoming(after time.Time, count int) []time.Time {
+	if count <= 0 {
+		return []time.Time{}
+	}
+	times := make([]time.Time, 0, count)
+	next := cs.Iterator(after)
+	for len(times) < count {
+		t, ok := next()
+		if !ok {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+func (cs *cronSchedule) Iterator(after time.Time) func() (t time.Time, ok bool) {
+	current := after
+	done := false
+	return func() (time.Time, bool) {
+		if done {
+			return time.Time{}, false
+		}
+		current = cs.Next(current)
+		if current.IsZero() {
+			done = true
+			return time.Time{}, false
+		}
+		return current, true
+	}
+}
@@ -0,0 +1,61 @@
+package dateutils
+
+import (
+	"context"
+	"time"
+)
+
+// RemainingTime returns the time remaining until the context's deadline. If the
+// context has no deadline, the boolean is false. If the deadline has already
+// passed, the duration will be 0.
+func RemainingTime(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// HasAtLeast returns whether the context has at least the given amount of time
+// remaining before its deadline. A context without a deadline always has enough
+// time, unless it's already done.
+func HasAtLeast(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	remaining, ok := RemainingTime(ctx)
+	return !ok || remaining >= d
+}
+
+// SubContextWithFraction creates a child context whose deadline is the given fraction
+// (in the range (0, 1]) of the parent's remaining time. This is useful for reserving time
+// for cleanup or retries, e.g. giving an operation 80% of a Lambda's remaining time.
+// If the parent has no deadline, the child is only cancellable.
+func SubContextWithFraction(ctx context.Context, fraction float64) (context.Context, context.CancelFunc) {
+	remaining, ok := RemainingTime(ctx)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	if fraction <= 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return context.WithTimeout(ctx, time.Duration(float64(remaining)*fraction))
+}
+
+// SubContextWithReserve creates a child context whose deadline is the given amount of
+// time before the parent's deadline. If the parent has no deadline, the child is only
+// cancellable. If the parent has less time remaining than the reserve, the child is
+// already done.
+func SubContextWithReserve(ctx context.Context, reserve time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-reserve))
+}
@@ -0,0 +1,55 @@
+package dateutils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The units used by FormatDuration, from largest to smallest
+var durationUnits = []struct {
+	size   time.Duration
+	suffix string
+}{
+	{24 * time.Hour, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+}
+
+// FormatDuration formats a duration in a short human-readable form, using (at most)
+// the two most significant units, e.g. "3d 4h", "2h 5m", "1m 30s", or "12s". Durations
+// under a second are formatted like time.Duration.String, rounded to the microsecond
+// (e.g. "350ms"). The result is truncated, not rounded, so "1h 59m 59s" is "1h 59m".
+func FormatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		// The minimum duration can't be negated, so take one nanosecond off first
+		if d == time.Duration(-1<<63) {
+			d++
+		}
+		d = -d
+	}
+	if d < time.Second {
+		return sign + d.Round(time.Microsecond).String()
+	}
+	parts := make([]string, 0, 2)
+	for _, unit := range durationUnits {
+		if len(parts) == 2 {
+			break
+		}
+		count := d / unit.size
+		if count == 0 && len(parts) == 0 {
+			continue
+		}
+		d -= count * unit.size
+		if count > 0 {
+			parts = append(parts, strconv.FormatInt(int64(count), 10)+unit.suffix)
+		} else {
+			// A zero in the second place means the rest is insignificant
+			break
+		}
+	}
+	return sign + strings.Join(parts, " ")
+}
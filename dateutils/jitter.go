@@ -0,0 +1,156 @@
+package dateutils
+
+import (
+	"context"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Jitter returns the duration randomly adjusted by up to +/- the given fraction
+// of itself (e.g. a fraction of 0.1 returns a duration between 90% and 110% of
+// the original). The fraction is limited to the range [0, 1].
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return numbers.Jitter(d, fraction)
+}
+
+// WaiterJittered is the same as Waiter, but the duration is randomly adjusted
+// by up to +/- the given fraction of itself.
+func WaiterJittered(ctx context.Context, duration time.Duration, jitterFraction float64, closeOnCtxDone bool) <-chan struct{} {
+	return Waiter(ctx, Jitter(duration, jitterFraction), closeOnCtxDone)
+}
+
+// JitteredTicker returns a channel that receives the current time repeatedly, with
+// each interval randomly adjusted by up to +/- the given fraction of the base interval.
+// This prevents many processes with the same interval from synchronizing. Like a
+// time.Ticker, ticks are dropped if the receiver isn't ready. The channel is closed
+// once the context is done.
+func JitteredTicker(ctx context.Context, base time.Duration, jitterFraction float64) <-chan time.Time {
+	return JitteredTickerWithClock(ctx, RealClock, base, jitterFraction)
+}
+
+// JitteredTickerWithClock is the same as JitteredTicker, but uses the given clock.
+// If the clock is nil, the real clock will be used.
+func JitteredTickerWithClock(ctx context.Context, clock Clock, base time.Duration, jitterFraction float64) <-chan time.Time {
+	if base <= 0 {
+		panic("non-positive interval for JitteredTicker")
+	}
+	clock = ClockOrDefault(clock)
+	c := make(chan time.Time, 1)
+	go func() {
+		defer close(c)
+		timer := clock.NewTimer(Jitter(base, jitterFraction))
+		for {
+			select {
+			case <-ctx.Done():
+				if !timer.Stop() {
+					<-timer.C()
+				}
+				return
+			case t := <-timer.C():
+				select {
+				case c <- t:
+				default:
+				}
+				timer.Reset(Jitter(base, jitterFraction))
+			}
+		}
+	}()
+	return c
+}
+
+type EveryInput struct {
+	// The interval between the scheduled start of each run
+	Interval time.Duration
+	// The function to run
+	Func func(ctx context.Context) stackerr.Error
+	// OPTIONAL. The fraction of the interval (in the range [0, 1]) to randomly
+	// adjust each run's start time by.
+	JitterFraction float64
+	// OPTIONAL. Whether to run the function immediately, instead of waiting
+	// for the first interval to pass.
+	RunImmediately bool
+	// OPTIONAL. If provided, errors (including recovered panics) are passed to this
+	// function and the runs continue. If not provided, the first error stops the runs
+	// and is returned.
+	OnError func(err stackerr.Error)
+	// OPTIONAL. The clock to use. If not provided, the real clock will be used.
+	Clock Clock
+}
+
+// Every runs the function at the given interval until the context is done or the function
+// returns an error (or panics). See EveryWithInput.
+func Every(ctx context.Context, interval time.Duration, f func(ctx context.Context) stackerr.Error) stackerr.Error {
+	return EveryWithInput(ctx, EveryInput{
+		Interval: interval,
+		Func:     f,
+	})
+}
+
+// EveryWithInput runs a function at a fixed interval until the context is done. Runs are
+// scheduled relative to the start time rather than the end of the previous run, so the
+// schedule doesn't drift as runs take time. If a run takes longer than the interval, the
+// missed runs are skipped. Panics in the function are recovered and treated as errors.
+// Returns nil when the context is done.
+func EveryWithInput(ctx context.Context, input EveryInput) stackerr.Error {
+	if input.Interval <= 0 {
+		return stackerr.Errorf("the `input.Interval` field must be greater than 0")
+	}
+	if input.Func == nil {
+		return stackerr.Errorf("the `input.Func` field must not be nil")
+	}
+	clock := ClockOrDefault(input.Clock)
+
+	run := func() (err stackerr.Error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = stackerr.FromRecover(r)
+			}
+		}()
+		return input.Func(ctx)
+	}
+
+	start := clock.Now()
+	// The index of the next scheduled run
+	var n int64 = 1
+	if input.RunImmediately {
+		n = 0
+	}
+	for {
+		scheduled := start.Add(time.Duration(n) * input.Interval)
+		wait := clock.Until(scheduled)
+		if input.JitterFraction > 0 {
+			wait += Jitter(input.Interval, input.JitterFraction) - input.Interval
+		}
+		if wait > 0 {
+			timer := clock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				if !timer.Stop() {
+					<-timer.C()
+				}
+				return nil
+			case <-timer.C():
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := run(); err != nil {
+			if input.OnError == nil {
+				return err
+			}
+			input.OnError(err)
+		}
+
+		// Skip any runs that were missed while this one was running
+		n++
+		if elapsed := clock.Since(start); elapsed >= time.Duration(n)*input.Interval {
+			n = int64(elapsed/input.Interval) + 1
+		}
+	}
+}
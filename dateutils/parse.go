@@ -0,0 +1,75 @@
+package dateutils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The layouts that ParseTimeFlexible tries after any custom layouts
+var flexibleTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	time.UnixDate,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ParseTimeFlexible parses a time that may be in one of many formats. The custom layouts are
+// tried first (in order), followed by RFC3339, RFC1123, and other common layouts. If none of
+// those match, the string is parsed as a Unix timestamp in seconds (which may have a decimal
+// part). Layouts without a time zone are parsed as UTC. To parse Unix timestamps in a
+// different unit, use ParseTimeFlexibleUnix.
+func ParseTimeFlexible(s string, layouts ...string) (time.Time, stackerr.Error) {
+	return ParseTimeFlexibleUnix(s, time.Second, layouts...)
+}
+
+// ParseTimeFlexibleUnix is the same as ParseTimeFlexible, but Unix timestamps are parsed
+// in the given unit, which must be time.Second, time.Millisecond, time.Microsecond,
+// or time.Nanosecond.
+func ParseTimeFlexibleUnix(s string, unit time.Duration, layouts ...string) (time.Time, stackerr.Error) {
+	switch unit {
+	case time.Second, time.Millisecond, time.Microsecond, time.Nanosecond:
+	default:
+		return time.Time{}, stackerr.Errorf("unsupported Unix timestamp unit %s", unit)
+	}
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return time.Time{}, stackerr.Errorf("cannot parse an empty string as a time")
+	}
+	for _, layoutSet := range [][]string{layouts, flexibleTimeLayouts} {
+		for _, layout := range layoutSet {
+			if t, err := time.Parse(layout, trimmed); err == nil {
+				return t, nil
+			}
+		}
+	}
+	if i, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+		switch unit {
+		case time.Second:
+			return TimeFromUnixSeconds(i), nil
+		case time.Millisecond:
+			return TimeFromUnixMillis(i), nil
+		case time.Microsecond:
+			return TimeFromUnixMicros(i), nil
+		default:
+			return TimeFromUnixNanos(i), nil
+		}
+	}
+	if f, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		// Convert to seconds, then split into whole seconds and nanoseconds
+		f = f * float64(unit) / float64(time.Second)
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+	return time.Time{}, stackerr.Errorf("could not parse '%s' as a time in any known format", s)
+}
@@ -0,0 +1,68 @@
+package dateutils
+
+import (
+	"math"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+)
+
+// RateToInterval converts a rate (events per second) to the interval between events.
+// If the rate is not positive, 0 is returned. If the rate is so low that the interval
+// overflows a time.Duration, the maximum duration is returned.
+func RateToInterval(perSecond float64) time.Duration {
+	if perSecond <= 0 || math.IsNaN(perSecond) {
+		return 0
+	}
+	interval := float64(time.Second) / perSecond
+	// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit in an int64
+	if interval >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(interval)
+}
+
+// IntervalToRate converts the interval between events to a rate (events per second).
+// If the interval is not positive, 0 is returned.
+func IntervalToRate(interval time.Duration) float64 {
+	if interval <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(interval)
+}
+
+// RateOver returns the rate (events per second) of the given number of events over the
+// given duration. If the duration is not positive, 0 is returned.
+func RateOver[T constraints.Simple](count T, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
+
+// DurationPercent returns the elapsed duration as a percentage (0-100) of the total
+// duration. The result is not limited to 100, so it can be used to detect overruns.
+// If the total is not positive, 0 is returned.
+func DurationPercent(elapsed time.Duration, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float64(elapsed) / float64(total)
+}
+
+// EstimateETA estimates the time remaining to complete the total amount of work, assuming
+// the rest of the work proceeds at the same rate as the work that's done so far. The boolean
+// is false if no estimate can be made (no work has been done yet).
+func EstimateETA[T constraints.Simple](done T, total T, elapsed time.Duration) (time.Duration, bool) {
+	if done <= 0 || elapsed < 0 {
+		return 0, false
+	}
+	if done >= total {
+		return 0, true
+	}
+	remaining := float64(elapsed) * (float64(total) - float64(done)) / float64(done)
+	if remaining >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(remaining), true
+}
@@ -0,0 +1,145 @@
+package dateutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// loggerOrDefault returns the logger, or the default logger if it's nil.
+func loggerOrDefault(logger log.Logger) log.Logger {
+	if logger == nil {
+		return log.FromContext(context.Background())
+	}
+	return logger
+}
+
+// Lap is a single timed segment of a stopwatch.
+type Lap struct {
+	Name string
+	// The duration of this segment (since the previous lap, or the start)
+	Duration time.Duration
+	// The total duration from the start of the stopwatch to the end of this lap
+	Elapsed time.Duration
+}
+
+// Stopwatch measures the total time of an operation, and the time of each
+// named segment (lap) within it.
+type Stopwatch interface {
+	// Lap ends the current segment with the given name and starts a new one.
+	// It returns the duration of the segment that was ended.
+	Lap(name string) time.Duration
+	// Laps returns all laps recorded so far.
+	Laps() []Lap
+	// Elapsed returns the total time since the stopwatch was started.
+	Elapsed() time.Duration
+	// Fields returns the timings as key-value pairs for structured logging: the total
+	// duration as "duration", and each lap's duration as "lap_<name>".
+	Fields() []interface{}
+	// Log logs the timings at the info level with the given message. If the
+	// logger is nil, the default logger will be used.
+	Log(logger log.Logger, msg string)
+}
+
+type stopwatch struct {
+	clock   Clock
+	lock    sync.Mutex
+	start   time.Time
+	lastLap time.Time
+	laps    []Lap
+}
+
+// NewStopwatch creates and starts a new stopwatch.
+func NewStopwatch() Stopwatch {
+	return NewStopwatchWithClock(RealClock)
+}
+
+// NewStopwatchWithClock creates and starts a new stopwatch that uses the given
+// clock. If the clock is nil, the real clock will be used.
+func NewStopwatchWithClock(clock Clock) Stopwatch {
+	clock = ClockOrDefault(clock)
+	now := clock.Now()
+	return &stopwatch{
+		clock:   clock,
+		start:   now,
+		lastLap: now,
+	}
+}
+
+func (s *stopwatch) Lap(name string) time.Duration {
+	now := s.clock.Now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	lap := Lap{
+		Name:     name,
+		Duration: now.Sub(s.lastLap),
+		Elapsed:  now.Sub(s.start),
+	}
+	s.laps = append(s.laps, lap)
+	s.lastLap = now
+	return lap.Duration
+}
+
+func (s *stopwatch) Laps() []Lap {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	laps := make([]Lap, len(s.laps))
+	copy(laps, s.laps)
+	return laps
+}
+
+func (s *stopwatch) Elapsed() time.Duration {
+	return s.clock.Since(s.start)
+}
+
+func (s *stopwatch) Fields() []interface{} {
+	laps := s.Laps()
+	fields := make([]interface{}, 0, 2+2*len(laps))
+	fields = append(fields, "duration", s.Elapsed())
+	for _, lap := range laps {
+		fields = append(fields, "lap_"+lap.Name, lap.Duration)
+	}
+	return fields
+}
+
+func (s *stopwatch) Log(logger log.Logger, msg string) {
+	loggerOrDefault(logger).Infow(msg, s.Fields()...)
+}
+
+// TimeFunc runs the function and logs how long it took at the info level. If the
+// logger is nil, the default logger will be used. The function's error is returned.
+func TimeFunc(logger log.Logger, name string, f func() stackerr.Error) stackerr.Error {
+	return TimeFuncWithThreshold(logger, name, 0, f)
+}
+
+// TimeFuncWithThreshold runs the function and logs how long it took. If the duration
+// exceeds the threshold (and the threshold is greater than 0), it is logged as a warning
+// instead of at the info level. If the logger is nil, the default logger will be used.
+// The function's error is returned.
+func TimeFuncWithThreshold(logger log.Logger, name string, threshold time.Duration, f func() stackerr.Error) stackerr.Error {
+	return TimeFuncWithClock(RealClock, logger, name, threshold, f)
+}
+
+// TimeFuncWithClock is the same as TimeFuncWithThreshold, but measures the duration
+// with the given clock. If the clock is nil, the real clock will be used.
+func TimeFuncWithClock(clock Clock, logger log.Logger, name string, threshold time.Duration, f func() stackerr.Error) stackerr.Error {
+	clock = ClockOrDefault(clock)
+	logger = loggerOrDefault(logger)
+	start := clock.Now()
+	err := f()
+	duration := clock.Since(start)
+	fields := []interface{}{
+		"timer_name", name,
+		"duration", duration,
+		"success", err == nil,
+	}
+	if threshold > 0 && duration > threshold {
+		logger.Warnw("Timed operation exceeded threshold", append(fields, "threshold", threshold)...)
+	} else {
+		logger.Infow("Timed operation completed", fields...)
+	}
+	return err
+}
@@ -9,6 +9,8 @@ import (
 )
 
 // TimeFromUnix will parse a Unix timestamp that can be in seconds, milliseconds, microseconds, or nanoseconds.
+// The unit is guessed from the magnitude, so when the unit is known, the explicit functions
+// (e.g. TimeFromUnixMillis) should be used instead.
 func TimeFromUnix[T constraints.Integer](unix T) time.Time {
 	u := int64(unix)
 	magnitude := numbers.Abs(u)
@@ -28,3 +30,43 @@ func TimeFromUnix[T constraints.Integer](unix T) time.Time {
 		return time.Unix(u/1e9, u%1e9)
 	}
 }
+
+// TimeFromUnixSeconds converts a Unix timestamp in seconds to a time.
+func TimeFromUnixSeconds[T constraints.Integer](unix T) time.Time {
+	return time.Unix(int64(unix), 0)
+}
+
+// TimeFromUnixMillis converts a Unix timestamp in milliseconds to a time.
+func TimeFromUnixMillis[T constraints.Integer](unix T) time.Time {
+	return time.UnixMilli(int64(unix))
+}
+
+// TimeFromUnixMicros converts a Unix timestamp in microseconds to a time.
+func TimeFromUnixMicros[T constraints.Integer](unix T) time.Time {
+	return time.UnixMicro(int64(unix))
+}
+
+// TimeFromUnixNanos converts a Unix timestamp in nanoseconds to a time.
+func TimeFromUnixNanos[T constraints.Integer](unix T) time.Time {
+	return time.Unix(0, int64(unix))
+}
+
+// ToUnixSeconds converts a time to a Unix timestamp in seconds.
+func ToUnixSeconds(t time.Time) int64 {
+	return t.Unix()
+}
+
+// ToUnixMillis converts a time to a Unix timestamp in milliseconds.
+func ToUnixMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// ToUnixMicros converts a time to a Unix timestamp in microseconds.
+func ToUnixMicros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+// ToUnixNanos converts a time to a Unix timestamp in nanoseconds.
+func ToUnixNanos(t time.Time) int64 {
+	return t.UnixNano()
+}
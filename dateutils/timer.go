@@ -14,18 +14,24 @@ func Waiter(ctx context.Context, duration time.Duration, closeOnCtxDone bool) <-
 // WaiterWithCallback will return a channel that will close after the specified duration.
 // If the context is cancelled, the channel will never close.
 func WaiterWithCallback(ctx context.Context, duration time.Duration, closeOnCtxDone bool, callback func(ctx context.Context)) <-chan struct{} {
+	return WaiterWithClock(ctx, RealClock, duration, closeOnCtxDone, callback)
+}
+
+// WaiterWithClock is the same as WaiterWithCallback, but uses the given clock
+// for the timer. If the clock is nil, the real clock will be used.
+func WaiterWithClock(ctx context.Context, clock Clock, duration time.Duration, closeOnCtxDone bool, callback func(ctx context.Context)) <-chan struct{} {
 	waitChan := make(chan struct{})
-	timer := time.NewTimer(duration)
+	timer := ClockOrDefault(clock).NewTimer(duration)
 	go func() {
 		select {
-		case <-timer.C:
+		case <-timer.C():
 			close(waitChan)
 			if callback != nil {
 				callback(ctx)
 			}
 		case <-ctx.Done():
 			if !timer.Stop() {
-				<-timer.C
+				<-timer.C()
 			}
 			if closeOnCtxDone {
 				close(waitChan)
@@ -0,0 +1,219 @@
+package debugging
+
+import (
+	"context"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Goroutine is a parsed goroutine from a stack dump.
+type Goroutine struct {
+	ID int64
+	// The state of the goroutine, e.g. "running" or "chan receive"
+	State string
+	// The function that the goroutine is currently in
+	TopFunction string
+	// The full stack trace, including the header line
+	Stack string
+}
+
+// Goroutines returns all goroutines that currently exist.
+func Goroutines() []Goroutine {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return parseGoroutines(string(buf))
+}
+
+// parseGoroutines parses the output of runtime.Stack.
+func parseGoroutines(dump string) []Goroutine {
+	goroutines := []Goroutine{}
+	for _, block := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		lines := strings.Split(block, "\n")
+		// The header is in the format "goroutine 123 [state, 5 minutes]:"
+		header := strings.TrimPrefix(lines[0], "goroutine ")
+		idEnd := strings.IndexByte(header, ' ')
+		if idEnd < 0 {
+			continue
+		}
+		id, err := strconv.ParseInt(header[:idEnd], 10, 64)
+		if err != nil {
+			continue
+		}
+		state := strings.TrimSuffix(strings.TrimPrefix(header[idEnd+1:], "["), "]:")
+		if idx := strings.IndexByte(state, ','); idx >= 0 {
+			state = state[:idx]
+		}
+		g := Goroutine{
+			ID:    id,
+			State: state,
+			Stack: block,
+		}
+		if len(lines) > 1 {
+			g.TopFunction = lines[1]
+			if idx := strings.LastIndexByte(g.TopFunction, '('); idx > 0 {
+				g.TopFunction = g.TopFunction[:idx]
+			}
+		}
+		goroutines = append(goroutines, g)
+	}
+	return goroutines
+}
+
+// currentGoroutineID gets the ID of the calling goroutine.
+func currentGoroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	gs := parseGoroutines(string(buf))
+	if len(gs) == 0 {
+		return 0
+	}
+	return gs[0].ID
+}
+
+// Stack patterns of goroutines that are always ignored, since they belong
+// to the runtime or the testing framework rather than the code under test.
+var defaultLeakIgnorePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^testing\.\(\*T\)\.Run\(`),
+	regexp.MustCompile(`(?m)^testing\.\(\*M\)\.`),
+	regexp.MustCompile(`(?m)^testing\.tRunner\(`),
+	regexp.MustCompile(`(?m)^testing\.runTests\(`),
+	regexp.MustCompile(`(?m)^os/signal\.(signal_recv|loop)\(`),
+	regexp.MustCompile(`(?m)^runtime\.goexit\(`),
+	regexp.MustCompile(`(?m)^runtime\.ensureSigM\.`),
+}
+
+type FindLeaksInput struct {
+	// OPTIONAL. Goroutines that existed before the code being checked started (e.g. the
+	// result of calling Goroutines at the start of a test), which are not leaks.
+	Baseline []Goroutine
+	// OPTIONAL. Regular expressions that are matched against each goroutine's stack.
+	// Goroutines that match any of them are not considered leaks.
+	IgnorePatterns []string
+	// OPTIONAL. How long to wait for goroutines to exit before considering them leaked,
+	// since goroutines that are shutting down may take a moment to finish. Defaults to 1 second.
+	Timeout time.Duration
+}
+
+// FindLeaks returns all goroutines (other than the calling goroutine) that aren't in the
+// baseline or ignored, waiting up to the timeout for them to exit.
+func FindLeaks(input FindLeaksInput) ([]Goroutine, stackerr.Error) {
+	if input.Timeout <= 0 {
+		input.Timeout = time.Second
+	}
+	ignore := append([]*regexp.Regexp{}, defaultLeakIgnorePatterns...)
+	for _, pattern := range input.IgnorePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, stackerr.Wrap(err).WithSingle("ignore_pattern", pattern)
+		}
+		ignore = append(ignore, re)
+	}
+	baseline := make(map[int64]struct{}, len(input.Baseline)+1)
+	for _, g := range input.Baseline {
+		baseline[g.ID] = struct{}{}
+	}
+	baseline[currentGoroutineID()] = struct{}{}
+
+	find := func() []Goroutine {
+		leaks := []Goroutine{}
+	goroutines:
+		for _, g := range Goroutines() {
+			if _, ok := baseline[g.ID]; ok {
+				continue
+			}
+			for _, re := range ignore {
+				if re.MatchString(g.Stack) {
+					continue goroutines
+				}
+			}
+			leaks = append(leaks, g)
+		}
+		return leaks
+	}
+
+	deadline := time.Now().Add(input.Timeout)
+	delay := time.Millisecond
+	for {
+		leaks := find()
+		if len(leaks) == 0 || time.Now().After(deadline) {
+			return leaks, nil
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// TestingT is the subset of testing.TB that is used by VerifyNoLeaks.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// VerifyNoLeaks checks that there are no leaked goroutines (see FindLeaks), and reports
+// each one as a test error. It is intended to be deferred (or registered with t.Cleanup)
+// at the start of a test, with the baseline taken before the test does anything:
+//
+//	baseline := debugging.Goroutines()
+//	defer debugging.VerifyNoLeaks(t, debugging.FindLeaksInput{Baseline: baseline})
+func VerifyNoLeaks(t TestingT, input FindLeaksInput) {
+	t.Helper()
+	leaks, err := FindLeaks(input)
+	if err != nil {
+		t.Errorf("failed to check for goroutine leaks: %v", err)
+		return
+	}
+	for _, g := range leaks {
+		t.Errorf("leaked goroutine %d [%s] in %s:\n%s", g.ID, g.State, g.TopFunction, g.Stack)
+	}
+}
+
+// The interval at which WatchGoroutineCount checks the number of goroutines
+const goroutineWatchInterval = 10 * time.Second
+
+// WatchGoroutineCount starts a routine that checks the number of goroutines every 10 seconds
+// until the context is done, calling onExceed when the count exceeds the threshold. It is
+// called once each time the threshold is exceeded, and is re-armed once the count drops back
+// to the threshold or below. If onExceed is nil, a warning is logged instead.
+func WatchGoroutineCount(ctx context.Context, threshold int, onExceed func(ctx context.Context, count int)) {
+	if onExceed == nil {
+		onExceed = func(ctx context.Context, count int) {
+			log.FromContext(ctx).Warnw("Goroutine count exceeded threshold", "goroutine_count", count, "goroutine_threshold", threshold)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(goroutineWatchInterval)
+		defer ticker.Stop()
+		exceeded := false
+		for {
+			count := runtime.NumGoroutine()
+			if count > threshold {
+				if !exceeded {
+					onExceed(ctx, count)
+				}
+				exceeded = true
+			} else {
+				exceeded = false
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -3,6 +3,8 @@ package debugging
 import (
 	"fmt"
 	"runtime"
+
+	"github.com/Invicton-Labs/go-common/numbers"
 )
 
 func GetMemUsage() runtime.MemStats {
@@ -14,14 +16,17 @@ func GetMemUsage() runtime.MemStats {
 func GetFormattedMemUsage() string {
 	m := GetMemUsage()
 	return fmt.Sprintf(`Memory Allocation
-	Total Reserved: %d MiB
-	Heap Reserved: %d MiB
-	Heap In-Use: %d MiB
-	Heap Allocated: %d MiB
-	Stack Reserved: %d MiB
-	Stack In-Use: %d MiB`, bToMb(m.Sys), bToMb(m.HeapSys), bToMb(m.HeapInuse), bToMb(m.HeapAlloc), bToMb(m.StackSys), bToMb(m.StackInuse))
-}
-
-func bToMb(b uint64) uint64 {
-	return b / 1024 / 1024
+	Total Reserved: %s
+	Heap Reserved: %s
+	Heap In-Use: %s
+	Heap Allocated: %s
+	Stack Reserved: %s
+	Stack In-Use: %s`,
+		numbers.FormatBytes(m.Sys),
+		numbers.FormatBytes(m.HeapSys),
+		numbers.FormatBytes(m.HeapInuse),
+		numbers.FormatBytes(m.HeapAlloc),
+		numbers.FormatBytes(m.StackSys),
+		numbers.FormatBytes(m.StackInuse),
+	)
 }
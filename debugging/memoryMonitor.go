@@ -1,39 +1,295 @@
 package debugging
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/Invicton-Labs/go-concurrency"
+	"github.com/Invicton-Labs/go-common/aws/s3"
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/numbers"
 	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 )
 
-var memoryMonitorCtx context.Context = nil
-var maxReserved uint64 = 0
-var maxInUse uint64 = 0
-
-func StartMemoryMonitor(ctx context.Context) context.Context {
-	if memoryMonitorCtx != nil {
-		return memoryMonitorCtx
-	}
-	executor := concurrency.ContinuousFinal(
-		ctx, concurrency.ContinuousFinalInput{
-			Name: "memory-monitor",
-			Func: func(ctx context.Context, metadata *concurrency.RoutineFunctionMetadata) (err stackerr.Error) {
-				mem := GetMemUsage()
-				if mem.Sys > maxReserved {
-					maxReserved = mem.Sys
-				}
-				if mem.HeapInuse+mem.StackInuse > maxInUse {
-					maxInUse = mem.HeapInuse + mem.StackInuse
-				}
+// MemoryThresholdEvent describes a memory threshold being crossed.
+type MemoryThresholdEvent struct {
+	// The threshold that was crossed
+	Threshold MemoryThreshold
+	// The memory limit that the threshold is relative to, in bytes
+	Limit uint64
+	// The memory reserved from the OS when the threshold was crossed, in bytes
+	Reserved uint64
+	// The full memory stats when the threshold was crossed
+	Stats runtime.MemStats
+}
+
+// MemoryThreshold is a fraction of the memory limit that triggers a callback when
+// the reserved memory reaches it.
+type MemoryThreshold struct {
+	// The fraction (in the range (0, 1]) of the memory limit to trigger at
+	Fraction float64
+	// OPTIONAL. The function to call when the threshold is reached. If not provided,
+	// a warning (which is sent to any log write hooks, such as Slack) is logged instead.
+	Callback func(ctx context.Context, event MemoryThresholdEvent)
+}
+
+// HeapProfileConfig configures automatically writing a heap profile when memory
+// usage reaches a threshold, so that there's something to debug after an OOM.
+type HeapProfileConfig struct {
+	// The fraction (in the range (0, 1]) of the memory limit at which to write a profile
+	Fraction float64
+	// OPTIONAL. The local directory to write profiles to (e.g. "/tmp" in Lambda). It
+	// is created if it doesn't exist.
+	Directory string
+	// OPTIONAL. The S3 ARN prefix to upload profiles to (e.g. "arn:aws:s3:::bucket/profiles/").
+	// The profile's file name is appended to it.
+	S3ArnPrefix string
+	// OPTIONAL. The minimum time between profiles, so that sustained memory pressure
+	// doesn't keep writing them. Defaults to 5 minutes.
+	MinInterval time.Duration
+}
+
+type StartMemoryMonitorInput struct {
+	// OPTIONAL. How often to sample the memory usage. Defaults to 1 second.
+	Interval time.Duration
+	// OPTIONAL. The memory limit (in bytes) that thresholds are relative to. If not
+	// provided, the Lambda function's memory size is used when running in Lambda,
+	// otherwise the Go runtime's soft memory limit (see debug.SetMemoryLimit) is used
+	// if one has been set.
+	Limit uint64
+	// OPTIONAL. The thresholds to trigger callbacks at. Each threshold is triggered
+	// once when it's reached, and re-armed when the memory drops back below it.
+	// Thresholds are ignored if there is no memory limit.
+	Thresholds []MemoryThreshold
+	// OPTIONAL. Writes a heap profile (in pprof format) when memory usage reaches a
+	// threshold. It is ignored if there is no memory limit.
+	HeapProfile *HeapProfileConfig
+	// OPTIONAL. The clock to use. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+}
+
+// MemoryMonitor periodically samples the process's memory usage.
+type MemoryMonitor interface {
+	// Stop stops the monitor and waits for it to exit. It is safe to call multiple times.
+	Stop()
+	// Done returns a channel that is closed once the monitor has stopped.
+	Done() <-chan struct{}
+	// MaxReserved returns the maximum memory reserved from the OS, in bytes.
+	MaxReserved() uint64
+	// MaxInUse returns the maximum memory in use by the heap and stacks, in bytes.
+	MaxInUse() uint64
+	// Limit returns the memory limit that thresholds are relative to, in
+	// bytes, or 0 if there is no limit.
+	Limit() uint64
+}
+
+type memoryMonitor struct {
+	lock        sync.Mutex
+	maxReserved uint64
+	maxInUse    uint64
+	limit       uint64
+	thresholds  []MemoryThreshold
+	// Whether each threshold is currently triggered
+	triggered   []bool
+	heapProfile *HeapProfileConfig
+	// When the most recent heap profile was written
+	lastHeapProfile time.Time
+	clock           dateutils.Clock
+	cancel          context.CancelFunc
+	done            chan struct{}
+}
+
+// defaultMemoryLimit gets the memory limit of the Lambda function, or the Go runtime's
+// soft memory limit, or 0 if there is neither.
+func defaultMemoryLimit() uint64 {
+	if lambdacontext.MemoryLimitInMB > 0 {
+		return uint64(lambdacontext.MemoryLimitInMB) * uint64(numbers.MiB)
+	}
+	// A negative value reads the limit without changing it
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit != math.MaxInt64 {
+		return uint64(limit)
+	}
+	return 0
+}
+
+// StartMemoryMonitor starts a routine that samples the memory usage at an interval,
+// tracking the maximum usage and triggering callbacks when thresholds are reached.
+// The monitor runs until it's stopped or the context is done.
+func StartMemoryMonitor(ctx context.Context, input StartMemoryMonitorInput) (MemoryMonitor, stackerr.Error) {
+	if input.Interval <= 0 {
+		input.Interval = time.Second
+	}
+	if input.Limit == 0 {
+		input.Limit = defaultMemoryLimit()
+	}
+	for _, threshold := range input.Thresholds {
+		if !(threshold.Fraction > 0 && threshold.Fraction <= 1) {
+			return nil, stackerr.Errorf("memory threshold fractions must be in the range (0, 1], got %v", threshold.Fraction)
+		}
+	}
+	if input.HeapProfile != nil {
+		if !(input.HeapProfile.Fraction > 0 && input.HeapProfile.Fraction <= 1) {
+			return nil, stackerr.Errorf("the heap profile fraction must be in the range (0, 1], got %v", input.HeapProfile.Fraction)
+		}
+		if input.HeapProfile.Directory == "" && input.HeapProfile.S3ArnPrefix == "" {
+			return nil, stackerr.Errorf("the heap profile config must have a directory and/or an S3 ARN prefix")
+		}
+		// Copy it so that the caller can't modify it while the monitor is running
+		heapProfile := *input.HeapProfile
+		if heapProfile.MinInterval <= 0 {
+			heapProfile.MinInterval = 5 * time.Minute
+		}
+		input.HeapProfile = &heapProfile
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &memoryMonitor{
+		limit:       input.Limit,
+		thresholds:  input.Thresholds,
+		triggered:   make([]bool, len(input.Thresholds)),
+		heapProfile: input.HeapProfile,
+		clock:       dateutils.ClockOrDefault(input.Clock),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go func() {
+		defer close(m.done)
+		_ = dateutils.EveryWithInput(ctx, dateutils.EveryInput{
+			Interval:       input.Interval,
+			RunImmediately: true,
+			Clock:          input.Clock,
+			Func: func(ctx context.Context) stackerr.Error {
+				m.sample(ctx)
 				return nil
 			},
-		}, 10*time.Microsecond)
-	memoryMonitorCtx = executor.Ctx()
-	return memoryMonitorCtx
+			OnError: func(err stackerr.Error) {
+				log.FromContext(ctx).Error(err)
+			},
+		})
+	}()
+	return m, nil
+}
+
+func (m *memoryMonitor) sample(ctx context.Context) {
+	mem := GetMemUsage()
+	inUse := mem.HeapInuse + mem.StackInuse
+
+	m.lock.Lock()
+	if mem.Sys > m.maxReserved {
+		m.maxReserved = mem.Sys
+	}
+	if inUse > m.maxInUse {
+		m.maxInUse = inUse
+	}
+	triggered := []MemoryThreshold{}
+	writeHeapProfile := false
+	if m.limit > 0 {
+		for i, threshold := range m.thresholds {
+			reached := float64(mem.Sys) >= threshold.Fraction*float64(m.limit)
+			if reached && !m.triggered[i] {
+				triggered = append(triggered, threshold)
+			}
+			m.triggered[i] = reached
+		}
+		if m.heapProfile != nil && float64(mem.Sys) >= m.heapProfile.Fraction*float64(m.limit) {
+			now := m.clock.Now()
+			if m.lastHeapProfile.IsZero() || now.Sub(m.lastHeapProfile) >= m.heapProfile.MinInterval {
+				m.lastHeapProfile = now
+				writeHeapProfile = true
+			}
+		}
+	}
+	m.lock.Unlock()
+
+	// Run the callbacks without holding the lock
+	for _, threshold := range triggered {
+		event := MemoryThresholdEvent{
+			Threshold: threshold,
+			Limit:     m.limit,
+			Reserved:  mem.Sys,
+			Stats:     mem,
+		}
+		if threshold.Callback != nil {
+			threshold.Callback(ctx, event)
+			continue
+		}
+		log.FromContext(ctx).Warnw("Memory usage threshold reached",
+			"memory_threshold_percent", threshold.Fraction*100,
+			"memory_reserved", numbers.FormatBytes(mem.Sys),
+			"memory_limit", numbers.FormatBytes(m.limit),
+		)
+	}
+
+	if writeHeapProfile {
+		if err := m.writeHeapProfile(ctx); err != nil {
+			log.FromContext(ctx).Error(err)
+		}
+	}
+}
+
+// writeHeapProfile writes a heap profile to the configured directory and/or S3 prefix.
+func (m *memoryMonitor) writeHeapProfile(ctx context.Context) stackerr.Error {
+	buf := bytes.Buffer{}
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
+		return stackerr.Wrap(err)
+	}
+	name := fmt.Sprintf("heap-%s-%d.pb.gz", m.clock.Now().UTC().Format("20060102T150405.000Z"), os.Getpid())
+	locations := []string{}
+	if m.heapProfile.Directory != "" {
+		if err := os.MkdirAll(m.heapProfile.Directory, 0o755); err != nil {
+			return stackerr.Wrap(err)
+		}
+		path := filepath.Join(m.heapProfile.Directory, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return stackerr.Wrap(err)
+		}
+		locations = append(locations, path)
+	}
+	if m.heapProfile.S3ArnPrefix != "" {
+		arn := m.heapProfile.S3ArnPrefix + name
+		if err := s3.PutObject(ctx, arn, buf.Bytes(), nil); err != nil {
+			return err.WithSingle("s3_arn", arn)
+		}
+		locations = append(locations, arn)
+	}
+	log.FromContext(ctx).Warnw("Wrote heap profile due to memory pressure",
+		"heap_profile_locations", strings.Join(locations, ", "),
+	)
+	return nil
+}
+
+func (m *memoryMonitor) Stop() {
+	m.cancel()
+	<-m.done
+}
+
+func (m *memoryMonitor) Done() <-chan struct{} {
+	return m.done
+}
+
+func (m *memoryMonitor) MaxReserved() uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.maxReserved
+}
+
+func (m *memoryMonitor) MaxInUse() uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.maxInUse
 }
 
-func GetMaxMemoryUsageMb() (maxReserved uint64, maxInUse uint64) {
-	return bToMb(maxReserved), bToMb(maxInUse)
+func (m *memoryMonitor) Limit() uint64 {
+	return m.limit
 }
@@ -0,0 +1,93 @@
+package debugging
+
+import (
+	"context"
+
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type RecoverInput struct {
+	// OPTIONAL. The context to get the logger from (see log.FromContext). If not
+	// provided, the default logger is used.
+	Context context.Context
+	// OPTIONAL. A function to call with the recovered panic (converted to an error),
+	// after it has been logged. This can be used to return the error from the
+	// function that panicked, by assigning it to a named return value.
+	OnPanic func(err stackerr.Error)
+	// OPTIONAL. Whether to panic again (with the original value) after the panic
+	// has been logged and handled, so that it still crashes the process.
+	Repanic bool
+}
+
+// RecoverWithInput recovers from a panic, logs it as an error (which triggers any log
+// write hooks, such as Slack), and then handles it as configured by the input. It
+// must be deferred directly, otherwise it won't be able to recover the panic:
+//
+//	defer debugging.RecoverWithInput(debugging.RecoverInput{...})
+func RecoverWithInput(input RecoverInput) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := stackerr.FromRecover(r)
+	if input.Context != nil {
+		log.FromContext(input.Context).Error(err)
+	} else {
+		log.Error(err)
+	}
+	if input.OnPanic != nil {
+		input.OnPanic(err)
+	}
+	if input.Repanic {
+		panic(r)
+	}
+}
+
+// Recover recovers from a panic, logs it as an error with the default logger, and
+// then calls onPanic (if it isn't nil) with the error. It must be deferred directly,
+// otherwise it won't be able to recover the panic:
+//
+//	func work() (err stackerr.Error) {
+//		defer debugging.Recover(func(panicErr stackerr.Error) {
+//			err = panicErr
+//		})
+//		...
+//	}
+func Recover(onPanic func(err stackerr.Error)) {
+	// This can't call RecoverWithInput, since recover only works
+	// when it's called directly by the deferred function.
+	r := recover()
+	if r == nil {
+		return
+	}
+	err := stackerr.FromRecover(r)
+	log.Error(err)
+	if onPanic != nil {
+		onPanic(err)
+	}
+}
+
+// GoSafe runs the function in a new goroutine, logging (with the context's logger)
+// any error that it returns or panic that it causes, instead of crashing the process.
+// The returned channel receives the error (or nil) once the function has finished.
+func GoSafe(ctx context.Context, fn func(ctx context.Context) stackerr.Error) <-chan stackerr.Error {
+	result := make(chan stackerr.Error, 1)
+	go func() {
+		var err stackerr.Error
+		defer func() {
+			result <- err
+		}()
+		defer RecoverWithInput(RecoverInput{
+			Context: ctx,
+			OnPanic: func(panicErr stackerr.Error) {
+				err = panicErr
+			},
+		})
+		err = fn(ctx)
+		if err != nil {
+			log.FromContext(ctx).Error(err)
+		}
+	}()
+	return result
+}
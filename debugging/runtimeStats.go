@@ -0,0 +1,235 @@
+package debugging
+
+import (
+	"context"
+	"math"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	gometrics "github.com/Invicton-Labs/go-common/metrics"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// RuntimeStats is a snapshot of the Go runtime's health.
+type RuntimeStats struct {
+	// The number of goroutines that currently exist
+	Goroutines int
+	// The number of completed GC cycles
+	NumGC uint32
+	// The total time spent in GC stop-the-world pauses
+	GCPauseTotal time.Duration
+	// The memory in use by the heap, in bytes
+	HeapInUse uint64
+	// The memory reserved from the OS, in bytes
+	Reserved uint64
+	// The number of open file descriptors, or -1 if it can't be determined
+	// (it's only available on Linux)
+	OpenFDs int
+	// The 50th and 99th percentile of the time that goroutines spent waiting to run
+	// after becoming runnable. For a reporter, these are for the time since the previous
+	// report; for GetRuntimeStats, they're for the lifetime of the process.
+	SchedLatencyP50 time.Duration
+	SchedLatencyP99 time.Duration
+}
+
+// The runtime/metrics name of the scheduler latency histogram
+const schedLatencyMetric = "/sched/latencies:seconds"
+
+// countOpenFDs counts the open file descriptors of the process, or returns -1 if it can't.
+func countOpenFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// Reading the directory uses a file descriptor of its own
+	return len(entries) - 1
+}
+
+// readSchedLatencies reads the cumulative scheduler latency histogram, or nil if it isn't supported.
+func readSchedLatencies() *metrics.Float64Histogram {
+	sample := []metrics.Sample{{Name: schedLatencyMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	return sample[0].Value.Float64Histogram()
+}
+
+// histogramQuantile estimates a quantile of a runtime histogram's counts, using the upper
+// bound of the bucket that contains it (or the lower bound, if the upper is infinite).
+func histogramQuantile(counts []uint64, buckets []float64, q float64) time.Duration {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		if cumulative >= rank {
+			bound := buckets[i+1]
+			if math.IsInf(bound, 1) {
+				bound = buckets[i]
+			}
+			return time.Duration(bound * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// collectRuntimeStats gets the current runtime stats, with the scheduler latency
+// percentiles calculated from the counts since the previous counts (if provided).
+func collectRuntimeStats(previousSchedCounts []uint64) (RuntimeStats, []uint64) {
+	mem := GetMemUsage()
+	stats := RuntimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		HeapInUse:    mem.HeapInuse,
+		Reserved:     mem.Sys,
+		OpenFDs:      countOpenFDs(),
+	}
+	latencies := readSchedLatencies()
+	if latencies == nil {
+		return stats, nil
+	}
+	counts := make([]uint64, len(latencies.Counts))
+	copy(counts, latencies.Counts)
+	delta := counts
+	if len(previousSchedCounts) == len(counts) {
+		delta = make([]uint64, len(counts))
+		for i := range counts {
+			delta[i] = counts[i] - previousSchedCounts[i]
+		}
+	}
+	stats.SchedLatencyP50 = histogramQuantile(delta, latencies.Buckets, 0.5)
+	stats.SchedLatencyP99 = histogramQuantile(delta, latencies.Buckets, 0.99)
+	return stats, counts
+}
+
+// GetRuntimeStats gets a snapshot of the Go runtime's health.
+func GetRuntimeStats() RuntimeStats {
+	stats, _ := collectRuntimeStats(nil)
+	return stats
+}
+
+type StartRuntimeReporterInput struct {
+	// How often to report the runtime stats
+	Interval time.Duration
+	// OPTIONAL. The logger to log the stats with. If neither this nor Registry is
+	// provided, the logger from the context (see log.FromContext) is used.
+	Logger log.Logger
+	// OPTIONAL. A metrics registry to set gauges for each stat in, instead of (or in
+	// addition to, if Logger is also provided) logging them.
+	Registry gometrics.Registry
+	// OPTIONAL. The clock to use. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+}
+
+// The gauges that the runtime reporter sets in a metrics registry
+type runtimeGauges struct {
+	goroutines      gometrics.Gauge
+	gcCount         gometrics.Gauge
+	gcPauseTotal    gometrics.Gauge
+	heapInUse       gometrics.Gauge
+	reserved        gometrics.Gauge
+	openFDs         gometrics.Gauge
+	schedLatencyP50 gometrics.Gauge
+	schedLatencyP99 gometrics.Gauge
+}
+
+func newRuntimeGauges(registry gometrics.Registry) (*runtimeGauges, stackerr.Error) {
+	g := &runtimeGauges{}
+	for _, def := range []struct {
+		gauge *gometrics.Gauge
+		name  string
+		help  string
+	}{
+		{&g.goroutines, "go_goroutines", "The number of goroutines that currently exist."},
+		{&g.gcCount, "go_gc_cycles", "The number of completed GC cycles."},
+		{&g.gcPauseTotal, "go_gc_pause_seconds", "The total time spent in GC stop-the-world pauses."},
+		{&g.heapInUse, "go_heap_inuse_bytes", "The memory in use by the heap."},
+		{&g.reserved, "go_memory_reserved_bytes", "The memory reserved from the OS."},
+		{&g.openFDs, "process_open_fds", "The number of open file descriptors."},
+		{&g.schedLatencyP50, "go_sched_latency_p50_seconds", "The median time that goroutines waited to run, since the previous report."},
+		{&g.schedLatencyP99, "go_sched_latency_p99_seconds", "The 99th percentile time that goroutines waited to run, since the previous report."},
+	} {
+		gauge, err := registry.Gauge(def.name, def.help)
+		if err != nil {
+			return nil, err
+		}
+		*def.gauge = gauge
+	}
+	return g, nil
+}
+
+func (g *runtimeGauges) set(stats RuntimeStats) {
+	g.goroutines.Set(float64(stats.Goroutines), nil)
+	g.gcCount.Set(float64(stats.NumGC), nil)
+	g.gcPauseTotal.Set(stats.GCPauseTotal.Seconds(), nil)
+	g.heapInUse.Set(float64(stats.HeapInUse), nil)
+	g.reserved.Set(float64(stats.Reserved), nil)
+	if stats.OpenFDs >= 0 {
+		g.openFDs.Set(float64(stats.OpenFDs), nil)
+	}
+	g.schedLatencyP50.Set(stats.SchedLatencyP50.Seconds(), nil)
+	g.schedLatencyP99.Set(stats.SchedLatencyP99.Seconds(), nil)
+}
+
+// StartRuntimeReporter starts a routine that periodically reports the runtime stats
+// (see RuntimeStats) by logging them and/or setting gauges in a metrics registry. It
+// runs until the context is done.
+func StartRuntimeReporter(ctx context.Context, input StartRuntimeReporterInput) stackerr.Error {
+	if input.Interval <= 0 {
+		return stackerr.Errorf("the `input.Interval` field must be greater than 0")
+	}
+	if input.Logger == nil && input.Registry == nil {
+		input.Logger = log.FromContext(ctx)
+	}
+	var gauges *runtimeGauges
+	if input.Registry != nil {
+		var err stackerr.Error
+		gauges, err = newRuntimeGauges(input.Registry)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start with the current counts, so the first report only covers its own interval
+	_, schedCounts := collectRuntimeStats(nil)
+	go func() {
+		_ = dateutils.EveryWithInput(ctx, dateutils.EveryInput{
+			Interval: input.Interval,
+			Clock:    input.Clock,
+			Func: func(ctx context.Context) stackerr.Error {
+				var stats RuntimeStats
+				stats, schedCounts = collectRuntimeStats(schedCounts)
+				if gauges != nil {
+					gauges.set(stats)
+				}
+				if input.Logger != nil {
+					input.Logger.Infow("Runtime stats",
+						"goroutines", stats.Goroutines,
+						"gc_count", stats.NumGC,
+						"gc_pause_total", stats.GCPauseTotal.String(),
+						"heap_in_use", numbers.FormatBytes(stats.HeapInUse),
+						"memory_reserved", numbers.FormatBytes(stats.Reserved),
+						"open_fds", stats.OpenFDs,
+						"sched_latency_p50", stats.SchedLatencyP50.String(),
+						"sched_latency_p99", stats.SchedLatencyP99.String(),
+					)
+				}
+				return nil
+			},
+		})
+	}()
+	return nil
+}
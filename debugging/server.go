@@ -0,0 +1,202 @@
+package debugging
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type StartDebugServerInput struct {
+	// OPTIONAL. If provided, requests must include this token, either as a bearer
+	// token in the Authorization header or as the `token` query parameter.
+	Token string
+	// OPTIONAL. The maximum amount of time to wait for in-flight requests to
+	// finish when shutting down. Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+	// OPTIONAL. If provided, traces captured with the /debug/trace endpoint are uploaded
+	// to S3 with this ARN prefix (e.g. "arn:aws:s3:::bucket/traces/") instead of being
+	// returned in the response.
+	TraceS3ArnPrefix string
+}
+
+// DebugServer is a running debug server.
+type DebugServer interface {
+	// Addr returns the address that the server is listening on.
+	Addr() net.Addr
+	// Done returns a channel that is closed once the server has shut down.
+	Done() <-chan struct{}
+	// Err returns the error (if any) that the server exited with, once it has shut down.
+	Err() stackerr.Error
+}
+
+type debugServer struct {
+	listener net.Listener
+	done     chan struct{}
+	err      stackerr.Error
+}
+
+func (ds *debugServer) Addr() net.Addr {
+	return ds.listener.Addr()
+}
+
+func (ds *debugServer) Done() <-chan struct{} {
+	return ds.done
+}
+
+func (ds *debugServer) Err() stackerr.Error {
+	select {
+	case <-ds.done:
+		return ds.err
+	default:
+		return nil
+	}
+}
+
+// requireToken wraps a handler so that it requires the given token.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			provided = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The maximum duration of a trace captured with the /debug/trace endpoint
+const maxTraceDuration = 5 * time.Minute
+
+// traceHandler captures an execution trace for the duration in the `seconds` query
+// parameter (defaulting to 5), and either returns it or uploads it to S3.
+func traceHandler(s3ArnPrefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		duration := 5 * time.Second
+		if seconds := r.URL.Query().Get("seconds"); seconds != "" {
+			parsed, err := strconv.ParseFloat(seconds, 64)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "the `seconds` parameter must be a positive number", http.StatusBadRequest)
+				return
+			}
+			duration = time.Duration(parsed * float64(time.Second))
+		}
+		if duration > maxTraceDuration {
+			http.Error(w, "the trace duration must not be more than "+maxTraceDuration.String(), http.StatusBadRequest)
+			return
+		}
+		if s3ArnPrefix != "" {
+			arn, err := CaptureTraceToS3(r.Context(), duration, s3ArnPrefix)
+			if err != nil {
+				log.FromContext(r.Context()).Error(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(arn + "\n"))
+			return
+		}
+		data, err := CaptureTrace(r.Context(), duration)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace.out"`)
+		_, _ = w.Write(data)
+	}
+}
+
+// newDebugMux creates the handler for all of the debug endpoints.
+func newDebugMux(input StartDebugServerInput) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/memory", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(GetFormattedMemUsage() + "\n"))
+	})
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Goroutines: " + strconv.Itoa(runtime.NumGoroutine()) + "\n\n"))
+		// Debug level 2 prints the full stack of every goroutine, like an unrecovered panic
+		_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	mux.HandleFunc("/debug/trace", traceHandler(input.TraceS3ArnPrefix))
+	return mux
+}
+
+// StartDebugServer starts an HTTP server on the given address (e.g. "localhost:6060", or
+// ":0" for a random port) that serves debugging endpoints:
+//   - /debug/pprof/: the standard pprof profiles
+//   - /debug/vars: the expvar variables
+//   - /debug/memory: the current memory usage
+//   - /debug/goroutines: the count and full stacks of all goroutines
+//   - /debug/trace: an execution trace over `seconds` (defaulting to 5), which is
+//     uploaded to S3 if the input's TraceS3ArnPrefix is set
+//
+// The server is shut down when the context is done. The address is bound before
+// this returns, so an error is returned if it's unavailable.
+func StartDebugServer(ctx context.Context, addr string, input StartDebugServerInput) (DebugServer, stackerr.Error) {
+	if input.ShutdownTimeout <= 0 {
+		input.ShutdownTimeout = 5 * time.Second
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	server := &http.Server{
+		Handler:           requireToken(input.Token, newDebugMux(input)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ds := &debugServer{
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	go func() {
+		defer close(ds.done)
+		select {
+		case err := <-serveErr:
+			// The server stopped on its own
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				ds.err = stackerr.Wrap(err)
+				log.FromContext(ctx).Error(ds.err)
+			}
+			return
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), input.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			ds.err = stackerr.Wrap(err)
+		}
+		<-serveErr
+	}()
+	return ds, nil
+}
@@ -0,0 +1,52 @@
+package debugging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/trace"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/aws/s3"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// CaptureTrace records an execution trace (see runtime/trace) for the given duration, and
+// returns it in the format that `go tool trace` reads. Only one trace can be recorded at a
+// time, so this returns an error if another trace is already running. If the context is
+// done before the duration has passed, the trace is stopped and the context's error is returned.
+func CaptureTrace(ctx context.Context, duration time.Duration) ([]byte, stackerr.Error) {
+	if duration <= 0 {
+		return nil, stackerr.Errorf("the trace duration must be greater than 0")
+	}
+	buf := bytes.Buffer{}
+	if err := trace.Start(&buf); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		trace.Stop()
+		return nil, stackerr.Wrap(ctx.Err())
+	}
+	trace.Stop()
+	return buf.Bytes(), nil
+}
+
+// CaptureTraceToS3 records an execution trace (see CaptureTrace) and uploads it to S3,
+// with a file name appended to the given ARN prefix (e.g. "arn:aws:s3:::bucket/traces/").
+// It returns the ARN of the uploaded trace.
+func CaptureTraceToS3(ctx context.Context, duration time.Duration, s3ArnPrefix string) (string, stackerr.Error) {
+	data, err := CaptureTrace(ctx, duration)
+	if err != nil {
+		return "", err
+	}
+	arn := s3ArnPrefix + fmt.Sprintf("trace-%s-%d.out", time.Now().UTC().Format("20060102T150405.000Z"), os.Getpid())
+	if err := s3.PutObject(ctx, arn, data, nil); err != nil {
+		return "", err.WithSingle("s3_arn", arn)
+	}
+	return arn, nil
+}
@@ -0,0 +1,40 @@
+package genjson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// DecodeLines decodes a stream of JSON values (e.g. JSONL, with one value per line), calling
+// the function with each value and its index as it's decoded, so the whole stream doesn't need
+// to fit in memory. It stops at the first decoding error or error returned by the function.
+func DecodeLines[T any](r io.Reader, f func(index int, v T) stackerr.Error) stackerr.Error {
+	dec := json.NewDecoder(r)
+	for index := 0; ; index++ {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return stackerr.Wrap(err).WithSingle("index", index)
+		}
+		if err := f(index, v); err != nil {
+			return err
+		}
+	}
+}
+
+// EncodeLines writes the values as JSONL, with one compact JSON value per line.
+func EncodeLines[T any](w io.Writer, values []T) stackerr.Error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for i, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return stackerr.Wrap(err).WithSingle("index", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package genjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// marshal encodes the value without escaping HTML characters, since the output
+// is rarely embedded in HTML and the escaping makes it harder to read.
+func marshal(v any, indent string) ([]byte, stackerr.Error) {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	// The encoder always adds a trailing newline
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// MarshalCompact encodes the value as JSON with no whitespace. Unlike json.Marshal,
+// it doesn't escape the HTML characters <, >, and &.
+func MarshalCompact(v any) ([]byte, stackerr.Error) {
+	return marshal(v, "")
+}
+
+// MarshalIndent encodes the value as JSON, indenting each level with two spaces.
+// Unlike json.MarshalIndent, it doesn't escape the HTML characters <, >, and &.
+func MarshalIndent(v any) ([]byte, stackerr.Error) {
+	return marshal(v, "  ")
+}
+
+// UnmarshalStrict is the same as Unmarshal, except that it returns an error if the
+// data has any object keys that don't match a field of the type (at any depth), or
+// if there is anything other than whitespace after the value.
+func UnmarshalStrict[T any](data []byte) (v T, err stackerr.Error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return v, stackerr.Wrap(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return v, stackerr.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
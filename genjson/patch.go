@@ -0,0 +1,57 @@
+package genjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// MergePatch applies a JSON merge patch (RFC 7386) to a document: object keys in the patch
+// replace the same keys in the document (recursively for nested objects), keys with a null
+// value are removed, and any other patch value (including an array) replaces the document.
+func MergePatch(doc []byte, patch []byte) ([]byte, stackerr.Error) {
+	patchValue, err := decodePreservingNumbers(patch)
+	if err != nil {
+		return nil, err.WithSingle("json_input", "patch")
+	}
+	var docValue any
+	if len(doc) > 0 {
+		docValue, err = decodePreservingNumbers(doc)
+		if err != nil {
+			return nil, err.WithSingle("json_input", "document")
+		}
+	}
+	return MarshalCompact(mergePatch(docValue, patchValue))
+}
+
+// decodePreservingNumbers decodes JSON into generic values, keeping numbers as
+// json.Number so that large integers don't lose precision by becoming float64s.
+func decodePreservingNumbers(data []byte) (any, stackerr.Error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return v, nil
+}
+
+func mergePatch(doc any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]any)
+	if !ok {
+		docObject = map[string]any{}
+	}
+	for k, v := range patchObject {
+		if v == nil {
+			delete(docObject, k)
+			continue
+		}
+		docObject[k] = mergePatch(docObject[k], v)
+	}
+	return docObject
+}
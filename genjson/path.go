@@ -0,0 +1,97 @@
+package genjson
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// pathSegment is a single step of a path: either an object key or an array index.
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses a path like "a.b[0].c" into its segments.
+func parsePath(path string) ([]pathSegment, stackerr.Error) {
+	segments := []pathSegment{}
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		indexes := ""
+		if idx := strings.IndexByte(part, '['); idx >= 0 {
+			key = part[:idx]
+			indexes = part[idx:]
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key})
+		} else if indexes == "" {
+			return nil, stackerr.Errorf("the JSON path '%s' has an empty key", path)
+		}
+		for indexes != "" {
+			end := strings.IndexByte(indexes, ']')
+			if indexes[0] != '[' || end < 0 {
+				return nil, stackerr.Errorf("the JSON path '%s' has an invalid array index", path)
+			}
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil || index < 0 {
+				return nil, stackerr.Errorf("the JSON path '%s' has an invalid array index '%s'", path, indexes[1:end])
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+			indexes = indexes[end+1:]
+		}
+	}
+	return segments, nil
+}
+
+// GetPath gets the raw JSON value at a path within a JSON document, where the path is made up
+// of object keys separated by dots, and array indexes in square brackets (e.g. "a.b[0].c").
+// Only the parts of the document along the path are decoded. The boolean is false if any part
+// of the path doesn't exist (or is null). An error is returned if the path or document is invalid,
+// or if a part of the path is a different type than the path expects (e.g. an array index into
+// an object, or a key into a string). Keys that contain dots or square brackets can't be used.
+func GetPath(raw []byte, path string) (json.RawMessage, bool, stackerr.Error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	current := json.RawMessage(raw)
+	for i, segment := range segments {
+		if segment.isIndex {
+			var array []json.RawMessage
+			if err := json.Unmarshal(current, &array); err != nil {
+				return nil, false, stackerr.Wrap(err).WithSingle("json_path_segment", i)
+			}
+			if array == nil || segment.index >= len(array) {
+				return nil, false, nil
+			}
+			current = array[segment.index]
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(current, &object); err != nil {
+			return nil, false, stackerr.Wrap(err).WithSingle("json_path_segment", i)
+		}
+		value, ok := object[segment.key]
+		if !ok {
+			return nil, false, nil
+		}
+		current = value
+	}
+	return current, true, nil
+}
+
+// GetPathAs is the same as GetPath, but unmarshals the value into the given type.
+func GetPathAs[T any](raw []byte, path string) (v T, found bool, err stackerr.Error) {
+	value, found, err := GetPath(raw, path)
+	if err != nil || !found {
+		return v, found, err
+	}
+	v, err = Unmarshal[T](value)
+	if err != nil {
+		return v, true, err.WithSingle("json_path", path)
+	}
+	return v, true, nil
+}
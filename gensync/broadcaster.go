@@ -0,0 +1,208 @@
+package gensync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrBroadcasterClosed is returned when publishing to a closed Broadcaster.
+var ErrBroadcasterClosed = errors.New("broadcaster is closed")
+
+// SlowSubscriberPolicy is what a Broadcaster does when a value is published while
+// a subscriber's channel buffer is full.
+type SlowSubscriberPolicy int
+
+const (
+	// Wait until the subscriber has room for the value
+	SlowSubscriberBlock SlowSubscriberPolicy = iota
+	// Drop the new value for that subscriber
+	SlowSubscriberDropNewest
+	// Drop the oldest value in the subscriber's buffer to make room for the new one
+	SlowSubscriberDropOldest
+)
+
+type NewBroadcasterInput[T any] struct {
+	// OPTIONAL. The size of each subscriber's channel buffer. Defaults to 0 (unbuffered).
+	BufferSize int
+	// OPTIONAL. What to do when a subscriber's buffer is full. Defaults to SlowSubscriberBlock.
+	SlowSubscriberPolicy SlowSubscriberPolicy
+	// OPTIONAL. A function to call with each value that's dropped for a subscriber by
+	// the SlowSubscriberDropNewest or SlowSubscriberDropOldest policies.
+	OnDrop func(value T)
+}
+
+// Broadcaster distributes each published value to all subscribers (fan-out),
+// each of which receives the values on its own channel.
+type Broadcaster[T any] interface {
+	// Subscribe returns a channel that receives every value that's published until the
+	// context is done, at which point the subscriber is removed and the channel is closed.
+	// The channel is also closed when the broadcaster is closed.
+	Subscribe(ctx context.Context) <-chan T
+	// Publish sends a value to all current subscribers, handling slow subscribers according
+	// to the policy. With SlowSubscriberBlock, it returns the context's error if the context
+	// is done before all subscribers have received it (in which case some may have).
+	Publish(ctx context.Context, value T) stackerr.Error
+	// Subscribers returns the number of current subscribers.
+	Subscribers() int
+	// Close closes the broadcaster and all subscriber channels. Publishes that are
+	// waiting on slow subscribers stop waiting.
+	Close()
+}
+
+type broadcastSubscriber[T any] struct {
+	ch   chan T
+	done <-chan struct{}
+	// Held while sending to the channel, so that it's never closed mid-send
+	lock   sync.Mutex
+	closed bool
+}
+
+// close closes the subscriber's channel, if it isn't already.
+func (s *broadcastSubscriber[T]) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+type broadcaster[T any] struct {
+	input       NewBroadcasterInput[T]
+	lock        sync.Mutex
+	subscribers map[*broadcastSubscriber[T]]struct{}
+	closed      bool
+	closeOnce   sync.Once
+	// Closed when the broadcaster is closed, to wake up anything that's waiting
+	closing chan struct{}
+}
+
+func NewBroadcaster[T any](input NewBroadcasterInput[T]) Broadcaster[T] {
+	if input.BufferSize < 0 {
+		input.BufferSize = 0
+	}
+	return &broadcaster[T]{
+		input:       input,
+		subscribers: map[*broadcastSubscriber[T]]struct{}{},
+		closing:     make(chan struct{}),
+	}
+}
+
+func (b *broadcaster[T]) Subscribe(ctx context.Context) <-chan T {
+	sub := &broadcastSubscriber[T]{
+		ch:   make(chan T, b.input.BufferSize),
+		done: ctx.Done(),
+	}
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		sub.close()
+		return sub.ch
+	}
+	b.subscribers[sub] = struct{}{}
+	b.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.lock.Lock()
+			delete(b.subscribers, sub)
+			b.lock.Unlock()
+			sub.close()
+		case <-b.closing:
+			// Close takes care of it
+		}
+	}()
+	return sub.ch
+}
+
+func (b *broadcaster[T]) Publish(ctx context.Context, value T) stackerr.Error {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return stackerr.Wrap(ErrBroadcasterClosed)
+	}
+	subs := make([]*broadcastSubscriber[T], 0, len(b.subscribers))
+	for sub := range b.subscribers {
+		subs = append(subs, sub)
+	}
+	b.lock.Unlock()
+
+	for _, sub := range subs {
+		if err := b.send(ctx, sub, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a value to a single subscriber.
+func (b *broadcaster[T]) send(ctx context.Context, sub *broadcastSubscriber[T], value T) stackerr.Error {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	if sub.closed {
+		return nil
+	}
+	select {
+	case sub.ch <- value:
+		return nil
+	default:
+	}
+	switch b.input.SlowSubscriberPolicy {
+	case SlowSubscriberDropNewest:
+		if b.input.OnDrop != nil {
+			b.input.OnDrop(value)
+		}
+		return nil
+	case SlowSubscriberDropOldest:
+		select {
+		case dropped := <-sub.ch:
+			if b.input.OnDrop != nil {
+				b.input.OnDrop(dropped)
+			}
+		default:
+		}
+		select {
+		case sub.ch <- value:
+		default:
+			// The channel is unbuffered and nothing is receiving, so there's nowhere to put it
+			if b.input.OnDrop != nil {
+				b.input.OnDrop(value)
+			}
+		}
+		return nil
+	}
+	select {
+	case sub.ch <- value:
+	case <-sub.done:
+		// The subscriber is being removed
+	case <-b.closing:
+		// The subscriber will be closed
+	case <-ctx.Done():
+		return stackerr.Wrap(ctx.Err())
+	}
+	return nil
+}
+
+func (b *broadcaster[T]) Subscribers() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.subscribers)
+}
+
+func (b *broadcaster[T]) Close() {
+	b.closeOnce.Do(func() {
+		close(b.closing)
+		b.lock.Lock()
+		b.closed = true
+		subs := b.subscribers
+		b.subscribers = map[*broadcastSubscriber[T]]struct{}{}
+		b.lock.Unlock()
+		for sub := range subs {
+			sub.close()
+		}
+	})
+}
@@ -28,12 +28,11 @@ func NewRingSlice[T any](values []T) RingSlice[T] {
 	if len(values) == 0 {
 		panic("no values provided")
 	}
-	idx := atomic.Int64{}
-	idx.Add(-1)
-	return &ringSlice[T]{
-		idx:    idx,
+	rs := &ringSlice[T]{
 		values: values,
 	}
+	rs.idx.Store(-1)
+	return rs
 }
 
 func (rs *ringSlice[T]) Next() T {
@@ -56,8 +55,7 @@ func (rs *ringSlice[T]) Set(values []T) {
 	defer rs.lock.Unlock()
 
 	rs.values = values
-	rs.idx = atomic.Int64{}
-	rs.idx.Add(-1)
+	rs.idx.Store(-1)
 }
 
 func (rs *ringSlice[T]) Values() []T {
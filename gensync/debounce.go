@@ -0,0 +1,177 @@
+package gensync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// CoalescedFunc is a function wrapper that collapses bursts of calls into fewer
+// invocations of the function. Invocations never overlap.
+type CoalescedFunc interface {
+	// Call requests an invocation of the function, which happens asynchronously.
+	// It's ignored after Close.
+	Call()
+	// Flush waits for any invocation that's in progress, then immediately invokes the
+	// function if a call is pending, and waits for it to return. It returns the context's
+	// error if the context is done before the pending invocation starts.
+	Flush(ctx context.Context) stackerr.Error
+	// Close stops the wrapper, so that later calls are ignored. If a call is pending, it
+	// invokes the function, so the last call is never lost. It waits for any invocation
+	// that's in progress to return.
+	Close()
+}
+
+// coalescedFunc holds the state that's shared by debounced and throttled functions.
+type coalescedFunc struct {
+	fn func()
+	// Holds a value while the function is being invoked
+	running chan struct{}
+	lock    sync.Mutex
+	pending bool
+	closed  bool
+	timer   *time.Timer
+	// Tracks invocations that have been started asynchronously
+	async sync.WaitGroup
+}
+
+func newCoalescedFunc(fn func()) *coalescedFunc {
+	return &coalescedFunc{
+		fn:      fn,
+		running: make(chan struct{}, 1),
+	}
+}
+
+// run invokes the function if a call is pending, after any invocation
+// that's in progress returns.
+func (c *coalescedFunc) run(ctx context.Context) stackerr.Error {
+	select {
+	case c.running <- struct{}{}:
+	case <-ctx.Done():
+		return stackerr.Wrap(ctx.Err())
+	}
+	defer func() {
+		<-c.running
+	}()
+	c.lock.Lock()
+	pending := c.pending
+	c.pending = false
+	c.lock.Unlock()
+	if pending {
+		c.fn()
+	}
+	return nil
+}
+
+// invoke invokes the function asynchronously, whether or
+// not a call is pending. The lock must be held.
+func (c *coalescedFunc) invoke() {
+	c.async.Add(1)
+	go func() {
+		defer c.async.Done()
+		c.running <- struct{}{}
+		defer func() {
+			<-c.running
+		}()
+		c.fn()
+	}()
+}
+
+func (c *coalescedFunc) Flush(ctx context.Context) stackerr.Error {
+	return c.run(ctx)
+}
+
+func (c *coalescedFunc) Close() {
+	c.lock.Lock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.lock.Unlock()
+	c.async.Wait()
+	_ = c.run(context.Background())
+}
+
+type debouncedFunc struct {
+	*coalescedFunc
+	window time.Duration
+}
+
+// Debounce wraps a function so that it's invoked once calls stop for the given
+// window, instead of on every call. Each call restarts the window.
+func Debounce(fn func(), window time.Duration) CoalescedFunc {
+	return &debouncedFunc{
+		coalescedFunc: newCoalescedFunc(fn),
+		window:        window,
+	}
+}
+
+func (d *debouncedFunc) Call() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return
+	}
+	d.pending = true
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.window, func() {
+			_ = d.run(context.Background())
+		})
+	} else {
+		d.timer.Reset(d.window)
+	}
+}
+
+type throttledFunc struct {
+	*coalescedFunc
+	interval time.Duration
+	// Whether an invocation has started within the last interval
+	coolingDown bool
+}
+
+// Throttle wraps a function so that it's invoked at most once per interval. The first
+// call invokes it right away, and any calls during the following interval are collapsed
+// into a single invocation at the end of the interval.
+func Throttle(fn func(), interval time.Duration) CoalescedFunc {
+	return &throttledFunc{
+		coalescedFunc: newCoalescedFunc(fn),
+		interval:      interval,
+	}
+}
+
+func (t *throttledFunc) Call() {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return
+	}
+	if t.coolingDown {
+		t.pending = true
+		t.lock.Unlock()
+		return
+	}
+	t.coolingDown = true
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.interval, t.tick)
+	} else {
+		t.timer.Reset(t.interval)
+	}
+	t.invoke()
+	t.lock.Unlock()
+}
+
+// tick is called at the end of each interval, and invokes the
+// function if there were any calls during the interval.
+func (t *throttledFunc) tick() {
+	t.lock.Lock()
+	if !t.pending || t.closed {
+		t.coolingDown = false
+		t.lock.Unlock()
+		return
+	}
+	t.timer.Reset(t.interval)
+	t.lock.Unlock()
+	_ = t.run(context.Background())
+}
@@ -0,0 +1,141 @@
+package gensync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrGroup is a collection of goroutines working on subtasks of a common task.
+// It wraps errgroup.Group, but recovers panics in the subtasks and returns
+// them as errors.
+type ErrGroup interface {
+	// Go calls the given function in a new goroutine. If a limit has been set,
+	// it blocks until the new goroutine can be added without exceeding the limit.
+	Go(f func() stackerr.Error)
+	// TryGo calls the given function in a new goroutine only if the number of
+	// active goroutines is currently below the limit. It returns whether the
+	// goroutine was started.
+	TryGo(f func() stackerr.Error) bool
+	// GoNamed is the same as Go, but any error (or panic) from the function is annotated
+	// with the given task name, so it can be identified in the errors returned by Wait.
+	GoNamed(name string, f func() stackerr.Error)
+	// TryGoNamed is the same as TryGo, but any error (or panic) from the function is
+	// annotated with the given task name.
+	TryGoNamed(name string, f func() stackerr.Error) bool
+	// SetLimit limits the number of active goroutines to at most n. A negative
+	// value indicates no limit. It must not be called while goroutines are active.
+	SetLimit(n int)
+	// Wait blocks until all goroutines have returned, then returns the
+	// first error (or all errors, if CollectAllErrors was set).
+	Wait() stackerr.Error
+}
+
+type NewErrGroupInput struct {
+	// OPTIONAL. The maximum number of goroutines that can be active at once.
+	// If 0 or less, there is no limit.
+	Limit int
+	// OPTIONAL. If true, Wait will return all errors combined instead of only the
+	// first one, and the group's context (if any) will not be cancelled until
+	// Wait returns.
+	CollectAllErrors bool
+}
+
+type errGroup struct {
+	group            errgroup.Group
+	collectAllErrors bool
+	cancel           context.CancelFunc
+	errLock          sync.Mutex
+	errs             []error
+}
+
+// NewErrGroup creates a new ErrGroup.
+func NewErrGroup(input NewErrGroupInput) ErrGroup {
+	eg := &errGroup{
+		collectAllErrors: input.CollectAllErrors,
+	}
+	if input.Limit > 0 {
+		eg.group.SetLimit(input.Limit)
+	}
+	return eg
+}
+
+// NewErrGroupWithContext creates a new ErrGroup and an associated context derived from
+// the given context. The derived context is cancelled the first time a function passed
+// to Go returns an error (unless CollectAllErrors is set) or the first time Wait returns,
+// whichever occurs first.
+func NewErrGroupWithContext(ctx context.Context, input NewErrGroupInput) (ErrGroup, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	eg := NewErrGroup(input).(*errGroup)
+	eg.cancel = cancel
+	return eg, ctx
+}
+
+// wrap wraps the function so that panics are recovered and errors are recorded.
+// If a name is given, recorded errors are annotated with it.
+func (eg *errGroup) wrap(name string, f func() stackerr.Error) func() error {
+	return func() error {
+		err := eg.run(f)
+		if err != nil {
+			if name != "" {
+				err = stackerr.Errorf("task %q: %w", name, err).WithSingle("task", name)
+			}
+			eg.errLock.Lock()
+			eg.errs = append(eg.errs, err)
+			eg.errLock.Unlock()
+			if !eg.collectAllErrors && eg.cancel != nil {
+				eg.cancel()
+			}
+		}
+		// Errors are tracked separately, so the underlying group never sees them
+		return nil
+	}
+}
+
+func (eg *errGroup) run(f func() stackerr.Error) (err stackerr.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = stackerr.FromRecover(r)
+		}
+	}()
+	return f()
+}
+
+func (eg *errGroup) Go(f func() stackerr.Error) {
+	eg.group.Go(eg.wrap("", f))
+}
+
+func (eg *errGroup) TryGo(f func() stackerr.Error) bool {
+	return eg.group.TryGo(eg.wrap("", f))
+}
+
+func (eg *errGroup) GoNamed(name string, f func() stackerr.Error) {
+	eg.group.Go(eg.wrap(name, f))
+}
+
+func (eg *errGroup) TryGoNamed(name string, f func() stackerr.Error) bool {
+	return eg.group.TryGo(eg.wrap(name, f))
+}
+
+func (eg *errGroup) SetLimit(n int) {
+	eg.group.SetLimit(n)
+}
+
+func (eg *errGroup) Wait() stackerr.Error {
+	_ = eg.group.Wait()
+	if eg.cancel != nil {
+		eg.cancel()
+	}
+	eg.errLock.Lock()
+	defer eg.errLock.Unlock()
+	if len(eg.errs) == 0 {
+		return nil
+	}
+	if !eg.collectAllErrors {
+		return stackerr.WrapWithoutExtraStack(eg.errs[0])
+	}
+	return stackerr.Wrap(multierr.Combine(eg.errs...))
+}
@@ -0,0 +1,166 @@
+package gensync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+	"go.uber.org/multierr"
+)
+
+// Future is the result of an asynchronous operation, which becomes available
+// when the operation is complete.
+type Future[T any] interface {
+	// Set sets the result, and returns a bool of whether it was set. Only the
+	// first call sets it; later calls are ignored.
+	Set(value T, err stackerr.Error) bool
+	// Get waits for the result and returns it. It returns the context's error
+	// if the context is done first.
+	Get(ctx context.Context) (T, stackerr.Error)
+	// Done returns a channel that's closed when the result is available.
+	Done() <-chan struct{}
+}
+
+type future[T any] struct {
+	once  sync.Once
+	done  chan struct{}
+	value T
+	err   stackerr.Error
+}
+
+// NewFuture creates a future that doesn't have a result yet. The result
+// is provided with Set.
+func NewFuture[T any]() Future[T] {
+	return newFuture[T]()
+}
+
+// NewCompletedFuture creates a future that already has the given result.
+func NewCompletedFuture[T any](value T, err stackerr.Error) Future[T] {
+	f := newFuture[T]()
+	f.Set(value, err)
+	return f
+}
+
+func newFuture[T any]() *future[T] {
+	return &future[T]{
+		done: make(chan struct{}),
+	}
+}
+
+func (f *future[T]) Set(value T, err stackerr.Error) bool {
+	set := false
+	f.once.Do(func() {
+		f.value = value
+		f.err = err
+		close(f.done)
+		set = true
+	})
+	return set
+}
+
+func (f *future[T]) Get(ctx context.Context) (T, stackerr.Error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	default:
+	}
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, stackerr.Wrap(ctx.Err())
+	}
+}
+
+func (f *future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// futureResult gets the result of a future that's done.
+func futureResult[T any](f Future[T]) (T, stackerr.Error) {
+	return f.Get(context.Background())
+}
+
+// All returns a future for the values of all of the given futures, in the same order. If any
+// of them has an error, the returned future has the first error without waiting for the rest.
+func All[T any](futures ...Future[T]) Future[[]T] {
+	combined := newFuture[[]T]()
+	if len(futures) == 0 {
+		combined.Set([]T{}, nil)
+		return combined
+	}
+	values := make([]T, len(futures))
+	lock := sync.Mutex{}
+	remaining := len(futures)
+	for i, f := range futures {
+		go func(i int, f Future[T]) {
+			<-f.Done()
+			value, err := futureResult(f)
+			if err != nil {
+				combined.Set(nil, err)
+				return
+			}
+			lock.Lock()
+			values[i] = value
+			remaining--
+			last := remaining == 0
+			lock.Unlock()
+			if last {
+				combined.Set(values, nil)
+			}
+		}(i, f)
+	}
+	return combined
+}
+
+// Any returns a future for the value of the first of the given futures to succeed. If all
+// of them have errors, the returned future has all of the errors combined.
+func Any[T any](futures ...Future[T]) Future[T] {
+	combined := newFuture[T]()
+	if len(futures) == 0 {
+		var zero T
+		combined.Set(zero, stackerr.Errorf("no futures were provided"))
+		return combined
+	}
+	errs := make([]error, len(futures))
+	lock := sync.Mutex{}
+	remaining := len(futures)
+	for i, f := range futures {
+		go func(i int, f Future[T]) {
+			<-f.Done()
+			value, err := futureResult(f)
+			if err == nil {
+				combined.Set(value, nil)
+				return
+			}
+			lock.Lock()
+			errs[i] = err
+			remaining--
+			last := remaining == 0
+			lock.Unlock()
+			if last {
+				combined.Set(value, stackerr.Wrap(multierr.Combine(errs...)))
+			}
+		}(i, f)
+	}
+	return combined
+}
+
+// Race returns a future for the result of the first of the given futures to
+// complete, whether it succeeded or not.
+func Race[T any](futures ...Future[T]) Future[T] {
+	combined := newFuture[T]()
+	if len(futures) == 0 {
+		var zero T
+		combined.Set(zero, stackerr.Errorf("no futures were provided"))
+		return combined
+	}
+	for _, f := range futures {
+		go func(f Future[T]) {
+			<-f.Done()
+			combined.Set(futureResult(f))
+		}(f)
+	}
+	return combined
+}
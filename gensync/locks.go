@@ -6,7 +6,6 @@ import (
 
 	"github.com/Invicton-Labs/go-common/collections"
 	"github.com/Invicton-Labs/go-stackerr"
-	"golang.org/x/sync/errgroup"
 )
 
 type MultiLock[T comparable] interface {
@@ -62,16 +61,11 @@ func (ml *multiLock[T]) LockAll(excluded ...T) {
 	for _, ek := range excluded {
 		excludedKeys[ek] = struct{}{}
 	}
-	errgrp := errgroup.Group{}
+	errgrp := NewErrGroup(NewErrGroupInput{})
 	for _, k := range ml.lockKeys {
 		if _, ok := excludedKeys[k]; !ok {
 			lockLey := k
-			errgrp.Go(func() (err error) {
-				defer func() {
-					if r := recover(); r != nil {
-						err = stackerr.FromRecover(r)
-					}
-				}()
+			errgrp.Go(func() stackerr.Error {
 				l, _ := ml.locks.Load(lockLey)
 				l.Lock()
 				return nil
@@ -1,14 +1,19 @@
 package gensync
 
 import (
+	"context"
 	"sync"
 
 	"github.com/Invicton-Labs/go-stackerr"
 )
 
-// gensync.Once is a form of sync.Once that captures and returns stack errors
+// gensync.Once is a form of sync.Once that captures and returns stack errors,
+// and can be reset so that a failed initialization can be retried.
 type Once struct {
 	sync.Once
+	// Held for reading by Do, and for writing by Reset, so that the
+	// embedded sync.Once is never replaced while it's in use
+	resetLock sync.RWMutex
 }
 
 // Do calls the function f if and only if Do is being called for the
@@ -18,7 +23,7 @@ type Once struct {
 //
 // if once.Do(f) is called multiple times, only the first call will invoke f,
 // even if f has a different value in each invocation. A new instance of
-// Once is required for each function to execute.
+// Once (or a call of Reset) is required for each function to execute.
 //
 // Do is intended for initialization that must be run exactly once. Since f
 // is niladic, it may be necessary to use a function literal to capture the
@@ -32,9 +37,131 @@ type Once struct {
 // If f panics, Do considers it to have returned; future calls of Do return
 // without calling f.
 func (o *Once) Do(f func() stackerr.Error) stackerr.Error {
+	o.resetLock.RLock()
+	defer o.resetLock.RUnlock()
 	var err stackerr.Error
 	o.Once.Do(func() {
 		err = f()
 	})
 	return err
 }
+
+// Reset makes the next call of Do call its function again, e.g. to retry an
+// initialization that returned an error. If a call of Do is in progress, it
+// waits for it to return. It must not be called concurrently with calls of
+// the embedded sync.Once's Do.
+func (o *Once) Reset() {
+	o.resetLock.Lock()
+	defer o.resetLock.Unlock()
+	o.Once = sync.Once{}
+}
+
+// OnceValue calls a function that returns a value once, and memoizes its result
+// for all callers. The zero value is ready to use.
+type OnceValue[T any] struct {
+	lock  sync.Mutex
+	done  bool
+	value T
+	err   stackerr.Error
+}
+
+// Do calls the function f if and only if Do is being called for the first time
+// for this instance of OnceValue (or since it was reset), and returns its result.
+// Later calls wait for the first one to return, and return the same result. If
+// f panics, the panic is recovered and returned as the error.
+func (o *OnceValue[T]) Do(f func() (T, stackerr.Error)) (T, stackerr.Error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.done {
+		o.value, o.err = o.run(f)
+		o.done = true
+	}
+	return o.value, o.err
+}
+
+func (o *OnceValue[T]) run(f func() (T, stackerr.Error)) (value T, err stackerr.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = stackerr.FromRecover(r)
+		}
+	}()
+	return f()
+}
+
+// Reset clears the memoized result, so that the next call of Do calls its function again,
+// e.g. to retry an initialization that returned an error. If a call of Do is in progress,
+// it waits for it to return.
+func (o *OnceValue[T]) Reset() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	var zero T
+	o.done = false
+	o.value = zero
+	o.err = nil
+}
+
+// OnceCtx is a form of Once where callers that are waiting for the function
+// to finish can stop waiting when their context is done. The zero value is
+// ready to use.
+type OnceCtx struct {
+	lock sync.Mutex
+	done bool
+	// Set while the function is running, and closed when it returns
+	running chan struct{}
+}
+
+// Do calls the function f with the context if and only if Do is being called for the
+// first time for this instance of OnceCtx (or since it was reset), and returns its error.
+// If another call is in progress, it waits for it to return (and returns nil), or returns
+// the context's error if the context is done first.
+//
+// If f panics, Do considers it to have returned; future calls of Do return
+// without calling f.
+func (o *OnceCtx) Do(ctx context.Context, f func(ctx context.Context) stackerr.Error) stackerr.Error {
+	for {
+		o.lock.Lock()
+		if o.done {
+			o.lock.Unlock()
+			return nil
+		}
+		if running := o.running; running != nil {
+			o.lock.Unlock()
+			select {
+			case <-running:
+				// Check again, since it may have been reset
+				continue
+			case <-ctx.Done():
+				return stackerr.Wrap(ctx.Err())
+			}
+		}
+		running := make(chan struct{})
+		o.running = running
+		o.lock.Unlock()
+
+		defer func() {
+			o.lock.Lock()
+			o.done = true
+			o.running = nil
+			o.lock.Unlock()
+			close(running)
+		}()
+		return f(ctx)
+	}
+}
+
+// Reset makes the next call of Do call its function again, e.g. to retry an
+// initialization that returned an error. If a call of Do is in progress, it
+// waits for it to return.
+func (o *OnceCtx) Reset() {
+	for {
+		o.lock.Lock()
+		running := o.running
+		if running == nil {
+			o.done = false
+			o.lock.Unlock()
+			return
+		}
+		o.lock.Unlock()
+		<-running
+	}
+}
@@ -0,0 +1,148 @@
+package gensync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrPoolClosed is returned for tasks that are submitted to a Pool after it's been shut down.
+var ErrPoolClosed = errors.New("pool is shut down")
+
+type NewPoolInput[In any, Out any] struct {
+	// The function that processes each task. The context is the one that the task
+	// was submitted with, limited by the task timeout (if any).
+	Handler func(ctx context.Context, in In) (Out, stackerr.Error)
+	// OPTIONAL. The number of workers that process tasks at once. Defaults to 1.
+	Workers int
+	// OPTIONAL. The number of submitted tasks that can wait for a worker before
+	// Submit blocks. Defaults to 0, so Submit blocks until a worker takes the task.
+	QueueSize int
+	// OPTIONAL. The maximum time that each task can run for. If not provided,
+	// tasks have no time limit other than their context.
+	TaskTimeout time.Duration
+}
+
+// Pool is a fixed-size pool of workers that process submitted tasks.
+type Pool[In any, Out any] interface {
+	// Submit submits a task, and returns a future for its result. It blocks until
+	// the task is queued. If the context is done first, or the pool has been shut
+	// down, the future has the context's error or ErrPoolClosed. Panics in the
+	// handler are recovered and returned as errors.
+	Submit(ctx context.Context, in In) Future[Out]
+	// Shutdown stops the pool from accepting new tasks, and waits for the tasks that
+	// were already submitted to finish. It returns the context's error if the context
+	// is done first, in which case the remaining tasks still finish in the background.
+	Shutdown(ctx context.Context) stackerr.Error
+}
+
+type poolTask[In any, Out any] struct {
+	ctx    context.Context
+	in     In
+	result *future[Out]
+}
+
+type pool[In any, Out any] struct {
+	input NewPoolInput[In, Out]
+	// Held for reading while submitting, and for writing while closing the task channel
+	lock     sync.RWMutex
+	closed   bool
+	tasks    chan poolTask[In, Out]
+	finished chan struct{}
+}
+
+// NewPool creates a worker pool, and starts its workers.
+func NewPool[In any, Out any](input NewPoolInput[In, Out]) (Pool[In, Out], stackerr.Error) {
+	if input.Handler == nil {
+		return nil, stackerr.Errorf("the `input.Handler` field must not be nil")
+	}
+	if input.Workers < 1 {
+		input.Workers = 1
+	}
+	if input.QueueSize < 0 {
+		input.QueueSize = 0
+	}
+	p := &pool[In, Out]{
+		input:    input,
+		tasks:    make(chan poolTask[In, Out], input.QueueSize),
+		finished: make(chan struct{}),
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(input.Workers)
+	for i := 0; i < input.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for task := range p.tasks {
+				p.process(task)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.finished)
+	}()
+	return p, nil
+}
+
+func (p *pool[In, Out]) process(task poolTask[In, Out]) {
+	// Don't bother running it if the submitter has already given up
+	if err := task.ctx.Err(); err != nil {
+		var zero Out
+		task.result.Set(zero, stackerr.Wrap(err))
+		return
+	}
+	ctx := task.ctx
+	if p.input.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.input.TaskTimeout)
+		defer cancel()
+	}
+	var value Out
+	var err stackerr.Error
+	defer func() {
+		if r := recover(); r != nil {
+			err = stackerr.FromRecover(r)
+		}
+		task.result.Set(value, err)
+	}()
+	value, err = p.input.Handler(ctx, task.in)
+}
+
+func (p *pool[In, Out]) Submit(ctx context.Context, in In) Future[Out] {
+	result := newFuture[Out]()
+	var zero Out
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		result.Set(zero, stackerr.Wrap(ErrPoolClosed))
+		return result
+	}
+	select {
+	case p.tasks <- poolTask[In, Out]{
+		ctx:    ctx,
+		in:     in,
+		result: result,
+	}:
+	case <-ctx.Done():
+		result.Set(zero, stackerr.Wrap(ctx.Err()))
+	}
+	return result
+}
+
+func (p *pool[In, Out]) Shutdown(ctx context.Context) stackerr.Error {
+	p.lock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.lock.Unlock()
+	select {
+	case <-p.finished:
+		return nil
+	case <-ctx.Done():
+		return stackerr.Wrap(ctx.Err())
+	}
+}
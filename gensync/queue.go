@@ -0,0 +1,205 @@
+package gensync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrQueueFull is returned when pushing to a full BoundedQueue with the OverflowError policy.
+var ErrQueueFull = errors.New("queue is full")
+
+// ErrQueueClosed is returned when pushing to a closed BoundedQueue, or popping
+// from one that's closed and empty.
+var ErrQueueClosed = errors.New("queue is closed")
+
+// OverflowPolicy is what a BoundedQueue does when a value is pushed to it while it's full.
+type OverflowPolicy int
+
+const (
+	// Wait until there's room for the value
+	OverflowBlock OverflowPolicy = iota
+	// Drop the oldest value in the queue to make room for the new one
+	OverflowDropOldest
+	// Drop the new value
+	OverflowDropNewest
+	// Return ErrQueueFull
+	OverflowError
+)
+
+type NewBoundedQueueInput[T any] struct {
+	// The maximum number of values in the queue. Must be at least 1.
+	Capacity int
+	// OPTIONAL. What to do when a value is pushed while the queue is full.
+	// Defaults to OverflowBlock.
+	OverflowPolicy OverflowPolicy
+	// OPTIONAL. A function to call with each value that's dropped by the
+	// OverflowDropOldest or OverflowDropNewest policies.
+	OnDrop func(value T)
+}
+
+// BoundedQueue is a first-in-first-out queue with a maximum size, for passing values
+// between producers and consumers.
+type BoundedQueue[T any] interface {
+	// Push adds a value to the back of the queue. If the queue is full, it's handled according
+	// to the overflow policy. With OverflowBlock, it returns the context's error if the context is
+	// done before there's room.
+	Push(ctx context.Context, value T) stackerr.Error
+	// TryPush adds a value to the back of the queue if there's room, and returns a bool of
+	// whether it was added. It never blocks or drops values, regardless of the overflow policy.
+	TryPush(value T) bool
+	// Pop removes and returns the value at the front of the queue, waiting until there is one.
+	// It returns the context's error if the context is done first, or ErrQueueClosed if the
+	// queue is closed and empty.
+	Pop(ctx context.Context) (T, stackerr.Error)
+	// TryPop removes and returns the value at the front of the queue, and a bool of whether
+	// there was one. It never blocks.
+	TryPop() (T, bool)
+	// Len returns the number of values in the queue.
+	Len() int
+	// Cap returns the maximum number of values in the queue.
+	Cap() int
+	// Close closes the queue, so that no more values can be pushed. Values that are already
+	// in the queue can still be popped.
+	Close()
+}
+
+type boundedQueue[T any] struct {
+	input  NewBoundedQueueInput[T]
+	lock   sync.Mutex
+	values collections.Deque[T]
+	closed bool
+	// Closed and replaced whenever a value is pushed or popped, or the
+	// queue is closed, to wake up anything that's waiting
+	changed chan struct{}
+}
+
+func NewBoundedQueue[T any](input NewBoundedQueueInput[T]) (BoundedQueue[T], stackerr.Error) {
+	if input.Capacity < 1 {
+		return nil, stackerr.Errorf("the `input.Capacity` field must be at least 1, got %d", input.Capacity)
+	}
+	return &boundedQueue[T]{
+		input:   input,
+		values:  collections.NewDequePreallocated[T](input.Capacity),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// broadcast wakes up anything that's waiting. The lock must be held.
+func (q *boundedQueue[T]) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *boundedQueue[T]) Push(ctx context.Context, value T) stackerr.Error {
+	q.lock.Lock()
+	for {
+		if q.closed {
+			q.lock.Unlock()
+			return stackerr.Wrap(ErrQueueClosed)
+		}
+		if q.values.Len() < q.input.Capacity {
+			q.values.PushBack(value)
+			q.broadcast()
+			q.lock.Unlock()
+			return nil
+		}
+		switch q.input.OverflowPolicy {
+		case OverflowDropOldest:
+			dropped, _ := q.values.PopFront()
+			q.values.PushBack(value)
+			q.broadcast()
+			q.lock.Unlock()
+			if q.input.OnDrop != nil {
+				q.input.OnDrop(dropped)
+			}
+			return nil
+		case OverflowDropNewest:
+			q.lock.Unlock()
+			if q.input.OnDrop != nil {
+				q.input.OnDrop(value)
+			}
+			return nil
+		case OverflowError:
+			q.lock.Unlock()
+			return stackerr.Wrap(ErrQueueFull)
+		}
+		// Block until something changes, then check again
+		changed := q.changed
+		q.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return stackerr.Wrap(ctx.Err())
+		case <-changed:
+		}
+		q.lock.Lock()
+	}
+}
+
+func (q *boundedQueue[T]) TryPush(value T) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed || q.values.Len() >= q.input.Capacity {
+		return false
+	}
+	q.values.PushBack(value)
+	q.broadcast()
+	return true
+}
+
+func (q *boundedQueue[T]) Pop(ctx context.Context) (T, stackerr.Error) {
+	q.lock.Lock()
+	for {
+		if value, ok := q.values.PopFront(); ok {
+			q.broadcast()
+			q.lock.Unlock()
+			return value, nil
+		}
+		if q.closed {
+			q.lock.Unlock()
+			var zero T
+			return zero, stackerr.Wrap(ErrQueueClosed)
+		}
+		changed := q.changed
+		q.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, stackerr.Wrap(ctx.Err())
+		case <-changed:
+		}
+		q.lock.Lock()
+	}
+}
+
+func (q *boundedQueue[T]) TryPop() (T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	value, ok := q.values.PopFront()
+	if ok {
+		q.broadcast()
+	}
+	return value, ok
+}
+
+func (q *boundedQueue[T]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.values.Len()
+}
+
+func (q *boundedQueue[T]) Cap() int {
+	return q.input.Capacity
+}
+
+func (q *boundedQueue[T]) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.closed {
+		q.closed = true
+		q.broadcast()
+	}
+}
@@ -0,0 +1,263 @@
+package gensync
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type NewRateLimiterInput struct {
+	// The number of events that are allowed per period. Must be at least 1.
+	Limit int
+	// The length of the period. Must be greater than 0.
+	Period time.Duration
+	// OPTIONAL. For token bucket limiters, the maximum number of events that can happen
+	// at once after a quiet period. Defaults to Limit. Sliding window limiters ignore it.
+	Burst int
+	// OPTIONAL. A function that gets the current time. If not provided, time.Now is used.
+	Now func() time.Time
+}
+
+// RateLimiter limits how often events can happen.
+type RateLimiter interface {
+	// Wait waits until an event is allowed, and takes it. It returns the context's
+	// error (and takes nothing) if the context is done first.
+	Wait(ctx context.Context) stackerr.Error
+	// Allow takes an event if one is allowed right now, and returns
+	// a bool of whether it was taken. It never blocks.
+	Allow() bool
+	// Reserve takes the next event that's allowed, and returns how long to wait
+	// before it happens. It never blocks.
+	Reserve() time.Duration
+	// SetRate changes the number of events that are allowed per period. It applies
+	// to events that haven't been reserved yet.
+	SetRate(limit int, period time.Duration) stackerr.Error
+	// SetBurst changes the burst size of a token bucket limiter. Sliding
+	// window limiters ignore it.
+	SetBurst(burst int)
+}
+
+func validateRate(limit int, period time.Duration) stackerr.Error {
+	if limit < 1 {
+		return stackerr.Errorf("the limit must be at least 1, got %d", limit)
+	}
+	if period <= 0 {
+		return stackerr.Errorf("the period must be greater than 0, got %s", period)
+	}
+	return nil
+}
+
+// waitForReservation waits for a reserved event, and gives it back
+// if the context is done first.
+func waitForReservation(ctx context.Context, delay time.Duration, cancel func()) stackerr.Error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return stackerr.Wrap(ctx.Err())
+	}
+}
+
+type tokenBucketRateLimiter struct {
+	lock sync.Mutex
+	now  func() time.Time
+	// Tokens per nanosecond
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketRateLimiter creates a rate limiter that uses a token bucket. The bucket
+// holds up to Burst tokens and starts full, each event takes a token, and tokens are
+// added at a steady rate of Limit per Period.
+func NewTokenBucketRateLimiter(input NewRateLimiterInput) (RateLimiter, stackerr.Error) {
+	if err := validateRate(input.Limit, input.Period); err != nil {
+		return nil, err
+	}
+	if input.Burst < 1 {
+		input.Burst = input.Limit
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	return &tokenBucketRateLimiter{
+		now:    input.Now,
+		rate:   float64(input.Limit) / float64(input.Period),
+		burst:  input.Burst,
+		tokens: float64(input.Burst),
+		last:   input.Now(),
+	}, nil
+}
+
+// refill adds the tokens that have accumulated since the last refill. The lock must be held.
+func (rl *tokenBucketRateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(rl.last); elapsed > 0 {
+		rl.tokens = math.Min(float64(rl.burst), rl.tokens+float64(elapsed)*rl.rate)
+		rl.last = now
+	}
+}
+
+// reserve takes a token, and returns how long to wait until it's available. If
+// onlyNow is true, it only takes the token if it's available right away.
+func (rl *tokenBucketRateLimiter) reserve(onlyNow bool) (time.Duration, bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.refill(rl.now())
+	if rl.tokens >= 1 {
+		rl.tokens--
+		return 0, true
+	}
+	if onlyNow {
+		return 0, false
+	}
+	rl.tokens--
+	return time.Duration(math.Ceil(-rl.tokens / rl.rate)), true
+}
+
+func (rl *tokenBucketRateLimiter) Wait(ctx context.Context) stackerr.Error {
+	if err := ctx.Err(); err != nil {
+		return stackerr.Wrap(err)
+	}
+	delay, _ := rl.reserve(false)
+	return waitForReservation(ctx, delay, func() {
+		rl.lock.Lock()
+		defer rl.lock.Unlock()
+		rl.tokens = math.Min(float64(rl.burst), rl.tokens+1)
+	})
+}
+
+func (rl *tokenBucketRateLimiter) Allow() bool {
+	_, ok := rl.reserve(true)
+	return ok
+}
+
+func (rl *tokenBucketRateLimiter) Reserve() time.Duration {
+	delay, _ := rl.reserve(false)
+	return delay
+}
+
+func (rl *tokenBucketRateLimiter) SetRate(limit int, period time.Duration) stackerr.Error {
+	if err := validateRate(limit, period); err != nil {
+		return err
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	// Add the tokens that accumulated at the old rate before changing it
+	rl.refill(rl.now())
+	rl.rate = float64(limit) / float64(period)
+	return nil
+}
+
+func (rl *tokenBucketRateLimiter) SetBurst(burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.refill(rl.now())
+	rl.burst = burst
+	rl.tokens = math.Min(float64(burst), rl.tokens)
+}
+
+type slidingWindowRateLimiter struct {
+	lock   sync.Mutex
+	now    func() time.Time
+	limit  int
+	period time.Duration
+	// The times of the events in the current window (including reserved
+	// events in the future), in ascending order
+	events []time.Time
+}
+
+// NewSlidingWindowRateLimiter creates a rate limiter that uses a sliding window, which
+// allows at most Limit events in any span of time of length Period.
+func NewSlidingWindowRateLimiter(input NewRateLimiterInput) (RateLimiter, stackerr.Error) {
+	if err := validateRate(input.Limit, input.Period); err != nil {
+		return nil, err
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	return &slidingWindowRateLimiter{
+		now:    input.Now,
+		limit:  input.Limit,
+		period: input.Period,
+	}, nil
+}
+
+// reserve takes the next event, and returns its time and how long to wait until it. If
+// onlyNow is true, it only takes the event if it's allowed right away.
+func (rl *slidingWindowRateLimiter) reserve(onlyNow bool) (time.Time, time.Duration, bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	now := rl.now()
+	// Remove the events that are no longer in the window
+	expired := 0
+	for expired < len(rl.events) && !rl.events[expired].After(now.Add(-rl.period)) {
+		expired++
+	}
+	rl.events = rl.events[expired:]
+
+	at := now
+	if len(rl.events) >= rl.limit {
+		// It can happen once the event that's the limit before it leaves the window
+		if next := rl.events[len(rl.events)-rl.limit].Add(rl.period); next.After(at) {
+			at = next
+		}
+	}
+	if onlyNow && at.After(now) {
+		return at, 0, false
+	}
+	rl.events = append(rl.events, at)
+	return at, at.Sub(now), true
+}
+
+func (rl *slidingWindowRateLimiter) Wait(ctx context.Context) stackerr.Error {
+	if err := ctx.Err(); err != nil {
+		return stackerr.Wrap(err)
+	}
+	at, delay, _ := rl.reserve(false)
+	return waitForReservation(ctx, delay, func() {
+		rl.lock.Lock()
+		defer rl.lock.Unlock()
+		for i := len(rl.events) - 1; i >= 0; i-- {
+			if rl.events[i].Equal(at) {
+				rl.events = append(rl.events[:i], rl.events[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func (rl *slidingWindowRateLimiter) Allow() bool {
+	_, _, ok := rl.reserve(true)
+	return ok
+}
+
+func (rl *slidingWindowRateLimiter) Reserve() time.Duration {
+	_, delay, _ := rl.reserve(false)
+	return delay
+}
+
+func (rl *slidingWindowRateLimiter) SetRate(limit int, period time.Duration) stackerr.Error {
+	if err := validateRate(limit, period); err != nil {
+		return err
+	}
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.limit = limit
+	rl.period = period
+	return nil
+}
+
+func (rl *slidingWindowRateLimiter) SetBurst(burst int) {}
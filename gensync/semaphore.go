@@ -0,0 +1,159 @@
+package gensync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrSemaphoreOverReleased is returned when releasing more of a Semaphore than is acquired.
+var ErrSemaphoreOverReleased = errors.New("released more than was acquired")
+
+// Semaphore is a weighted semaphore, which limits the total weight of the holders at once.
+// Waiters acquire it in the order that they started waiting, so a large request isn't
+// starved by smaller ones.
+type Semaphore interface {
+	// Acquire acquires the given weight, waiting until it's available. It returns the
+	// context's error (and acquires nothing) if the context is done first.
+	Acquire(ctx context.Context, n int64) stackerr.Error
+	// TryAcquire acquires the given weight if it's available and nothing is waiting,
+	// and returns a bool of whether it was acquired. It never blocks.
+	TryAcquire(n int64) bool
+	// Release releases the given weight. It returns an error (and releases
+	// nothing) if more would be released than is acquired.
+	Release(n int64) stackerr.Error
+	// Resize changes the capacity. If it's reduced below the current acquired weight,
+	// holders keep their weight, and new acquisitions wait until enough is released.
+	Resize(capacity int64)
+	// Capacity returns the current capacity.
+	Capacity() int64
+	// Acquired returns the weight that's currently acquired.
+	Acquired() int64
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+type semaphore struct {
+	lock     sync.Mutex
+	capacity int64
+	acquired int64
+	waiters  collections.LinkedList[*semaphoreWaiter]
+}
+
+// NewSemaphore creates a semaphore with the given capacity.
+func NewSemaphore(capacity int64) Semaphore {
+	return &semaphore{
+		capacity: capacity,
+		waiters:  collections.NewLinkedList[*semaphoreWaiter](),
+	}
+}
+
+func (s *semaphore) Acquire(ctx context.Context, n int64) stackerr.Error {
+	if n < 0 {
+		return stackerr.Errorf("cannot acquire a negative weight (%d)", n)
+	}
+	s.lock.Lock()
+	if s.waiters.Len() == 0 && s.acquired+n <= s.capacity {
+		s.acquired += n
+		s.lock.Unlock()
+		return nil
+	}
+	w := &semaphoreWaiter{
+		n:     n,
+		ready: make(chan struct{}),
+	}
+	elem := s.waiters.PushBack(w)
+	s.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		select {
+		case <-w.ready:
+			// It was acquired just after the context was done, so give it back
+			s.acquired -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// If this waiter was blocking the ones behind it, they may be able to acquire now
+			if isFront {
+				s.notifyWaiters()
+			}
+		}
+		s.lock.Unlock()
+		return stackerr.Wrap(ctx.Err())
+	}
+}
+
+func (s *semaphore) TryAcquire(n int64) bool {
+	if n < 0 {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.waiters.Len() == 0 && s.acquired+n <= s.capacity {
+		s.acquired += n
+		return true
+	}
+	return false
+}
+
+func (s *semaphore) Release(n int64) stackerr.Error {
+	if n < 0 {
+		return stackerr.Errorf("cannot release a negative weight (%d)", n)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if n > s.acquired {
+		return stackerr.Wrap(ErrSemaphoreOverReleased)
+	}
+	s.acquired -= n
+	s.notifyWaiters()
+	return nil
+}
+
+func (s *semaphore) Resize(capacity int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.capacity = capacity
+	s.notifyWaiters()
+}
+
+func (s *semaphore) Capacity() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.capacity
+}
+
+func (s *semaphore) Acquired() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.acquired
+}
+
+// notifyWaiters gives the weight to waiters in order, until the next one doesn't
+// fit. The lock must be held.
+func (s *semaphore) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value()
+		if s.acquired+w.n > s.capacity {
+			return
+		}
+		s.acquired += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,319 @@
+package gensync
+
+import (
+	"math"
+	"reflect"
+	"sync"
+)
+
+// The number of shards in a ShardedMap that was created without a shard count
+const defaultShardedMapShards = 32
+
+type mapShard[K comparable, V any] struct {
+	lock sync.RWMutex
+	m    map[K]V
+}
+
+// ShardedMap is a concurrent map with the same API as Map, which partitions its keys
+// across a number of separately locked maps. Unlike Map (which uses sync.Map), it
+// performs well for write-heavy workloads. The zero value is ready to use, with the
+// default number of shards.
+type ShardedMap[K comparable, V any] struct {
+	initOnce sync.Once
+	shards   []*mapShard[K, V]
+	hasher   func(key K) uint64
+}
+
+// NewShardedMap creates a new sharded map with initial values. If the number
+// of shards is 0 or less, the default (32) is used.
+func NewShardedMap[K comparable, V any](shards int, initial map[K]V) *ShardedMap[K, V] {
+	return NewShardedMapWithHasher(shards, nil, initial)
+}
+
+// NewShardedMapWithHasher is the same as NewShardedMap, but uses the given function to hash
+// keys to shards, which must return the same hash for equal keys. It's useful for struct keys,
+// which are otherwise hashed field by field with reflection. If the hasher is nil, the default
+// hashing is used.
+func NewShardedMapWithHasher[K comparable, V any](shards int, hasher func(key K) uint64, initial map[K]V) *ShardedMap[K, V] {
+	m := &ShardedMap[K, V]{
+		hasher: hasher,
+	}
+	m.init(shards)
+	for k, v := range initial {
+		m.Store(k, v)
+	}
+	return m
+}
+
+func (m *ShardedMap[K, V]) init(shards int) {
+	m.initOnce.Do(func() {
+		if shards <= 0 {
+			shards = defaultShardedMapShards
+		}
+		m.shards = make([]*mapShard[K, V], shards)
+		for i := range m.shards {
+			m.shards[i] = &mapShard[K, V]{
+				m: map[K]V{},
+			}
+		}
+	})
+}
+
+// mixShardHash mixes the bits of a hash (the splitmix64 finalizer), so that
+// keys that differ only in a few bits are spread across the shards.
+func mixShardHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// hashShardString hashes a string with FNV-1a.
+func hashShardString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// hashShardFloat hashes a float, so that 0 and -0 (which are equal keys) have the same hash.
+func hashShardFloat(f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return mixShardHash(math.Float64bits(f))
+}
+
+// hashShardKey hashes a key for choosing its shard. Equal keys always have the same hash.
+func hashShardKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashShardString(k)
+	case int:
+		return mixShardHash(uint64(k))
+	case int8:
+		return mixShardHash(uint64(k))
+	case int16:
+		return mixShardHash(uint64(k))
+	case int32:
+		return mixShardHash(uint64(k))
+	case int64:
+		return mixShardHash(uint64(k))
+	case uint:
+		return mixShardHash(uint64(k))
+	case uint8:
+		return mixShardHash(uint64(k))
+	case uint16:
+		return mixShardHash(uint64(k))
+	case uint32:
+		return mixShardHash(uint64(k))
+	case uint64:
+		return mixShardHash(k)
+	case uintptr:
+		return mixShardHash(uint64(k))
+	case float32:
+		return hashShardFloat(float64(k))
+	case float64:
+		return hashShardFloat(k)
+	case complex64:
+		return hashShardFloat(float64(real(k))) ^ mixShardHash(hashShardFloat(float64(imag(k))))
+	case complex128:
+		return hashShardFloat(real(k)) ^ mixShardHash(hashShardFloat(imag(k)))
+	case bool:
+		if k {
+			return 1
+		}
+		return 0
+	}
+	var boxed any = key
+	return hashShardValue(reflect.ValueOf(boxed))
+}
+
+// hashShardValue hashes a value of any comparable type (including named types,
+// structs, arrays, pointers, and interfaces) with reflection.
+func hashShardValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return hashShardString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mixShardHash(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mixShardHash(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashShardFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return hashShardFloat(real(c)) ^ mixShardHash(hashShardFloat(imag(c)))
+	case reflect.Bool:
+		if v.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return mixShardHash(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashShardValue(v.Elem())
+	case reflect.Struct:
+		h := uint64(0)
+		for i := 0; i < v.NumField(); i++ {
+			h = mixShardHash(h ^ hashShardValue(v.Field(i)))
+		}
+		return h
+	case reflect.Array:
+		h := uint64(0)
+		for i := 0; i < v.Len(); i++ {
+			h = mixShardHash(h ^ hashShardValue(v.Index(i)))
+		}
+		return h
+	}
+	// Nothing else is comparable, so it can't be a key
+	return 0
+}
+
+// shard gets the shard that a key is in.
+func (m *ShardedMap[K, V]) shard(key K) *mapShard[K, V] {
+	m.init(0)
+	var h uint64
+	if m.hasher != nil {
+		h = m.hasher(key)
+	} else {
+		h = hashShardKey(key)
+	}
+	return m.shards[h%uint64(len(m.shards))]
+}
+
+// Delete deletes the value for a key.
+func (m *ShardedMap[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.m, key)
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no value is present. The ok result indicates whether value was found in the map.
+func (m *ShardedMap[K, V]) Load(key K) (value V, ok bool) {
+	s := m.shard(key)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	value, ok = s.m[key]
+	return value, ok
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any. The loaded result reports whether the key was present.
+func (m *ShardedMap[K, V]) LoadAndDelete(key K) (value V, ok bool) {
+	s := m.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	value, ok = s.m[key]
+	delete(s.m, key)
+	return value, ok
+}
+
+// LoadOrStore returns the existing value for the key if present. Otherwise, it stores and returns the given value. The loaded result is true if the value was loaded, false if stored.
+func (m *ShardedMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if existing, ok := s.m[key]; ok {
+		return existing, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+/*
+Range calls f sequentially for each key and value present in the map. If f returns false, range stops the iteration.
+
+Range does not correspond to a consistent snapshot of the map's contents: each shard is copied when it's reached, so changes to shards that haven't been reached yet may be reflected. No shard is locked while f is called, so f may call any method on m.
+*/
+func (m *ShardedMap[K, V]) Range(f func(key K, value V) bool) {
+	m.init(0)
+	for _, s := range m.shards {
+		s.lock.RLock()
+		keys := make([]K, 0, len(s.m))
+		values := make([]V, 0, len(s.m))
+		for k, v := range s.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.lock.RUnlock()
+		for i := range keys {
+			if !f(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Length will get the number of elements in the map. It is subject to the same conditions/restrictions as Range.
+func (m *ShardedMap[K, V]) Length() (length int) {
+	m.Range(func(_ K, _ V) bool {
+		length++
+		return true
+	})
+	return length
+}
+
+// LenFast will get the number of elements in the map, by adding up the size of each shard
+// without iterating over them. Like Length, it's not a consistent snapshot if the map is
+// being modified concurrently.
+func (m *ShardedMap[K, V]) LenFast() (length int) {
+	m.init(0)
+	for _, s := range m.shards {
+		s.lock.RLock()
+		length += len(s.m)
+		s.lock.RUnlock()
+	}
+	return length
+}
+
+// Keys will get all keys in the map. It is subject to the same conditions/restrictions as Range.
+func (m *ShardedMap[K, V]) Keys() []K {
+	keys := []K{}
+	m.Range(func(key K, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Values will get all values in the map. It is subject to the same conditions/restrictions as Range.
+func (m *ShardedMap[K, V]) Values() []V {
+	values := []V{}
+	m.Range(func(_ K, value V) bool {
+		values = append(values, value)
+		return true
+	})
+	return values
+}
+
+// Store sets the value for a key.
+func (m *ShardedMap[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m[key] = value
+}
+
+// Has checks if the map contains the given key
+func (m *ShardedMap[K, V]) Has(key K) bool {
+	_, ok := m.Load(key)
+	return ok
+}
+
+// ToMap returns a standard map with all of the values in this sharded map.
+func (m *ShardedMap[K, V]) ToMap() map[K]V {
+	nm := map[K]V{}
+	m.Range(func(key K, value V) bool {
+		nm[key] = value
+		return true
+	})
+	return nm
+}
@@ -0,0 +1,81 @@
+package gensync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type groupCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   stackerr.Error
+	// The number of callers that joined the call after the first one
+	dups int
+}
+
+// Group collapses concurrent calls for the same key into a single call of the function
+// (singleflight), and gives its result to all of the callers. The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	lock  sync.Mutex
+	calls map[K]*groupCall[V]
+}
+
+// Do calls the function for a key, unless there's already a call in progress for the key, in which
+// case it waits for that call and returns its result. The shared result is true if the result was
+// given to more than one caller. Panics in the function are recovered and returned as errors.
+//
+// The function runs in its own goroutine, so if the context is done before it returns, Do returns
+// the context's error without waiting for it, and the call continues for any other callers.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func() (V, stackerr.Error)) (value V, shared bool, err stackerr.Error) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = map[K]*groupCall[V]{}
+	}
+	call, ok := g.calls[key]
+	if ok {
+		call.dups++
+	} else {
+		call = &groupCall[V]{
+			done: make(chan struct{}),
+		}
+		g.calls[key] = call
+		go g.run(key, call, fn)
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return value, false, stackerr.Wrap(ctx.Err())
+	case <-call.done:
+	}
+	g.lock.Lock()
+	shared = call.dups > 0
+	g.lock.Unlock()
+	return call.value, shared, call.err
+}
+
+func (g *Group[K, V]) run(key K, call *groupCall[V], fn func() (V, stackerr.Error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = stackerr.FromRecover(r)
+		}
+		g.lock.Lock()
+		// The key may have been forgotten, and a new call started for it
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.lock.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+}
+
+// Forget makes the next call of Do for a key call the function, instead of waiting for
+// a call that's in progress. Callers that are already waiting still get its result.
+func (g *Group[K, V]) Forget(key K) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.calls, key)
+}
@@ -0,0 +1,201 @@
+package gensync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type NewTTLMapInput[K comparable, V any] struct {
+	// OPTIONAL. How long entries are kept for after they're set, unless a TTL is given
+	// for the entry. If not provided, entries don't expire unless they have their own TTL.
+	DefaultTTL time.Duration
+	// OPTIONAL. How often the background janitor removes expired entries. Defaults
+	// to 1 minute. Expired entries are never returned, even before they're removed.
+	JanitorInterval time.Duration
+	// OPTIONAL. A function to call with each expired entry when it's removed (by the janitor
+	// or RemoveExpired). It's called without any locks held, so it may use the map.
+	OnEvict func(key K, value V)
+	// OPTIONAL. A function that gets the current time. If not provided, time.Now is used.
+	Now func() time.Time
+}
+
+type ttlMapEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// ttlMapCall is an in-progress computation of a value by GetOrCompute.
+type ttlMapCall[V any] struct {
+	done  chan struct{}
+	value V
+	ttl   time.Duration
+	err   stackerr.Error
+}
+
+// TTLMap is a concurrent map where entries expire after a TTL. Expired entries are removed
+// by a background janitor goroutine, which runs until the context that the map was created
+// with is done.
+type TTLMap[K comparable, V any] struct {
+	input    NewTTLMapInput[K, V]
+	lock     sync.Mutex
+	entries  map[K]ttlMapEntry[V]
+	inflight map[K]*ttlMapCall[V]
+}
+
+// NewTTLMap creates a new TTL map, and starts its janitor goroutine, which
+// runs until the context is done.
+func NewTTLMap[K comparable, V any](ctx context.Context, input NewTTLMapInput[K, V]) *TTLMap[K, V] {
+	if input.JanitorInterval <= 0 {
+		input.JanitorInterval = time.Minute
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	m := &TTLMap[K, V]{
+		input:    input,
+		entries:  map[K]ttlMapEntry[V]{},
+		inflight: map[K]*ttlMapCall[V]{},
+	}
+	go m.janitor(ctx)
+	return m
+}
+
+func (m *TTLMap[K, V]) janitor(ctx context.Context) {
+	ticker := time.NewTicker(m.input.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.RemoveExpired()
+		}
+	}
+}
+
+// expired checks whether an entry has expired.
+func (m *TTLMap[K, V]) expired(entry ttlMapEntry[V], now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+// load gets the value for a key if it hasn't expired. The lock must be held.
+func (m *TTLMap[K, V]) load(key K) (value V, ok bool) {
+	entry, ok := m.entries[key]
+	if !ok || m.expired(entry, m.input.Now()) {
+		return value, false
+	}
+	return entry.value, true
+}
+
+// store sets the value for a key. The lock must be held.
+func (m *TTLMap[K, V]) store(key K, value V, ttl time.Duration) {
+	entry := ttlMapEntry[V]{
+		value: value,
+	}
+	if ttl > 0 {
+		entry.expires = m.input.Now().Add(ttl)
+	}
+	m.entries[key] = entry
+}
+
+// Load returns the value stored in the map for a key, or the zero value if no value is present
+// (or it has expired). The ok result indicates whether value was found in the map.
+func (m *TTLMap[K, V]) Load(key K) (value V, ok bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.load(key)
+}
+
+// Store sets the value for a key, with the default TTL.
+func (m *TTLMap[K, V]) Store(key K, value V) {
+	m.StoreWithTTL(key, value, m.input.DefaultTTL)
+}
+
+// StoreWithTTL sets the value for a key, which expires after the given TTL. A TTL
+// of 0 or less means that it doesn't expire.
+func (m *TTLMap[K, V]) StoreWithTTL(key K, value V, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.store(key, value, ttl)
+}
+
+// Delete deletes the value for a key.
+func (m *TTLMap[K, V]) Delete(key K) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, key)
+}
+
+// GetOrCompute returns the value for a key if it's present. Otherwise, it calls the compute function
+// to get the value and its TTL (where 0 or less means the default TTL), stores it, and returns it. If
+// the compute function returns an error, nothing is stored and the error is returned. Concurrent
+// calls for the same key wait for a single call of the compute function and share its result.
+func (m *TTLMap[K, V]) GetOrCompute(key K, compute func() (value V, ttl time.Duration, err stackerr.Error)) (value V, err stackerr.Error) {
+	m.lock.Lock()
+	if value, ok := m.load(key); ok {
+		m.lock.Unlock()
+		return value, nil
+	}
+	if call, ok := m.inflight[key]; ok {
+		m.lock.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &ttlMapCall[V]{
+		done: make(chan struct{}),
+	}
+	m.inflight[key] = call
+	m.lock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = stackerr.FromRecover(r)
+		}
+		m.lock.Lock()
+		delete(m.inflight, key)
+		if call.err == nil {
+			ttl := call.ttl
+			if ttl <= 0 {
+				ttl = m.input.DefaultTTL
+			}
+			m.store(key, call.value, ttl)
+		}
+		m.lock.Unlock()
+		close(call.done)
+		value, err = call.value, call.err
+	}()
+	call.value, call.ttl, call.err = compute()
+	return call.value, call.err
+}
+
+// Len returns the number of entries, which may include expired entries that
+// haven't been removed yet.
+func (m *TTLMap[K, V]) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.entries)
+}
+
+// RemoveExpired removes all entries that have expired, and returns the number that
+// were removed. It's called periodically by the janitor, but may also be called directly.
+func (m *TTLMap[K, V]) RemoveExpired() int {
+	m.lock.Lock()
+	now := m.input.Now()
+	evicted := map[K]V{}
+	for key, entry := range m.entries {
+		if m.expired(entry, now) {
+			evicted[key] = entry.value
+			delete(m.entries, key)
+		}
+	}
+	m.lock.Unlock()
+	if m.input.OnEvict != nil {
+		for key, value := range evicted {
+			m.input.OnEvict(key, value)
+		}
+	}
+	return len(evicted)
+}
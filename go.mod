@@ -3,7 +3,6 @@ module github.com/Invicton-Labs/go-common
 go 1.20
 
 require (
-	github.com/Invicton-Labs/go-concurrency v0.1.2
 	github.com/Invicton-Labs/go-stackerr v0.1.0
 	github.com/aws/aws-lambda-go v1.34.1
 	github.com/aws/aws-sdk-go v1.44.116
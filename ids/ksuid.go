@@ -0,0 +1,141 @@
+package ids
+
+import (
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The base62 alphabet, used for KSUIDs
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// KSUID timestamps are seconds since this epoch (2014-05-13T16:53:20Z), which
+// extends the range of the 32-bit timestamp by ~100 years.
+const ksuidEpoch int64 = 1400000000
+
+// The length of a KSUID's string representation
+const ksuidStringLength = 27
+
+var base62 = big.NewInt(62)
+
+// KSUID is a K-Sortable Unique Identifier. It consists of a 32-bit timestamp
+// (seconds since the KSUID epoch) followed by 128 bits of randomness.
+type KSUID [20]byte
+
+var (
+	ksuidLock        sync.Mutex
+	lastKsuidSeconds uint32
+	lastKsuidPayload [16]byte
+)
+
+// NewKSUID generates a new KSUID using cryptographically secure randomness. KSUIDs generated
+// within the same second by this process are monotonically increasing.
+func NewKSUID() KSUID {
+	return newKSUIDAt(time.Now())
+}
+
+func newKSUIDAt(t time.Time) KSUID {
+	seconds := uint32(t.Unix() - ksuidEpoch)
+
+	ksuidLock.Lock()
+	defer ksuidLock.Unlock()
+
+	if seconds <= lastKsuidSeconds {
+		// Same (or earlier, if the clock went backwards) second, so increment
+		// the previous payload to keep the IDs monotonic.
+		seconds = lastKsuidSeconds
+		overflow := true
+		for i := len(lastKsuidPayload) - 1; i >= 0; i-- {
+			lastKsuidPayload[i]++
+			if lastKsuidPayload[i] != 0 {
+				overflow = false
+				break
+			}
+		}
+		// If the payload overflowed, move to the next second
+		if overflow {
+			seconds++
+			mustReadRandom(lastKsuidPayload[:])
+		}
+	} else {
+		mustReadRandom(lastKsuidPayload[:])
+	}
+	lastKsuidSeconds = seconds
+
+	var k KSUID
+	binary.BigEndian.PutUint32(k[:4], seconds)
+	copy(k[4:], lastKsuidPayload[:])
+	return k
+}
+
+// ParseKSUID parses a KSUID from its base62 string representation.
+func ParseKSUID(s string) (KSUID, stackerr.Error) {
+	var k KSUID
+	if len(s) != ksuidStringLength {
+		return k, stackerr.Errorf("invalid KSUID '%s': must be %d characters long", s, ksuidStringLength)
+	}
+	n := new(big.Int)
+	for i := 0; i < len(s); i++ {
+		idx := strings.IndexByte(base62Alphabet, s[i])
+		if idx < 0 {
+			return k, stackerr.Errorf("invalid KSUID '%s': invalid character '%c'", s, s[i])
+		}
+		n.Mul(n, base62)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+	b := n.Bytes()
+	if len(b) > len(k) {
+		return k, stackerr.Errorf("invalid KSUID '%s': value overflows 160 bits", s)
+	}
+	copy(k[len(k)-len(b):], b)
+	return k, nil
+}
+
+// IsValidKSUID returns whether the string is a valid KSUID.
+func IsValidKSUID(s string) bool {
+	_, err := ParseKSUID(s)
+	return err == nil
+}
+
+// String returns the base62 representation of the KSUID.
+func (k KSUID) String() string {
+	n := new(big.Int).SetBytes(k[:])
+	dst := make([]byte, ksuidStringLength)
+	mod := new(big.Int)
+	for i := ksuidStringLength - 1; i >= 0; i-- {
+		n.DivMod(n, base62, mod)
+		dst[i] = base62Alphabet[mod.Int64()]
+	}
+	return string(dst)
+}
+
+// Time returns the time encoded in the KSUID (with second precision).
+func (k KSUID) Time() time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint32(k[:4]))+ksuidEpoch, 0)
+}
+
+// Payload returns the random payload of the KSUID.
+func (k KSUID) Payload() []byte {
+	p := make([]byte, 16)
+	copy(p, k[4:])
+	return p
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k KSUID) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *KSUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseKSUID(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
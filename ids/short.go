@@ -0,0 +1,56 @@
+package ids
+
+import (
+	"strings"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The URL-safe alphabet used for short IDs
+const shortIdAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
+
+// NewShortID generates a random URL-safe ID of the given length using cryptographically
+// secure randomness. Each character carries 6 bits of randomness, so a length of 22
+// provides roughly the same collision resistance as a UUID.
+func NewShortID(length int) string {
+	if length <= 0 {
+		return ""
+	}
+	b := make([]byte, length)
+	mustReadRandom(b)
+	for i := range b {
+		// The alphabet has exactly 64 characters, so this has no modulo bias
+		b[i] = shortIdAlphabet[b[i]&63]
+	}
+	return string(b)
+}
+
+// IsValidShortID returns whether the string is a valid short ID of the given length.
+// If the length is 0 or less, any non-empty length is accepted.
+func IsValidShortID(s string, length int) bool {
+	if s == "" || (length > 0 && len(s) != length) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(shortIdAlphabet, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewPrefixedID generates a new ID that consists of the prefix followed by a new
+// ULID (e.g. "lock_01H4Z3J6W8X9Y0Z1A2B3C4D5E6"). Since ULIDs are time-ordered,
+// IDs with the same prefix sort in the order they were generated.
+func NewPrefixedID(prefix string) string {
+	return prefix + NewULID().String()
+}
+
+// ParsePrefixedID verifies that the ID has the given prefix followed by a valid
+// ULID, and returns the ULID.
+func ParsePrefixedID(id string, prefix string) (ULID, stackerr.Error) {
+	if !strings.HasPrefix(id, prefix) {
+		return ULID{}, stackerr.Errorf("ID '%s' does not have the expected prefix '%s'", id, prefix)
+	}
+	return ParseULID(id[len(prefix):])
+}
@@ -0,0 +1,205 @@
+package ids
+
+import (
+	"crypto/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The Crockford base32 alphabet, used for ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// The length of a ULID's string representation
+const ulidStringLength = 26
+
+var crockfordDecoding [256]byte
+
+func init() {
+	for i := range crockfordDecoding {
+		crockfordDecoding[i] = 0xFF
+	}
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		crockfordDecoding[crockfordAlphabet[i]] = byte(i)
+		crockfordDecoding[strings.ToLower(crockfordAlphabet[i : i+1])[0]] = byte(i)
+	}
+	// Crockford's base32 treats these as aliases
+	for _, c := range []byte{'O', 'o'} {
+		crockfordDecoding[c] = 0
+	}
+	for _, c := range []byte{'I', 'i', 'L', 'l'} {
+		crockfordDecoding[c] = 1
+	}
+}
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier. It consists of
+// a 48-bit millisecond timestamp followed by 80 bits of randomness, so ULIDs generated
+// later sort after ones generated earlier.
+type ULID [16]byte
+
+var (
+	ulidLock       sync.Mutex
+	lastUlidMillis uint64
+	lastUlidRandom [10]byte
+)
+
+// NewULID generates a new ULID using cryptographically secure randomness. ULIDs generated
+// within the same millisecond by this process are monotonically increasing.
+func NewULID() ULID {
+	return newULIDAt(time.Now())
+}
+
+func newULIDAt(t time.Time) ULID {
+	ms := uint64(t.UnixMilli())
+
+	ulidLock.Lock()
+	defer ulidLock.Unlock()
+
+	if ms <= lastUlidMillis {
+		// Same (or earlier, if the clock went backwards) millisecond, so increment
+		// the previous random component to keep the IDs monotonic.
+		ms = lastUlidMillis
+		overflow := true
+		for i := len(lastUlidRandom) - 1; i >= 0; i-- {
+			lastUlidRandom[i]++
+			if lastUlidRandom[i] != 0 {
+				overflow = false
+				break
+			}
+		}
+		// If the random component overflowed, move to the next millisecond
+		if overflow {
+			ms++
+			mustReadRandom(lastUlidRandom[:])
+		}
+	} else {
+		mustReadRandom(lastUlidRandom[:])
+	}
+	lastUlidMillis = ms
+
+	var u ULID
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	copy(u[6:], lastUlidRandom[:])
+	return u
+}
+
+// ParseULID parses a ULID from its string representation (case-insensitive).
+func ParseULID(s string) (ULID, stackerr.Error) {
+	var u ULID
+	if len(s) != ulidStringLength {
+		return u, stackerr.Errorf("invalid ULID '%s': must be %d characters long", s, ulidStringLength)
+	}
+	var v [ulidStringLength]byte
+	for i := 0; i < len(s); i++ {
+		v[i] = crockfordDecoding[s[i]]
+		if v[i] == 0xFF {
+			return u, stackerr.Errorf("invalid ULID '%s': invalid character '%c'", s, s[i])
+		}
+	}
+	// The first character can only use 3 bits, otherwise it overflows 128 bits
+	if v[0] > 7 {
+		return u, stackerr.Errorf("invalid ULID '%s': value overflows 128 bits", s)
+	}
+
+	// Timestamp (first 10 characters)
+	u[0] = (v[0] << 5) | v[1]
+	u[1] = (v[2] << 3) | (v[3] >> 2)
+	u[2] = (v[3] << 6) | (v[4] << 1) | (v[5] >> 4)
+	u[3] = (v[5] << 4) | (v[6] >> 1)
+	u[4] = (v[6] << 7) | (v[7] << 2) | (v[8] >> 3)
+	u[5] = (v[8] << 5) | v[9]
+
+	// Randomness (last 16 characters)
+	u[6] = (v[10] << 3) | (v[11] >> 2)
+	u[7] = (v[11] << 6) | (v[12] << 1) | (v[13] >> 4)
+	u[8] = (v[13] << 4) | (v[14] >> 1)
+	u[9] = (v[14] << 7) | (v[15] << 2) | (v[16] >> 3)
+	u[10] = (v[16] << 5) | v[17]
+	u[11] = (v[18] << 3) | (v[19] >> 2)
+	u[12] = (v[19] << 6) | (v[20] << 1) | (v[21] >> 4)
+	u[13] = (v[21] << 4) | (v[22] >> 1)
+	u[14] = (v[22] << 7) | (v[23] << 2) | (v[24] >> 3)
+	u[15] = (v[24] << 5) | v[25]
+
+	return u, nil
+}
+
+// IsValidULID returns whether the string is a valid ULID.
+func IsValidULID(s string) bool {
+	_, err := ParseULID(s)
+	return err == nil
+}
+
+// String returns the canonical (uppercase Crockford base32) representation of the ULID.
+func (u ULID) String() string {
+	dst := make([]byte, ulidStringLength)
+	a := crockfordAlphabet
+
+	// Timestamp (10 characters)
+	dst[0] = a[(u[0]&224)>>5]
+	dst[1] = a[u[0]&31]
+	dst[2] = a[(u[1]&248)>>3]
+	dst[3] = a[((u[1]&7)<<2)|((u[2]&192)>>6)]
+	dst[4] = a[(u[2]&62)>>1]
+	dst[5] = a[((u[2]&1)<<4)|((u[3]&240)>>4)]
+	dst[6] = a[((u[3]&15)<<1)|((u[4]&128)>>7)]
+	dst[7] = a[(u[4]&124)>>2]
+	dst[8] = a[((u[4]&3)<<3)|((u[5]&224)>>5)]
+	dst[9] = a[u[5]&31]
+
+	// Randomness (16 characters)
+	dst[10] = a[(u[6]&248)>>3]
+	dst[11] = a[((u[6]&7)<<2)|((u[7]&192)>>6)]
+	dst[12] = a[(u[7]&62)>>1]
+	dst[13] = a[((u[7]&1)<<4)|((u[8]&240)>>4)]
+	dst[14] = a[((u[8]&15)<<1)|((u[9]&128)>>7)]
+	dst[15] = a[(u[9]&124)>>2]
+	dst[16] = a[((u[9]&3)<<3)|((u[10]&224)>>5)]
+	dst[17] = a[u[10]&31]
+	dst[18] = a[(u[11]&248)>>3]
+	dst[19] = a[((u[11]&7)<<2)|((u[12]&192)>>6)]
+	dst[20] = a[(u[12]&62)>>1]
+	dst[21] = a[((u[12]&1)<<4)|((u[13]&240)>>4)]
+	dst[22] = a[((u[13]&15)<<1)|((u[14]&128)>>7)]
+	dst[23] = a[(u[14]&124)>>2]
+	dst[24] = a[((u[14]&3)<<3)|((u[15]&224)>>5)]
+	dst[25] = a[u[15]&31]
+
+	return string(dst)
+}
+
+// Time returns the time encoded in the ULID (with millisecond precision).
+func (u ULID) Time() time.Time {
+	ms := uint64(u[5]) | uint64(u[4])<<8 | uint64(u[3])<<16 | uint64(u[2])<<24 | uint64(u[1])<<32 | uint64(u[0])<<40
+	return time.UnixMilli(int64(ms))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *ULID) UnmarshalText(text []byte) error {
+	parsed, err := ParseULID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// mustReadRandom fills the slice with cryptographically secure random bytes. It panics
+// if the system's secure random number generator fails, since that should never happen.
+func mustReadRandom(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(stackerr.Wrap(err))
+	}
+}
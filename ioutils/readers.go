@@ -0,0 +1,238 @@
+package ioutils
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// ErrReadLimitExceeded is returned when a LimitedReadCloser has more data than its limit.
+var ErrReadLimitExceeded = errors.New("read limit exceeded")
+
+// ErrRewindBufferExceeded is returned when rewinding a body that has been read
+// past the size of its rewind buffer.
+var ErrRewindBufferExceeded = errors.New("cannot rewind, the rewind buffer size was exceeded")
+
+type limitedReadCloser struct {
+	rc        io.ReadCloser
+	remaining int64
+}
+
+// LimitedReadCloser wraps a ReadCloser so that reading more than `limit` bytes from
+// it returns ErrReadLimitExceeded. Unlike io.LimitReader, which silently truncates,
+// this makes it an error for the source to be larger than expected.
+func LimitedReadCloser(rc io.ReadCloser, limit int64) io.ReadCloser {
+	return &limitedReadCloser{
+		rc:        rc,
+		remaining: limit,
+	}
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Check whether there's any more data, since it's only
+		// an error if the source is actually over the limit.
+		var b [1]byte
+		n, err := l.rc.Read(b[:])
+		if n > 0 {
+			return 0, stackerr.Wrap(ErrReadLimitExceeded)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func (l *limitedReadCloser) Close() error {
+	return l.rc.Close()
+}
+
+// The window that the rates of counting readers and writers are calculated over
+const countingRateWindow = 10 * time.Second
+
+// newCountingRateTracker creates the rate tracker for a counting reader or writer.
+func newCountingRateTracker() numbers.RateTracker {
+	// This can't fail, since the window is valid
+	rt, _ := numbers.NewRateTracker(numbers.NewRateTrackerInput{
+		Window:  countingRateWindow,
+		Buckets: 10,
+	})
+	return rt
+}
+
+// CountingReader is a reader that counts the bytes read through it. It is
+// safe to call Count and Rate while another goroutine is reading.
+type CountingReader interface {
+	io.Reader
+	// Count returns the total number of bytes read.
+	Count() int64
+	// Rate returns the bytes per second read over the last 10 seconds.
+	Rate() float64
+}
+
+type countingReader struct {
+	r     io.Reader
+	count atomic.Int64
+	rate  numbers.RateTracker
+}
+
+// NewCountingReader wraps a reader to count the bytes read through it.
+func NewCountingReader(r io.Reader) CountingReader {
+	return &countingReader{
+		r:    r,
+		rate: newCountingRateTracker(),
+	}
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.count.Add(int64(n))
+		c.rate.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *countingReader) Count() int64 {
+	return c.count.Load()
+}
+
+func (c *countingReader) Rate() float64 {
+	return c.rate.Rate()
+}
+
+// CountingWriter is a writer that counts the bytes written through it. It is
+// safe to call Count and Rate while another goroutine is writing.
+type CountingWriter interface {
+	io.Writer
+	// Count returns the total number of bytes written.
+	Count() int64
+	// Rate returns the bytes per second written over the last 10 seconds.
+	Rate() float64
+}
+
+type countingWriter struct {
+	w     io.Writer
+	count atomic.Int64
+	rate  numbers.RateTracker
+}
+
+// NewCountingWriter wraps a writer to count the bytes written through it.
+func NewCountingWriter(w io.Writer) CountingWriter {
+	return &countingWriter{
+		w:    w,
+		rate: newCountingRateTracker(),
+	}
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		c.count.Add(int64(n))
+		c.rate.Add(int64(n))
+	}
+	return n, err
+}
+
+func (c *countingWriter) Count() int64 {
+	return c.count.Load()
+}
+
+func (c *countingWriter) Rate() float64 {
+	return c.rate.Rate()
+}
+
+type teeReadCloser struct {
+	io.Reader
+	rc io.ReadCloser
+}
+
+// TeeReadCloser is like io.TeeReader, but for a ReadCloser. Closing it closes
+// the underlying ReadCloser (but not the writer).
+func TeeReadCloser(rc io.ReadCloser, w io.Writer) io.ReadCloser {
+	return &teeReadCloser{
+		Reader: io.TeeReader(rc, w),
+		rc:     rc,
+	}
+}
+
+func (t *teeReadCloser) Close() error {
+	return t.rc.Close()
+}
+
+// Rewindable is a body that can be rewound to the start, as long as it
+// hasn't been read past the size of its rewind buffer.
+type Rewindable interface {
+	io.ReadCloser
+	// Rewind moves back to the start of the body, so that it can be read again. It
+	// returns an error wrapping ErrRewindBufferExceeded if more than the maximum
+	// buffer size has been read.
+	Rewind() stackerr.Error
+}
+
+type rewindable struct {
+	lock      sync.Mutex
+	rc        io.ReadCloser
+	maxBuffer int64
+	buffer    []byte
+	// The position in the buffer that the next read starts at
+	pos      int
+	exceeded bool
+}
+
+// RewindableBody wraps a ReadCloser so that it can be rewound and read again (e.g. to retry a
+// request or log part of a response), buffering up to maxBuffer bytes as they're read. Unlike
+// reading the entire body into memory, only the part that has actually been read is buffered,
+// and reading past maxBuffer continues to work (it just can't be rewound any more).
+func RewindableBody(rc io.ReadCloser, maxBuffer int64) Rewindable {
+	return &rewindable{
+		rc:        rc,
+		maxBuffer: maxBuffer,
+	}
+}
+
+func (r *rewindable) Read(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// Serve from the buffer first, if it has been rewound
+	if r.pos < len(r.buffer) {
+		n := copy(p, r.buffer[r.pos:])
+		r.pos += n
+		return n, nil
+	}
+	n, err := r.rc.Read(p)
+	if n > 0 && !r.exceeded {
+		if int64(len(r.buffer)+n) > r.maxBuffer {
+			r.exceeded = true
+			r.buffer = nil
+			r.pos = 0
+		} else {
+			r.buffer = append(r.buffer, p[:n]...)
+			r.pos = len(r.buffer)
+		}
+	}
+	return n, err
+}
+
+func (r *rewindable) Rewind() stackerr.Error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.exceeded {
+		return stackerr.Wrap(ErrRewindBufferExceeded).WithSingle("max_buffer", r.maxBuffer)
+	}
+	r.pos = 0
+	return nil
+}
+
+func (r *rewindable) Close() error {
+	return r.rc.Close()
+}
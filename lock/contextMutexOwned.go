@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// MutexHolder is information about what holds a CtxMutexOwned.
+type MutexHolder struct {
+	// The label that the mutex was locked with, or the file and
+	// line that locked it if it was locked without a label
+	Label string
+	// When the mutex was locked
+	Acquired time.Time
+}
+
+// CtxMutexOwned is a CtxMutex that records what holds it, which
+// is useful for debugging deadlocks in long-running services.
+type CtxMutexOwned interface {
+	CtxMutex
+
+	// LockWithLabel is the same as Lock, except that it records the
+	// given label as the holder of the mutex.
+	LockWithLabel(ctx context.Context, label string) (err stackerr.Error)
+
+	// TryLockWithLabel is the same as TryLock, except that it records
+	// the given label as the holder of the mutex.
+	TryLockWithLabel(label string) (locked bool)
+
+	// Holder will return what currently holds the mutex. The boolean
+	// is false if the mutex is not currently locked.
+	Holder() (holder MutexHolder, locked bool)
+}
+
+type ctxMutexOwned struct {
+	ctxMutex
+	// Protects the holder, so that it's always consistent with
+	// the state of the mutex
+	holderLock sync.Mutex
+	holder     *MutexHolder
+}
+
+// NewCtxMutexOwned creates a new CtxMutexOwned
+func NewCtxMutexOwned() CtxMutexOwned {
+	return &ctxMutexOwned{
+		ctxMutex: ctxMutex{
+			ch: make(chan struct{}, 1),
+		},
+	}
+}
+
+// callerLabel gets the file and line of the caller of the function
+// that called callerLabel, to use as the label of a holder.
+func callerLabel() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func (mu *ctxMutexOwned) setHolder(label string) {
+	mu.holderLock.Lock()
+	defer mu.holderLock.Unlock()
+	mu.holder = &MutexHolder{
+		Label:    label,
+		Acquired: time.Now(),
+	}
+}
+
+func (mu *ctxMutexOwned) Lock(ctx context.Context) (err stackerr.Error) {
+	return mu.LockWithLabel(ctx, callerLabel())
+}
+
+func (mu *ctxMutexOwned) LockWithLabel(ctx context.Context, label string) (err stackerr.Error) {
+	if err := mu.ctxMutex.Lock(ctx); err != nil {
+		return err
+	}
+	mu.setHolder(label)
+	return nil
+}
+
+func (mu *ctxMutexOwned) TryLock() (locked bool) {
+	return mu.TryLockWithLabel(callerLabel())
+}
+
+func (mu *ctxMutexOwned) TryLockWithLabel(label string) (locked bool) {
+	if !mu.ctxMutex.TryLock() {
+		return false
+	}
+	mu.setHolder(label)
+	return true
+}
+
+func (mu *ctxMutexOwned) Unlock() {
+	if !mu.TryUnlock() {
+		panic("unlock of unlocked mutex")
+	}
+}
+
+func (mu *ctxMutexOwned) TryUnlock() (unlocked bool) {
+	mu.holderLock.Lock()
+	defer mu.holderLock.Unlock()
+	if !mu.ctxMutex.TryUnlock() {
+		return false
+	}
+	mu.holder = nil
+	return true
+}
+
+func (mu *ctxMutexOwned) Holder() (holder MutexHolder, locked bool) {
+	mu.holderLock.Lock()
+	defer mu.holderLock.Unlock()
+	// The mutex may have been locked, but the holder not set yet
+	if mu.holder == nil {
+		return MutexHolder{}, mu.Locked()
+	}
+	return *mu.holder, true
+}
@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// CtxRWMutex is a reader/writer mutex where Lock and RLock operations
+// use a context that can be cancelled/deadlined to terminate the lock
+// attempt. Writers have priority: once a writer is waiting, new readers
+// wait until it has acquired and released the lock, so a steady stream
+// of readers can't starve writers.
+type CtxRWMutex interface {
+
+	// Lock will wait until either the mutex can be locked for writing, or
+	// the context is done (cancelled/deadlined). If the lock succeeds, it
+	// will return nil. If the context is done, it will return a
+	// stack-wrapped version of the context's error.
+	Lock(ctx context.Context) (err stackerr.Error)
+
+	// TryLock will attempt to lock the mutex for writing, but will not
+	// wait if it cannot immediately do so.
+	TryLock() (locked bool)
+
+	// Unlock will unlock the mutex for writing, and will panic if the
+	// mutex is not currently locked for writing.
+	Unlock()
+
+	// TryUnlock will attempt to unlock the mutex for writing, but will not
+	// panic if the mutex is not currently locked for writing.
+	TryUnlock() (unlocked bool)
+
+	// RLock will wait until either the mutex can be locked for reading, or
+	// the context is done (cancelled/deadlined). If the lock succeeds, it
+	// will return nil. If the context is done, it will return a
+	// stack-wrapped version of the context's error.
+	RLock(ctx context.Context) (err stackerr.Error)
+
+	// TryRLock will attempt to lock the mutex for reading, but will not
+	// wait if it cannot immediately do so.
+	TryRLock() (locked bool)
+
+	// RUnlock will release one read lock of the mutex, and will panic if
+	// the mutex is not currently locked for reading.
+	RUnlock()
+
+	// TryRUnlock will attempt to release one read lock of the mutex, but
+	// will not panic if the mutex is not currently locked for reading.
+	TryRUnlock() (unlocked bool)
+}
+
+type ctxRWMutex struct {
+	lock sync.Mutex
+	// The number of active readers
+	readers int
+	// Whether a writer holds the lock
+	writer bool
+	// The number of writers waiting for the lock
+	waitingWriters int
+	// A channel that is closed (and replaced) whenever the state changes,
+	// so that waiters can check whether they can now acquire the lock
+	changed chan struct{}
+}
+
+// NewCtxRWMutex creates a new CtxRWMutex
+func NewCtxRWMutex() CtxRWMutex {
+	return &ctxRWMutex{
+		changed: make(chan struct{}),
+	}
+}
+
+// broadcast wakes all waiters. It must be called with the lock held.
+func (mu *ctxRWMutex) broadcast() {
+	close(mu.changed)
+	mu.changed = make(chan struct{})
+}
+
+// wait waits until the state changes or the context is done. It must be called with the
+// lock held, and returns with the lock held.
+func (mu *ctxRWMutex) wait(ctx context.Context) (err stackerr.Error) {
+	changed := mu.changed
+	mu.lock.Unlock()
+	select {
+	case <-ctx.Done():
+		err = stackerr.Wrap(ctx.Err())
+	case <-changed:
+	}
+	mu.lock.Lock()
+	return err
+}
+
+func (mu *ctxRWMutex) canLock() bool {
+	return !mu.writer && mu.readers == 0
+}
+
+func (mu *ctxRWMutex) canRLock() bool {
+	return !mu.writer && mu.waitingWriters == 0
+}
+
+func (mu *ctxRWMutex) Lock(ctx context.Context) (err stackerr.Error) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	mu.waitingWriters++
+	for !mu.canLock() {
+		if err := mu.wait(ctx); err != nil {
+			mu.waitingWriters--
+			// Readers that were waiting for this writer may be able to proceed
+			if mu.waitingWriters == 0 {
+				mu.broadcast()
+			}
+			return err
+		}
+	}
+	mu.waitingWriters--
+	mu.writer = true
+	return nil
+}
+
+func (mu *ctxRWMutex) TryLock() (locked bool) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	if !mu.canLock() {
+		return false
+	}
+	mu.writer = true
+	return true
+}
+
+func (mu *ctxRWMutex) Unlock() {
+	if !mu.TryUnlock() {
+		panic("unlock of unlocked mutex")
+	}
+}
+
+func (mu *ctxRWMutex) TryUnlock() (unlocked bool) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	if !mu.writer {
+		return false
+	}
+	mu.writer = false
+	mu.broadcast()
+	return true
+}
+
+func (mu *ctxRWMutex) RLock(ctx context.Context) (err stackerr.Error) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	for !mu.canRLock() {
+		if err := mu.wait(ctx); err != nil {
+			return err
+		}
+	}
+	mu.readers++
+	return nil
+}
+
+func (mu *ctxRWMutex) TryRLock() (locked bool) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	if !mu.canRLock() {
+		return false
+	}
+	mu.readers++
+	return true
+}
+
+func (mu *ctxRWMutex) RUnlock() {
+	if !mu.TryRUnlock() {
+		panic("runlock of unlocked mutex")
+	}
+}
+
+func (mu *ctxRWMutex) TryRUnlock() (unlocked bool) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	if mu.readers == 0 {
+		return false
+	}
+	mu.readers--
+	if mu.readers == 0 {
+		mu.broadcast()
+	}
+	return true
+}
@@ -6,13 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Invicton-Labs/go-common/aws/lambda"
 	"github.com/Invicton-Labs/go-common/collections"
-	"github.com/Invicton-Labs/go-common/conversions"
 	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/debugging"
 	"github.com/Invicton-Labs/go-common/gensync"
 	"github.com/Invicton-Labs/go-common/log"
 	"github.com/Invicton-Labs/go-common/slack/links"
@@ -21,36 +22,89 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
-	"golang.org/x/sync/errgroup"
-)
-
-const (
-	metaColumn     string = "Metadata"
-	acquiredColumn string = "AcquiredUnixNano"
-	logsUrlColumn  string = "LogsUrl"
-	expiresColumn  string = "ExpiresUnixNano"
+	"go.uber.org/multierr"
 )
 
 var (
 	runId          string
 	lockCounterMap gensync.Map[string, *atomic.Int32]
+	// All distributed locks that are currently held by this process
+	heldLocks gensync.Map[*distributedLock, struct{}]
 )
 
 func init() {
 	runId = strings.ToLower(uuid.NewString())
 }
 
+// LockCallbacks are functions that are called at points in the lifecycle of the locks
+// held by a locker, e.g. for emitting metrics or alerts. They're called synchronously, so
+// they should return quickly. Panics in them are logged and otherwise ignored.
+type LockCallbacks struct {
+	// OPTIONAL. Called when a lock is acquired.
+	OnAcquired func(lock LockData)
+	// OPTIONAL. Called after each successful renewal of a lock's expiry.
+	OnHeartbeat func(lock LockData)
+	// OPTIONAL. Called when a lock's heartbeat stops before the lock is released, with the
+	// reason (e.g. the lock was taken by something else, the renewal failed, or the lock's
+	// context was cancelled). The lock's context is cancelled at the same time.
+	OnLost func(lock LockData, err stackerr.Error)
+	// OPTIONAL. Called when a lock is released with Unlock.
+	OnReleased func(lock LockData)
+}
+
+// runCallback runs a lifecycle callback, logging any panic instead of
+// propagating it, since a broken callback shouldn't break the lock.
+func runCallback(name string, lock LockData, callback func()) {
+	defer debugging.RecoverWithInput(debugging.RecoverInput{
+		Context: log.LogContext(context.Background(), log.With(
+			"lock_key", lock.Key(),
+			"lock_callback", name,
+		)),
+	})
+	callback()
+}
+
 type DistributedLockerConfig struct {
 	TableArn      string `json:"arn"`
 	KeyColumn     string `json:"key_column"`
 	VersionColumn string `json:"version_column"`
+	// OPTIONAL. The name of the table's TTL attribute. If provided, each lock's expiry (plus
+	// the TTL delay) is also written to it in epoch seconds, so that DynamoDB deletes stale
+	// lock rows. Deleting a row also deletes its fencing token (see PurgeExpiredLocks). Rows
+	// that were written before this was set can be backfilled with MigrateTTL.
+	TtlColumn string `json:"ttl_column"`
+	// OPTIONAL. How long after a lock expires that its row may be deleted by the TTL.
+	// Defaults to 24 hours.
+	TtlDelay time.Duration `json:"ttl_delay"`
 	// OPTIONAL. An AWS config to use. If not provided,
 	// the default config will be used.
 	AwsConfig *aws.Config
+	// OPTIONAL. The clock to use for lock timestamps and the
+	// heartbeat. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+	// OPTIONAL. How long a lock is held for after it's acquired and after each
+	// heartbeat. This is how long other processes must wait for the lock if the
+	// process holding it dies without unlocking it. Defaults to 20 seconds.
+	LockDuration time.Duration
+	// OPTIONAL. How often the lock's expiry is renewed. Longer intervals use fewer
+	// DynamoDB writes for long-running jobs. It must be less than the lock duration
+	// (including jitter), leaving enough time for the renewal to complete. Defaults
+	// to half of the lock duration.
+	HeartbeatInterval time.Duration
+	// OPTIONAL. The fraction (in the range [0, 1)) that each heartbeat interval is
+	// randomly adjusted by, so that many locks acquired at once don't renew at the
+	// same time. Defaults to 0.
+	HeartbeatJitter float64
+	// OPTIONAL. Whether Lock and LockWait can re-acquire a lock that's already held by the
+	// same process (the same Lambda request, or the same run outside of Lambda). See
+	// DistributedLocker.Lock for details. Semaphores ignore it. Defaults to false.
+	Reentrant bool `json:"reentrant"`
+	// OPTIONAL. Callbacks for lock lifecycle events.
+	LockCallbacks `json:"-"`
+	// OPTIONAL. Statistics collection for the locker's locks.
+	LockStatsConfig
 }
 
 type LockData interface {
@@ -71,6 +125,14 @@ type LockData interface {
 	Metadata() map[string]json.RawMessage
 	// Active checks whether the lock is currently active (held by something).
 	Active() bool
+	// FencingToken gets the lock's fencing token, which increases every time the lock
+	// is acquired. Downstream systems can reject writes with a lower token than the
+	// highest they've seen (see ValidateFencingToken), which prevents a holder that
+	// has lost the lock (e.g. due to a heartbeat failure) from making changes.
+	FencingToken() int64
+	// HoldCount gets the number of times that the lock is currently held by its holder,
+	// which is more than 1 if it has been re-acquired by a reentrant locker.
+	HoldCount() int64
 }
 
 type lockData struct {
@@ -81,6 +143,8 @@ type lockData struct {
 	logsUrl  string
 	metadata map[string]json.RawMessage
 	active   bool
+	fencing  int64
+	holds    int64
 }
 
 func (ld lockData) Key() string {
@@ -105,6 +169,31 @@ func (ld lockData) Metadata() map[string]json.RawMessage {
 func (ld lockData) Active() bool {
 	return ld.active
 }
+func (ld lockData) FencingToken() int64 {
+	return ld.fencing
+}
+func (ld lockData) HoldCount() int64 {
+	return ld.holds
+}
+
+// ErrStaleFencingToken is returned when a fencing token is lower than the highest one seen.
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+// ValidateFencingToken checks a fencing token from a lock holder against the highest token
+// that a downstream system has seen for the same lock, returning an error wrapping
+// ErrStaleFencingToken if it's lower (meaning that the lock has since been acquired by
+// something else). The downstream system should store the token with each accepted
+// write, and ideally perform the check atomically with the write (e.g. as a DynamoDB
+// condition expression).
+func ValidateFencingToken(token int64, highestSeen int64) stackerr.Error {
+	if token < highestSeen {
+		return stackerr.Wrap(ErrStaleFencingToken).With(map[string]any{
+			"fencing_token":              token,
+			"highest_seen_fencing_token": highestSeen,
+		})
+	}
+	return nil
+}
 
 // DistributedLock is a lock that can be used across multiple processes, computers, etc.
 // It requires internet connectivity and an AWS DynamoDB table to use.
@@ -129,58 +218,123 @@ type DistributedLock interface {
 
 type distributedLock struct {
 	distributedLocker *distributedLocker
+	unlockCtx         context.Context
 	unlockCtxCancel   context.CancelFunc
 	locked            atomic.Bool
-	heartbeatErrGroup errgroup.Group
+	heartbeatErrGroup gensync.ErrGroup
+	// The ID of the process that holds the lock
+	lockerId string
+	// The context that was returned when the lock was acquired
+	passthroughCtx context.Context
+
+	// This serializes re-acquisitions and unlocks of the lock's handles (for
+	// reentrant lockers), and released is set once the lock has been released
+	holdLock sync.Mutex
+	released bool
+	// For reentrant lockers, whether this handle (the one that first
+	// acquired the lock) has been unlocked
+	unlocked atomic.Bool
 
 	// Include the lock data
 	lockData
 }
 
 func (dl *distributedLock) Unlock(ctx context.Context) stackerr.Error {
+	if !dl.distributedLocker.reentrant {
+		dl.holdLock.Lock()
+		defer dl.holdLock.Unlock()
+		dl.released = true
+		return dl.release(ctx)
+	}
+	if !dl.unlocked.CompareAndSwap(false, true) {
+		return stackerr.Errorf("could not unlock distributed lock '%s', as this handle has already been unlocked", dl.key)
+	}
+	return dl.releaseHold(ctx)
+}
+
+// releaseHold releases one hold of a lock from a reentrant locker, and
+// releases the lock itself once it has no holds left.
+func (dl *distributedLock) releaseHold(ctx context.Context) stackerr.Error {
+	dl.holdLock.Lock()
+	defer dl.holdLock.Unlock()
+	if dl.released {
+		return stackerr.Errorf("could not unlock distributed lock '%s', as it has already been released", dl.key)
+	}
+	holds, held, err := dl.distributedLocker.backend.addHolds(ctx, dl.key, dl.version, -1)
+	if err != nil {
+		return err
+	}
+	if !held {
+		return stackerr.Errorf("could not unlock distributed lock '%s', as it is not currently locked by this process", dl.key)
+	}
+	if holds > 0 {
+		log.Debugw("Distributed lock hold released", "lock_key", dl.key, "lock_version", dl.version, "lock_holds", holds)
+		return nil
+	}
+	dl.released = true
+	return dl.release(ctx)
+}
+
+// releaseAll releases the lock regardless of how many holds it has, unless
+// it has already been released.
+func (dl *distributedLock) releaseAll(ctx context.Context) stackerr.Error {
+	dl.holdLock.Lock()
+	defer dl.holdLock.Unlock()
+	if dl.released {
+		return nil
+	}
+	dl.released = true
+	return dl.release(ctx)
+}
+
+// release releases the lock, regardless of how many holds it has.
+func (dl *distributedLock) release(ctx context.Context) stackerr.Error {
+	// It's no longer considered to be held by this process, even if
+	// the unlock fails (it will expire since the heartbeat is stopped).
+	heldLocks.Delete(dl)
+
 	// Cancel the context for the heartbeat
 	dl.unlockCtxCancel()
 
 	// Wait for the heartbeat to finish (it should exit now that the
 	// context has been cancelled).
-	heartbeatErr := stackerr.Wrap(dl.heartbeatErrGroup.Wait())
-
-	if _, err := dl.distributedLocker.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: &dl.distributedLocker.tableName,
-		Key: map[string]types.AttributeValue{
-			dl.distributedLocker.config.KeyColumn: &types.AttributeValueMemberS{
-				Value: dl.key,
-			},
-		},
-		// Update the expires time
-		UpdateExpression: conversions.GetPtr("SET #expires_column = :expires_unix_nano"),
-		// Only update it if we still hold the lock
-		ConditionExpression: conversions.GetPtr("#version_column = :version"),
-		ExpressionAttributeNames: map[string]string{
-			"#expires_column": expiresColumn,
-			"#version_column": dl.distributedLocker.config.VersionColumn,
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":expires_unix_nano": &types.AttributeValueMemberN{
-				Value: fmt.Sprintf("%d", time.Now().UnixNano()),
-			},
-			":version": &types.AttributeValueMemberS{
-				Value: dl.version,
-			},
-		},
-		ReturnConsumedCapacity: types.ReturnConsumedCapacityNone,
-		ReturnValues:           types.ReturnValueNone,
-	}); err != nil {
-		var ccfe *types.ConditionalCheckFailedException
-		if errors.As(err, &ccfe) {
-			return stackerr.Errorf("could not unlock distributed lock '%s', as it is not currently locked by this process", dl.key)
-		}
-		return stackerr.Wrap(err)
+	heartbeatErr := dl.heartbeatErrGroup.Wait()
+
+	held, err := dl.distributedLocker.backend.setExpiry(ctx, dl.key, dl.version, dl.distributedLocker.clock.Now())
+	if err != nil {
+		return err
+	}
+	if !held {
+		return stackerr.Errorf("could not unlock distributed lock '%s', as it is not currently locked by this process", dl.key)
+	}
+	dl.distributedLocker.stats.ended(dl.key, dl.distributedLocker.clock.Since(dl.acquired), false)
+
+	if onReleased := dl.distributedLocker.callbacks.OnReleased; onReleased != nil {
+		runCallback("OnReleased", dl.lockData, func() {
+			onReleased(dl.lockData)
+		})
 	}
 
 	return heartbeatErr
 }
 
+// reentrantLock is a handle for a lock that was re-acquired by a reentrant
+// locker while it was already held by the same process.
+type reentrantLock struct {
+	lock     *distributedLock
+	unlocked atomic.Bool
+
+	// Include the lock data
+	lockData
+}
+
+func (rl *reentrantLock) Unlock(ctx context.Context) stackerr.Error {
+	if !rl.unlocked.CompareAndSwap(false, true) {
+		return stackerr.Errorf("could not unlock distributed lock '%s', as this handle has already been unlocked", rl.key)
+	}
+	return rl.lock.releaseHold(ctx)
+}
+
 type DistributedLocker interface {
 	/*
 		Lock will attempt to acquire a distributed lock for the given key.
@@ -206,9 +360,31 @@ type DistributedLocker interface {
 		this will be nil.
 
 		err - an error generated by this function
+
+		If the locker is reentrant (see DistributedLockerConfig.Reentrant) and the lock is
+		already held by this process, the lock's hold count is incremented and a new handle
+		for the same lock is returned, along with the context that was returned when the
+		lock was first acquired. The metadata is ignored in that case. Each handle must be
+		unlocked, and the lock is only released once all of them have been.
 	*/
 	Lock(ctx context.Context, key string, metadata map[string]any) (newCtx context.Context, newLock DistributedLock, existingLock LockData, err stackerr.Error)
 
+	// LockWait is the same as Lock, except that if the lock is already held, it waits (polling
+	// with a backoff) until the lock can be acquired instead of returning the existing lock. It
+	// returns the context's error if the context is done first, or an error wrapping
+	// dateutils.ErrBackoffExhausted if the backoff's attempt or elapsed time limit is reached.
+	LockWait(ctx context.Context, key string, metadata map[string]any, input LockWaitInput) (newCtx context.Context, newLock DistributedLock, err stackerr.Error)
+
+	// LockMany acquires the locks for all of the given keys, or none of them. The locks are
+	// acquired in sorted key order (ignoring duplicate keys), so that processes that lock
+	// overlapping sets of keys can't deadlock each other. If any lock is already held (or
+	// fails to be acquired), the locks that were already acquired are released, and the
+	// existing lock (if there is one) is returned.
+	//
+	// The returned locks are in sorted key order, and must each be unlocked. The returned
+	// context is cancelled if any of their heartbeats fail.
+	LockMany(ctx context.Context, keys []string, metadata map[string]any) (newCtx context.Context, newLocks []DistributedLock, existingLock LockData, err stackerr.Error)
+
 	// GetAllLocks will get a map of all locks that are stored in the lock table, regardless of whether they're active
 	GetAllLocks(ctx context.Context) (map[string]LockData, stackerr.Error)
 
@@ -217,158 +393,167 @@ type DistributedLocker interface {
 
 	// GetExpiredLocks will get a map of all expired locks
 	GetExpiredLocks(ctx context.Context) (map[string]LockData, stackerr.Error)
-}
 
-type distributedLocker struct {
-	client    *dynamodb.Client
-	config    DistributedLockerConfig
-	tableName string
+	// ForceUnlock releases the lock for the key, regardless of what holds it. It's intended
+	// for operators to break stuck locks. The holder (if it's still running) will find that
+	// the lock has been lost on its next heartbeat. It returns the lock as it was before it
+	// was released, or nil if there's no lock for the key.
+	ForceUnlock(ctx context.Context, key string) (LockData, stackerr.Error)
+
+	// ExtendLock sets the expiry of the lock for the key to the given duration from now, if
+	// the lock still has the given version. It returns an error if it doesn't. This is only
+	// useful for locks whose holder has stopped heartbeating (e.g. to keep a crashed job's
+	// lock held while it's investigated), since the next heartbeat overwrites the expiry.
+	ExtendLock(ctx context.Context, key string, version string, duration time.Duration) stackerr.Error
+
+	// PurgeExpiredLocks deletes all locks that have expired, and returns the number that were
+	// deleted. Deleting a lock also deletes its fencing token, so the next holder's tokens
+	// start again from 1; don't purge locks whose fencing tokens are in use downstream.
+	PurgeExpiredLocks(ctx context.Context) (int, stackerr.Error)
+
+	// Stats gets the statistics for each lock key that this locker has used, if statistics
+	// are enabled (see LockStatsConfig). Otherwise, it returns an empty map.
+	Stats() map[string]LockKeyStats
+
+	// WatchLock watches the lock for the key by polling it, and sends an event on the returned
+	// channel whenever it's acquired, renewed, released, or expires, so that processes waiting
+	// for it can react promptly. The first event is always the lock's initial state. Errors
+	// while polling are logged, and polling continues. The channel is closed once the context
+	// is done, and the context must be done eventually to stop polling.
+	WatchLock(ctx context.Context, key string, input WatchLockInput) (<-chan LockEvent, stackerr.Error)
 }
 
-func (dl *distributedLocker) parseLockData(item map[string]types.AttributeValue) (LockData, stackerr.Error) {
-	var key, version, logsUrl string
-	var acquiredUnixNano, expiresUnixNano int64
-	metadata := map[string]json.RawMessage{}
-
-	// Extract the key column value
-	keyValue, ok := item[dl.config.KeyColumn]
-	if !ok {
-		return nil, stackerr.Errorf("No key field in existing lock row")
-	}
-	// Try to unmarshal it
-	if err := attributevalue.Unmarshal(keyValue, &key); err != nil {
-		return nil, stackerr.Errorf("Version field in existing lock row is not of expected type")
-	}
+type LockWaitInput struct {
+	// OPTIONAL. The backoff to use between lock attempts. If not provided, it starts at
+	// 250ms and increases up to 5s, with full jitter and no limits. The attempts and elapsed
+	// time limits of the backoff can be used to stop waiting. If the existing lock will
+	// expire before the next backoff wait is over, it waits until the expiry instead.
+	Backoff *dateutils.NewBackoffInput
+}
 
-	// Extract the version column value
-	versionValue, ok := item[dl.config.VersionColumn]
-	if !ok {
-		return nil, stackerr.Errorf("No version field in existing lock row")
-	}
-	// Try to unmarshal it
-	if err := attributevalue.Unmarshal(versionValue, &version); err != nil {
-		return nil, stackerr.Errorf("Version field in existing lock row is not of expected type")
-	}
+// lockRow is the data that's stored for a lock when it's acquired.
+type lockRow struct {
+	key      string
+	version  string
+	acquired time.Time
+	expires  time.Time
+	logsUrl  string
+	// The metadata, in JSON format
+	metadata string
+}
 
-	// Extract the acquired column value
-	acquiredValue, ok := item[acquiredColumn]
-	if !ok {
-		return nil, stackerr.Errorf("No acquired field in existing lock row")
-	}
-	// Try to unmarshal it
-	if err := attributevalue.Unmarshal(acquiredValue, &acquiredUnixNano); err != nil {
-		return nil, stackerr.Errorf("Acquired field in existing lock row is not of expected type")
-	}
+// lockBackend is the storage that a distributed locker keeps its locks in. All
+// conditional operations must be atomic in the storage.
+type lockBackend interface {
+	// acquire stores the lock if no lock exists for the key or the existing lock has
+	// expired, incrementing the key's fencing token and returning the new value. If
+	// there's an active lock for the key, it returns that lock instead.
+	acquire(ctx context.Context, row lockRow) (fencing int64, existingLock LockData, err stackerr.Error)
+	// get gets the lock for the key.
+	get(ctx context.Context, key string) (LockData, stackerr.Error)
+	// getMany gets the locks for the keys, omitting keys that don't have one.
+	getMany(ctx context.Context, keys []string) ([]LockData, stackerr.Error)
+	// setExpiry sets the expiry of the lock for the key, if the lock still has the given
+	// version. The boolean is false if it doesn't (i.e. the lock was lost).
+	setExpiry(ctx context.Context, key string, version string, expires time.Time) (bool, stackerr.Error)
+	// list gets all locks of the given type.
+	list(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error)
+	// addHolds adds the delta to the hold count of the lock for the key, if the lock still
+	// has the given version and (for a positive delta) hasn't expired, and returns the new
+	// hold count. The boolean is false if it doesn't (i.e. the lock was lost).
+	addHolds(ctx context.Context, key string, version string, delta int64) (holds int64, held bool, err stackerr.Error)
+	// replace sets the version and expiry of the lock for the key, regardless of its current
+	// version, and returns the lock as it was before. It returns nil if there's no lock.
+	replace(ctx context.Context, key string, version string, expires time.Time) (LockData, stackerr.Error)
+	// deleteIfExpired deletes the lock for the key if it has expired by the given time. The
+	// boolean is false if it wasn't deleted.
+	deleteIfExpired(ctx context.Context, key string, now time.Time) (bool, stackerr.Error)
+}
 
-	// Extract the expires column value
-	expiresValue, ok := item[expiresColumn]
-	if !ok {
-		return nil, stackerr.Errorf("No expires field in existing lock row")
-	}
-	// Try to unmarshal it
-	if err := attributevalue.Unmarshal(expiresValue, &expiresUnixNano); err != nil {
-		return nil, stackerr.Errorf("Expires field in existing lock row is not of expected type")
-	}
+type distributedLocker struct {
+	backend           lockBackend
+	clock             dateutils.Clock
+	lockDuration      time.Duration
+	heartbeatInterval time.Duration
+	heartbeatJitter   float64
+	reentrant         bool
+	callbacks         LockCallbacks
+	stats             *lockStats
+}
 
-	// Check if there's a logs URL
-	if logsUrlValue, ok := item[logsUrlColumn]; ok {
-		// If there is, try to unmarshal it
-		if err := attributevalue.Unmarshal(logsUrlValue, &logsUrl); err != nil {
-			// Log the error, but don't exit out since it doesn't prevent us from continuing
-			// (it just makes the log alerts a bit less useful)
-			log.Errorf("Logs URL field in existing lock row is not of expected type")
+func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[string]any) (newCtx context.Context, newLock DistributedLock, existingLock LockData, err stackerr.Error) {
+	if dl.reentrant {
+		newCtx, newLock, err := dl.reacquire(ctx, key)
+		if err != nil || newLock != nil {
+			return newCtx, newLock, nil, err
 		}
 	}
+	return dl.acquire(ctx, key, metadata, true)
+}
 
-	// Check if there's metadata
-	if metadataValue, ok := item[metaColumn]; ok {
-		var rawMetadata string
-		// If there is, try to unmarshal it
-		if err := attributevalue.Unmarshal(metadataValue, &rawMetadata); err != nil {
-			log.Errorf("Metadata field in existing lock row is not of expected type")
-		} else {
-			if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
-				log.With(
-					"json", rawMetadata,
-				).Errorf("Metadata field in existing lock row is not in valid JSON format")
-			}
-		}
+// getLockerId gets the ID of the process, which is the AWS request ID in Lambda.
+func getLockerId(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
 	}
-
-	acquired := dateutils.TimeFromUnix(acquiredUnixNano)
-	expires := dateutils.TimeFromUnix(expiresUnixNano)
-
-	return lockData{
-		key:      key,
-		version:  version,
-		acquired: acquired,
-		expires:  expires,
-		logsUrl:  logsUrl,
-		metadata: metadata,
-		active:   expires.After(time.Now()),
-	}, nil
+	return "local/" + runId
 }
 
-func (dl *distributedLocker) getExistingLock(ctx context.Context, key string) (LockData, stackerr.Error) {
-	keyAttribute, err := attributevalue.Marshal(key)
-	if err != nil {
-		return nil, stackerr.Wrap(err)
+// reacquire adds a hold to a lock that's already held by this process. It returns
+// a nil lock if this process doesn't hold the lock.
+func (dl *distributedLocker) reacquire(ctx context.Context, key string) (newCtx context.Context, newLock DistributedLock, err stackerr.Error) {
+	id := getLockerId(ctx)
+	var held *distributedLock
+	heldLocks.Range(func(lock *distributedLock, _ struct{}) bool {
+		if lock.distributedLocker == dl && lock.key == key && lock.lockerId == id {
+			held = lock
+			return false
+		}
+		return true
+	})
+	if held == nil {
+		return ctx, nil, nil
 	}
 
-	// Get the existing lock
-	existing, err := dl.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: &dl.tableName,
-		Key: map[string]types.AttributeValue{
-			dl.config.KeyColumn: keyAttribute,
-		},
-		// Ensure the lock read is consistent
-		ConsistentRead: conversions.GetPtr(true),
-	})
+	held.holdLock.Lock()
+	defer held.holdLock.Unlock()
+	if held.released {
+		return ctx, nil, nil
+	}
+	holds, ok, err := dl.backend.addHolds(ctx, key, held.version, 1)
 	if err != nil {
-		return nil, stackerr.Wrap(err)
+		return ctx, nil, err
 	}
-
-	existingLockData, serr := dl.parseLockData(existing.Item)
-	if serr != nil {
-		return nil, serr.WithSingle("key", key)
+	if !ok {
+		// The lock has been lost, so try to acquire it as normal
+		return ctx, nil, nil
 	}
-
-	return existingLockData, nil
+	log.Infow("Distributed lock re-acquired",
+		"lock_key", key,
+		"lock_version", held.version,
+		"lock_holds", holds,
+	)
+	lockData := held.lockData
+	lockData.holds = holds
+	return held.passthroughCtx, &reentrantLock{
+		lock:     held,
+		lockData: lockData,
+	}, nil
 }
 
-func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[string]any) (newCtx context.Context, newLock DistributedLock, existingLock LockData, err stackerr.Error) {
+// acquire attempts to acquire a lock. If logFailure is false, failing to acquire the
+// lock because it's already held isn't logged (e.g. when trying many semaphore slots).
+func (dl *distributedLocker) acquire(ctx context.Context, key string, metadata map[string]any, logFailure bool) (newCtx context.Context, newLock DistributedLock, existingLock LockData, err stackerr.Error) {
 
 	// How long we hold the lock for on each heartbeat
-	lockDuration := 20 * time.Second
-	// Heartbeats occur at half of the lock duration. This ensures we always
-	// keep it locked.
-	heartbeatInterval := lockDuration / 2
-
-	// The initial expiry time is now plus the lock duration
-	initialExpiry := time.Now().Add(lockDuration)
+	lockDuration := dl.lockDuration
+	heartbeatInterval := dl.heartbeatInterval
 
-	acquiredUnixNano := time.Now().UnixNano()
-
-	// The lock row values to insert
-	attributes := map[string]types.AttributeValue{
-		// Put the key name in the key column
-		dl.config.KeyColumn: &types.AttributeValueMemberS{
-			Value: key,
-		},
-		// Set the timestamp for when the lock was most recently acquired
-		acquiredColumn: &types.AttributeValueMemberN{
-			Value: fmt.Sprintf("%d", acquiredUnixNano),
-		},
-		// Set the timestamp for when the lock should expire
-		expiresColumn: &types.AttributeValueMemberN{
-			Value: fmt.Sprintf("%d", initialExpiry.UnixNano()),
-		},
-	}
+	acquired := dl.clock.Now()
 
-	var lockerId, logsUrl string
-	// Use the AWS request ID if available
-	if lc, ok := lambdacontext.FromContext(ctx); ok {
-		lockerId = lc.AwsRequestID
+	lockerId := getLockerId(ctx)
+	var logsUrl string
+	if _, ok := lambdacontext.FromContext(ctx); ok {
 		// Try to generate the URL for accessing the log stream. This
 		// is useful for external alerts (e.g. Slack notifications of errors)
 		// so the logs can be quickly and easily accessed.
@@ -376,11 +561,6 @@ func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[
 		if err != nil {
 			return nil, nil, nil, err
 		}
-		attributes[logsUrlColumn] = &types.AttributeValueMemberS{
-			Value: logsUrl,
-		}
-	} else {
-		lockerId = "local/" + runId
 	}
 
 	lockCounter, _ := lockCounterMap.LoadOrStore(key, &atomic.Int32{})
@@ -394,11 +574,6 @@ func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[
 		"lock_version", version,
 	)
 
-	// Set the version in the version column
-	attributes[dl.config.VersionColumn] = &types.AttributeValueMemberS{
-		Value: version,
-	}
-
 	// If no metadata was provided, create an empty map for it, for consistency
 	if metadata == nil {
 		metadata = map[string]any{}
@@ -409,55 +584,36 @@ func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[
 	if cerr != nil {
 		return nil, nil, nil, stackerr.Wrap(cerr)
 	}
-	// Set the metadata JSON into the metadata column
-	attributes[metaColumn] = &types.AttributeValueMemberS{
-		Value: string(meta),
-	}
 
-	// Put an item for the lock, where either the row does not exist,
-	// or the lock has expired.
-	if _, err := dl.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           &dl.tableName,
-		Item:                attributes,
-		ConditionExpression: conversions.GetPtr("attribute_not_exists(#key_column) OR #expires_column <= :current_time_nano"),
-		ExpressionAttributeNames: map[string]string{
-			"#key_column":     dl.config.KeyColumn,
-			"#expires_column": expiresColumn,
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":current_time_nano": &types.AttributeValueMemberN{
-				Value: fmt.Sprintf("%d", time.Now().UnixNano()),
-			},
-		},
-		ReturnValues: types.ReturnValueNone,
-	}); err != nil {
-		// There was an error updating it. Check if it was a conditional check failure.
-		// If it was, that means that there's already a lock that isn't expired.
-		var ccfe *types.ConditionalCheckFailedException
-		if errors.As(err, &ccfe) {
-
-			// Try to get the existing lock
-			existingLock, err := dl.getExistingLock(ctx, key)
-			if err != nil {
-				return ctx, nil, nil, err
-			}
-
-			// Log that we failed to acquire the lock
+	// Store the lock, if it isn't already held
+	fencing, existingLock, err := dl.backend.acquire(ctx, lockRow{
+		key:      key,
+		version:  version,
+		acquired: acquired,
+		// The initial expiry time is now plus the lock duration
+		expires:  acquired.Add(lockDuration),
+		logsUrl:  logsUrl,
+		metadata: string(meta),
+	})
+	dl.stats.attempted(key, err == nil && existingLock == nil, err)
+	if err != nil {
+		return ctx, nil, nil, err
+	}
+	if existingLock != nil {
+		// Log that we failed to acquire the lock
+		if logFailure {
 			log.Infow("Distributed lock acquisition failed, lock already held",
 				"existing_lock_version", existingLock.Version(),
 				"existing_lock_acquired", existingLock.Acquired(),
 				"existing_lock_active", existingLock.Active(),
 				"existing_lock_logs", existingLock.LogsUrl(),
 			)
-			return ctx, nil, existingLock, nil
 		}
-
-		// It was an error other than an existing lock, so return that error
-		return ctx, nil, nil, stackerr.Wrap(err)
+		return ctx, nil, existingLock, nil
 	}
 
 	// Log that we succeeded in acquiring the lock
-	log.Infow("Distributed lock acquired")
+	log.Infow("Distributed lock acquired", "lock_fencing_token", fencing)
 
 	// Convert the metadata input to match the output type when getting an existing lock
 	metadataJson, err := collections.TransformMapWithErr(metadata, func(key string, value any) (transformedKey string, transformedValue json.RawMessage, err stackerr.Error) {
@@ -481,128 +637,293 @@ func (dl *distributedLocker) Lock(ctx context.Context, key string, metadata map[
 
 	lock := distributedLock{
 		distributedLocker: dl,
+		unlockCtx:         unlockCtx,
 		unlockCtxCancel:   unlockCtxCancel,
 		locked:            atomic.Bool{},
-		heartbeatErrGroup: errgroup.Group{},
+		heartbeatErrGroup: gensync.NewErrGroup(gensync.NewErrGroupInput{}),
+		lockerId:          lockerId,
+		passthroughCtx:    passthroughCtx,
 		lockData: lockData{
 			key:      key,
 			version:  version,
-			acquired: dateutils.TimeFromUnix(acquiredUnixNano),
+			acquired: acquired,
 			logsUrl:  logsUrl,
 			metadata: metadataJson,
 			active:   true,
+			fencing:  fencing,
+			holds:    1,
 		},
 	}
 	lock.locked.Store(true)
+	heldLocks.Store(&lock, struct{}{})
 
 	// Start the heartbeat routine
-	lock.heartbeatErrGroup.Go(func() (err error) {
+	lock.heartbeatErrGroup.Go(func() (err stackerr.Error) {
+		returned := false
 		defer func() {
-			// Recover any panics and convert them to stack errors
-			if r := recover(); r != nil {
-				err = stackerr.FromRecover(r)
-			}
-
-			// If the heartbeat exited with an error, cancel
-			// the passthrough context so that downstream
-			// processes know that we lost the lock.
-			if err != nil {
+			// If the heartbeat exited with an error (or panicked, which the
+			// errgroup converts to an error), cancel the passthrough context
+			// so that downstream processes know that we lost the lock.
+			if err != nil || !returned {
+				heldLocks.Delete(&lock)
 				passthroughCtxCancel()
+				dl.stats.ended(key, dl.clock.Since(acquired), true)
+				if onLost := dl.callbacks.OnLost; onLost != nil {
+					lostErr := err
+					if lostErr == nil {
+						lostErr = stackerr.Errorf("distributed lock heartbeat panicked")
+					}
+					runCallback("OnLost", lock.lockData, func() {
+						onLost(lock.lockData, lostErr)
+					})
+				}
 			}
-
-			// Ensure the returned error is a stack error
-			err = stackerr.WrapWithoutExtraStack(err)
 		}()
+		err = dl.heartbeat(ctx, unlockCtx, &lock, key, version, lockDuration, heartbeatInterval)
+		returned = true
+		return err
+	})
+
+	if onAcquired := dl.callbacks.OnAcquired; onAcquired != nil {
+		runCallback("OnAcquired", lock.lockData, func() {
+			onAcquired(lock.lockData)
+		})
+	}
+
+	return passthroughCtx, &lock, nil, nil
+}
+
+// newBackoff creates the backoff for waiting for a lock.
+func (input LockWaitInput) newBackoff(clock dateutils.Clock) dateutils.Backoff {
+	backoffInput := dateutils.NewBackoffInput{
+		Min:    250 * time.Millisecond,
+		Max:    5 * time.Second,
+		Jitter: dateutils.FullJitter,
+	}
+	if input.Backoff != nil {
+		backoffInput = *input.Backoff
+	}
+	if backoffInput.Clock == nil {
+		backoffInput.Clock = clock
+	}
+	return dateutils.NewBackoff(backoffInput)
+}
+
+// waitForLockRetry waits for the next backoff duration before retrying a lock, or until
+// the given expiry of the existing lock if that's sooner.
+func waitForLockRetry(ctx context.Context, clock dateutils.Clock, backoff dateutils.Backoff, existingExpiry time.Time) stackerr.Error {
+	wait, ok := backoff.Next()
+	if !ok {
+		return stackerr.Wrap(dateutils.ErrBackoffExhausted)
+	}
+	// There's no point in waiting longer than it takes for the existing lock to expire. If
+	// it has already expired (e.g. due to clock skew), use the full wait to avoid a busy loop.
+	if untilExpiry := existingExpiry.Sub(clock.Now()); untilExpiry > 0 && untilExpiry < wait {
+		wait = untilExpiry
+	}
+	timer := clock.NewTimer(wait)
+	select {
+	case <-ctx.Done():
+		if !timer.Stop() {
+			<-timer.C()
+		}
+		return stackerr.Wrap(ctx.Err())
+	case <-timer.C():
+		return nil
+	}
+}
+
+func (dl *distributedLocker) LockWait(ctx context.Context, key string, metadata map[string]any, input LockWaitInput) (newCtx context.Context, newLock DistributedLock, err stackerr.Error) {
+	backoff := input.newBackoff(dl.clock)
+	start := dl.clock.Now()
+
+	for {
+		newCtx, newLock, existingLock, err := dl.Lock(ctx, key, metadata)
+		if err != nil {
+			return ctx, nil, err
+		}
+		if newLock != nil {
+			dl.stats.waited(key, dl.clock.Since(start))
+			return newCtx, newLock, nil
+		}
 
-		for {
-			timer := time.NewTimer(heartbeatInterval)
+		if err := waitForLockRetry(ctx, dl.clock, backoff, existingLock.Expires()); err != nil {
+			return ctx, nil, err.With(map[string]any{
+				"lock_key":              key,
+				"existing_lock_version": existingLock.Version(),
+				"existing_lock_expires": existingLock.Expires(),
+			})
+		}
+	}
+}
+
+func (dl *distributedLocker) LockMany(ctx context.Context, keys []string, metadata map[string]any) (newCtx context.Context, newLocks []DistributedLock, existingLock LockData, err stackerr.Error) {
+	newCtx = ctx
+	newLocks = []DistributedLock{}
+
+	// Release the locks that were acquired, if they weren't all acquired
+	defer func() {
+		if err == nil && existingLock == nil {
+			return
+		}
+		for i := len(newLocks) - 1; i >= 0; i-- {
+			if unlockErr := newLocks[i].Unlock(ctx); unlockErr != nil {
+				log.Error(unlockErr)
+			}
+		}
+		newCtx = ctx
+		newLocks = nil
+	}()
+
+	lockCtxs := []context.Context{}
+	for _, key := range collections.SortSliceAscendingCopy(collections.SliceUnique(keys)) {
+		// Each lock is acquired with the original context, so that losing one
+		// lock doesn't cancel the heartbeats of the others
+		lockCtx, newLock, existingLock, err := dl.Lock(ctx, key, metadata)
+		if err != nil {
+			return ctx, newLocks, nil, err
+		}
+		if newLock == nil {
+			return ctx, newLocks, existingLock, nil
+		}
+		lockCtxs = append(lockCtxs, lockCtx)
+		newLocks = append(newLocks, newLock)
+	}
+	return mergeLockContexts(ctx, newLocks, lockCtxs), newLocks, nil, nil
+}
+
+// mergeLockContexts creates a context derived from the given context, which is cancelled
+// if any of the locks' contexts are cancelled. It stops watching each lock's context once
+// that lock has been released.
+func mergeLockContexts(ctx context.Context, locks []DistributedLock, lockCtxs []context.Context) context.Context {
+	mergedCtx, cancel := context.WithCancel(ctx)
+	lost := make(chan struct{}, len(locks))
+	wg := &sync.WaitGroup{}
+	wg.Add(len(locks))
+	for i, lock := range locks {
+		var held *distributedLock
+		switch l := lock.(type) {
+		case *distributedLock:
+			held = l
+		case *reentrantLock:
+			held = l.lock
+		}
+		go func(lockCtx context.Context) {
+			defer wg.Done()
+			select {
+			case <-lockCtx.Done():
+				lost <- struct{}{}
+			case <-held.unlockCtx.Done():
+				// The lock has been released, so it can no longer be lost
+			case <-mergedCtx.Done():
+			}
+		}(lockCtxs[i])
+	}
+	allReleased := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allReleased)
+	}()
+	go func() {
+		select {
+		case <-lost:
+			cancel()
+		case <-allReleased:
+			// A lock may have been lost just before the rest were released
 			select {
-			case <-unlockCtx.Done():
+			case <-lost:
+				cancel()
+			default:
+			}
+		case <-mergedCtx.Done():
+		}
+	}()
+	return mergedCtx
+}
 
-				// Stop and clear the heartbeat timer
-				if !timer.Stop() {
-					<-timer.C
-				}
+// heartbeat periodically renews the expiry of a held lock until the unlock context is done.
+func (dl *distributedLocker) heartbeat(ctx context.Context, unlockCtx context.Context, lock *distributedLock, key string, version string, lockDuration time.Duration, heartbeatInterval time.Duration) stackerr.Error {
+	for {
+		timer := dl.clock.NewTimer(dateutils.Jitter(heartbeatInterval, dl.heartbeatJitter))
+		select {
+		case <-unlockCtx.Done():
 
-				if lock.locked.Load() {
-					// The context was cancelled but the lock is still held,
-					// so that's an error.
-					return stackerr.Wrap(ctx.Err())
-				}
+			// Stop and clear the heartbeat timer
+			if !timer.Stop() {
+				<-timer.C()
+			}
 
-				// The lock is no longer held, so the context was cancelled by (or after)
-				// the lock being unlocked
-				return nil
-
-			// Wait for the heartbeat interval
-			case <-timer.C:
-				log.Debugw("Distributed lock heartbeat")
-
-				// Renew the expiry on the lock we hold
-				if _, err := dl.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-					TableName: &dl.tableName,
-					Key: map[string]types.AttributeValue{
-						dl.config.KeyColumn: &types.AttributeValueMemberS{
-							Value: key,
-						},
-					},
-					// Update the expiry time
-					UpdateExpression: conversions.GetPtr("SET #expires_column = :expires_time_nano"),
-					// Only update it if we still hold the lock
-					ConditionExpression: conversions.GetPtr("#version_column = :version"),
-					ExpressionAttributeNames: map[string]string{
-						"#expires_column": expiresColumn,
-						"#version_column": dl.config.VersionColumn,
-					},
-					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":expires_time_nano": &types.AttributeValueMemberN{
-							Value: fmt.Sprintf("%d", time.Now().Add(heartbeatInterval).UnixNano()),
-						},
-						":version": &types.AttributeValueMemberS{
-							Value: version,
-						},
-					},
-					ReturnConsumedCapacity: types.ReturnConsumedCapacityNone,
-					ReturnValues:           types.ReturnValueNone,
-				}); err != nil {
-					// The update failed. Check if it was a conditional check failure.
-					var ccfe *types.ConditionalCheckFailedException
-					if errors.As(err, &ccfe) {
-						// It was a conditional check failure, so get the existing lock that
-						// caused it to fail. Store it in a variable that is accessible
-						// to the deferre
-						existingLock, err := dl.getExistingLock(ctx, key)
-						if err != nil {
-							return err
-						}
-						err = stackerr.Errorf("Distributed lock has been lost").With(map[string]any{
-							"existing_lock_version":  existingLock.Version(),
-							"existing_lock_acquired": existingLock.Acquired(),
-							"existing_lock_active":   existingLock.Active(),
-							"existing_lock_logs":     links.NewSlackLink(existingLock.LogsUrl(), "Log Stream"),
-						})
-						log.Error(err)
-						return err
-					}
-					return stackerr.Wrap(err)
+			if lock.locked.Load() {
+				// The context was cancelled but the lock is still held,
+				// so that's an error.
+				return stackerr.Wrap(ctx.Err())
+			}
+
+			// The lock is no longer held, so the context was cancelled by (or after)
+			// the lock being unlocked
+			return nil
+
+		// Wait for the heartbeat interval
+		case <-timer.C():
+			log.Debugw("Distributed lock heartbeat")
+
+			// Renew the expiry on the lock we hold
+			held, err := dl.backend.setExpiry(ctx, key, version, dl.clock.Now().Add(lockDuration))
+			if err != nil {
+				return err
+			}
+			if !held {
+				// Get the existing lock that caused the renewal to fail
+				existingLock, err := dl.backend.get(ctx, key)
+				if err != nil {
+					return err
 				}
+				err = stackerr.Errorf("Distributed lock has been lost").With(map[string]any{
+					"existing_lock_version":  existingLock.Version(),
+					"existing_lock_acquired": existingLock.Acquired(),
+					"existing_lock_active":   existingLock.Active(),
+					"existing_lock_logs":     links.NewSlackLink(existingLock.LogsUrl(), "Log Stream"),
+				})
+				log.Error(err)
+				return err
+			}
+			if onHeartbeat := dl.callbacks.OnHeartbeat; onHeartbeat != nil {
+				runCallback("OnHeartbeat", lock.lockData, func() {
+					onHeartbeat(lock.lockData)
+				})
 			}
 		}
-	})
+	}
+}
 
-	return passthroughCtx, &lock, nil, nil
+// UnlockAll will release all distributed locks that are currently held by this process.
+// It is intended for use during shutdown, so that other processes don't need to wait
+// for the locks to expire before they can acquire them. Locks that have been re-acquired
+// by a reentrant locker are released regardless of how many holds they have.
+func UnlockAll(ctx context.Context) stackerr.Error {
+	errs := []error{}
+	heldLocks.Range(func(lock *distributedLock, _ struct{}) bool {
+		if err := lock.releaseAll(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	if len(errs) > 0 {
+		return stackerr.Wrap(multierr.Combine(errs...))
+	}
+	return nil
 }
 
 func (dl *distributedLocker) GetAllLocks(ctx context.Context) (map[string]LockData, stackerr.Error) {
-	return dl.getLocks(ctx, all)
+	return dl.backend.list(ctx, all)
 }
 
 func (dl *distributedLocker) GetActiveLocks(ctx context.Context) (map[string]LockData, stackerr.Error) {
-	return dl.getLocks(ctx, active)
+	return dl.backend.list(ctx, active)
 }
 
 func (dl *distributedLocker) GetExpiredLocks(ctx context.Context) (map[string]LockData, stackerr.Error) {
-	return dl.getLocks(ctx, expired)
+	return dl.backend.list(ctx, expired)
 }
 
 type lockType int
@@ -613,58 +934,86 @@ const (
 	expired
 )
 
-func (dl *distributedLocker) getLocks(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error) {
-
-	input := &dynamodb.ScanInput{
-		TableName:      &dl.tableName,
-		Select:         types.SelectAllAttributes,
-		ConsistentRead: conversions.GetPtr(true),
+func (dl *distributedLocker) ForceUnlock(ctx context.Context, key string) (LockData, stackerr.Error) {
+	// Change the version as well as the expiry, so that the holder's heartbeat fails
+	previous, err := dl.backend.replace(ctx, key, "force-unlocked/"+runId, dl.clock.Now())
+	if err != nil {
+		return nil, err.WithSingle("lock_key", key)
 	}
-
-	if typ != all {
-		input.ExpressionAttributeNames = map[string]string{
-			"#expires_column": expiresColumn,
-		}
-		input.ExpressionAttributeValues = map[string]types.AttributeValue{
-			":current_time_unix_nano": &types.AttributeValueMemberN{
-				Value: fmt.Sprintf("%d", time.Now().UnixNano()),
-			},
-		}
-
-		switch typ {
-		case active:
-			// Only get items where the expires value is in the future
-			input.FilterExpression = conversions.GetPtr("#expires_column > :current_time_unix_nano")
-		case expired:
-			// Only get items where the expires value is now or in the past
-			input.FilterExpression = conversions.GetPtr("#expires_column <= :current_time_unix_nano")
-		}
+	if previous != nil {
+		log.Warnw("Distributed lock force-unlocked",
+			"lock_key", key,
+			"previous_lock_version", previous.Version(),
+			"previous_lock_active", previous.Active(),
+			"previous_lock_logs", previous.LogsUrl(),
+		)
 	}
+	return previous, nil
+}
 
-	paginator := dynamodb.NewScanPaginator(dl.client, input)
+func (dl *distributedLocker) ExtendLock(ctx context.Context, key string, version string, duration time.Duration) stackerr.Error {
+	if duration <= 0 {
+		return stackerr.Errorf("the duration must be greater than 0")
+	}
+	held, err := dl.backend.setExpiry(ctx, key, version, dl.clock.Now().Add(duration))
+	if err != nil {
+		return err.WithSingle("lock_key", key)
+	}
+	if !held {
+		return stackerr.Errorf("could not extend distributed lock '%s', as it does not have version '%s'", key, version)
+	}
+	return nil
+}
 
-	items := []map[string]types.AttributeValue{}
+func (dl *distributedLocker) Stats() map[string]LockKeyStats {
+	return dl.stats.snapshot()
+}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, stackerr.Wrap(err)
-		}
-		items = append(items, page.Items...)
+func (dl *distributedLocker) PurgeExpiredLocks(ctx context.Context) (int, stackerr.Error) {
+	expiredLocks, err := dl.backend.list(ctx, expired)
+	if err != nil {
+		return 0, err
 	}
-
-	locks, err := collections.TransformSliceToMapWithErr(items, func(_ int, sliceValue map[string]types.AttributeValue) (mapKey string, mapValue LockData, err stackerr.Error) {
-		lock, err := dl.parseLockData(sliceValue)
+	now := dl.clock.Now()
+	purged := 0
+	for _, key := range collections.SortSliceAscendingCopy(collections.MapKeys(expiredLocks)) {
+		// The lock may have been acquired since it was listed, so only delete it if it's still expired
+		deleted, err := dl.backend.deleteIfExpired(ctx, key, now)
 		if err != nil {
-			return "", nil, err
+			return purged, err.WithSingle("lock_key", key)
+		}
+		if deleted {
+			purged++
 		}
-		return lock.Key(), lock, nil
-	})
-	if err != nil {
-		return nil, err
 	}
+	return purged, nil
+}
 
-	return locks, nil
+// validateLockTiming validates the lock duration and heartbeat settings of a locker
+// config, and sets the defaults for any that weren't provided.
+func validateLockTiming(lockDuration *time.Duration, heartbeatInterval *time.Duration, heartbeatJitter float64) stackerr.Error {
+	if *lockDuration < 0 {
+		return stackerr.Errorf("the `config.LockDuration` field must not be negative")
+	}
+	if *heartbeatInterval < 0 {
+		return stackerr.Errorf("the `config.HeartbeatInterval` field must not be negative")
+	}
+	if !(heartbeatJitter >= 0 && heartbeatJitter < 1) {
+		return stackerr.Errorf("the `config.HeartbeatJitter` field must be in the range [0, 1), got %v", heartbeatJitter)
+	}
+	if *lockDuration == 0 {
+		*lockDuration = 20 * time.Second
+	}
+	if *heartbeatInterval == 0 {
+		// Heartbeats occur at half of the lock duration by default. This
+		// ensures we always keep it locked.
+		*heartbeatInterval = *lockDuration / 2
+	}
+	// The longest time between heartbeats, after jitter
+	if maxInterval := time.Duration(float64(*heartbeatInterval) * (1 + heartbeatJitter)); maxInterval >= *lockDuration {
+		return stackerr.Errorf("the heartbeat interval (%s, up to %s with jitter) must be less than the lock duration (%s)", *heartbeatInterval, maxInterval, *lockDuration)
+	}
+	return nil
 }
 
 // NewDistributedLocker creates a new DynamoDB-based distributed locker that allows holding global locks.
@@ -675,6 +1024,20 @@ func NewDistributedLocker(ctx context.Context, dlConfig DistributedLockerConfig)
 	if dlConfig.KeyColumn == "" {
 		return nil, stackerr.Errorf("the `config.KeyColumn` field must not be empty")
 	}
+	if dlConfig.TtlDelay < 0 {
+		return nil, stackerr.Errorf("the `config.TtlDelay` field must not be negative")
+	}
+	if dlConfig.TtlDelay == 0 {
+		dlConfig.TtlDelay = 24 * time.Hour
+	}
+
+	if err := validateLockTiming(&dlConfig.LockDuration, &dlConfig.HeartbeatInterval, dlConfig.HeartbeatJitter); err != nil {
+		return nil, err
+	}
+	stats, err := newLockStats(dlConfig.LockStatsConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	a, cerr := arn.Parse(dlConfig.TableArn)
 	if cerr != nil {
@@ -698,9 +1061,23 @@ func NewDistributedLocker(ctx context.Context, dlConfig DistributedLockerConfig)
 	// Create an SQS client
 	client := dynamodb.NewFromConfig(cfg)
 
+	clock := dateutils.ClockOrDefault(dlConfig.Clock)
 	return &distributedLocker{
-		client:    client,
-		config:    dlConfig,
-		tableName: strings.TrimPrefix(a.Resource, "table/"),
+		backend: &dynamoLockBackend{
+			client:        client,
+			tableName:     strings.TrimPrefix(a.Resource, "table/"),
+			keyColumn:     dlConfig.KeyColumn,
+			versionColumn: dlConfig.VersionColumn,
+			ttlColumn:     dlConfig.TtlColumn,
+			ttlDelay:      dlConfig.TtlDelay,
+			clock:         clock,
+		},
+		clock:             clock,
+		lockDuration:      dlConfig.LockDuration,
+		heartbeatInterval: dlConfig.HeartbeatInterval,
+		heartbeatJitter:   dlConfig.HeartbeatJitter,
+		reentrant:         dlConfig.Reentrant,
+		callbacks:         dlConfig.LockCallbacks,
+		stats:             stats,
 	}, nil
 }
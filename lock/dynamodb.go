@@ -0,0 +1,602 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-common/conversions"
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	metaColumn     string = "Metadata"
+	acquiredColumn string = "AcquiredUnixNano"
+	logsUrlColumn  string = "LogsUrl"
+	expiresColumn  string = "ExpiresUnixNano"
+	fencingColumn  string = "FencingToken"
+	holdsColumn    string = "HoldCount"
+)
+
+// dynamoLockBackend stores locks in a DynamoDB table, with one item per lock.
+type dynamoLockBackend struct {
+	client        *dynamodb.Client
+	tableName     string
+	keyColumn     string
+	versionColumn string
+	// The TTL attribute, if there is one
+	ttlColumn string
+	ttlDelay  time.Duration
+	clock     dateutils.Clock
+}
+
+// ttlValue gets the value of the TTL attribute for a lock that expires at the given time.
+func (db *dynamoLockBackend) ttlValue(expires time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{
+		Value: fmt.Sprintf("%d", expires.Add(db.ttlDelay).Unix()),
+	}
+}
+
+// setExpiryExpression gets the update expression that sets the expiry of a lock (and its
+// TTL, if there is one), adding the names and values that it uses.
+func (db *dynamoLockBackend) setExpiryExpression(expires time.Time, names map[string]string, values map[string]types.AttributeValue) string {
+	names["#expires_column"] = expiresColumn
+	values[":expires_unix_nano"] = &types.AttributeValueMemberN{
+		Value: fmt.Sprintf("%d", expires.UnixNano()),
+	}
+	if db.ttlColumn == "" {
+		return "#expires_column = :expires_unix_nano"
+	}
+	names["#ttl_column"] = db.ttlColumn
+	values[":ttl"] = db.ttlValue(expires)
+	return "#expires_column = :expires_unix_nano, #ttl_column = :ttl"
+}
+
+func (db *dynamoLockBackend) parseLockData(item map[string]types.AttributeValue) (LockData, stackerr.Error) {
+	var key, version, logsUrl string
+	var acquiredUnixNano, expiresUnixNano int64
+	metadata := map[string]json.RawMessage{}
+
+	// Extract the key column value
+	keyValue, ok := item[db.keyColumn]
+	if !ok {
+		return nil, stackerr.Errorf("No key field in existing lock row")
+	}
+	// Try to unmarshal it
+	if err := attributevalue.Unmarshal(keyValue, &key); err != nil {
+		return nil, stackerr.Errorf("Version field in existing lock row is not of expected type")
+	}
+
+	// Extract the version column value
+	versionValue, ok := item[db.versionColumn]
+	if !ok {
+		return nil, stackerr.Errorf("No version field in existing lock row")
+	}
+	// Try to unmarshal it
+	if err := attributevalue.Unmarshal(versionValue, &version); err != nil {
+		return nil, stackerr.Errorf("Version field in existing lock row is not of expected type")
+	}
+
+	// Extract the acquired column value
+	acquiredValue, ok := item[acquiredColumn]
+	if !ok {
+		return nil, stackerr.Errorf("No acquired field in existing lock row")
+	}
+	// Try to unmarshal it
+	if err := attributevalue.Unmarshal(acquiredValue, &acquiredUnixNano); err != nil {
+		return nil, stackerr.Errorf("Acquired field in existing lock row is not of expected type")
+	}
+
+	// Extract the expires column value
+	expiresValue, ok := item[expiresColumn]
+	if !ok {
+		return nil, stackerr.Errorf("No expires field in existing lock row")
+	}
+	// Try to unmarshal it
+	if err := attributevalue.Unmarshal(expiresValue, &expiresUnixNano); err != nil {
+		return nil, stackerr.Errorf("Expires field in existing lock row is not of expected type")
+	}
+
+	// Check if there's a logs URL
+	if logsUrlValue, ok := item[logsUrlColumn]; ok {
+		// If there is, try to unmarshal it
+		if err := attributevalue.Unmarshal(logsUrlValue, &logsUrl); err != nil {
+			// Log the error, but don't exit out since it doesn't prevent us from continuing
+			// (it just makes the log alerts a bit less useful)
+			log.Errorf("Logs URL field in existing lock row is not of expected type")
+		}
+	}
+
+	// Check if there's metadata
+	if metadataValue, ok := item[metaColumn]; ok {
+		var rawMetadata string
+		// If there is, try to unmarshal it
+		if err := attributevalue.Unmarshal(metadataValue, &rawMetadata); err != nil {
+			log.Errorf("Metadata field in existing lock row is not of expected type")
+		} else {
+			if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+				log.With(
+					"json", rawMetadata,
+				).Errorf("Metadata field in existing lock row is not in valid JSON format")
+			}
+		}
+	}
+
+	// Check if there's a fencing token (there won't be for locks
+	// that haven't been acquired since fencing tokens were added)
+	var fencing int64
+	if fencingValue, ok := item[fencingColumn]; ok {
+		if err := attributevalue.Unmarshal(fencingValue, &fencing); err != nil {
+			return nil, stackerr.Errorf("Fencing token field in existing lock row is not of expected type")
+		}
+	}
+
+	// Locks that haven't been acquired since hold counts were added are held once
+	holds := int64(1)
+	if holdsValue, ok := item[holdsColumn]; ok {
+		if err := attributevalue.Unmarshal(holdsValue, &holds); err != nil {
+			return nil, stackerr.Errorf("Hold count field in existing lock row is not of expected type")
+		}
+	}
+
+	acquired := dateutils.TimeFromUnix(acquiredUnixNano)
+	expires := dateutils.TimeFromUnix(expiresUnixNano)
+
+	return lockData{
+		key:      key,
+		version:  version,
+		acquired: acquired,
+		expires:  expires,
+		logsUrl:  logsUrl,
+		metadata: metadata,
+		active:   expires.After(db.clock.Now()),
+		fencing:  fencing,
+		holds:    holds,
+	}, nil
+}
+
+func (db *dynamoLockBackend) get(ctx context.Context, key string) (LockData, stackerr.Error) {
+	keyAttribute, err := attributevalue.Marshal(key)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+
+	// Get the existing lock
+	existing, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: keyAttribute,
+		},
+		// Ensure the lock read is consistent
+		ConsistentRead: conversions.GetPtr(true),
+	})
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+
+	existingLockData, serr := db.parseLockData(existing.Item)
+	if serr != nil {
+		return nil, serr.WithSingle("key", key)
+	}
+
+	return existingLockData, nil
+}
+
+func (db *dynamoLockBackend) acquire(ctx context.Context, row lockRow) (fencing int64, existingLock LockData, err stackerr.Error) {
+	// The lock row values to set
+	attributes := map[string]types.AttributeValue{
+		// Set the version in the version column
+		db.versionColumn: &types.AttributeValueMemberS{
+			Value: row.version,
+		},
+		// Set the timestamp for when the lock was most recently acquired
+		acquiredColumn: &types.AttributeValueMemberN{
+			Value: fmt.Sprintf("%d", row.acquired.UnixNano()),
+		},
+		// Set the timestamp for when the lock should expire
+		expiresColumn: &types.AttributeValueMemberN{
+			Value: fmt.Sprintf("%d", row.expires.UnixNano()),
+		},
+		// Set the metadata JSON into the metadata column
+		metaColumn: &types.AttributeValueMemberS{
+			Value: row.metadata,
+		},
+		// A newly acquired lock is held once
+		holdsColumn: &types.AttributeValueMemberN{
+			Value: "1",
+		},
+	}
+	if db.ttlColumn != "" {
+		attributes[db.ttlColumn] = db.ttlValue(row.expires)
+	}
+	if row.logsUrl != "" {
+		attributes[logsUrlColumn] = &types.AttributeValueMemberS{
+			Value: row.logsUrl,
+		}
+	}
+
+	// Build an update that sets all of the attributes and increments the fencing token
+	// (which can't be done with a PutItem, since that replaces the whole item).
+	names := map[string]string{
+		"#key_column":     db.keyColumn,
+		"#expires_column": expiresColumn,
+		"#fencing_column": fencingColumn,
+	}
+	values := map[string]types.AttributeValue{
+		":current_time_nano": &types.AttributeValueMemberN{
+			Value: fmt.Sprintf("%d", db.clock.Now().UnixNano()),
+		},
+		":one": &types.AttributeValueMemberN{
+			Value: "1",
+		},
+	}
+	sets := []string{}
+	for i, column := range collections.SortSliceAscendingCopy(collections.MapKeys(attributes)) {
+		names[fmt.Sprintf("#attr%d", i)] = column
+		values[fmt.Sprintf(":attr%d", i)] = attributes[column]
+		sets = append(sets, fmt.Sprintf("#attr%d = :attr%d", i, i))
+	}
+	updateExpression := "SET " + strings.Join(sets, ", ") + " ADD #fencing_column :one"
+	if _, ok := attributes[logsUrlColumn]; !ok {
+		// Remove the logs URL of the previous holder, if there was one
+		names["#logs_url_column"] = logsUrlColumn
+		updateExpression += " REMOVE #logs_url_column"
+	}
+
+	// Update the item for the lock, where either the row does not exist,
+	// or the lock has expired.
+	updated, cerr := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: row.key,
+			},
+		},
+		UpdateExpression:          conversions.GetPtr(updateExpression),
+		ConditionExpression:       conversions.GetPtr("attribute_not_exists(#key_column) OR #expires_column <= :current_time_nano"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if cerr != nil {
+		// There was an error updating it. Check if it was a conditional check failure.
+		// If it was, that means that there's already a lock that isn't expired.
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(cerr, &ccfe) {
+			// Try to get the existing lock
+			existingLock, err := db.get(ctx, row.key)
+			if err != nil {
+				return 0, nil, err
+			}
+			return 0, existingLock, nil
+		}
+
+		// It was an error other than an existing lock, so return that error
+		return 0, nil, stackerr.Wrap(cerr)
+	}
+
+	if fencingValue, ok := updated.Attributes[fencingColumn]; ok {
+		if err := attributevalue.Unmarshal(fencingValue, &fencing); err != nil {
+			return 0, nil, stackerr.Wrap(err)
+		}
+	}
+	return fencing, nil, nil
+}
+
+func (db *dynamoLockBackend) setExpiry(ctx context.Context, key string, version string, expires time.Time) (bool, stackerr.Error) {
+	names := map[string]string{
+		"#version_column": db.versionColumn,
+	}
+	values := map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberS{
+			Value: version,
+		},
+	}
+	if _, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: key,
+			},
+		},
+		// Update the expiry time
+		UpdateExpression: conversions.GetPtr("SET " + db.setExpiryExpression(expires, names, values)),
+		// Only update it if we still hold the lock
+		ConditionExpression:       conversions.GetPtr("#version_column = :version"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityNone,
+		ReturnValues:              types.ReturnValueNone,
+	}); err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return false, nil
+		}
+		return false, stackerr.Wrap(err)
+	}
+	return true, nil
+}
+
+func (db *dynamoLockBackend) addHolds(ctx context.Context, key string, version string, delta int64) (holds int64, held bool, err stackerr.Error) {
+	names := map[string]string{
+		"#holds_column":   holdsColumn,
+		"#version_column": db.versionColumn,
+	}
+	values := map[string]types.AttributeValue{
+		":delta": &types.AttributeValueMemberN{
+			Value: fmt.Sprintf("%d", delta),
+		},
+		":version": &types.AttributeValueMemberS{
+			Value: version,
+		},
+	}
+	// Only update it if we still hold the lock
+	condition := "#version_column = :version"
+	if delta > 0 {
+		// Don't add holds to a lock that has expired
+		names["#expires_column"] = expiresColumn
+		values[":current_time_unix_nano"] = &types.AttributeValueMemberN{
+			Value: fmt.Sprintf("%d", db.clock.Now().UnixNano()),
+		}
+		condition += " AND #expires_column > :current_time_unix_nano"
+	}
+	updated, cerr := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: key,
+			},
+		},
+		UpdateExpression:          conversions.GetPtr("ADD #holds_column :delta"),
+		ConditionExpression:       conversions.GetPtr(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if cerr != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(cerr, &ccfe) {
+			return 0, false, nil
+		}
+		return 0, false, stackerr.Wrap(cerr)
+	}
+	if holdsValue, ok := updated.Attributes[holdsColumn]; ok {
+		if err := attributevalue.Unmarshal(holdsValue, &holds); err != nil {
+			return 0, false, stackerr.Wrap(err)
+		}
+	}
+	return holds, true, nil
+}
+
+func (db *dynamoLockBackend) getMany(ctx context.Context, keys []string) ([]LockData, stackerr.Error) {
+	keyAttributes := collections.TransformSlice(keys, func(key string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: key,
+			},
+		}
+	})
+
+	locks := []LockData{}
+	// BatchGetItem is limited to 100 keys per request
+	for start := 0; start < len(keyAttributes); start += 100 {
+		end := numbers.Min(start+100, len(keyAttributes))
+		requestItems := map[string]types.KeysAndAttributes{
+			db.tableName: {
+				Keys:           keyAttributes[start:end],
+				ConsistentRead: conversions.GetPtr(true),
+			},
+		}
+		for len(requestItems) > 0 {
+			output, err := db.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, stackerr.Wrap(err)
+			}
+			for _, item := range output.Responses[db.tableName] {
+				lock, err := db.parseLockData(item)
+				if err != nil {
+					return nil, err
+				}
+				locks = append(locks, lock)
+			}
+			// Retry any keys that weren't processed due to throttling
+			requestItems = output.UnprocessedKeys
+		}
+	}
+	return locks, nil
+}
+
+func (db *dynamoLockBackend) replace(ctx context.Context, key string, version string, expires time.Time) (LockData, stackerr.Error) {
+	names := map[string]string{
+		"#key_column":     db.keyColumn,
+		"#version_column": db.versionColumn,
+	}
+	values := map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberS{
+			Value: version,
+		},
+	}
+	output, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: key,
+			},
+		},
+		UpdateExpression: conversions.GetPtr("SET #version_column = :version, " + db.setExpiryExpression(expires, names, values)),
+		// Don't create a lock if there isn't one
+		ConditionExpression:       conversions.GetPtr("attribute_exists(#key_column)"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllOld,
+	})
+	if err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return nil, nil
+		}
+		return nil, stackerr.Wrap(err)
+	}
+	return db.parseLockData(output.Attributes)
+}
+
+func (db *dynamoLockBackend) deleteIfExpired(ctx context.Context, key string, now time.Time) (bool, stackerr.Error) {
+	if _, err := db.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &db.tableName,
+		Key: map[string]types.AttributeValue{
+			db.keyColumn: &types.AttributeValueMemberS{
+				Value: key,
+			},
+		},
+		ConditionExpression: conversions.GetPtr("#expires_column <= :current_time_unix_nano"),
+		ExpressionAttributeNames: map[string]string{
+			"#expires_column": expiresColumn,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":current_time_unix_nano": &types.AttributeValueMemberN{
+				Value: fmt.Sprintf("%d", now.UnixNano()),
+			},
+		},
+	}); err != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(err, &ccfe) {
+			return false, nil
+		}
+		return false, stackerr.Wrap(err)
+	}
+	return true, nil
+}
+
+func (db *dynamoLockBackend) list(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error) {
+
+	input := &dynamodb.ScanInput{
+		TableName:      &db.tableName,
+		Select:         types.SelectAllAttributes,
+		ConsistentRead: conversions.GetPtr(true),
+	}
+
+	if typ != all {
+		input.ExpressionAttributeNames = map[string]string{
+			"#expires_column": expiresColumn,
+		}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":current_time_unix_nano": &types.AttributeValueMemberN{
+				Value: fmt.Sprintf("%d", db.clock.Now().UnixNano()),
+			},
+		}
+
+		switch typ {
+		case active:
+			// Only get items where the expires value is in the future
+			input.FilterExpression = conversions.GetPtr("#expires_column > :current_time_unix_nano")
+		case expired:
+			// Only get items where the expires value is now or in the past
+			input.FilterExpression = conversions.GetPtr("#expires_column <= :current_time_unix_nano")
+		}
+	}
+
+	paginator := dynamodb.NewScanPaginator(db.client, input)
+
+	items := []map[string]types.AttributeValue{}
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		items = append(items, page.Items...)
+	}
+
+	locks, err := collections.TransformSliceToMapWithErr(items, func(_ int, sliceValue map[string]types.AttributeValue) (mapKey string, mapValue LockData, err stackerr.Error) {
+		lock, err := db.parseLockData(sliceValue)
+		if err != nil {
+			return "", nil, err
+		}
+		return lock.Key(), lock, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locks, nil
+}
+
+// MigrateTTL backfills the TTL attribute (see DistributedLockerConfig.TtlColumn) of all lock
+// rows that don't have one yet, e.g. after a TTL column is first configured, and returns the
+// number of rows that were updated. Rows that are written by the locker while it runs get
+// the attribute anyway, so they're skipped. The locker must be a DynamoDB-based locker
+// (from NewDistributedLocker) with a TTL column.
+func MigrateTTL(ctx context.Context, locker DistributedLocker) (int, stackerr.Error) {
+	dl, ok := locker.(*distributedLocker)
+	if !ok {
+		return 0, stackerr.Errorf("the locker must be a DynamoDB-based distributed locker")
+	}
+	db, ok := dl.backend.(*dynamoLockBackend)
+	if !ok {
+		return 0, stackerr.Errorf("the locker must be a DynamoDB-based distributed locker")
+	}
+	if db.ttlColumn == "" {
+		return 0, stackerr.Errorf("the locker does not have a TTL column")
+	}
+
+	paginator := dynamodb.NewScanPaginator(db.client, &dynamodb.ScanInput{
+		TableName:            &db.tableName,
+		ProjectionExpression: conversions.GetPtr("#key_column, #expires_column"),
+		FilterExpression:     conversions.GetPtr("attribute_not_exists(#ttl_column)"),
+		ExpressionAttributeNames: map[string]string{
+			"#key_column":     db.keyColumn,
+			"#expires_column": expiresColumn,
+			"#ttl_column":     db.ttlColumn,
+		},
+	})
+
+	migrated := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return migrated, stackerr.Wrap(err)
+		}
+		for _, item := range page.Items {
+			var expiresUnixNano int64
+			if err := attributevalue.Unmarshal(item[expiresColumn], &expiresUnixNano); err != nil {
+				return migrated, stackerr.Errorf("Expires field in existing lock row is not of expected type")
+			}
+			if _, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName: &db.tableName,
+				Key: map[string]types.AttributeValue{
+					db.keyColumn: item[db.keyColumn],
+				},
+				UpdateExpression: conversions.GetPtr("SET #ttl_column = :ttl"),
+				// Don't overwrite a TTL that was set since the scan, or create a row that was deleted
+				ConditionExpression: conversions.GetPtr("attribute_exists(#key_column) AND attribute_not_exists(#ttl_column)"),
+				ExpressionAttributeNames: map[string]string{
+					"#key_column": db.keyColumn,
+					"#ttl_column": db.ttlColumn,
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":ttl": db.ttlValue(dateutils.TimeFromUnix(expiresUnixNano)),
+				},
+			}); err != nil {
+				var ccfe *types.ConditionalCheckFailedException
+				if errors.As(err, &ccfe) {
+					continue
+				}
+				return migrated, stackerr.Wrap(err)
+			}
+			migrated++
+		}
+	}
+	return migrated, nil
+}
@@ -0,0 +1,362 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type FileLockerConfig struct {
+	// The directory to store the lock files in. It's created if it doesn't exist. All
+	// processes that share locks must use the same directory, on the same host (network
+	// file systems often don't support file locking reliably).
+	Dir string
+	// OPTIONAL. The clock to use for lock timestamps and the
+	// heartbeat. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+	// OPTIONAL. How long a lock is held for after it's acquired and after each
+	// heartbeat. This is how long other processes must wait for the lock if the
+	// process holding it dies without unlocking it. Defaults to 20 seconds.
+	LockDuration time.Duration
+	// OPTIONAL. How often the lock's expiry is renewed. It must be less than the lock
+	// duration (including jitter). Defaults to half of the lock duration.
+	HeartbeatInterval time.Duration
+	// OPTIONAL. The fraction (in the range [0, 1)) that each heartbeat interval is
+	// randomly adjusted by, so that many locks acquired at once don't renew at the
+	// same time. Defaults to 0.
+	HeartbeatJitter float64
+	// OPTIONAL. Whether Lock and LockWait can re-acquire a lock that's already held by the
+	// same process. See DistributedLockerConfig.Reentrant. Defaults to false.
+	Reentrant bool
+	// OPTIONAL. Callbacks for lock lifecycle events.
+	LockCallbacks
+	// OPTIONAL. Statistics collection for the locker's locks.
+	LockStatsConfig
+}
+
+// The suffix of the file for each lock
+const fileLockSuffix = ".lock.json"
+
+// The name of the file that's locked while a lock file is being read or changed
+const fileLockMutexName = ".mutex"
+
+// fileLockRecord is the content of the file for a lock.
+type fileLockRecord struct {
+	Key              string `json:"key"`
+	Version          string `json:"version"`
+	AcquiredUnixNano int64  `json:"acquired_unix_nano"`
+	ExpiresUnixNano  int64  `json:"expires_unix_nano"`
+	LogsUrl          string `json:"logs_url,omitempty"`
+	// The metadata, in JSON format
+	Metadata     string `json:"metadata"`
+	FencingToken int64  `json:"fencing_token"`
+	HoldCount    int64  `json:"hold_count"`
+}
+
+// fileLockBackend stores locks in a directory, with a JSON file for each lock. All
+// operations hold an exclusive file lock (flock on Unix) on a mutex file in the
+// directory, so they're atomic across all processes on the host.
+type fileLockBackend struct {
+	dir   string
+	clock dateutils.Clock
+}
+
+// path gets the path of the file for a lock.
+func (fb *fileLockBackend) path(key string) string {
+	return filepath.Join(fb.dir, url.QueryEscape(key)+fileLockSuffix)
+}
+
+// withMutex runs the function while holding the directory's mutex, waiting for it
+// until the context is done.
+func (fb *fileLockBackend) withMutex(ctx context.Context, f func() stackerr.Error) stackerr.Error {
+	path := filepath.Join(fb.dir, fileLockMutexName)
+	for {
+		unlock, locked, err := tryLockFile(path)
+		if err != nil {
+			return stackerr.Wrap(err).WithSingle("path", path)
+		}
+		if locked {
+			defer func() {
+				if err := unlock(); err != nil {
+					log.Error(stackerr.Wrap(err).WithSingle("path", path))
+				}
+			}()
+			return f()
+		}
+		timer := fb.clock.NewTimer(10 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			if !timer.Stop() {
+				<-timer.C()
+			}
+			return stackerr.Wrap(ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
+
+// read reads the file for a lock. The record is nil if there isn't one.
+func (fb *fileLockBackend) read(key string) (*fileLockRecord, stackerr.Error) {
+	data, err := os.ReadFile(fb.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, stackerr.Wrap(err)
+	}
+	record := &fileLockRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, stackerr.Wrap(err).WithSingle("key", key)
+	}
+	return record, nil
+}
+
+// write writes the file for a lock. It writes to a temporary file first, so that
+// the file is never partially written.
+func (fb *fileLockBackend) write(record *fileLockRecord) stackerr.Error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	path := fb.path(record.Key)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return stackerr.Wrap(err).WithSingle("path", tmpPath)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return stackerr.Wrap(err).WithSingle("path", path)
+	}
+	return nil
+}
+
+func (fb *fileLockBackend) parseLockData(record *fileLockRecord) LockData {
+	metadata := map[string]json.RawMessage{}
+	if record.Metadata != "" {
+		if err := json.Unmarshal([]byte(record.Metadata), &metadata); err != nil {
+			log.With(
+				"json", record.Metadata,
+			).Errorf("Metadata field in existing lock file is not in valid JSON format")
+		}
+	}
+	expires := dateutils.TimeFromUnix(record.ExpiresUnixNano)
+	return lockData{
+		key:      record.Key,
+		version:  record.Version,
+		acquired: dateutils.TimeFromUnix(record.AcquiredUnixNano),
+		expires:  expires,
+		logsUrl:  record.LogsUrl,
+		metadata: metadata,
+		active:   expires.After(fb.clock.Now()),
+		fencing:  record.FencingToken,
+		holds:    record.HoldCount,
+	}
+}
+
+func (fb *fileLockBackend) acquire(ctx context.Context, row lockRow) (fencing int64, existingLock LockData, err stackerr.Error) {
+	err = fb.withMutex(ctx, func() stackerr.Error {
+		existing, err := fb.read(row.key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if dateutils.TimeFromUnix(existing.ExpiresUnixNano).After(fb.clock.Now()) {
+				existingLock = fb.parseLockData(existing)
+				return nil
+			}
+			fencing = existing.FencingToken
+		}
+		fencing++
+		return fb.write(&fileLockRecord{
+			Key:              row.key,
+			Version:          row.version,
+			AcquiredUnixNano: row.acquired.UnixNano(),
+			ExpiresUnixNano:  row.expires.UnixNano(),
+			LogsUrl:          row.logsUrl,
+			Metadata:         row.metadata,
+			FencingToken:     fencing,
+			HoldCount:        1,
+		})
+	})
+	if err != nil || existingLock != nil {
+		return 0, existingLock, err
+	}
+	return fencing, nil, nil
+}
+
+func (fb *fileLockBackend) get(ctx context.Context, key string) (LockData, stackerr.Error) {
+	locks, err := fb.getMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) == 0 {
+		return nil, stackerr.Errorf("No existing lock file").WithSingle("key", key)
+	}
+	return locks[0], nil
+}
+
+func (fb *fileLockBackend) getMany(ctx context.Context, keys []string) ([]LockData, stackerr.Error) {
+	locks := []LockData{}
+	err := fb.withMutex(ctx, func() stackerr.Error {
+		for _, key := range keys {
+			record, err := fb.read(key)
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				locks = append(locks, fb.parseLockData(record))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+// update reads the file for a lock, and writes it back if the function returns true.
+// The function isn't called if there's no lock.
+func (fb *fileLockBackend) update(ctx context.Context, key string, f func(record *fileLockRecord) bool) stackerr.Error {
+	return fb.withMutex(ctx, func() stackerr.Error {
+		record, err := fb.read(key)
+		if err != nil || record == nil {
+			return err
+		}
+		if !f(record) {
+			return nil
+		}
+		return fb.write(record)
+	})
+}
+
+func (fb *fileLockBackend) setExpiry(ctx context.Context, key string, version string, expires time.Time) (bool, stackerr.Error) {
+	updated := false
+	err := fb.update(ctx, key, func(record *fileLockRecord) bool {
+		if record.Version != version {
+			return false
+		}
+		record.ExpiresUnixNano = expires.UnixNano()
+		updated = true
+		return true
+	})
+	return updated, err
+}
+
+func (fb *fileLockBackend) addHolds(ctx context.Context, key string, version string, delta int64) (holds int64, held bool, err stackerr.Error) {
+	err = fb.update(ctx, key, func(record *fileLockRecord) bool {
+		if record.Version != version {
+			return false
+		}
+		if delta > 0 && !dateutils.TimeFromUnix(record.ExpiresUnixNano).After(fb.clock.Now()) {
+			return false
+		}
+		record.HoldCount += delta
+		holds = record.HoldCount
+		held = true
+		return true
+	})
+	return holds, held, err
+}
+
+func (fb *fileLockBackend) replace(ctx context.Context, key string, version string, expires time.Time) (LockData, stackerr.Error) {
+	var previous LockData
+	err := fb.update(ctx, key, func(record *fileLockRecord) bool {
+		previous = fb.parseLockData(record)
+		record.Version = version
+		record.ExpiresUnixNano = expires.UnixNano()
+		return true
+	})
+	return previous, err
+}
+
+func (fb *fileLockBackend) deleteIfExpired(ctx context.Context, key string, now time.Time) (bool, stackerr.Error) {
+	deleted := false
+	err := fb.withMutex(ctx, func() stackerr.Error {
+		record, err := fb.read(key)
+		if err != nil || record == nil {
+			return err
+		}
+		if dateutils.TimeFromUnix(record.ExpiresUnixNano).After(now) {
+			return nil
+		}
+		if err := os.Remove(fb.path(key)); err != nil {
+			return stackerr.Wrap(err)
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+func (fb *fileLockBackend) list(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error) {
+	entries, cerr := os.ReadDir(fb.dir)
+	if cerr != nil {
+		return nil, stackerr.Wrap(cerr)
+	}
+	keys := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileLockSuffix) {
+			continue
+		}
+		key, cerr := url.QueryUnescape(strings.TrimSuffix(name, fileLockSuffix))
+		if cerr != nil {
+			// It isn't a file that this locker created
+			continue
+		}
+		keys = append(keys, key)
+	}
+	locks, err := fb.getMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]LockData{}
+	for _, lock := range locks {
+		if typ == all || (typ == active) == lock.Active() {
+			result[lock.Key()] = lock
+		}
+	}
+	return result, nil
+}
+
+// NewFileLocker creates a new distributed locker that stores its locks in files in a local
+// directory, which has the same behaviour as the DynamoDB-based one (see NewDistributedLocker)
+// for processes on a single host, e.g. CLI tools and single-host deployments.
+func NewFileLocker(config FileLockerConfig) (DistributedLocker, stackerr.Error) {
+	if config.Dir == "" {
+		return nil, stackerr.Errorf("the `config.Dir` field must not be empty")
+	}
+	if err := validateLockTiming(&config.LockDuration, &config.HeartbeatInterval, config.HeartbeatJitter); err != nil {
+		return nil, err
+	}
+	stats, err := newLockStats(config.LockStatsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, stackerr.Wrap(err).WithSingle("path", config.Dir)
+	}
+	clock := dateutils.ClockOrDefault(config.Clock)
+	return &distributedLocker{
+		backend: &fileLockBackend{
+			dir:   config.Dir,
+			clock: clock,
+		},
+		clock:             clock,
+		lockDuration:      config.LockDuration,
+		heartbeatInterval: config.HeartbeatInterval,
+		heartbeatJitter:   config.HeartbeatJitter,
+		reentrant:         config.Reentrant,
+		callbacks:         config.LockCallbacks,
+		stats:             stats,
+	}, nil
+}
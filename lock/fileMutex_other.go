@@ -0,0 +1,36 @@
+//go:build !unix
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// How old a mutex file must be before it's assumed to have been left behind
+// by a process that died while holding it
+const staleFileMutexAge = 30 * time.Second
+
+// tryLockFile attempts to take an exclusive lock on the path by creating a file at it,
+// without waiting. This is used on platforms without flock, so a lock that's left behind
+// by a process that died is only broken once it's older than staleFileMutexAge.
+func tryLockFile(path string) (unlock func() error, locked bool, err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			return nil, false, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleFileMutexAge {
+			os.Remove(path)
+		}
+		return nil, false, nil
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, false, err
+	}
+	return func() error {
+		return os.Remove(path)
+	}, true, nil
+}
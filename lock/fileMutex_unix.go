@@ -0,0 +1,30 @@
+//go:build unix
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile attempts to take an exclusive flock on the file at the path (creating it
+// if it doesn't exist), without waiting. The lock is released automatically if the
+// process dies.
+func tryLockFile(path string) (unlock func() error, locked bool, err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return func() error {
+		// Closing the file releases the lock
+		return f.Close()
+	}, true, nil
+}
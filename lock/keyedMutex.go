@@ -0,0 +1,153 @@
+package lock
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// KeyedMutex is a set of mutexes, one per key, where Lock
+// operations use a context that can be cancelled/deadlined
+// to terminate the lock attempt. Unlike gensync.MultiLock,
+// the keys don't need to be known up front: the mutex for a
+// key is created when it's first needed, and removed once
+// nothing holds or is waiting for it.
+type KeyedMutex[K comparable] interface {
+
+	// Lock will wait until either the mutex for the key can be
+	// locked, or the context is done (cancelled/deadlined). If
+	// the lock succeeds, it will return nil. If the context is
+	// done, it will return a stack-wrapped version of the
+	// context's error.
+	Lock(ctx context.Context, key K) (err stackerr.Error)
+
+	// TryLock will attempt to lock the mutex for the key, but
+	// will not wait if it cannot immediately do so.
+	TryLock(key K) (locked bool)
+
+	// Unlock will unlock the mutex for the key, and will panic
+	// if the mutex is not currently locked.
+	Unlock(key K)
+
+	// TryUnlock will attempt to unlock the mutex for the key, but
+	// will not panic if the mutex is not currently locked.
+	TryUnlock(key K) (unlocked bool)
+
+	// Locked will return whether the mutex for the key is
+	// currently locked.
+	Locked(key K) bool
+
+	// Len will return the number of keys that are currently
+	// locked or being waited on.
+	Len() int
+}
+
+type keyedMutexEntry struct {
+	mu *ctxMutex
+	// The number of goroutines that hold or are waiting for the mutex
+	refs int
+}
+
+func newKeyedMutexEntry() *keyedMutexEntry {
+	return &keyedMutexEntry{
+		mu: &ctxMutex{
+			ch: make(chan struct{}, 1),
+		},
+	}
+}
+
+type keyedMutex[K comparable] struct {
+	lock    sync.Mutex
+	entries map[K]*keyedMutexEntry
+}
+
+// NewKeyedMutex creates a new KeyedMutex
+func NewKeyedMutex[K comparable]() KeyedMutex[K] {
+	return &keyedMutex[K]{
+		entries: map[K]*keyedMutexEntry{},
+	}
+}
+
+// acquire gets the entry for a key, creating it if it doesn't
+// exist, and adds a reference to it.
+func (km *keyedMutex[K]) acquire(key K) *keyedMutexEntry {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	entry, ok := km.entries[key]
+	if !ok {
+		entry = newKeyedMutexEntry()
+		km.entries[key] = entry
+	}
+	entry.refs++
+	return entry
+}
+
+// release removes a reference to the entry for a key, and removes
+// the entry once it has no references left. It must be called with
+// the lock held.
+func (km *keyedMutex[K]) release(key K, entry *keyedMutexEntry) {
+	entry.refs--
+	if entry.refs == 0 {
+		delete(km.entries, key)
+	}
+}
+
+func (km *keyedMutex[K]) Lock(ctx context.Context, key K) (err stackerr.Error) {
+	entry := km.acquire(key)
+	if err := entry.mu.Lock(ctx); err != nil {
+		km.lock.Lock()
+		km.release(key, entry)
+		km.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (km *keyedMutex[K]) TryLock(key K) (locked bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	entry, ok := km.entries[key]
+	if !ok {
+		entry = newKeyedMutexEntry()
+		km.entries[key] = entry
+	}
+	if !entry.mu.TryLock() {
+		return false
+	}
+	entry.refs++
+	return true
+}
+
+func (km *keyedMutex[K]) Unlock(key K) {
+	if !km.TryUnlock(key) {
+		panic("unlock of unlocked mutex")
+	}
+}
+
+func (km *keyedMutex[K]) TryUnlock(key K) (unlocked bool) {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	entry, ok := km.entries[key]
+	if !ok || !entry.mu.TryUnlock() {
+		return false
+	}
+	km.release(key, entry)
+	return true
+}
+
+func (km *keyedMutex[K]) Locked(key K) bool {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	entry, ok := km.entries[key]
+	if !ok {
+		return false
+	}
+	return entry.mu.Locked()
+}
+
+func (km *keyedMutex[K]) Len() int {
+	km.lock.Lock()
+	defer km.lock.Unlock()
+	return len(km.entries)
+}
@@ -0,0 +1,339 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type PostgresDistributedLockerConfig struct {
+	// The database to store locks in. Any Postgres driver can be used (e.g. pgx's stdlib
+	// package or lib/pq), as long as it supports $1-style placeholders.
+	DB *sql.DB
+	// OPTIONAL. The table to store locks in, which may be schema-qualified (e.g.
+	// "jobs.locks"). Defaults to "distributed_locks".
+	TableName string
+	// OPTIONAL. Whether to create the table (if it doesn't exist) when the locker is
+	// created. Otherwise, it must already exist with the columns that CreateTable uses.
+	CreateTable bool
+	// OPTIONAL. The clock to use for lock timestamps and the
+	// heartbeat. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+	// OPTIONAL. How long a lock is held for after it's acquired and after each
+	// heartbeat. This is how long other processes must wait for the lock if the
+	// process holding it dies without unlocking it. Defaults to 20 seconds.
+	LockDuration time.Duration
+	// OPTIONAL. How often the lock's expiry is renewed. It must be less than the lock
+	// duration (including jitter), leaving enough time for the renewal to complete.
+	// Defaults to half of the lock duration.
+	HeartbeatInterval time.Duration
+	// OPTIONAL. The fraction (in the range [0, 1)) that each heartbeat interval is
+	// randomly adjusted by, so that many locks acquired at once don't renew at the
+	// same time. Defaults to 0.
+	HeartbeatJitter float64
+	// OPTIONAL. Whether Lock and LockWait can re-acquire a lock that's already held by the
+	// same process. See DistributedLockerConfig.Reentrant. Defaults to false.
+	Reentrant bool
+	// OPTIONAL. Callbacks for lock lifecycle events.
+	LockCallbacks
+	// OPTIONAL. Statistics collection for the locker's locks.
+	LockStatsConfig
+}
+
+// The columns of a lock row, in the order they're selected
+const postgresLockColumns = "key, version, acquired_unix_nano, expires_unix_nano, logs_url, metadata, fencing_token, hold_count"
+
+// postgresLockBackend stores locks in a Postgres table, with one row (a lease) per lock.
+// Advisory locks aren't used, since they're tied to a database session and can't expire
+// on their own if the holder stops heartbeating.
+type postgresLockBackend struct {
+	db    *sql.DB
+	table string
+	clock dateutils.Clock
+}
+
+// quotePostgresIdentifier quotes a (possibly schema-qualified) identifier.
+func quotePostgresIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+func (pb *postgresLockBackend) createTable(ctx context.Context) stackerr.Error {
+	if _, err := pb.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT PRIMARY KEY,
+	version TEXT NOT NULL,
+	acquired_unix_nano BIGINT NOT NULL,
+	expires_unix_nano BIGINT NOT NULL,
+	logs_url TEXT NOT NULL DEFAULT '',
+	metadata TEXT NOT NULL DEFAULT '',
+	fencing_token BIGINT NOT NULL DEFAULT 0,
+	hold_count BIGINT NOT NULL DEFAULT 1
+)`, pb.table)); err != nil {
+		return stackerr.Wrap(err)
+	}
+	return nil
+}
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLockData scans a row of the lock columns.
+func (pb *postgresLockBackend) scanLockData(row rowScanner) (LockData, error) {
+	var key, version, logsUrl, rawMetadata string
+	var acquiredUnixNano, expiresUnixNano, fencing, holds int64
+	if err := row.Scan(&key, &version, &acquiredUnixNano, &expiresUnixNano, &logsUrl, &rawMetadata, &fencing, &holds); err != nil {
+		return nil, err
+	}
+	metadata := map[string]json.RawMessage{}
+	if rawMetadata != "" {
+		if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+			log.With(
+				"json", rawMetadata,
+			).Errorf("Metadata field in existing lock row is not in valid JSON format")
+		}
+	}
+	expires := dateutils.TimeFromUnix(expiresUnixNano)
+	return lockData{
+		key:      key,
+		version:  version,
+		acquired: dateutils.TimeFromUnix(acquiredUnixNano),
+		expires:  expires,
+		logsUrl:  logsUrl,
+		metadata: metadata,
+		active:   expires.After(pb.clock.Now()),
+		fencing:  fencing,
+		holds:    holds,
+	}, nil
+}
+
+// query runs a query that returns lock rows.
+func (pb *postgresLockBackend) query(ctx context.Context, query string, args ...any) ([]LockData, stackerr.Error) {
+	rows, err := pb.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	defer rows.Close()
+	locks := []LockData{}
+	for rows.Next() {
+		lock, err := pb.scanLockData(rows)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		locks = append(locks, lock)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return locks, nil
+}
+
+func (pb *postgresLockBackend) acquire(ctx context.Context, row lockRow) (fencing int64, existingLock LockData, err stackerr.Error) {
+	// Insert the lock, or take over the existing row if it has expired
+	cerr := pb.db.QueryRowContext(ctx, fmt.Sprintf(`INSERT INTO %[1]s AS l (%[2]s)
+VALUES ($1, $2, $3, $4, $5, $6, 1, 1)
+ON CONFLICT (key) DO UPDATE SET
+	version = EXCLUDED.version,
+	acquired_unix_nano = EXCLUDED.acquired_unix_nano,
+	expires_unix_nano = EXCLUDED.expires_unix_nano,
+	logs_url = EXCLUDED.logs_url,
+	metadata = EXCLUDED.metadata,
+	fencing_token = l.fencing_token + 1,
+	hold_count = 1
+WHERE l.expires_unix_nano <= $7
+RETURNING fencing_token`, pb.table, postgresLockColumns),
+		row.key,
+		row.version,
+		row.acquired.UnixNano(),
+		row.expires.UnixNano(),
+		row.logsUrl,
+		row.metadata,
+		pb.clock.Now().UnixNano(),
+	).Scan(&fencing)
+	if cerr != nil {
+		// If no row was returned, there's already a lock that isn't expired
+		if errors.Is(cerr, sql.ErrNoRows) {
+			existingLock, err := pb.get(ctx, row.key)
+			if err != nil {
+				return 0, nil, err
+			}
+			return 0, existingLock, nil
+		}
+		return 0, nil, stackerr.Wrap(cerr)
+	}
+	return fencing, nil, nil
+}
+
+func (pb *postgresLockBackend) get(ctx context.Context, key string) (LockData, stackerr.Error) {
+	locks, err := pb.getMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) == 0 {
+		return nil, stackerr.Errorf("No existing lock row").WithSingle("key", key)
+	}
+	return locks[0], nil
+}
+
+func (pb *postgresLockBackend) getMany(ctx context.Context, keys []string) ([]LockData, stackerr.Error) {
+	locks := []LockData{}
+	// Limit the number of parameters in each query
+	for start := 0; start < len(keys); start += 1000 {
+		batch := keys[start:numbers.Min(start+1000, len(keys))]
+		placeholders := make([]string, len(batch))
+		args := make([]any, len(batch))
+		for i, key := range batch {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = key
+		}
+		batchLocks, err := pb.query(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE key IN (%s)", postgresLockColumns, pb.table, strings.Join(placeholders, ", ")), args...)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, batchLocks...)
+	}
+	return locks, nil
+}
+
+func (pb *postgresLockBackend) setExpiry(ctx context.Context, key string, version string, expires time.Time) (bool, stackerr.Error) {
+	result, err := pb.db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET expires_unix_nano = $1 WHERE key = $2 AND version = $3", pb.table), expires.UnixNano(), key, version)
+	if err != nil {
+		return false, stackerr.Wrap(err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, stackerr.Wrap(err)
+	}
+	return updated > 0, nil
+}
+
+func (pb *postgresLockBackend) addHolds(ctx context.Context, key string, version string, delta int64) (holds int64, held bool, err stackerr.Error) {
+	query := fmt.Sprintf("UPDATE %s SET hold_count = hold_count + $1 WHERE key = $2 AND version = $3", pb.table)
+	args := []any{delta, key, version}
+	if delta > 0 {
+		// Don't add holds to a lock that has expired
+		query += " AND expires_unix_nano > $4"
+		args = append(args, pb.clock.Now().UnixNano())
+	}
+	if cerr := pb.db.QueryRowContext(ctx, query+" RETURNING hold_count", args...).Scan(&holds); cerr != nil {
+		if errors.Is(cerr, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, stackerr.Wrap(cerr)
+	}
+	return holds, true, nil
+}
+
+func (pb *postgresLockBackend) replace(ctx context.Context, key string, version string, expires time.Time) (previous LockData, err stackerr.Error) {
+	tx, cerr := pb.db.BeginTx(ctx, nil)
+	if cerr != nil {
+		return nil, stackerr.Wrap(cerr)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	previous, cerr = pb.scanLockData(tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE key = $1 FOR UPDATE", postgresLockColumns, pb.table), key))
+	if cerr != nil {
+		if errors.Is(cerr, sql.ErrNoRows) {
+			return nil, stackerr.Wrap(tx.Rollback())
+		}
+		return nil, stackerr.Wrap(cerr)
+	}
+	if _, cerr := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET version = $1, expires_unix_nano = $2 WHERE key = $3", pb.table), version, expires.UnixNano(), key); cerr != nil {
+		return nil, stackerr.Wrap(cerr)
+	}
+	if cerr := tx.Commit(); cerr != nil {
+		return nil, stackerr.Wrap(cerr)
+	}
+	return previous, nil
+}
+
+func (pb *postgresLockBackend) deleteIfExpired(ctx context.Context, key string, now time.Time) (bool, stackerr.Error) {
+	result, err := pb.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = $1 AND expires_unix_nano <= $2", pb.table), key, now.UnixNano())
+	if err != nil {
+		return false, stackerr.Wrap(err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, stackerr.Wrap(err)
+	}
+	return deleted > 0, nil
+}
+
+func (pb *postgresLockBackend) list(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", postgresLockColumns, pb.table)
+	args := []any{}
+	switch typ {
+	case active:
+		query += " WHERE expires_unix_nano > $1"
+		args = append(args, pb.clock.Now().UnixNano())
+	case expired:
+		query += " WHERE expires_unix_nano <= $1"
+		args = append(args, pb.clock.Now().UnixNano())
+	}
+	locks, err := pb.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]LockData{}
+	for _, lock := range locks {
+		result[lock.Key()] = lock
+	}
+	return result, nil
+}
+
+// NewPostgresDistributedLocker creates a new Postgres-based distributed locker, which has the
+// same behaviour as the DynamoDB-based one (see NewDistributedLocker), for infrastructure that
+// uses Postgres (e.g. RDS) instead of DynamoDB.
+func NewPostgresDistributedLocker(ctx context.Context, config PostgresDistributedLockerConfig) (DistributedLocker, stackerr.Error) {
+	if config.DB == nil {
+		return nil, stackerr.Errorf("the `config.DB` field must not be nil")
+	}
+	if config.TableName == "" {
+		config.TableName = "distributed_locks"
+	}
+	if err := validateLockTiming(&config.LockDuration, &config.HeartbeatInterval, config.HeartbeatJitter); err != nil {
+		return nil, err
+	}
+	stats, err := newLockStats(config.LockStatsConfig)
+	if err != nil {
+		return nil, err
+	}
+	clock := dateutils.ClockOrDefault(config.Clock)
+	backend := &postgresLockBackend{
+		db:    config.DB,
+		table: quotePostgresIdentifier(config.TableName),
+		clock: clock,
+	}
+	if config.CreateTable {
+		if err := backend.createTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return &distributedLocker{
+		backend:           backend,
+		clock:             clock,
+		lockDuration:      config.LockDuration,
+		heartbeatInterval: config.HeartbeatInterval,
+		heartbeatJitter:   config.HeartbeatJitter,
+		reentrant:         config.Reentrant,
+		callbacks:         config.LockCallbacks,
+		stats:             stats,
+	}, nil
+}
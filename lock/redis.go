@@ -0,0 +1,474 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// RedisClient is the Redis functionality that the Redis distributed locker needs. It's an
+// interface so that any Redis client library can be used. For example, with go-redis:
+//
+//	type goRedisClient struct {
+//		*redis.Client
+//	}
+//
+//	func (c goRedisClient) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return c.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisClient interface {
+	// Eval runs a Lua script with the given keys and arguments, and returns the result. Integer
+	// replies must be returned as an int64, bulk string replies as a string or []byte, and
+	// array replies as a []any. The scripts that the locker runs never return a nil reply.
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+type RedisDistributedLockerConfig struct {
+	// The Redis client to use.
+	Client RedisClient
+	// OPTIONAL. The prefix for all Redis keys used by the locker. When using Redis Cluster,
+	// it must contain a hash tag (e.g. "{locks}:") so that all keys are in the same slot.
+	// Defaults to "{lock}:".
+	KeyPrefix string
+	// OPTIONAL. The clock to use for lock timestamps and the
+	// heartbeat. If not provided, the real clock will be used.
+	Clock dateutils.Clock
+	// OPTIONAL. How long a lock is held for after it's acquired and after each
+	// heartbeat. This is how long other processes must wait for the lock if the
+	// process holding it dies without unlocking it. Defaults to 20 seconds.
+	LockDuration time.Duration
+	// OPTIONAL. How often the lock's expiry is renewed. It must be less than the lock
+	// duration (including jitter), leaving enough time for the renewal to complete.
+	// Defaults to half of the lock duration.
+	HeartbeatInterval time.Duration
+	// OPTIONAL. The fraction (in the range [0, 1)) that each heartbeat interval is
+	// randomly adjusted by, so that many locks acquired at once don't renew at the
+	// same time. Defaults to 0.
+	HeartbeatJitter float64
+	// OPTIONAL. Whether Lock and LockWait can re-acquire a lock that's already held by the
+	// same process. See DistributedLockerConfig.Reentrant. Defaults to false.
+	Reentrant bool
+	// OPTIONAL. Callbacks for lock lifecycle events.
+	LockCallbacks
+	// OPTIONAL. Statistics collection for the locker's locks.
+	LockStatsConfig
+}
+
+// The fields of the Redis hash that stores each lock
+const (
+	redisKeyField      string = "key"
+	redisVersionField  string = "version"
+	redisAcquiredField string = "acquired"
+	redisExpiresField  string = "expires"
+	redisLogsUrlField  string = "logs_url"
+	redisMetaField     string = "metadata"
+	redisFencingField  string = "fencing"
+	redisHoldsField    string = "holds"
+)
+
+// Acquires a lock if it doesn't exist or has expired. Lua numbers are doubles, so the expiry
+// comparison is only precise to a fraction of a microsecond, but the stored values are exact.
+//
+// KEYS[1] is the lock's hash, and KEYS[2] is the set of all lock keys. ARGV is the current
+// time, then the key, version, acquired time, expiry, logs URL, and metadata.
+const redisAcquireScript = `
+local expires = redis.call('HGET', KEYS[1], 'expires')
+if expires and tonumber(expires) > tonumber(ARGV[1]) then
+	return {0, redis.call('HGETALL', KEYS[1])}
+end
+local fencing = redis.call('HINCRBY', KEYS[1], 'fencing', 1)
+redis.call('HSET', KEYS[1], 'key', ARGV[2], 'version', ARGV[3], 'acquired', ARGV[4], 'expires', ARGV[5], 'logs_url', ARGV[6], 'metadata', ARGV[7], 'holds', 1)
+redis.call('SADD', KEYS[2], ARGV[2])
+return {1, fencing}
+`
+
+// Sets the expiry of a lock, if it still has the given version.
+//
+// KEYS[1] is the lock's hash. ARGV is the version, then the new expiry.
+const redisSetExpiryScript = `
+if redis.call('HGET', KEYS[1], 'version') == ARGV[1] then
+	redis.call('HSET', KEYS[1], 'expires', ARGV[2])
+	return 1
+end
+return 0
+`
+
+// Adds to the hold count of a lock, if it still has the given version and (when adding
+// holds) hasn't expired. It returns whether it did, and the new hold count.
+//
+// KEYS[1] is the lock's hash. ARGV is the version, then the delta, then the current time.
+const redisAddHoldsScript = `
+if redis.call('HGET', KEYS[1], 'version') ~= ARGV[1] then
+	return {0, 0}
+end
+if tonumber(ARGV[2]) > 0 and tonumber(redis.call('HGET', KEYS[1], 'expires')) <= tonumber(ARGV[3]) then
+	return {0, 0}
+end
+return {1, redis.call('HINCRBY', KEYS[1], 'holds', ARGV[2])}
+`
+
+// Gets the hashes of the given locks.
+const redisGetScript = `
+local locks = {}
+for i, key in ipairs(KEYS) do
+	locks[i] = redis.call('HGETALL', key)
+end
+return locks
+`
+
+// Sets the version and expiry of a lock, if it exists, and returns its hash from before.
+//
+// KEYS[1] is the lock's hash. ARGV is the new version, then the new expiry.
+const redisReplaceScript = `
+local previous = redis.call('HGETALL', KEYS[1])
+if #previous > 0 then
+	redis.call('HSET', KEYS[1], 'version', ARGV[1], 'expires', ARGV[2])
+end
+return previous
+`
+
+// Deletes a lock if it has expired.
+//
+// KEYS[1] is the lock's hash, and KEYS[2] is the set of all lock keys. ARGV is
+// the current time, then the lock's key.
+const redisDeleteExpiredScript = `
+local expires = redis.call('HGET', KEYS[1], 'expires')
+if expires and tonumber(expires) <= tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', KEYS[2], ARGV[2])
+	return 1
+end
+return 0
+`
+
+// Gets the keys of all locks. KEYS[1] is the set of all lock keys.
+const redisListScript = `
+return redis.call('SMEMBERS', KEYS[1])
+`
+
+// redisLockBackend stores locks in Redis, with a hash for each lock and
+// a set of all lock keys (so they can be listed without a SCAN).
+type redisLockBackend struct {
+	client    RedisClient
+	keyPrefix string
+	clock     dateutils.Clock
+}
+
+// hashKey gets the Redis key of the hash for a lock.
+func (rb *redisLockBackend) hashKey(key string) string {
+	return rb.keyPrefix + "lock:" + key
+}
+
+// indexKey gets the Redis key of the set of all lock keys.
+func (rb *redisLockBackend) indexKey() string {
+	return rb.keyPrefix + "keys"
+}
+
+func (rb *redisLockBackend) eval(ctx context.Context, script string, keys []string, args ...any) (any, stackerr.Error) {
+	result, err := rb.client.Eval(ctx, script, keys, args...)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return result, nil
+}
+
+// redisString converts a bulk string reply to a string.
+func redisString(v any) (string, stackerr.Error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	default:
+		return "", stackerr.Errorf("expected a Redis string reply, got %T", v)
+	}
+}
+
+// redisArray converts an array reply to a slice.
+func redisArray(v any) ([]any, stackerr.Error) {
+	a, ok := v.([]any)
+	if !ok {
+		return nil, stackerr.Errorf("expected a Redis array reply, got %T", v)
+	}
+	return a, nil
+}
+
+// redisInt converts an integer reply to an int64.
+func redisInt(v any) (int64, stackerr.Error) {
+	n, ok := v.(int64)
+	if !ok {
+		return 0, stackerr.Errorf("expected a Redis integer reply, got %T", v)
+	}
+	return n, nil
+}
+
+// parseLockData parses the HGETALL reply for a lock's hash.
+func (rb *redisLockBackend) parseLockData(reply any) (LockData, stackerr.Error) {
+	pairs, err := redisArray(reply)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		field, err := redisString(pairs[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := redisString(pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		fields[field] = value
+	}
+
+	key, ok := fields[redisKeyField]
+	if !ok {
+		return nil, stackerr.Errorf("No key field in existing lock hash")
+	}
+	version, ok := fields[redisVersionField]
+	if !ok {
+		return nil, stackerr.Errorf("No version field in existing lock hash")
+	}
+	acquiredUnixNano, cerr := strconv.ParseInt(fields[redisAcquiredField], 10, 64)
+	if cerr != nil {
+		return nil, stackerr.Errorf("Acquired field in existing lock hash is not of expected type")
+	}
+	expiresUnixNano, cerr := strconv.ParseInt(fields[redisExpiresField], 10, 64)
+	if cerr != nil {
+		return nil, stackerr.Errorf("Expires field in existing lock hash is not of expected type")
+	}
+	fencing, cerr := strconv.ParseInt(fields[redisFencingField], 10, 64)
+	if cerr != nil {
+		return nil, stackerr.Errorf("Fencing token field in existing lock hash is not of expected type")
+	}
+
+	// Locks that haven't been acquired since hold counts were added are held once
+	holds := int64(1)
+	if rawHolds, ok := fields[redisHoldsField]; ok {
+		if holds, cerr = strconv.ParseInt(rawHolds, 10, 64); cerr != nil {
+			return nil, stackerr.Errorf("Hold count field in existing lock hash is not of expected type")
+		}
+	}
+
+	metadata := map[string]json.RawMessage{}
+	if rawMetadata := fields[redisMetaField]; rawMetadata != "" {
+		if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+			log.With(
+				"json", rawMetadata,
+			).Errorf("Metadata field in existing lock hash is not in valid JSON format")
+		}
+	}
+
+	expires := dateutils.TimeFromUnix(expiresUnixNano)
+	return lockData{
+		key:      key,
+		version:  version,
+		acquired: dateutils.TimeFromUnix(acquiredUnixNano),
+		expires:  expires,
+		logsUrl:  fields[redisLogsUrlField],
+		metadata: metadata,
+		active:   expires.After(rb.clock.Now()),
+		fencing:  fencing,
+		holds:    holds,
+	}, nil
+}
+
+func (rb *redisLockBackend) acquire(ctx context.Context, row lockRow) (fencing int64, existingLock LockData, err stackerr.Error) {
+	reply, err := rb.eval(ctx, redisAcquireScript, []string{rb.hashKey(row.key), rb.indexKey()},
+		rb.clock.Now().UnixNano(),
+		row.key,
+		row.version,
+		row.acquired.UnixNano(),
+		row.expires.UnixNano(),
+		row.logsUrl,
+		row.metadata,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	result, err := redisArray(reply)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(result) != 2 {
+		return 0, nil, stackerr.Errorf("expected 2 values from the lock acquisition script, got %d", len(result))
+	}
+	acquired, err := redisInt(result[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if acquired == 0 {
+		existingLock, err := rb.parseLockData(result[1])
+		if err != nil {
+			return 0, nil, err.WithSingle("key", row.key)
+		}
+		return 0, existingLock, nil
+	}
+	fencing, err = redisInt(result[1])
+	if err != nil {
+		return 0, nil, err
+	}
+	return fencing, nil, nil
+}
+
+func (rb *redisLockBackend) get(ctx context.Context, key string) (LockData, stackerr.Error) {
+	locks, err := rb.getMany(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) == 0 {
+		return nil, stackerr.Errorf("No existing lock hash").WithSingle("key", key)
+	}
+	return locks[0], nil
+}
+
+func (rb *redisLockBackend) getMany(ctx context.Context, keys []string) ([]LockData, stackerr.Error) {
+	if len(keys) == 0 {
+		return []LockData{}, nil
+	}
+	reply, err := rb.eval(ctx, redisGetScript, collections.TransformSlice(keys, rb.hashKey))
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := redisArray(reply)
+	if err != nil {
+		return nil, err
+	}
+	locks := make([]LockData, 0, len(hashes))
+	for i, hash := range hashes {
+		// Locks that don't exist have an empty hash
+		if fields, ok := hash.([]any); ok && len(fields) == 0 {
+			continue
+		}
+		lock, err := rb.parseLockData(hash)
+		if err != nil {
+			return nil, err.WithSingle("key", keys[i])
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func (rb *redisLockBackend) setExpiry(ctx context.Context, key string, version string, expires time.Time) (bool, stackerr.Error) {
+	reply, err := rb.eval(ctx, redisSetExpiryScript, []string{rb.hashKey(key)}, version, expires.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	updated, err := redisInt(reply)
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+func (rb *redisLockBackend) addHolds(ctx context.Context, key string, version string, delta int64) (holds int64, held bool, err stackerr.Error) {
+	reply, err := rb.eval(ctx, redisAddHoldsScript, []string{rb.hashKey(key)}, version, delta, rb.clock.Now().UnixNano())
+	if err != nil {
+		return 0, false, err
+	}
+	result, err := redisArray(reply)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(result) != 2 {
+		return 0, false, stackerr.Errorf("expected 2 values from the lock hold script, got %d", len(result))
+	}
+	updated, err := redisInt(result[0])
+	if err != nil {
+		return 0, false, err
+	}
+	if holds, err = redisInt(result[1]); err != nil {
+		return 0, false, err
+	}
+	return holds, updated == 1, nil
+}
+
+func (rb *redisLockBackend) list(ctx context.Context, typ lockType) (map[string]LockData, stackerr.Error) {
+	reply, err := rb.eval(ctx, redisListScript, []string{rb.indexKey()})
+	if err != nil {
+		return nil, err
+	}
+	members, err := redisArray(reply)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	for i, member := range members {
+		if keys[i], err = redisString(member); err != nil {
+			return nil, err
+		}
+	}
+	locks, err := rb.getMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]LockData{}
+	for _, lock := range locks {
+		if typ == all || (typ == active) == lock.Active() {
+			result[lock.Key()] = lock
+		}
+	}
+	return result, nil
+}
+
+func (rb *redisLockBackend) replace(ctx context.Context, key string, version string, expires time.Time) (LockData, stackerr.Error) {
+	reply, err := rb.eval(ctx, redisReplaceScript, []string{rb.hashKey(key)}, version, expires.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if fields, ok := reply.([]any); ok && len(fields) == 0 {
+		return nil, nil
+	}
+	return rb.parseLockData(reply)
+}
+
+func (rb *redisLockBackend) deleteIfExpired(ctx context.Context, key string, now time.Time) (bool, stackerr.Error) {
+	reply, err := rb.eval(ctx, redisDeleteExpiredScript, []string{rb.hashKey(key), rb.indexKey()}, now.UnixNano(), key)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := redisInt(reply)
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// NewRedisDistributedLocker creates a new Redis-based distributed locker, which has the same
+// behaviour as the DynamoDB-based one (see NewDistributedLocker), for use outside of AWS.
+func NewRedisDistributedLocker(config RedisDistributedLockerConfig) (DistributedLocker, stackerr.Error) {
+	if config.Client == nil {
+		return nil, stackerr.Errorf("the `config.Client` field must not be nil")
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "{lock}:"
+	}
+	if err := validateLockTiming(&config.LockDuration, &config.HeartbeatInterval, config.HeartbeatJitter); err != nil {
+		return nil, err
+	}
+	stats, err := newLockStats(config.LockStatsConfig)
+	if err != nil {
+		return nil, err
+	}
+	clock := dateutils.ClockOrDefault(config.Clock)
+	return &distributedLocker{
+		backend: &redisLockBackend{
+			client:    config.Client,
+			keyPrefix: config.KeyPrefix,
+			clock:     clock,
+		},
+		clock:             clock,
+		lockDuration:      config.LockDuration,
+		heartbeatInterval: config.HeartbeatInterval,
+		heartbeatJitter:   config.HeartbeatJitter,
+		reentrant:         config.Reentrant,
+		callbacks:         config.LockCallbacks,
+		stats:             stats,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// DistributedSemaphore allows up to a fixed number of concurrent holders for each key, across
+// multiple processes. Each holder has its own heartbeat and expiry, exactly like a DistributedLock.
+type DistributedSemaphore interface {
+	/*
+		Acquire will attempt to acquire one of the semaphore's slots for the given key.
+
+		Arguments:
+
+		ctx - the context to use for all operations. If the context is cancelled, the slot
+		will not be updated and will eventually expire.
+
+		key - the key of the semaphore to acquire a slot of.
+
+		metadata - a map of metadata that should be stored with the slot.
+
+		Return Values:
+
+		newCtx - a context that will be cancelled if the heartbeat fails (but will NOT be
+		cancelled if the heartbeat exits because the slot was intentionally released).
+
+		newLock - the slot that was acquired, which must be unlocked to release it. If all
+		slots are already held, this will be nil.
+
+		err - an error generated by this function
+	*/
+	Acquire(ctx context.Context, key string, metadata map[string]any) (newCtx context.Context, newLock DistributedLock, err stackerr.Error)
+
+	// AcquireWait is the same as Acquire, except that if all slots are held, it waits (polling
+	// with a backoff, as with DistributedLocker.LockWait) until a slot can be acquired.
+	AcquireWait(ctx context.Context, key string, metadata map[string]any, input LockWaitInput) (newCtx context.Context, newLock DistributedLock, err stackerr.Error)
+
+	// Holders gets the active holders of the semaphore's slots for the given key.
+	Holders(ctx context.Context, key string) ([]LockData, stackerr.Error)
+
+	// Limit returns the maximum number of concurrent holders for each key.
+	Limit() int
+}
+
+type DistributedSemaphoreConfig struct {
+	// The config for the underlying locks. Each slot of the semaphore is stored in
+	// the lock table as a separate lock, with a key of "<key>#<slot number>".
+	DistributedLockerConfig
+	// The maximum number of concurrent holders for each key. All processes
+	// using the same semaphore keys must use the same limit.
+	Limit int
+}
+
+type distributedSemaphore struct {
+	locker *distributedLocker
+	limit  int
+}
+
+// NewDistributedSemaphore creates a new DynamoDB-based distributed semaphore, which uses
+// the same table scheme as a distributed locker.
+func NewDistributedSemaphore(ctx context.Context, config DistributedSemaphoreConfig) (DistributedSemaphore, stackerr.Error) {
+	if config.Limit < 1 {
+		return nil, stackerr.Errorf("the `config.Limit` field must be at least 1, got %d", config.Limit)
+	}
+	// Each acquisition must take a separate slot, so slots can't be re-acquired
+	config.Reentrant = false
+	locker, err := NewDistributedLocker(ctx, config.DistributedLockerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &distributedSemaphore{
+		locker: locker.(*distributedLocker),
+		limit:  config.Limit,
+	}, nil
+}
+
+func (ds *distributedSemaphore) Limit() int {
+	return ds.limit
+}
+
+// slotKey gets the lock key for a slot of the semaphore.
+func (ds *distributedSemaphore) slotKey(key string, slot int) string {
+	return fmt.Sprintf("%s#%d", key, slot)
+}
+
+// tryAcquire tries each slot once, starting from a random slot so that concurrent
+// callers don't all contend for the same slots. If no slot could be acquired, it
+// returns the earliest expiry of the held slots.
+func (ds *distributedSemaphore) tryAcquire(ctx context.Context, key string, metadata map[string]any) (context.Context, DistributedLock, time.Time, stackerr.Error) {
+	start := numbers.RandomIntInRange(0, ds.limit-1)
+	var earliestExpiry time.Time
+	for i := 0; i < ds.limit; i++ {
+		slot := (start + i) % ds.limit
+		newCtx, newLock, existingLock, err := ds.locker.acquire(ctx, ds.slotKey(key, slot), metadata, false)
+		if err != nil {
+			return ctx, nil, time.Time{}, err.WithSingle("semaphore_slot", slot)
+		}
+		if newLock != nil {
+			return newCtx, newLock, time.Time{}, nil
+		}
+		if earliestExpiry.IsZero() || existingLock.Expires().Before(earliestExpiry) {
+			earliestExpiry = existingLock.Expires()
+		}
+	}
+	return ctx, nil, earliestExpiry, nil
+}
+
+func (ds *distributedSemaphore) Acquire(ctx context.Context, key string, metadata map[string]any) (newCtx context.Context, newLock DistributedLock, err stackerr.Error) {
+	newCtx, newLock, _, err = ds.tryAcquire(ctx, key, metadata)
+	return newCtx, newLock, err
+}
+
+func (ds *distributedSemaphore) AcquireWait(ctx context.Context, key string, metadata map[string]any, input LockWaitInput) (newCtx context.Context, newLock DistributedLock, err stackerr.Error) {
+	backoff := input.newBackoff(ds.locker.clock)
+
+	for {
+		newCtx, newLock, earliestExpiry, err := ds.tryAcquire(ctx, key, metadata)
+		if err != nil {
+			return ctx, nil, err
+		}
+		if newLock != nil {
+			return newCtx, newLock, nil
+		}
+
+		if err := waitForLockRetry(ctx, ds.locker.clock, backoff, earliestExpiry); err != nil {
+			return ctx, nil, err.WithSingle("semaphore_key", key)
+		}
+	}
+}
+
+func (ds *distributedSemaphore) Holders(ctx context.Context, key string) ([]LockData, stackerr.Error) {
+	keys := make([]string, ds.limit)
+	for slot := range keys {
+		keys[slot] = ds.slotKey(key, slot)
+	}
+	locks, err := ds.locker.backend.getMany(ctx, keys)
+	if err != nil {
+		return nil, err.WithSingle("semaphore_key", key)
+	}
+	return collections.FilterSlice(locks, func(lock LockData) bool {
+		return lock.Active()
+	}), nil
+}
@@ -0,0 +1,199 @@
+package lock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/metrics"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// LockStatsConfig configures the statistics that a locker collects about its locks.
+type LockStatsConfig struct {
+	// OPTIONAL. Whether to collect statistics for each lock key, which are available
+	// from DistributedLocker.Stats. Defaults to false.
+	CollectStats bool `json:"collect_stats"`
+	// OPTIONAL. A metrics registry to also record the statistics in (with the lock key as a
+	// label), e.g. so they can be flushed to CloudWatch with metrics.NewEMFExporter. If
+	// provided, statistics are collected regardless of CollectStats. Since each key is a
+	// separate series, this should only be used with a small, fixed set of keys.
+	StatsRegistry metrics.Registry `json:"-"`
+}
+
+// LockKeyStats are the statistics for a single lock key, since the locker was created.
+type LockKeyStats struct {
+	// The number of attempts to acquire the lock
+	Attempts int64
+	// The number of attempts that acquired the lock
+	Acquired int64
+	// The number of attempts that failed because the lock was already held
+	Contended int64
+	// The number of attempts that failed with an error
+	Errors int64
+	// The number of times the lock was lost (its heartbeat failed) before it was released
+	Lost int64
+	// The number of times the lock was released
+	Released int64
+	// The time (in seconds) that LockWait waited before acquiring the lock
+	WaitSeconds numbers.Histogram
+	// The time (in seconds) that the lock was held for, until it was released or lost
+	HoldSeconds numbers.Histogram
+}
+
+// The histogram buckets for lock wait and hold times, from 10ms to about 11.6 hours
+var lockDurationBuckets, _ = numbers.ExponentialBuckets(0.01, 4, 12)
+
+// lockMetrics are the metrics that lock statistics are recorded in.
+type lockMetrics struct {
+	attempts    metrics.Counter
+	acquired    metrics.Counter
+	contended   metrics.Counter
+	errors      metrics.Counter
+	lost        metrics.Counter
+	released    metrics.Counter
+	waitSeconds metrics.Histogram
+	holdSeconds metrics.Histogram
+}
+
+// lockStats collects the statistics of a locker's locks. A nil *lockStats
+// is valid, and doesn't collect anything.
+type lockStats struct {
+	lock    sync.Mutex
+	keys    map[string]*LockKeyStats
+	metrics *lockMetrics
+}
+
+// newLockStats creates a statistics collector for a locker. It returns nil if
+// statistics aren't enabled.
+func newLockStats(config LockStatsConfig) (*lockStats, stackerr.Error) {
+	if !config.CollectStats && config.StatsRegistry == nil {
+		return nil, nil
+	}
+	stats := &lockStats{
+		keys: map[string]*LockKeyStats{},
+	}
+	if registry := config.StatsRegistry; registry != nil {
+		lm := &lockMetrics{}
+		var err stackerr.Error
+		for _, counter := range []struct {
+			counter *metrics.Counter
+			name    string
+			help    string
+		}{
+			{&lm.attempts, "distributed_lock_attempts_total", "The number of attempts to acquire a distributed lock"},
+			{&lm.acquired, "distributed_lock_acquired_total", "The number of times a distributed lock was acquired"},
+			{&lm.contended, "distributed_lock_contended_total", "The number of lock attempts that failed because the lock was already held"},
+			{&lm.errors, "distributed_lock_errors_total", "The number of lock attempts that failed with an error"},
+			{&lm.lost, "distributed_lock_lost_total", "The number of times a distributed lock was lost before it was released"},
+			{&lm.released, "distributed_lock_released_total", "The number of times a distributed lock was released"},
+		} {
+			if *counter.counter, err = registry.Counter(counter.name, counter.help); err != nil {
+				return nil, err
+			}
+		}
+		if lm.waitSeconds, err = registry.Histogram("distributed_lock_wait_seconds", "The time waited to acquire a distributed lock", lockDurationBuckets); err != nil {
+			return nil, err
+		}
+		if lm.holdSeconds, err = registry.Histogram("distributed_lock_hold_seconds", "The time a distributed lock was held for", lockDurationBuckets); err != nil {
+			return nil, err
+		}
+		stats.metrics = lm
+	}
+	return stats, nil
+}
+
+// update updates the statistics for a key, and records the metric (if there
+// are metrics) with the key as a label.
+func (ls *lockStats) update(key string, f func(stats *LockKeyStats), metric func(lm *lockMetrics, labels metrics.Labels)) {
+	if ls == nil {
+		return
+	}
+	ls.lock.Lock()
+	stats, ok := ls.keys[key]
+	if !ok {
+		// The buckets are valid, so this can't fail
+		waitSeconds, _ := numbers.NewHistogram(lockDurationBuckets)
+		holdSeconds, _ := numbers.NewHistogram(lockDurationBuckets)
+		stats = &LockKeyStats{
+			WaitSeconds: waitSeconds,
+			HoldSeconds: holdSeconds,
+		}
+		ls.keys[key] = stats
+	}
+	f(stats)
+	ls.lock.Unlock()
+	if ls.metrics != nil {
+		metric(ls.metrics, metrics.Labels{"key": key})
+	}
+}
+
+// attempted records an attempt to acquire a lock.
+func (ls *lockStats) attempted(key string, acquired bool, err stackerr.Error) {
+	ls.update(key, func(stats *LockKeyStats) {
+		stats.Attempts++
+		switch {
+		case err != nil:
+			stats.Errors++
+		case acquired:
+			stats.Acquired++
+		default:
+			stats.Contended++
+		}
+	}, func(lm *lockMetrics, labels metrics.Labels) {
+		lm.attempts.Inc(labels)
+		switch {
+		case err != nil:
+			lm.errors.Inc(labels)
+		case acquired:
+			lm.acquired.Inc(labels)
+		default:
+			lm.contended.Inc(labels)
+		}
+	})
+}
+
+// waited records the time that was waited to acquire a lock.
+func (ls *lockStats) waited(key string, wait time.Duration) {
+	ls.update(key, func(stats *LockKeyStats) {
+		stats.WaitSeconds.Observe(wait.Seconds())
+	}, func(lm *lockMetrics, labels metrics.Labels) {
+		lm.waitSeconds.Observe(wait.Seconds(), labels)
+	})
+}
+
+// ended records a lock being released, or being lost if lost is true.
+func (ls *lockStats) ended(key string, held time.Duration, lost bool) {
+	ls.update(key, func(stats *LockKeyStats) {
+		if lost {
+			stats.Lost++
+		} else {
+			stats.Released++
+		}
+		stats.HoldSeconds.Observe(held.Seconds())
+	}, func(lm *lockMetrics, labels metrics.Labels) {
+		if lost {
+			lm.lost.Inc(labels)
+		} else {
+			lm.released.Inc(labels)
+		}
+		lm.holdSeconds.Observe(held.Seconds(), labels)
+	})
+}
+
+// snapshot gets a copy of the statistics for all keys.
+func (ls *lockStats) snapshot() map[string]LockKeyStats {
+	snapshot := map[string]LockKeyStats{}
+	if ls == nil {
+		return snapshot
+	}
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	for key, stats := range ls.keys {
+		s := *stats
+		s.WaitSeconds = stats.WaitSeconds.Clone()
+		s.HoldSeconds = stats.HoldSeconds.Clone()
+		snapshot[key] = s
+	}
+	return snapshot
+}
@@ -0,0 +1,130 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type LockEventType string
+
+const (
+	// The lock's state when the watch started. The event's lock is nil if there's no lock.
+	LockEventInitial LockEventType = "initial"
+	// The lock was acquired (including by a different holder than before)
+	LockEventAcquired LockEventType = "acquired"
+	// The lock's expiry was renewed by its holder
+	LockEventRenewed LockEventType = "renewed"
+	// The lock was released (unlocked, force-unlocked, or purged)
+	LockEventReleased LockEventType = "released"
+	// The lock expired without being released (e.g. its holder died)
+	LockEventExpired LockEventType = "expired"
+)
+
+// LockEvent is a change to a watched lock.
+type LockEvent struct {
+	Type LockEventType
+	// The lock after the change. For a release by purging the lock, it's
+	// the lock before the change, since the lock no longer exists.
+	Lock LockData
+}
+
+type WatchLockInput struct {
+	// OPTIONAL. How often to check the lock for changes. Changes that are undone within
+	// the interval (e.g. the lock is acquired and released) aren't seen, and renewals
+	// are only seen once per interval. Defaults to 1 second.
+	PollInterval time.Duration
+}
+
+// lockEvent gets the event for the change in a lock between two polls, if there was one.
+// Either lock may be nil if there was no lock.
+func lockEvent(previous LockData, current LockData) (LockEvent, bool) {
+	switch {
+	case current == nil:
+		if previous != nil && previous.Active() {
+			return LockEvent{Type: LockEventReleased, Lock: previous}, true
+		}
+	case current.Active():
+		if previous == nil || !previous.Active() || previous.Version() != current.Version() {
+			return LockEvent{Type: LockEventAcquired, Lock: current}, true
+		}
+		if !current.Expires().Equal(previous.Expires()) {
+			return LockEvent{Type: LockEventRenewed, Lock: current}, true
+		}
+	case previous != nil && previous.Active():
+		// Unlocking (and force-unlocking) sets the expiry to the time of the release,
+		// so a lock whose expiry didn't change expired instead
+		if current.Version() == previous.Version() && current.Expires().Equal(previous.Expires()) {
+			return LockEvent{Type: LockEventExpired, Lock: current}, true
+		}
+		return LockEvent{Type: LockEventReleased, Lock: current}, true
+	}
+	return LockEvent{}, false
+}
+
+func (dl *distributedLocker) WatchLock(ctx context.Context, key string, input WatchLockInput) (<-chan LockEvent, stackerr.Error) {
+	if input.PollInterval <= 0 {
+		input.PollInterval = time.Second
+	}
+
+	poll := func() (LockData, stackerr.Error) {
+		locks, err := dl.backend.getMany(ctx, []string{key})
+		if err != nil {
+			return nil, err.WithSingle("lock_key", key)
+		}
+		if len(locks) == 0 {
+			return nil, nil
+		}
+		return locks[0], nil
+	}
+
+	// Get the initial state before returning, so that errors (e.g. bad
+	// permissions) are returned instead of only being logged
+	previous, err := poll()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan LockEvent)
+	go func() {
+		defer close(events)
+		send := func(event LockEvent) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case events <- event:
+				return true
+			}
+		}
+		if !send(LockEvent{Type: LockEventInitial, Lock: previous}) {
+			return
+		}
+		for {
+			timer := dl.clock.NewTimer(input.PollInterval)
+			select {
+			case <-ctx.Done():
+				if !timer.Stop() {
+					<-timer.C()
+				}
+				return
+			case <-timer.C():
+			}
+
+			current, err := poll()
+			if err != nil {
+				// Keep watching, since the error may be temporary
+				log.FromContext(ctx).Error(err)
+				continue
+			}
+			if event, ok := lockEvent(previous, current); ok {
+				if !send(event) {
+					return
+				}
+			}
+			previous = current
+		}
+	}()
+	return events, nil
+}
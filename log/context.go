@@ -4,7 +4,7 @@ import (
 	"context"
 )
 
-type contextLogKeyType any
+type contextLogKeyType struct{}
 
 // Use a unique type so that there will never be a conflict with a different key
 var contextLogKey contextLogKeyType
@@ -1,6 +1,10 @@
 package log
 
 import (
+	"context"
+	"sync/atomic"
+	"time"
+
 	"github.com/Invicton-Labs/go-common/collections"
 	"github.com/Invicton-Labs/go-stackerr"
 	"go.uber.org/multierr"
@@ -9,6 +13,25 @@ import (
 
 type ZapWriteHook func(e zapcore.Entry, logFields map[string]zapcore.Field, errs []StackError, stacktraces stackerr.Stacks) stackerr.Error
 
+// The number of write hook calls that are currently in progress, across all loggers.
+var writeHooksInFlight atomic.Int64
+
+// DrainWriteHooks will wait until all write hooks that are currently in progress (on any
+// logger) have finished, or until the context is done. This is useful before a process exits,
+// so that alerts being sent by hooks (e.g. Slack) aren't lost.
+func DrainWriteHooks(ctx context.Context) stackerr.Error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for writeHooksInFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return stackerr.Wrap(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
 type stackTrace struct {
 	stack       stackerr.Stack
 	useAsCaller bool
@@ -217,11 +240,7 @@ func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 
 	// Call each hook
 	ent.LoggerName = c.name
-	for _, hook := range c.getWriteHooks() {
-		if err := hook(ent, logFields, stackErrs, stacktraces); err != nil {
-			errs = append(errs, err)
-		}
-	}
+	errs = append(errs, c.callWriteHooks(ent, logFields, stackErrs, stacktraces)...)
 
 	// Wrap all errors into a multi error
 	if len(errs) > 0 {
@@ -240,6 +259,19 @@ func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	return nil
 }
 
+// callWriteHooks calls each write hook, tracking that they're in progress
+// so that they can be drained with DrainWriteHooks.
+func (c *core) callWriteHooks(ent zapcore.Entry, logFields map[string]zapcore.Field, stackErrs []StackError, stacktraces stackerr.Stacks) (errs []error) {
+	writeHooksInFlight.Add(1)
+	defer writeHooksInFlight.Add(-1)
+	for _, hook := range c.getWriteHooks() {
+		if err := hook(ent, logFields, stackErrs, stacktraces); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 func (c *core) Sync() error {
 	return c.out.Sync()
 }
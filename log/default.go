@@ -117,6 +117,8 @@ var WithOptions func(opts ...zap.Option) Logger
 var WithError func(err error) Logger
 var WithStackTrace func(stack stackerr.Stack, useAsCaller bool) Logger
 
+var Sync func() error
+
 // InitDefault will create a new logger with the given settings
 // and will set it as the default global logger. This function
 // IS NOT thread-safe and cannot be used while other routines
@@ -157,6 +159,8 @@ func InitDefault(input NewInput) stackerr.Error {
 	WithError = defaultLogger.WithError
 	WithStackTrace = defaultLogger.WithStackTrace
 
+	Sync = defaultLogger.Sync
+
 	var err stackerr.Error
 	// Run all hooks
 	defaultLoggerHooks.Range(func(key string, hook defaultLoggerHook) bool {
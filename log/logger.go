@@ -59,6 +59,9 @@ type Logger interface {
 
 	// Clone returns a copy of the logger
 	Clone() Logger
+
+	// Sync flushes any buffered log entries
+	Sync() error
 }
 
 type logger struct {
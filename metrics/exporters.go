@@ -0,0 +1,316 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+	"go.uber.org/multierr"
+)
+
+// Exporter exports snapshots of metrics to some destination.
+type Exporter interface {
+	Export(ctx context.Context, families []Family) stackerr.Error
+}
+
+type NewEMFExporterInput struct {
+	// The CloudWatch namespace to put the metrics in
+	Namespace string
+	// OPTIONAL. Where to write the EMF lines. Defaults to stdout.
+	Writer io.Writer
+}
+
+type emfExporter struct {
+	namespace string
+	writer    io.Writer
+	lock      sync.Mutex
+	// The previously exported cumulative values, used to convert
+	// counters into deltas since CloudWatch sums the values it receives.
+	previous map[string]float64
+}
+
+// NewEMFExporter creates an exporter that writes metrics as JSON lines in the
+// CloudWatch Embedded Metric Format. When running in Lambda or with the CloudWatch
+// agent, these lines are automatically extracted into CloudWatch metrics.
+// The lines are written directly instead of through a logger, since log sampling
+// would silently drop metrics and the development console encoder doesn't produce JSON.
+// Each labelled series is written as a separate line, with the labels as dimensions.
+// Counters and histogram counts/sums are exported as the change since the previous export.
+func NewEMFExporter(input NewEMFExporterInput) (Exporter, stackerr.Error) {
+	if input.Namespace == "" {
+		return nil, stackerr.Errorf("the `input.Namespace` field must not be empty")
+	}
+	if input.Writer == nil {
+		input.Writer = os.Stdout
+	}
+	return &emfExporter{
+		namespace: input.Namespace,
+		writer:    input.Writer,
+		previous:  map[string]float64{},
+	}, nil
+}
+
+// delta returns the change in a cumulative value since the last export. The lock must be held.
+func (e *emfExporter) delta(key string, value float64) float64 {
+	prev := e.previous[key]
+	e.previous[key] = value
+	// If the value went down, the metric was reset
+	if value < prev {
+		return value
+	}
+	return value - prev
+}
+
+func (e *emfExporter) Export(ctx context.Context, families []Family) stackerr.Error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	timestamp := time.Now().UnixMilli()
+	errs := []error{}
+	for _, f := range families {
+		for _, s := range f.Series {
+			dimensions := collections.MapKeys(s.Labels)
+			collections.SortSliceAscendingInPlace(dimensions)
+			key := f.Name + "\x00" + labelsKey(s.Labels)
+
+			values := map[string]float64{}
+			switch f.Type {
+			case CounterType:
+				values[f.Name] = e.delta(key, s.Value)
+			case GaugeType:
+				values[f.Name] = s.Value
+			case HistogramType:
+				values[f.Name+"_count"] = e.delta(key+"\x00count", float64(s.Count))
+				values[f.Name+"_sum"] = e.delta(key+"\x00sum", s.Sum)
+			}
+
+			metricDefinitions := make([]map[string]string, 0, len(values))
+			line := make(map[string]interface{}, 1+len(values)+len(s.Labels))
+			for _, name := range collections.SortSliceAscendingCopy(collections.MapKeys(values)) {
+				metricDefinitions = append(metricDefinitions, map[string]string{
+					"Name": name,
+				})
+				line[name] = values[name]
+			}
+			for _, dimension := range dimensions {
+				line[dimension] = s.Labels[dimension]
+			}
+			line["_aws"] = map[string]interface{}{
+				"Timestamp": timestamp,
+				"CloudWatchMetrics": []map[string]interface{}{
+					{
+						"Namespace":  e.namespace,
+						"Dimensions": [][]string{dimensions},
+						"Metrics":    metricDefinitions,
+					},
+				},
+			}
+			encoded, err := json.Marshal(line)
+			if err != nil {
+				errs = append(errs, stackerr.Wrap(err))
+				continue
+			}
+			// Write each line in a single call so that concurrent
+			// output to the same writer can't interleave with it.
+			if _, err := e.writer.Write(append(encoded, '\n')); err != nil {
+				return stackerr.Wrap(err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return stackerr.Wrap(multierr.Combine(errs...))
+	}
+	return nil
+}
+
+type NewLogExporterInput struct {
+	// OPTIONAL. The logger to write the metrics to. If not provided,
+	// the logger from the context (or the default logger) will be used.
+	Logger log.Logger
+}
+
+type logExporter struct {
+	logger log.Logger
+}
+
+// NewLogExporter creates an exporter that writes the current value
+// of each labelled series as a plain structured log line.
+func NewLogExporter(input NewLogExporterInput) Exporter {
+	return &logExporter{
+		logger: input.Logger,
+	}
+}
+
+func (e *logExporter) Export(ctx context.Context, families []Family) stackerr.Error {
+	logger := e.logger
+	if logger == nil {
+		logger = log.FromContext(ctx)
+	}
+	for _, f := range families {
+		for _, s := range f.Series {
+			keysAndValues := []interface{}{
+				"metric_name", f.Name,
+				"metric_type", string(f.Type),
+				"labels", s.Labels,
+			}
+			if f.Type == HistogramType {
+				buckets := make(map[string]uint64, len(s.Buckets))
+				for _, b := range s.Buckets {
+					buckets[formatFloat(b.UpperBound)] = b.Count
+				}
+				keysAndValues = append(keysAndValues,
+					"count", s.Count,
+					"sum", s.Sum,
+					"buckets", buckets,
+				)
+			} else {
+				keysAndValues = append(keysAndValues, "value", s.Value)
+			}
+			logger.Infow("metric: "+f.Name, keysAndValues...)
+		}
+	}
+	return nil
+}
+
+// formatFloat formats a float in the way that the Prometheus text format expects.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// writePrometheusSeries writes a single line in the Prometheus text format.
+func writePrometheusSeries(b *strings.Builder, name string, labels Labels, extraLabelKey string, extraLabelValue string, value string) {
+	b.WriteString(name)
+	keys := collections.MapKeys(labels)
+	collections.SortSliceAscendingInPlace(keys)
+	if extraLabelKey != "" {
+		keys = append(keys, extraLabelKey)
+	}
+	if len(keys) > 0 {
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			v := labels[k]
+			if k == extraLabelKey {
+				v = extraLabelValue
+			}
+			b.WriteString(k)
+			b.WriteString(`="`)
+			b.WriteString(labelValueReplacer.Replace(v))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// FormatPrometheus formats the metrics in the Prometheus text exposition format.
+func FormatPrometheus(families []Family) string {
+	b := strings.Builder{}
+	for _, f := range families {
+		if f.Help != "" {
+			b.WriteString("# HELP " + f.Name + " " + helpReplacer.Replace(f.Help) + "\n")
+		}
+		b.WriteString("# TYPE " + f.Name + " " + string(f.Type) + "\n")
+		for _, s := range f.Series {
+			if f.Type != HistogramType {
+				writePrometheusSeries(&b, f.Name, s.Labels, "", "", formatFloat(s.Value))
+				continue
+			}
+			for _, bucket := range s.Buckets {
+				writePrometheusSeries(&b, f.Name+"_bucket", s.Labels, "le", formatFloat(bucket.UpperBound), strconv.FormatUint(bucket.Count, 10))
+			}
+			writePrometheusSeries(&b, f.Name+"_sum", s.Labels, "", "", formatFloat(s.Sum))
+			writePrometheusSeries(&b, f.Name+"_count", s.Labels, "", "", strconv.FormatUint(s.Count, 10))
+		}
+	}
+	return b.String()
+}
+
+// NewPrometheusHandler creates an HTTP handler that serves the metrics in the
+// registry in the Prometheus text exposition format. If the registry is nil,
+// the default registry will be used.
+func NewPrometheusHandler(registry Registry) http.Handler {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(FormatPrometheus(registry.Snapshot())))
+	})
+}
+
+type ExportPeriodicallyInput struct {
+	// OPTIONAL. The registry to export. If not provided, the default registry will be used.
+	Registry Registry
+	// The exporters to export to
+	Exporters []Exporter
+	// OPTIONAL. How often to export. Defaults to 1 minute.
+	Interval time.Duration
+}
+
+// ExportPeriodically exports the registry to all of the exporters at the given interval,
+// until the context is done. A final export is done after the context is done so that
+// no metrics are lost on shutdown. Export errors are logged and do not stop the exporting.
+func ExportPeriodically(ctx context.Context, input ExportPeriodicallyInput) stackerr.Error {
+	if len(input.Exporters) == 0 {
+		return stackerr.Errorf("the `input.Exporters` field must not be empty")
+	}
+	if input.Registry == nil {
+		input.Registry = DefaultRegistry
+	}
+	if input.Interval <= 0 {
+		input.Interval = time.Minute
+	}
+
+	export := func(ctx context.Context) {
+		families := input.Registry.Snapshot()
+		errs := []error{}
+		for _, exporter := range input.Exporters {
+			if err := exporter.Export(ctx, families); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			log.FromContext(ctx).Error(stackerr.Wrap(multierr.Combine(errs...)))
+		}
+	}
+
+	ticker := time.NewTicker(input.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Use a context that isn't done for the final export
+			export(log.LogContext(context.Background(), log.FromContext(ctx)))
+			return nil
+		case <-ticker.C:
+			export(ctx)
+		}
+	}
+}
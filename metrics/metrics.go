@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Invicton-Labs/go-common/collections"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Labels are key-value pairs that identify a specific series of a metric.
+type Labels map[string]string
+
+type MetricType string
+
+const (
+	CounterType   MetricType = "counter"
+	GaugeType     MetricType = "gauge"
+	HistogramType MetricType = "histogram"
+)
+
+// DefaultBuckets are the default histogram bucket upper bounds, suitable
+// for latencies measured in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is a metric that can only increase.
+type Counter interface {
+	// Inc increments the counter by 1.
+	Inc(labels Labels)
+	// Add increments the counter by the given amount, which must not be negative.
+	Add(delta float64, labels Labels)
+}
+
+// Gauge is a metric that can be set to any value.
+type Gauge interface {
+	// Set sets the gauge to the given value.
+	Set(value float64, labels Labels)
+	// Add adds the given amount (which may be negative) to the gauge.
+	Add(delta float64, labels Labels)
+}
+
+// Histogram is a metric that tracks the distribution of observed values.
+type Histogram interface {
+	// Observe records a single value.
+	Observe(value float64, labels Labels)
+}
+
+// Bucket is a histogram bucket with a cumulative count of all
+// observations that were less than or equal to the upper bound.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Series is a snapshot of a single labelled series of a metric.
+type Series struct {
+	Labels Labels
+	// The value for counters and gauges
+	Value float64
+	// The number of observations, for histograms
+	Count uint64
+	// The sum of all observations, for histograms
+	Sum float64
+	// The cumulative buckets, for histograms
+	Buckets []Bucket
+}
+
+// Family is a snapshot of a metric and all of its series.
+type Family struct {
+	Name   string
+	Help   string
+	Type   MetricType
+	Series []Series
+}
+
+// Registry holds a set of metrics.
+type Registry interface {
+	// Counter gets or creates a counter with the given name.
+	Counter(name string, help string) (Counter, stackerr.Error)
+	// Gauge gets or creates a gauge with the given name.
+	Gauge(name string, help string) (Gauge, stackerr.Error)
+	// Histogram gets or creates a histogram with the given name. If no buckets are
+	// provided, DefaultBuckets will be used. The numbers.LinearBuckets and
+	// numbers.ExponentialBuckets functions can be used to generate buckets.
+	Histogram(name string, help string, buckets []float64) (Histogram, stackerr.Error)
+	// Snapshot gets the current values of all metrics, sorted by name.
+	Snapshot() []Family
+}
+
+type series struct {
+	labels    Labels
+	value     float64
+	histogram numbers.Histogram
+}
+
+type family struct {
+	lock sync.Mutex
+	name string
+	help string
+	typ  MetricType
+	// An empty histogram with the family's buckets, which is cloned for each series
+	histogramTemplate numbers.Histogram
+	series            map[string]*series
+}
+
+// labelsKey generates a unique, deterministic key for a set of labels.
+func labelsKey(labels Labels) string {
+	keys := collections.MapKeys(labels)
+	collections.SortSliceAscendingInPlace(keys)
+	b := strings.Builder{}
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// getSeries gets or creates the series for the given labels. The family lock must be held.
+func (f *family) getSeries(labels Labels) *series {
+	key := labelsKey(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{
+			labels: collections.CopyMap(labels),
+		}
+		if f.typ == HistogramType {
+			s.histogram = f.histogramTemplate.Clone()
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) Inc(labels Labels) {
+	f.Add(1, labels)
+}
+
+func (f *family) Add(delta float64, labels Labels) {
+	if f.typ == CounterType && delta < 0 {
+		panic(stackerr.Errorf("counter '%s' cannot be decreased", f.name))
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.getSeries(labels).value += delta
+}
+
+func (f *family) Set(value float64, labels Labels) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.getSeries(labels).value = value
+}
+
+func (f *family) Observe(value float64, labels Labels) {
+	if math.IsNaN(value) {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.getSeries(labels).histogram.Observe(value)
+}
+
+func (f *family) snapshot() Family {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	snap := Family{
+		Name:   f.name,
+		Help:   f.help,
+		Type:   f.typ,
+		Series: make([]Series, 0, len(f.series)),
+	}
+	keys := collections.MapKeys(f.series)
+	collections.SortSliceAscendingInPlace(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		ss := Series{
+			Labels: collections.CopyMap(s.labels),
+			Value:  s.value,
+		}
+		if f.typ == HistogramType {
+			ss.Count = s.histogram.Count()
+			ss.Sum = s.histogram.Sum()
+			ss.Buckets = collections.TransformSlice(s.histogram.Buckets(), func(b numbers.HistogramBucket) Bucket {
+				return Bucket(b)
+			})
+		}
+		snap.Series = append(snap.Series, ss)
+	}
+	return snap
+}
+
+type registry struct {
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates a new, empty metrics registry.
+func NewRegistry() Registry {
+	return &registry{
+		families: map[string]*family{},
+	}
+}
+
+func (r *registry) getFamily(name string, help string, typ MetricType, histogramTemplate numbers.Histogram) (*family, stackerr.Error) {
+	if name == "" {
+		return nil, stackerr.Errorf("metric name must not be empty")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if f, ok := r.families[name]; ok {
+		if f.typ != typ {
+			return nil, stackerr.Errorf("metric '%s' is already registered as a %s", name, f.typ)
+		}
+		return f, nil
+	}
+	f := &family{
+		name:              name,
+		help:              help,
+		typ:               typ,
+		histogramTemplate: histogramTemplate,
+		series:            map[string]*series{},
+	}
+	r.families[name] = f
+	return f, nil
+}
+
+func (r *registry) Counter(name string, help string) (Counter, stackerr.Error) {
+	return r.getFamily(name, help, CounterType, nil)
+}
+
+func (r *registry) Gauge(name string, help string) (Gauge, stackerr.Error) {
+	return r.getFamily(name, help, GaugeType, nil)
+}
+
+func (r *registry) Histogram(name string, help string, buckets []float64) (Histogram, stackerr.Error) {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	// This always includes a +Inf bucket for everything
+	template, err := numbers.NewHistogram(buckets)
+	if err != nil {
+		return nil, err.WithSingle("metric_name", name)
+	}
+	return r.getFamily(name, help, HistogramType, template)
+}
+
+func (r *registry) Snapshot() []Family {
+	r.lock.Lock()
+	families := collections.MapValues(r.families)
+	r.lock.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return collections.TransformSlice(families, func(f *family) Family {
+		return f.snapshot()
+	})
+}
+
+// DefaultRegistry is the registry used by the package-level functions.
+var DefaultRegistry = NewRegistry()
+
+// NewCounter gets or creates a counter in the default registry.
+func NewCounter(name string, help string) (Counter, stackerr.Error) {
+	return DefaultRegistry.Counter(name, help)
+}
+
+// NewGauge gets or creates a gauge in the default registry.
+func NewGauge(name string, help string) (Gauge, stackerr.Error) {
+	return DefaultRegistry.Gauge(name, help)
+}
+
+// NewHistogram gets or creates a histogram in the default registry.
+func NewHistogram(name string, help string, buckets []float64) (Histogram, stackerr.Error) {
+	return DefaultRegistry.Histogram(name, help, buckets)
+}
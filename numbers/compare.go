@@ -26,6 +26,26 @@ func Max[T constraints.Ordered](val1 T, vals ...T) T {
 	return m
 }
 
+// Clamp limits the value to the range [lo, hi]. If lo is greater than hi,
+// the bounds are swapped.
+func Clamp[T constraints.Ordered](v T, lo T, hi T) T {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// InRange returns whether the value is in the range [lo, hi] (inclusive).
+func InRange[T constraints.Ordered](v T, lo T, hi T) bool {
+	return v >= lo && v <= hi
+}
+
 func IsNaN[T constraints.Float](x T) bool {
 	return x != x
 }
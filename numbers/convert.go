@@ -0,0 +1,121 @@
+package numbers
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+type numberKind int
+
+const (
+	signedKind numberKind = iota
+	unsignedKind
+	floatKind
+)
+
+// numberInfo gets the kind and size (in bits) of a numeric value.
+func numberInfo(v reflect.Value) (numberKind, int) {
+	bits := v.Type().Bits()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedKind, bits
+	case reflect.Float32, reflect.Float64:
+		return floatKind, bits
+	default:
+		return unsignedKind, bits
+	}
+}
+
+func conversionError(v any, to any, reason string) stackerr.Error {
+	return stackerr.Errorf("cannot convert %v (%T) to %T: %s", v, v, to, reason)
+}
+
+// ConvertChecked converts a number from one type to another, returning an error if the
+// value would overflow, underflow, or lose precision (e.g. a fractional float converted
+// to an integer, or a large integer that can't be represented exactly as a float). Unlike
+// a regular type conversion, it never silently changes the value.
+func ConvertChecked[From constraints.Simple, To constraints.Simple](v From) (To, stackerr.Error) {
+	var out To
+	in := reflect.ValueOf(v)
+	outValue := reflect.ValueOf(&out).Elem()
+	fromKind, _ := numberInfo(in)
+	toKind, toBits := numberInfo(outValue)
+
+	switch {
+	case fromKind != floatKind && toKind != floatKind:
+		// Integer to integer, which can be checked with a round trip
+		out = To(v)
+		if From(out) != v || (v < 0) != (out < 0) {
+			return 0, conversionError(v, out, "value out of range")
+		}
+		return out, nil
+
+	case fromKind != floatKind && toKind == floatKind:
+		// Integer to float, which must be exactly representable
+		exact := new(big.Float)
+		if fromKind == signedKind {
+			exact.SetInt64(in.Int())
+		} else {
+			exact.SetUint64(in.Uint())
+		}
+		out = To(v)
+		if big.NewFloat(float64(out)).Cmp(exact) != 0 {
+			return 0, conversionError(v, out, "value cannot be represented exactly")
+		}
+		return out, nil
+
+	case fromKind == floatKind && toKind != floatKind:
+		// Float to integer, which must be a whole number within range
+		f := in.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, conversionError(v, out, "value is not finite")
+		}
+		if f != math.Trunc(f) {
+			return 0, conversionError(v, out, "value is not a whole number")
+		}
+		var lower, upper float64
+		if toKind == signedKind {
+			lower, upper = -math.Ldexp(1, toBits-1), math.Ldexp(1, toBits-1)
+		} else {
+			lower, upper = 0, math.Ldexp(1, toBits)
+		}
+		if f < lower || f >= upper {
+			return 0, conversionError(v, out, "value out of range")
+		}
+		if toKind == signedKind {
+			outValue.SetInt(int64(f))
+		} else {
+			outValue.SetUint(uint64(f))
+		}
+		return out, nil
+
+	default:
+		// Float to float
+		f := in.Float()
+		out = To(v)
+		converted := outValue.Float()
+		if math.IsNaN(f) {
+			return out, nil
+		}
+		if math.IsInf(converted, 0) && !math.IsInf(f, 0) {
+			return 0, conversionError(v, out, "value out of range")
+		}
+		if converted != f {
+			return 0, conversionError(v, out, "value cannot be represented exactly")
+		}
+		return out, nil
+	}
+}
+
+// MustConvert is the same as ConvertChecked, but panics if the conversion fails.
+func MustConvert[From constraints.Simple, To constraints.Simple](v From) To {
+	out, err := ConvertChecked[From, To](v)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
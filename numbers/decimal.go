@@ -0,0 +1,422 @@
+package numbers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var bigTen = big.NewInt(10)
+
+// maxParsedDecimalExponent is the largest exponent (and scale) magnitude that ParseDecimal
+// accepts, since larger ones would need huge amounts of memory and CPU to expand.
+const maxParsedDecimalExponent = 10000
+
+// pow10 returns 10 to the power of n as a new big integer.
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// Decimal is an exact, arbitrary-precision decimal number, stored as an integer
+// coefficient and the number of digits after the decimal point (the scale). It is
+// intended for values such as money, where the rounding errors of float64 can't be
+// tolerated. Addition, subtraction, and multiplication are exact; division and
+// rounding use banker's rounding (round half to even).
+//
+// Decimals are immutable, and the zero value is 0.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+// coefficient returns the coefficient, treating nil as 0. It must not be modified.
+func (d Decimal) coefficient() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// NewDecimal creates a decimal with the value unscaled * 10^-scale. For
+// example, NewDecimal(12345, 2) is 123.45.
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	coef := big.NewInt(unscaled)
+	if scale < 0 {
+		coef.Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{
+		coef:  coef,
+		scale: scale,
+	}
+}
+
+// DecimalFromInt creates a decimal from an integer.
+func DecimalFromInt[T constraints.Integer](v T) Decimal {
+	coef := new(big.Int)
+	if v < 0 {
+		coef.SetInt64(int64(v))
+	} else {
+		coef.SetUint64(uint64(v))
+	}
+	return Decimal{
+		coef: coef,
+	}
+}
+
+// DecimalFromFloat creates a decimal from a float, using the shortest decimal
+// representation that converts back to the same float. It returns an error
+// if the float is NaN or infinite.
+func DecimalFromFloat(f float64) (Decimal, stackerr.Error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, stackerr.Errorf("cannot convert %v to a decimal", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// DecimalFromMinorUnits creates a decimal from an amount in a currency's minor units
+// (e.g. cents), where minorDigits is the number of minor unit digits of the currency
+// (e.g. 2 for USD, 0 for JPY). For example, DecimalFromMinorUnits(1999, 2) is 19.99.
+func DecimalFromMinorUnits(amount int64, minorDigits int32) Decimal {
+	return NewDecimal(amount, minorDigits)
+}
+
+// ParseDecimal parses a decimal from a string such as "-123.45" or "1.5e3". The
+// exponent and the resulting scale must be within ±10000.
+func ParseDecimal(s string) (Decimal, stackerr.Error) {
+	trimmed := strings.TrimSpace(s)
+	mantissa, exponent := trimmed, int64(0)
+	if idx := strings.IndexAny(trimmed, "eE"); idx >= 0 {
+		var err error
+		mantissa = trimmed[:idx]
+		exponent, err = strconv.ParseInt(trimmed[idx+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, stackerr.Errorf("invalid decimal '%s': invalid exponent", s)
+		}
+		if exponent > maxParsedDecimalExponent || exponent < -maxParsedDecimalExponent {
+			return Decimal{}, stackerr.Errorf("invalid decimal '%s': exponent out of range", s)
+		}
+	}
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") || strings.HasPrefix(mantissa, "+") {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	intPart, fracPart := mantissa, ""
+	if idx := strings.IndexByte(mantissa, '.'); idx >= 0 {
+		intPart, fracPart = mantissa[:idx], mantissa[idx+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" {
+		return Decimal{}, stackerr.Errorf("invalid decimal '%s': no digits found", s)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Decimal{}, stackerr.Errorf("invalid decimal '%s': invalid character '%c'", s, c)
+		}
+	}
+	coef, ok := new(big.Int).SetString(sign+digits, 10)
+	if !ok {
+		return Decimal{}, stackerr.Errorf("invalid decimal '%s'", s)
+	}
+	scale := int64(len(fracPart)) - exponent
+	if scale > maxParsedDecimalExponent || scale < -maxParsedDecimalExponent {
+		return Decimal{}, stackerr.Errorf("invalid decimal '%s': exponent out of range", s)
+	}
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{
+		coef:  coef,
+		scale: int32(scale),
+	}, nil
+}
+
+// MustParseDecimal is the same as ParseDecimal, but panics if the string is invalid.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// align returns the coefficients of both decimals at the same (larger) scale.
+func align(a Decimal, b Decimal) (*big.Int, *big.Int, int32) {
+	ac, bc := a.coefficient(), b.coefficient()
+	switch {
+	case a.scale < b.scale:
+		ac = new(big.Int).Mul(ac, pow10(b.scale-a.scale))
+		return ac, bc, b.scale
+	case b.scale < a.scale:
+		bc = new(big.Int).Mul(bc, pow10(a.scale-b.scale))
+		return ac, bc, a.scale
+	}
+	return ac, bc, a.scale
+}
+
+// quoRounded divides the numerator by the denominator, rounding with the given mode.
+func quoRounded(num *big.Int, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	negative := num.Sign() != den.Sign()
+	var awayFromZero bool
+	switch mode {
+	case RoundingTowardZero:
+		awayFromZero = false
+	case RoundingAwayFromZero:
+		awayFromZero = true
+	case RoundingFloor:
+		awayFromZero = negative
+	case RoundingCeil:
+		awayFromZero = !negative
+	default:
+		// Compare twice the remainder to the denominator to see which way to round
+		cmp := new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(den))
+		switch {
+		case cmp != 0:
+			awayFromZero = cmp > 0
+		case mode == RoundingHalfUp:
+			awayFromZero = true
+		case mode == RoundingHalfDown:
+			awayFromZero = false
+		default:
+			awayFromZero = q.Bit(0) == 1
+		}
+	}
+	if awayFromZero {
+		// QuoRem truncates towards zero
+		if negative {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// Add returns d + other.
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := align(d, other)
+	return Decimal{
+		coef:  new(big.Int).Add(a, b),
+		scale: scale,
+	}
+}
+
+// Sub returns d - other.
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := align(d, other)
+	return Decimal{
+		coef:  new(big.Int).Sub(a, b),
+		scale: scale,
+	}
+}
+
+// Mul returns d * other. The scale of the result is the sum of the scales.
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{
+		coef:  new(big.Int).Mul(d.coefficient(), other.coefficient()),
+		scale: d.scale + other.scale,
+	}
+}
+
+// Div returns d / other, rounded half to even to the given number of decimal places.
+// It returns an error if other is 0 or the number of places is negative.
+func (d Decimal) Div(other Decimal, places int32) (Decimal, stackerr.Error) {
+	if other.IsZero() {
+		return Decimal{}, stackerr.Errorf("cannot divide %s by zero", d)
+	}
+	if places < 0 {
+		return Decimal{}, stackerr.Errorf("the number of decimal places must not be negative, got %d", places)
+	}
+	// d / other = (dc / oc) * 10^(other.scale - d.scale), scaled up by 10^places
+	num, den := d.coefficient(), other.coefficient()
+	exponent := int64(other.scale) - int64(d.scale) + int64(places)
+	if exponent >= 0 {
+		num = new(big.Int).Mul(num, pow10(int32(exponent)))
+	} else {
+		den = new(big.Int).Mul(den, pow10(int32(-exponent)))
+	}
+	return Decimal{
+		coef:  quoRounded(num, den, RoundingHalfEven),
+		scale: places,
+	}, nil
+}
+
+// Round rounds the decimal half to even (banker's rounding) to the given number of
+// decimal places. If it already has that many places or fewer, the scale is increased
+// to the given number of places without changing the value.
+func (d Decimal) Round(places int32) Decimal {
+	return d.RoundWithMode(places, RoundingHalfEven)
+}
+
+// RoundWithMode is the same as Round, but uses the given rounding mode.
+func (d Decimal) RoundWithMode(places int32, mode RoundingMode) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return Decimal{
+			coef:  new(big.Int).Mul(d.coefficient(), pow10(places-d.scale)),
+			scale: places,
+		}
+	}
+	return Decimal{
+		coef:  quoRounded(d.coefficient(), pow10(d.scale-places), mode),
+		scale: places,
+	}
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{
+		coef:  new(big.Int).Neg(d.coefficient()),
+		scale: d.scale,
+	}
+}
+
+// Abs returns the absolute value of d.
+func (d Decimal) Abs() Decimal {
+	return Decimal{
+		coef:  new(big.Int).Abs(d.coefficient()),
+		scale: d.scale,
+	}
+}
+
+// Cmp compares d and other, returning -1 if d < other, 0 if they're
+// equal, and 1 if d > other. The scales don't need to match.
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := align(d, other)
+	return a.Cmp(b)
+}
+
+// Equal returns whether d and other have the same value (regardless of scale).
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Sign returns -1 if d is negative, 0 if it's zero, and 1 if it's positive.
+func (d Decimal) Sign() int {
+	return d.coefficient().Sign()
+}
+
+// IsZero returns whether d is 0.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// MinorUnits converts the decimal to an amount in a currency's minor units (e.g. cents),
+// where minorDigits is the number of minor unit digits of the currency. The value is
+// rounded half to even if it has more digits than that. It returns an error if the
+// amount doesn't fit in an int64.
+func (d Decimal) MinorUnits(minorDigits int32) (int64, stackerr.Error) {
+	rounded := d.Round(minorDigits)
+	if !rounded.coef.IsInt64() {
+		return 0, stackerr.Errorf("decimal %s is out of range for an int64 amount of minor units", d)
+	}
+	return rounded.coef.Int64(), nil
+}
+
+// Float64 returns the nearest float64 to the decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns the decimal with all of its decimal places, e.g. "-123.450".
+func (d Decimal) String() string {
+	coef := d.coefficient()
+	digits := new(big.Int).Abs(coef).String()
+	sign := ""
+	if coef.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// StringFixed returns the decimal rounded (half to even) to the given number of places.
+func (d Decimal) StringFixed(places int32) string {
+	return d.Round(places).String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The decimal is marshaled as a string,
+// since many JSON decoders would otherwise lose precision by parsing it as a float.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both strings and numbers are accepted.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var s string
+		if err := json.Unmarshal(trimmed, &s); err != nil {
+			return stackerr.Wrap(err)
+		}
+		return d.UnmarshalText([]byte(s))
+	}
+	return d.UnmarshalText(trimmed)
+}
+
+// MarshalDynamoDBAttributeValue implements attributevalue.Marshaler. The decimal is
+// marshaled as a number, which DynamoDB stores exactly (up to 38 digits of precision).
+func (d Decimal) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberN{
+		Value: d.String(),
+	}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue implements attributevalue.Unmarshaler. Both
+// number and string attributes are accepted.
+func (d *Decimal) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		return d.UnmarshalText([]byte(v.Value))
+	case *types.AttributeValueMemberS:
+		return d.UnmarshalText([]byte(v.Value))
+	case *types.AttributeValueMemberNULL:
+		return nil
+	default:
+		return stackerr.Errorf("cannot unmarshal DynamoDB attribute of type %T into a decimal", av)
+	}
+}
@@ -0,0 +1,44 @@
+package numbers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseDecimalExponentLimit(t *testing.T) {
+	valid := map[string]string{
+		"1.5e3":    "1500",
+		"-12.34":   "-12.34",
+		"1e10000":  "1" + strings.Repeat("0", 10000),
+		"1e-10000": "0." + strings.Repeat("0", 9999) + "1",
+	}
+	for input, expected := range valid {
+		d, err := ParseDecimal(input)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q) returned an error: %v", input, err)
+		}
+		if d.String() != expected {
+			t.Fatalf("ParseDecimal(%q) = %s, expected %s", input, d.String(), expected)
+		}
+	}
+
+	invalid := []string{
+		"1e10001",
+		"1e-10001",
+		"1e2147483647",
+		"1e-2147483648",
+		"1.5e99999999999",
+		// The exponent is in range, but the resulting scale isn't
+		"0.1e-10000",
+	}
+	for _, input := range invalid {
+		if _, err := ParseDecimal(input); err == nil {
+			t.Fatalf("ParseDecimal(%q) didn't return an error", input)
+		}
+		var d Decimal
+		if err := json.Unmarshal([]byte(input), &d); err == nil {
+			t.Fatalf("json.Unmarshal(%q) didn't return an error", input)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package numbers
+
+import (
+	"math"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// divOverflows returns whether dividing the numerator by the denominator overflows, which
+// only happens when dividing the minimum value of a signed integer type by -1. In that case
+// the result wraps around to the (negative) minimum value, even though both operands are negative.
+func divOverflows[T constraints.Simple](num T, den T, result T) bool {
+	return num < 0 && den < 0 && result < 0
+}
+
+// SafeDiv divides the numerator by the denominator, returning the fallback value if the
+// denominator is 0, if the division overflows (the minimum value of a signed integer type
+// divided by -1), or, for floats, if the result is not a finite number.
+func SafeDiv[T constraints.Simple](num T, den T, fallback T) T {
+	if den == 0 {
+		return fallback
+	}
+	result := num / den
+	if divOverflows(num, den, result) {
+		return fallback
+	}
+	f := float64(result)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fallback
+	}
+	return result
+}
+
+// DivChecked divides the numerator by the denominator, returning an error if the
+// denominator is 0, if the division overflows (the minimum value of a signed integer
+// type divided by -1), or, for floats, if the result is not a finite number.
+func DivChecked[T constraints.Simple](num T, den T) (T, stackerr.Error) {
+	if den == 0 {
+		return 0, stackerr.Errorf("cannot divide %v by zero", num)
+	}
+	result := num / den
+	if divOverflows(num, den, result) {
+		return 0, stackerr.Errorf("dividing %v by %v overflows", num, den)
+	}
+	f := float64(result)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, stackerr.Errorf("dividing %v by %v does not produce a finite result", num, den)
+	}
+	return result, nil
+}
+
+// DivRound divides two integers, rounding to the nearest integer (with halves rounded
+// away from zero) instead of truncating. It returns an error if the denominator is 0
+// or if the division overflows.
+func DivRound[T constraints.Integer](num T, den T) (T, stackerr.Error) {
+	if den == 0 {
+		return 0, stackerr.Errorf("cannot divide %v by zero", num)
+	}
+	q, r := num/den, num%den
+	if divOverflows(num, den, q) {
+		return 0, stackerr.Errorf("dividing %v by %v overflows", num, den)
+	}
+	if r == 0 {
+		return q, nil
+	}
+	// Round away from zero if |r| >= |den| - |r|. This is calculated without
+	// taking absolute values, which could overflow.
+	var roundAway bool
+	if (r < 0) == (den < 0) {
+		if den > 0 {
+			roundAway = r >= den-r
+		} else {
+			roundAway = r <= den-r
+		}
+	} else {
+		if den > 0 {
+			roundAway = -r >= den+r
+		} else {
+			roundAway = r >= -(den + r)
+		}
+	}
+	if roundAway {
+		if (num < 0) != (den < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q, nil
+}
+
+// CeilDiv divides two integers, rounding up (towards positive infinity) instead
+// of truncating. It returns an error if the denominator is 0 or if the division overflows.
+func CeilDiv[T constraints.Integer](num T, den T) (T, stackerr.Error) {
+	if den == 0 {
+		return 0, stackerr.Errorf("cannot divide %v by zero", num)
+	}
+	q, r := num/den, num%den
+	if divOverflows(num, den, q) {
+		return 0, stackerr.Errorf("dividing %v by %v overflows", num, den)
+	}
+	if r != 0 && (r > 0) == (den > 0) {
+		q++
+	}
+	return q, nil
+}
+
+// Ratio returns the part divided by the whole as a float, or 0 if the whole is 0.
+func Ratio[T constraints.Simple](part T, whole T) float64 {
+	return SafeDiv(float64(part), float64(whole), 0)
+}
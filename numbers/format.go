@@ -0,0 +1,148 @@
+package numbers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Binary (IEC) byte sizes
+const (
+	KiB int64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+	EiB
+)
+
+// Decimal (SI) byte sizes
+const (
+	KB int64 = 1000
+	MB       = 1000 * KB
+	GB       = 1000 * MB
+	TB       = 1000 * GB
+	PB       = 1000 * TB
+	EB       = 1000 * PB
+)
+
+var binaryByteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+var decimalByteUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+var countUnits = []string{"", "k", "M", "B", "T"}
+
+// The multipliers for each (lowercase) unit accepted by ParseBytes
+var byteUnitMultipliers = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   KB,
+	"kb":  KB,
+	"m":   MB,
+	"mb":  MB,
+	"g":   GB,
+	"gb":  GB,
+	"t":   TB,
+	"tb":  TB,
+	"p":   PB,
+	"pb":  PB,
+	"e":   EB,
+	"eb":  EB,
+	"ki":  KiB,
+	"kib": KiB,
+	"mi":  MiB,
+	"mib": MiB,
+	"gi":  GiB,
+	"gib": GiB,
+	"ti":  TiB,
+	"tib": TiB,
+	"pi":  PiB,
+	"pib": PiB,
+	"ei":  EiB,
+	"eib": EiB,
+}
+
+// formatScaled formats the value with one decimal place, scaled down by the base
+// until it's less than the base, with the matching unit appended.
+func formatScaled(v float64, base float64, units []string, separator string) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	unit := 0
+	for v >= base && unit < len(units)-1 {
+		v /= base
+		unit++
+	}
+	// Values just under the base can round up to it (e.g. 1023.96 KiB to 1024.0 KiB)
+	v = math.Round(v*10) / 10
+	if v >= base && unit < len(units)-1 {
+		v /= base
+		unit++
+	}
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	if unit == 0 || strings.HasSuffix(s, ".0") {
+		s = strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	if units[unit] == "" {
+		return sign + s
+	}
+	return sign + s + separator + units[unit]
+}
+
+// FormatBytes formats a number of bytes in binary (IEC) units, e.g. "512 B" or "1.5 GiB".
+func FormatBytes[T constraints.Integer](n T) string {
+	return formatScaled(float64(n), 1024, binaryByteUnits, " ")
+}
+
+// FormatBytesSI formats a number of bytes in decimal (SI) units, e.g. "512 B" or "1.5 GB".
+func FormatBytesSI[T constraints.Integer](n T) string {
+	return formatScaled(float64(n), 1000, decimalByteUnits, " ")
+}
+
+// FormatCount formats a count in a short human-readable form, e.g. "999", "12.3k", or "4.5M".
+func FormatCount[T constraints.Simple](n T) string {
+	return formatScaled(float64(n), 1000, countUnits, "")
+}
+
+// ParseBytes parses a human-readable byte size, such as "1.5GiB", "10 MB", or "512".
+// Units are case-insensitive. Binary units ("KiB", "Ki") are powers of 1024, and
+// decimal units ("KB", "K") are powers of 1000. A number without a unit is in bytes.
+func ParseBytes(s string) (int64, stackerr.Error) {
+	trimmed := strings.TrimSpace(s)
+	split := strings.IndexFunc(trimmed, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != '-' && r != '+'
+	})
+	numberPart, unitPart := trimmed, ""
+	if split >= 0 {
+		numberPart, unitPart = trimmed[:split], strings.TrimSpace(trimmed[split:])
+	}
+	if numberPart == "" {
+		return 0, stackerr.Errorf("invalid byte size '%s': no number found", s)
+	}
+	multiplier, ok := byteUnitMultipliers[strings.ToLower(unitPart)]
+	if !ok {
+		return 0, stackerr.Errorf("invalid byte size '%s': unknown unit '%s'", s, unitPart)
+	}
+
+	// Parse integers exactly, since large values can't be represented exactly as floats
+	if i, err := strconv.ParseInt(numberPart, 10, 64); err == nil {
+		if i != 0 && (i > math.MaxInt64/multiplier || i < math.MinInt64/multiplier) {
+			return 0, stackerr.Errorf("invalid byte size '%s': value out of range", s)
+		}
+		return i * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(numberPart, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, stackerr.Errorf("invalid byte size '%s': invalid number '%s'", s, numberPart)
+	}
+	bytes := math.Round(f * float64(multiplier))
+	// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit in an int64
+	if bytes >= math.MaxInt64 || bytes < math.MinInt64 {
+		return 0, stackerr.Errorf("invalid byte size '%s': value out of range", s)
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,284 @@
+package numbers
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// LinearBuckets returns `count` bucket upper bounds, starting at `start`
+// and increasing by `width` each time.
+func LinearBuckets(start float64, width float64, count int) []float64 {
+	if count < 1 {
+		return []float64{}
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+// ExponentialBuckets returns `count` bucket upper bounds, starting at `start`
+// and multiplied by `factor` each time. The start must be positive and the
+// factor must be greater than 1.
+func ExponentialBuckets(start float64, factor float64, count int) ([]float64, stackerr.Error) {
+	if start <= 0 {
+		return nil, stackerr.Errorf("the start of exponential buckets must be positive, got %v", start)
+	}
+	if factor <= 1 {
+		return nil, stackerr.Errorf("the factor of exponential buckets must be greater than 1, got %v", factor)
+	}
+	if count < 1 {
+		return []float64{}, nil
+	}
+	buckets := make([]float64, count)
+	buckets[0] = start
+	for i := 1; i < count; i++ {
+		buckets[i] = buckets[i-1] * factor
+	}
+	return buckets, nil
+}
+
+// HistogramBucket is a histogram bucket with a cumulative count of all
+// observations that were less than or equal to the upper bound.
+type HistogramBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Histogram tracks the distribution of observed values in a fixed set of buckets, so
+// that quantiles can be estimated without storing every value. It is safe for concurrent use.
+type Histogram interface {
+	// Observe records a single value. NaN values are ignored.
+	Observe(value float64)
+	// Count returns the number of observations.
+	Count() uint64
+	// Sum returns the sum of all observations.
+	Sum() float64
+	// Min returns the smallest observation, or NaN if there are no observations.
+	Min() float64
+	// Max returns the largest observation, or NaN if there are no observations.
+	Max() float64
+	// Mean returns the mean of the observations, or NaN if there are no observations.
+	Mean() float64
+	// Quantile estimates a quantile (in the range [0, 1]) of the observations, by linear
+	// interpolation within the bucket that contains it. The estimate is limited to the
+	// range of observed values. It returns NaN if there are no observations or the
+	// quantile is out of range.
+	Quantile(q float64) float64
+	// Buckets returns the cumulative buckets. The last bucket always has an upper
+	// bound of +Inf, so its count is the total number of observations.
+	Buckets() []HistogramBucket
+	// Merge adds all of the observations of another histogram, which must have
+	// the same bucket bounds.
+	Merge(other Histogram) stackerr.Error
+	// Clone returns an independent copy of the histogram.
+	Clone() Histogram
+	// Reset removes all observations.
+	Reset()
+}
+
+type histogram struct {
+	lock sync.Mutex
+	// The upper bounds, always ending with +Inf
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+	min    float64
+	max    float64
+}
+
+// NewHistogram creates a new histogram with the given bucket upper bounds (see
+// LinearBuckets and ExponentialBuckets). The bounds are sorted, and a final bucket
+// with an upper bound of +Inf is added if it isn't already included.
+func NewHistogram(bounds []float64) (Histogram, stackerr.Error) {
+	sorted := make([]float64, 0, len(bounds)+1)
+	for _, b := range bounds {
+		if math.IsNaN(b) {
+			return nil, stackerr.Errorf("histogram bucket bounds must not be NaN")
+		}
+		sorted = append(sorted, b)
+	}
+	sort.Float64s(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			return nil, stackerr.Errorf("histogram bucket bounds must be unique, found %v more than once", sorted[i])
+		}
+	}
+	if len(sorted) == 0 || !math.IsInf(sorted[len(sorted)-1], 1) {
+		sorted = append(sorted, math.Inf(1))
+	}
+	return &histogram{
+		bounds: sorted,
+		counts: make([]uint64, len(sorted)),
+	}, nil
+}
+
+func (h *histogram) Observe(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	// Find the first bucket that this value fits in
+	h.counts[sort.SearchFloat64s(h.bounds, value)]++
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *histogram) Count() uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.count
+}
+
+func (h *histogram) Sum() float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.sum
+}
+
+func (h *histogram) Min() float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.count == 0 {
+		return math.NaN()
+	}
+	return h.min
+}
+
+func (h *histogram) Max() float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.count == 0 {
+		return math.NaN()
+	}
+	return h.max
+}
+
+func (h *histogram) Mean() float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.count == 0 {
+		return math.NaN()
+	}
+	return h.sum / float64(h.count)
+}
+
+func (h *histogram) Quantile(q float64) float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.count == 0 || math.IsNaN(q) || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	rank := q * float64(h.count)
+	cumulative := uint64(0)
+	for i, c := range h.counts {
+		if c == 0 || float64(cumulative+c) < rank {
+			cumulative += c
+			continue
+		}
+		// The observed range bounds the first and last buckets, which
+		// would otherwise be open-ended.
+		lower := h.min
+		if i > 0 && h.bounds[i-1] > lower {
+			lower = h.bounds[i-1]
+		}
+		upper := h.max
+		if h.bounds[i] < upper {
+			upper = h.bounds[i]
+		}
+		estimate := lower + (upper-lower)*(rank-float64(cumulative))/float64(c)
+		return Clamp(estimate, h.min, h.max)
+	}
+	return h.max
+}
+
+func (h *histogram) Buckets() []HistogramBucket {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	buckets := make([]HistogramBucket, len(h.bounds))
+	cumulative := uint64(0)
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		buckets[i] = HistogramBucket{
+			UpperBound: bound,
+			Count:      cumulative,
+		}
+	}
+	return buckets
+}
+
+func (h *histogram) Merge(other Histogram) stackerr.Error {
+	o, ok := other.(*histogram)
+	if !ok {
+		return stackerr.Errorf("cannot merge a histogram of type %T", other)
+	}
+	if o == h {
+		return stackerr.Errorf("cannot merge a histogram into itself")
+	}
+	// Copy the other histogram first, so that both locks are never held at once
+	o = o.Clone().(*histogram)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(o.bounds) != len(h.bounds) {
+		return stackerr.Errorf("cannot merge histograms with different bucket bounds")
+	}
+	for i, b := range o.bounds {
+		if b != h.bounds[i] {
+			return stackerr.Errorf("cannot merge histograms with different bucket bounds")
+		}
+	}
+	if o.count == 0 {
+		return nil
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if h.count == 0 || o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+	return nil
+}
+
+func (h *histogram) Clone() Histogram {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	clone := &histogram{
+		bounds: h.bounds,
+		counts: make([]uint64, len(h.counts)),
+		count:  h.count,
+		sum:    h.sum,
+		min:    h.min,
+		max:    h.max,
+	}
+	copy(clone.counts, h.counts)
+	return clone
+}
+
+func (h *histogram) Reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.count = 0
+	h.sum = 0
+	h.min = 0
+	h.max = 0
+}
@@ -9,3 +9,27 @@ func Abs[T constraints.Signed](v T) T {
 	}
 	return v
 }
+
+// Sum returns the sum of all values in the slice (0 for an empty slice).
+func Sum[T constraints.Numeric](values []T) T {
+	var sum T
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// Product returns the product of all values in the slice (1 for an empty slice).
+func Product[T constraints.Numeric](values []T) T {
+	var product T = 1
+	for _, v := range values {
+		product *= v
+	}
+	return product
+}
+
+// Average returns the arithmetic mean of the values, or NaN if there are no values.
+// It is the same as Mean.
+func Average[T constraints.Simple](values []T) float64 {
+	return Mean(values)
+}
@@ -0,0 +1,155 @@
+package numbers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	mathrand "math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// RandomSource is a source of uniformly distributed random 64-bit values.
+// Implementations must be safe for concurrent use.
+type RandomSource interface {
+	Uint64() uint64
+}
+
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// This should never happen, since the system's secure
+		// random number generator should never fail.
+		panic(stackerr.Wrap(err))
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// CryptoSource is a random source that uses the system's cryptographically secure
+// random number generator. It is the default random source.
+var CryptoSource RandomSource = cryptoSource{}
+
+type seededSource struct {
+	lock sync.Mutex
+	rand *mathrand.Rand
+}
+
+func (s *seededSource) Uint64() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rand.Uint64()
+}
+
+// NewSeededSource creates a deterministic (and NOT cryptographically secure)
+// random source, which produces the same sequence of values for the same seed.
+// It is intended for tests.
+func NewSeededSource(seed int64) RandomSource {
+	return &seededSource{
+		rand: mathrand.New(mathrand.NewSource(seed)),
+	}
+}
+
+type sourceHolder struct {
+	source RandomSource
+}
+
+var randomSource atomic.Value
+
+func init() {
+	randomSource.Store(sourceHolder{CryptoSource})
+}
+
+// SetRandomSource sets the random source used by the random functions in this package
+// (other than RandomInt64Crypto, which always uses CryptoSource), and returns the previous
+// source so it can be restored. If the source is nil, CryptoSource is used. This is
+// intended for making random behaviour deterministic in tests.
+func SetRandomSource(source RandomSource) RandomSource {
+	if source == nil {
+		source = CryptoSource
+	}
+	return randomSource.Swap(sourceHolder{source}).(sourceHolder).source
+}
+
+func currentSource() RandomSource {
+	return randomSource.Load().(sourceHolder).source
+}
+
+// uint64Below returns a uniformly distributed value in the range [0, n), or any
+// value if n is 0 (representing the full range of a uint64).
+func uint64Below(source RandomSource, n uint64) uint64 {
+	if n == 0 {
+		return source.Uint64()
+	}
+	// Reject values from the incomplete final block to avoid modulo bias
+	limit := math.MaxUint64 - (math.MaxUint64 % n)
+	for {
+		v := source.Uint64()
+		if v < limit {
+			return v % n
+		}
+	}
+}
+
+// RandomUint64 returns a uniformly distributed random uint64.
+func RandomUint64() uint64 {
+	return currentSource().Uint64()
+}
+
+// RandomInt64Crypto returns a uniformly distributed non-negative random int64
+// from the system's cryptographically secure random number generator. Unlike
+// the other random functions, it ignores the source set with SetRandomSource.
+func RandomInt64Crypto() int64 {
+	return int64(CryptoSource.Uint64() >> 1)
+}
+
+// RandomIntInRange returns a uniformly distributed random integer in the range [lo, hi]
+// (inclusive). If lo is greater than hi, the bounds are swapped.
+func RandomIntInRange[T constraints.Integer](lo T, hi T) T {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	// Calculate the span in unsigned space, where it can't overflow (a span of
+	// the full 64-bit range wraps around to 0, which uint64Below handles).
+	span := uint64(hi) - uint64(lo) + 1
+	return T(uint64(lo) + uint64Below(currentSource(), span))
+}
+
+// RandomFloat returns a uniformly distributed random float in the range [0, 1).
+func RandomFloat() float64 {
+	// Use the top 53 bits, which is the precision of a float64
+	return float64(currentSource().Uint64()>>11) / (1 << 53)
+}
+
+// RandomFloatInRange returns a uniformly distributed random float in the range [lo, hi).
+func RandomFloatInRange(lo float64, hi float64) float64 {
+	return lo + RandomFloat()*(hi-lo)
+}
+
+// Jitter returns the value randomly adjusted by up to +/- the given fraction of itself
+// (e.g. a fraction of 0.1 returns a value between 90% and 110% of the original). The
+// fraction is limited to the range [0, 1]. It works with any numeric type, including
+// time.Duration.
+func Jitter[T constraints.Simple](base T, fraction float64) T {
+	if fraction <= 0 || math.IsNaN(fraction) {
+		return base
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return T(float64(base) * (1 + fraction*(2*RandomFloat()-1)))
+}
+
+// RandomChoice returns a uniformly chosen random element of the slice. The
+// boolean is false if the slice is empty.
+func RandomChoice[T any](values []T) (T, bool) {
+	if len(values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return values[uint64Below(currentSource(), uint64(len(values)))], true
+}
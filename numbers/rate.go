@@ -0,0 +1,224 @@
+package numbers
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// EWMA is an exponentially weighted moving average. It is safe for concurrent use.
+type EWMA interface {
+	// Add adds a value to the average.
+	Add(value float64)
+	// Value returns the current average, or NaN if no values have been added.
+	Value() float64
+	// Count returns the number of values that have been added.
+	Count() int64
+	// Set replaces the current average with the given value.
+	Set(value float64)
+	// Reset removes all values.
+	Reset()
+}
+
+type ewma struct {
+	alpha float64
+	lock  sync.Mutex
+	value float64
+	count int64
+}
+
+// NewEWMA creates a new exponentially weighted moving average with the given smoothing
+// factor (alpha), which must be in the range (0, 1]. Each new value is weighted by alpha,
+// and the previous average by (1 - alpha), so larger values of alpha react faster to
+// changes. The first value added is used as the initial average.
+func NewEWMA(alpha float64) (EWMA, stackerr.Error) {
+	if !(alpha > 0 && alpha <= 1) {
+		return nil, stackerr.Errorf("the EWMA alpha must be in the range (0, 1], got %v", alpha)
+	}
+	return &ewma{
+		alpha: alpha,
+	}, nil
+}
+
+// NewEWMAForSamples creates a new exponentially weighted moving average that approximates
+// a simple moving average over the given number of samples (alpha = 2 / (samples + 1)).
+func NewEWMAForSamples(samples int) (EWMA, stackerr.Error) {
+	if samples < 1 {
+		return nil, stackerr.Errorf("the number of EWMA samples must be at least 1, got %d", samples)
+	}
+	return NewEWMA(2 / (float64(samples) + 1))
+}
+
+func (e *ewma) Add(value float64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.count == 0 {
+		e.value = value
+	} else {
+		e.value += e.alpha * (value - e.value)
+	}
+	e.count++
+}
+
+func (e *ewma) Value() float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.count == 0 {
+		return math.NaN()
+	}
+	return e.value
+}
+
+func (e *ewma) Count() int64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.count
+}
+
+func (e *ewma) Set(value float64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.value = value
+	if e.count == 0 {
+		e.count = 1
+	}
+}
+
+func (e *ewma) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.value = 0
+	e.count = 0
+}
+
+// RateTracker tracks the rate of events (per second) over a sliding window. It is
+// safe for concurrent use.
+type RateTracker interface {
+	// Add records the given number of events.
+	Add(n int64)
+	// Inc records a single event.
+	Inc()
+	// Rate returns the rate of events per second over the whole window (or over the
+	// time since the tracker was created, if that is shorter than the window).
+	Rate() float64
+	// RateOver returns the rate of events per second over the given duration, which is
+	// rounded up to a whole number of buckets and limited to the window.
+	RateOver(d time.Duration) float64
+	// Total returns the total number of events recorded since the tracker was created.
+	Total() int64
+}
+
+type NewRateTrackerInput struct {
+	// The duration of the sliding window
+	Window time.Duration
+	// OPTIONAL. The number of buckets that the window is divided into. More buckets
+	// give a smoother rate, at the cost of more memory. Defaults to 60.
+	Buckets int
+	// OPTIONAL. The function to get the current time, which can be replaced in tests
+	// (e.g. with a fake dateutils.Clock's Now method). Defaults to time.Now.
+	Now func() time.Time
+}
+
+type rateTracker struct {
+	lock        sync.Mutex
+	now         func() time.Time
+	bucketWidth time.Duration
+	buckets     []int64
+	// The index (since the Unix epoch) of the most recently used bucket
+	lastBucket int64
+	start      time.Time
+	total      int64
+}
+
+// NewRateTracker creates a new rate tracker.
+func NewRateTracker(input NewRateTrackerInput) (RateTracker, stackerr.Error) {
+	if input.Buckets <= 0 {
+		input.Buckets = 60
+	}
+	if input.Now == nil {
+		input.Now = time.Now
+	}
+	bucketWidth := input.Window / time.Duration(input.Buckets)
+	if bucketWidth <= 0 {
+		return nil, stackerr.Errorf("the rate tracker window (%s) must be at least as many nanoseconds as there are buckets (%d)", input.Window, input.Buckets)
+	}
+	now := input.Now()
+	return &rateTracker{
+		now:         input.Now,
+		bucketWidth: bucketWidth,
+		buckets:     make([]int64, input.Buckets),
+		lastBucket:  now.UnixNano() / int64(bucketWidth),
+		start:       now,
+	}, nil
+}
+
+// advance clears any buckets that have fallen out of the window, and returns the
+// current time and bucket index. The lock must be held.
+func (rt *rateTracker) advance() (time.Time, int64) {
+	now := rt.now()
+	current := now.UnixNano() / int64(rt.bucketWidth)
+	if current > rt.lastBucket {
+		steps := current - rt.lastBucket
+		if steps > int64(len(rt.buckets)) {
+			steps = int64(len(rt.buckets))
+		}
+		for i := int64(1); i <= steps; i++ {
+			rt.buckets[(rt.lastBucket+i)%int64(len(rt.buckets))] = 0
+		}
+		rt.lastBucket = current
+	}
+	return now, rt.lastBucket
+}
+
+func (rt *rateTracker) Add(n int64) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	_, current := rt.advance()
+	rt.buckets[current%int64(len(rt.buckets))] += n
+	rt.total += n
+}
+
+func (rt *rateTracker) Inc() {
+	rt.Add(1)
+}
+
+func (rt *rateTracker) Rate() float64 {
+	return rt.RateOver(rt.bucketWidth * time.Duration(len(rt.buckets)))
+}
+
+func (rt *rateTracker) RateOver(d time.Duration) float64 {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	now, current := rt.advance()
+
+	count := int64((d + rt.bucketWidth - 1) / rt.bucketWidth)
+	if count > int64(len(rt.buckets)) {
+		count = int64(len(rt.buckets))
+	}
+	if count < 1 {
+		count = 1
+	}
+	var sum int64
+	for i := int64(0); i < count; i++ {
+		sum += rt.buckets[(current-i)%int64(len(rt.buckets))]
+	}
+
+	// The current bucket is only partially complete
+	currentElapsed := time.Duration(now.UnixNano() - current*int64(rt.bucketWidth))
+	elapsed := time.Duration(count-1)*rt.bucketWidth + currentElapsed
+	if sinceStart := now.Sub(rt.start); sinceStart < elapsed {
+		elapsed = sinceStart
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(sum) / elapsed.Seconds()
+}
+
+func (rt *rateTracker) Total() int64 {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	return rt.total
+}
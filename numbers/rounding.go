@@ -0,0 +1,84 @@
+package numbers
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+)
+
+// RoundingMode determines how a value is rounded when it's between two possible results.
+type RoundingMode int
+
+const (
+	// RoundingHalfEven rounds to the nearest value, with halves rounded to the
+	// nearest even value (banker's rounding). This avoids the upward bias of
+	// RoundingHalfUp when rounding many values.
+	RoundingHalfEven RoundingMode = iota
+	// RoundingHalfUp rounds to the nearest value, with halves rounded away
+	// from zero (the same as math.Round).
+	RoundingHalfUp
+	// RoundingHalfDown rounds to the nearest value, with halves rounded towards zero.
+	RoundingHalfDown
+	// RoundingFloor rounds towards negative infinity.
+	RoundingFloor
+	// RoundingCeil rounds towards positive infinity.
+	RoundingCeil
+	// RoundingTowardZero rounds towards zero (truncation).
+	RoundingTowardZero
+	// RoundingAwayFromZero rounds away from zero.
+	RoundingAwayFromZero
+)
+
+// RoundTo rounds the value to the given number of decimal places using the given mode.
+// A negative number of places rounds to the left of the decimal point (e.g. -2 rounds
+// to the nearest hundred). NaN and infinite values are returned unchanged.
+//
+// The rounding is done on the shortest decimal representation of the value, so it
+// matches what would be expected from the printed value (e.g. 1.005 rounded half up to
+// 2 places is 1.01, even though the nearest float64 to 1.005 is slightly below it).
+func RoundTo[T constraints.Float](v T, places int, mode RoundingMode) T {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return v
+	}
+	d, err := DecimalFromFloat(f)
+	if err != nil {
+		return v
+	}
+	if places >= 0 {
+		return T(d.RoundWithMode(int32(places), mode).Float64())
+	}
+	// Shift the decimal point left, round to an integer, then shift it back
+	shift := int32(-places)
+	shifted := Decimal{
+		coef:  d.coefficient(),
+		scale: d.scale + shift,
+	}
+	rounded := shifted.RoundWithMode(0, mode)
+	return T(Decimal{
+		coef: new(big.Int).Mul(rounded.coefficient(), pow10(shift)),
+	}.Float64())
+}
+
+// RoundHalfEven rounds the value to the given number of decimal places, with halves
+// rounded to the nearest even value (banker's rounding).
+func RoundHalfEven[T constraints.Float](v T, places int) T {
+	return RoundTo(v, places, RoundingHalfEven)
+}
+
+// RoundHalfUp rounds the value to the given number of decimal places, with
+// halves rounded away from zero.
+func RoundHalfUp[T constraints.Float](v T, places int) T {
+	return RoundTo(v, places, RoundingHalfUp)
+}
+
+// FloorTo rounds the value down (towards negative infinity) to the given number of decimal places.
+func FloorTo[T constraints.Float](v T, places int) T {
+	return RoundTo(v, places, RoundingFloor)
+}
+
+// CeilTo rounds the value up (towards positive infinity) to the given number of decimal places.
+func CeilTo[T constraints.Float](v T, places int) T {
+	return RoundTo(v, places, RoundingCeil)
+}
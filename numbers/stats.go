@@ -0,0 +1,234 @@
+package numbers
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Invicton-Labs/go-common/constraints"
+)
+
+// sortedFloats converts the values to a sorted slice of float64s.
+func sortedFloats[T constraints.Simple](values []T) []float64 {
+	sorted := make([]float64, len(values))
+	for i, v := range values {
+		sorted[i] = float64(v)
+	}
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// quantileSorted gets a quantile (in the range [0, 1]) from sorted values, using
+// linear interpolation between the closest ranks.
+func quantileSorted(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 || math.IsNaN(q) || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return sorted[lower]
+	}
+	frac := pos - float64(lower)
+	return sorted[lower] + frac*(sorted[upper]-sorted[lower])
+}
+
+// Mean returns the arithmetic mean of the values, or NaN if there are no values.
+func Mean[T constraints.Simple](values []T) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += float64(v)
+	}
+	return sum / float64(len(values))
+}
+
+// Median returns the median of the values, or NaN if there are no values.
+func Median[T constraints.Simple](values []T) float64 {
+	return Percentile(values, 50)
+}
+
+// Variance returns the population variance of the values, or NaN if there are no values.
+func Variance[T constraints.Simple](values []T) float64 {
+	acc := NewAccumulator()
+	for _, v := range values {
+		acc.Add(float64(v))
+	}
+	return acc.Variance()
+}
+
+// SampleVariance returns the sample variance (with Bessel's correction) of the
+// values, or NaN if there are fewer than 2 values.
+func SampleVariance[T constraints.Simple](values []T) float64 {
+	acc := NewAccumulator()
+	for _, v := range values {
+		acc.Add(float64(v))
+	}
+	return acc.SampleVariance()
+}
+
+// StdDev returns the population standard deviation of the values, or NaN if there are no values.
+func StdDev[T constraints.Simple](values []T) float64 {
+	return math.Sqrt(Variance(values))
+}
+
+// SampleStdDev returns the sample standard deviation of the values, or NaN if
+// there are fewer than 2 values.
+func SampleStdDev[T constraints.Simple](values []T) float64 {
+	return math.Sqrt(SampleVariance(values))
+}
+
+// Percentile returns the given percentile (in the range [0, 100]) of the values, using
+// linear interpolation between the closest ranks. Returns NaN if there are no values
+// or the percentile is out of range.
+func Percentile[T constraints.Simple](values []T, percentile float64) float64 {
+	return quantileSorted(sortedFloats(values), percentile/100)
+}
+
+// Quantiles returns the given quantiles (each in the range [0, 1]) of the values, using
+// linear interpolation between the closest ranks. The values are only sorted once, so this
+// is more efficient than calling Percentile multiple times. Quantiles that are out of range
+// (or all quantiles, if there are no values) are NaN.
+func Quantiles[T constraints.Simple](values []T, quantiles ...float64) []float64 {
+	sorted := sortedFloats(values)
+	results := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		results[i] = quantileSorted(sorted, q)
+	}
+	return results
+}
+
+// Accumulator calculates statistics of a stream of values in a single pass, without
+// storing the values (using Welford's algorithm). It is not safe for concurrent use.
+type Accumulator interface {
+	// Add adds a value.
+	Add(value float64)
+	// Merge adds all of the values that were added to another accumulator.
+	Merge(other Accumulator)
+	// Count returns the number of values that have been added.
+	Count() int64
+	// Sum returns the sum of the values.
+	Sum() float64
+	// Min returns the smallest value, or NaN if there are no values.
+	Min() float64
+	// Max returns the largest value, or NaN if there are no values.
+	Max() float64
+	// Mean returns the mean of the values, or NaN if there are no values.
+	Mean() float64
+	// Variance returns the population variance, or NaN if there are no values.
+	Variance() float64
+	// SampleVariance returns the sample variance, or NaN if there are fewer than 2 values.
+	SampleVariance() float64
+	// StdDev returns the population standard deviation, or NaN if there are no values.
+	StdDev() float64
+	// SampleStdDev returns the sample standard deviation, or NaN if there are fewer than 2 values.
+	SampleStdDev() float64
+	// Reset removes all values.
+	Reset()
+}
+
+type accumulator struct {
+	count int64
+	mean  float64
+	// The sum of squared differences from the mean
+	m2  float64
+	sum float64
+	min float64
+	max float64
+}
+
+// NewAccumulator creates a new, empty accumulator.
+func NewAccumulator() Accumulator {
+	return &accumulator{}
+}
+
+func (a *accumulator) Add(value float64) {
+	a.count++
+	delta := value - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (value - a.mean)
+	a.sum += value
+	if a.count == 1 || value < a.min {
+		a.min = value
+	}
+	if a.count == 1 || value > a.max {
+		a.max = value
+	}
+}
+
+func (a *accumulator) Merge(other Accumulator) {
+	o, ok := other.(*accumulator)
+	if !ok || o.count == 0 {
+		return
+	}
+	if a.count == 0 {
+		*a = *o
+		return
+	}
+	// Chan et al.'s parallel algorithm for combining variances
+	count := a.count + o.count
+	delta := o.mean - a.mean
+	a.mean += delta * float64(o.count) / float64(count)
+	a.m2 += o.m2 + delta*delta*float64(a.count)*float64(o.count)/float64(count)
+	a.count = count
+	a.sum += o.sum
+	a.min = math.Min(a.min, o.min)
+	a.max = math.Max(a.max, o.max)
+}
+
+func (a *accumulator) Count() int64 {
+	return a.count
+}
+
+func (a *accumulator) Sum() float64 {
+	return a.sum
+}
+
+func (a *accumulator) Min() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.min
+}
+
+func (a *accumulator) Max() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.max
+}
+
+func (a *accumulator) Mean() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.mean
+}
+
+func (a *accumulator) Variance() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.m2 / float64(a.count)
+}
+
+func (a *accumulator) SampleVariance() float64 {
+	if a.count < 2 {
+		return math.NaN()
+	}
+	return a.m2 / float64(a.count-1)
+}
+
+func (a *accumulator) StdDev() float64 {
+	return math.Sqrt(a.Variance())
+}
+
+func (a *accumulator) SampleStdDev() float64 {
+	return math.Sqrt(a.SampleVariance())
+}
+
+func (a *accumulator) Reset() {
+	*a = accumulator{}
+}
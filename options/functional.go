@@ -0,0 +1,53 @@
+package options
+
+import (
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Func is a functional option that modifies a config struct of type T.
+//
+// Functional options can be added alongside an existing input struct without breaking
+// callers, by having the constructor accept them as variadic arguments:
+//
+//	func WithTimeout(timeout time.Duration) options.Func[NewInput] {
+//		return func(input *NewInput) {
+//			input.Timeout = timeout
+//		}
+//	}
+//
+//	func New(input NewInput, opts ...options.Func[NewInput]) Thing {
+//		input = options.Apply(input, opts...)
+//		...
+//	}
+type Func[T any] func(config *T)
+
+// FuncWithError is a functional option that can fail, e.g. if it validates its arguments.
+type FuncWithError[T any] func(config *T) stackerr.Error
+
+// Apply applies the options, in order, to a copy of the defaults and returns the result.
+// Nil options are skipped.
+func Apply[T any](defaults T, opts ...Func[T]) T {
+	config := defaults
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return config
+}
+
+// ApplyWithErrors applies the options, in order, to a copy of the defaults and returns the
+// result. It stops at the first option that returns an error. Nil options are skipped.
+func ApplyWithErrors[T any](defaults T, opts ...FuncWithError[T]) (T, stackerr.Error) {
+	config := defaults
+	for i, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(&config); err != nil {
+			var zero T
+			return zero, err.WithSingle("option_index", i)
+		}
+	}
+	return config, nil
+}
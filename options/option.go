@@ -0,0 +1,131 @@
+package options
+
+import (
+	"encoding/json"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// Option holds either a value (Some) or nothing (None). Unlike a pointer, it can
+// distinguish "not set" from "set to the zero value" without any allocation or
+// aliasing. The zero value is None.
+type Option[T any] struct {
+	value T
+	ok    bool
+}
+
+// Some creates an option with the given value.
+func Some[T any](value T) Option[T] {
+	return Option[T]{
+		value: value,
+		ok:    true,
+	}
+}
+
+// None creates an option with no value.
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// FromPtr creates an option from a pointer, which is None if the pointer is nil.
+func FromPtr[T any](ptr *T) Option[T] {
+	if ptr == nil {
+		return None[T]()
+	}
+	return Some(*ptr)
+}
+
+// Get returns the value and whether there is one. If there isn't, the value
+// will be the zero value.
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// IsSome returns whether the option has a value.
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+// IsNone returns whether the option has no value.
+func (o Option[T]) IsNone() bool {
+	return !o.ok
+}
+
+// OrElse returns the value if there is one, or the given default value if there isn't.
+func (o Option[T]) OrElse(defaultValue T) T {
+	if !o.ok {
+		return defaultValue
+	}
+	return o.value
+}
+
+// OrElseFunc returns the value if there is one, or the result of the given
+// function if there isn't. The function is only called if it's needed.
+func (o Option[T]) OrElseFunc(f func() T) T {
+	if !o.ok {
+		return f()
+	}
+	return o.value
+}
+
+// Ptr returns a pointer to a copy of the value, or nil if there isn't one.
+func (o Option[T]) Ptr() *T {
+	if !o.ok {
+		return nil
+	}
+	v := o.value
+	return &v
+}
+
+// Or returns the option if it has a value, or the other option if it doesn't.
+func (o Option[T]) Or(other Option[T]) Option[T] {
+	if o.ok {
+		return o
+	}
+	return other
+}
+
+// Map converts the value of an option with the given function, or returns None
+// if there is no value. This is a function rather than a method, since Go
+// methods can't have their own type parameters.
+func Map[T any, U any](o Option[T], f func(T) U) Option[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return Some(f(o.value))
+}
+
+// FlatMap converts the value of an option with a function that returns an option,
+// or returns None if there is no value.
+func FlatMap[T any, U any](o Option[T], f func(T) Option[U]) Option[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return f(o.value)
+}
+
+// MarshalJSON marshals the value, or null if there is no value.
+func (o Option[T]) MarshalJSON() ([]byte, error) {
+	if !o.ok {
+		return []byte("null"), nil
+	}
+	data, err := json.Marshal(o.value)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return data, nil
+}
+
+// UnmarshalJSON unmarshals a value, with null unmarshaling into None.
+func (o *Option[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*o = None[T]()
+		return nil
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return stackerr.Wrap(err)
+	}
+	*o = Some(value)
+	return nil
+}
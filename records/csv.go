@@ -0,0 +1,314 @@
+// Package records encodes and decodes slices of structs as CSV or JSONL, for
+// generating reports (e.g. into a bytes.Buffer that is uploaded with s3.PutObject)
+// and reading them back.
+package records
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// csvColumn is a struct field that maps to a CSV column.
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// csvColumns gets the columns for a struct type, from the `csv` struct tags. Fields tagged
+// with "-" and unexported fields are skipped, and the fields of untagged embedded structs
+// are promoted (if the embedded type is exported). Fields without a tag use the field name.
+func csvColumns(t reflect.Type) ([]csvColumn, stackerr.Error) {
+	if t.Kind() != reflect.Struct {
+		return nil, stackerr.Errorf("CSV rows must be structs, got %s", t)
+	}
+	columns := []csvColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("csv"), ",")
+		if name == "-" {
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct && !isCSVScalar(f.Type) {
+			embedded, err := csvColumns(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range embedded {
+				c.index = append([]int{i}, c.index...)
+				columns = append(columns, c)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if !isCSVScalar(ft) {
+			return nil, stackerr.Errorf("the field '%s' has type %s, which can't be a CSV column", f.Name, f.Type)
+		}
+		columns = append(columns, csvColumn{
+			name:  name,
+			index: []int{i},
+		})
+	}
+	return columns, nil
+}
+
+// isCSVScalar returns whether the type can be stored in a single CSV cell.
+func isCSVScalar(t reflect.Type) bool {
+	if t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// formatCSVCell converts a field value into a CSV cell.
+func formatCSVCell(v reflect.Value) (string, stackerr.Error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", stackerr.Wrap(err)
+		}
+		return string(text), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", stackerr.Errorf("cannot convert a %s into a CSV cell", v.Type())
+}
+
+// parseCSVCell sets a field value from a CSV cell.
+func parseCSVCell(v reflect.Value, cell string) stackerr.Error {
+	if v.Kind() == reflect.Pointer {
+		if cell == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := parseCSVCell(elem.Elem(), cell); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Type() == timeType {
+		if cell == "" {
+			v.Set(reflect.Zero(timeType))
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, cell)
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return stackerr.Wrap(v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cell)))
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(cell)
+		return nil
+	}
+	// Empty cells are the zero value for all other types
+	if cell == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return stackerr.Wrap(err)
+		}
+		v.SetFloat(f)
+	default:
+		return stackerr.Errorf("cannot convert a CSV cell into a %s", v.Type())
+	}
+	return nil
+}
+
+// fieldByIndex gets a (possibly embedded) field.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, idx := range index {
+		v = v.Field(idx)
+	}
+	return v
+}
+
+// WriteCSV writes the rows (which must be structs) as CSV, with a header row of column names.
+// The columns are the struct fields, named by their `csv` struct tags (or the field names if
+// they don't have one). Fields tagged with "-" are skipped, and the fields of untagged embedded
+// structs are included. Fields can be strings, bools, numbers, time.Time (written as RFC 3339),
+// types that implement encoding.TextMarshaler, or pointers to any of those (nil pointers are
+// written as empty cells). Quoting is handled by encoding/csv.
+func WriteCSV[T any](w io.Writer, rows []T) stackerr.Error {
+	columns, err := csvColumns(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := cw.Write(record); err != nil {
+		return stackerr.Wrap(err)
+	}
+	for rowIndex, row := range rows {
+		rv := reflect.ValueOf(row)
+		for i, c := range columns {
+			cell, err := formatCSVCell(fieldByIndex(rv, c.index))
+			if err != nil {
+				return err.With(map[string]any{
+					"row":    rowIndex,
+					"column": c.name,
+				})
+			}
+			record[i] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return stackerr.Wrap(err).WithSingle("row", rowIndex)
+		}
+	}
+	cw.Flush()
+	return stackerr.Wrap(cw.Error())
+}
+
+// ReadCSVEach reads CSV rows into structs (see WriteCSV for the supported field types), calling
+// the function with each row as it's read so that the whole file doesn't need to fit in memory.
+// The first row must be a header of column names, which are matched to the struct's fields.
+// Columns that don't match a field are ignored, and fields without a column are left as the
+// zero value. It stops at the first error.
+func ReadCSVEach[T any](r io.Reader, f func(index int, row T) stackerr.Error) stackerr.Error {
+	columns, err := csvColumns(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]csvColumn, len(columns))
+	for _, c := range columns {
+		byName[c.name] = c
+	}
+
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, cerr := cr.Read()
+	if cerr != nil {
+		if errors.Is(cerr, io.EOF) {
+			return nil
+		}
+		return stackerr.Wrap(cerr)
+	}
+	// The column (if any) for each position in the CSV
+	positions := make([]*csvColumn, len(header))
+	for i, name := range header {
+		if c, ok := byName[name]; ok {
+			positions[i] = &c
+		}
+	}
+
+	for index := 0; ; index++ {
+		record, cerr := cr.Read()
+		if cerr != nil {
+			if errors.Is(cerr, io.EOF) {
+				return nil
+			}
+			return stackerr.Wrap(cerr).WithSingle("row", index)
+		}
+		var row T
+		rv := reflect.ValueOf(&row).Elem()
+		for i, cell := range record {
+			if positions[i] == nil {
+				continue
+			}
+			if err := parseCSVCell(fieldByIndex(rv, positions[i].index), cell); err != nil {
+				return err.With(map[string]any{
+					"row":    index,
+					"column": positions[i].name,
+				})
+			}
+		}
+		if err := f(index, row); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadCSV reads all CSV rows into structs. See ReadCSVEach for details.
+func ReadCSV[T any](r io.Reader) ([]T, stackerr.Error) {
+	rows := []T{}
+	if err := ReadCSVEach(r, func(_ int, row T) stackerr.Error {
+		rows = append(rows, row)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,31 @@
+package records
+
+import (
+	"io"
+
+	"github.com/Invicton-Labs/go-common/genjson"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// WriteJSONL writes the rows as JSONL, with one compact JSON value per line.
+func WriteJSONL[T any](w io.Writer, rows []T) stackerr.Error {
+	return genjson.EncodeLines(w, rows)
+}
+
+// ReadJSONLEach reads JSONL rows, calling the function with each row as it's read so
+// that the whole file doesn't need to fit in memory. It stops at the first error.
+func ReadJSONLEach[T any](r io.Reader, f func(index int, row T) stackerr.Error) stackerr.Error {
+	return genjson.DecodeLines(r, f)
+}
+
+// ReadJSONL reads all JSONL rows.
+func ReadJSONL[T any](r io.Reader) ([]T, stackerr.Error) {
+	rows := []T{}
+	if err := ReadJSONLEach(r, func(_ int, row T) stackerr.Error {
+		rows = append(rows, row)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
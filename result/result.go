@@ -0,0 +1,156 @@
+package result
+
+import (
+	"github.com/Invicton-Labs/go-stackerr"
+	"go.uber.org/multierr"
+)
+
+// Result holds either a value or an error.
+type Result[T any] struct {
+	value T
+	err   stackerr.Error
+}
+
+// Ok creates a successful result with the given value.
+func Ok[T any](value T) Result[T] {
+	return Result[T]{
+		value: value,
+	}
+}
+
+// Fail creates a failed result with the given error. If the error is nil,
+// the result will be successful with the zero value.
+func Fail[T any](err stackerr.Error) Result[T] {
+	return Result[T]{
+		err: err,
+	}
+}
+
+// From creates a result from a (value, error) pair, as returned by most functions.
+func From[T any](value T, err stackerr.Error) Result[T] {
+	if err != nil {
+		return Fail[T](err)
+	}
+	return Ok(value)
+}
+
+// Get converts the result back into a (value, error) pair. If the result
+// is an error, the value will be the zero value.
+func (r Result[T]) Get() (T, stackerr.Error) {
+	if r.err != nil {
+		var zero T
+		return zero, r.err
+	}
+	return r.value, nil
+}
+
+// IsOk returns whether the result is successful.
+func (r Result[T]) IsOk() bool {
+	return r.err == nil
+}
+
+// IsErr returns whether the result is an error.
+func (r Result[T]) IsErr() bool {
+	return r.err != nil
+}
+
+// Err returns the error of the result, or nil if it was successful.
+func (r Result[T]) Err() stackerr.Error {
+	return r.err
+}
+
+// ValueOr returns the value if the result was successful, or the
+// given default value if it was an error.
+func (r Result[T]) ValueOr(defaultValue T) T {
+	if r.err != nil {
+		return defaultValue
+	}
+	return r.value
+}
+
+// Must returns the value if the result was successful, or panics
+// with the error if it wasn't.
+func (r Result[T]) Must() T {
+	return Must(r.value, r.err)
+}
+
+// Must returns the value if the error is nil, or panics with the error (with
+// a stack trace) if it isn't. This is intended for initialization code and
+// tests where an error can only be caused by a programming mistake.
+func Must[T any](value T, err error) T {
+	if err != nil {
+		panic(stackerr.WrapWithFrameSkips(err, 1))
+	}
+	return value
+}
+
+// Try runs the function and converts its return values into a result. If the function
+// panics, the panic is recovered and returned as the result's error.
+func Try[T any](f func() (T, stackerr.Error)) (r Result[T]) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			r = Fail[T](stackerr.FromRecover(rec))
+		}
+	}()
+	return From(f())
+}
+
+// Map transforms the value of a successful result. If the result is an error,
+// the error is passed through and the transformation function is not called.
+func Map[In any, Out any](r Result[In], transformationFunc func(value In) (Out, stackerr.Error)) Result[Out] {
+	if r.err != nil {
+		return Fail[Out](r.err)
+	}
+	return From(transformationFunc(r.value))
+}
+
+// Collect converts a slice of results into a slice of values. If any of the
+// results are errors, the returned error will combine all of the errors.
+func Collect[T any](results []Result[T]) ([]T, stackerr.Error) {
+	if results == nil {
+		return nil, nil
+	}
+	values := make([]T, len(results))
+	errs := []error{}
+	for i, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err.WithSingle("result_index", i))
+			continue
+		}
+		values[i] = r.value
+	}
+	if len(errs) > 0 {
+		return nil, stackerr.Wrap(multierr.Combine(errs...))
+	}
+	return values, nil
+}
+
+// CollectFirst converts a slice of results into a slice of values. If any of
+// the results are errors, only the first error is returned.
+func CollectFirst[T any](results []Result[T]) ([]T, stackerr.Error) {
+	if results == nil {
+		return nil, nil
+	}
+	values := make([]T, len(results))
+	for i, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		values[i] = r.value
+	}
+	return values, nil
+}
+
+// TransformSlice runs the transformation function on every value in the slice, returning a
+// result for each one. Unlike collections.TransformSliceWithErr, it doesn't stop at the
+// first error, so the results can be used with Collect to get all errors at once.
+func TransformSlice[In any, Out any](in []In, transformationFunc func(value In) (Out, stackerr.Error)) []Result[Out] {
+	if in == nil {
+		return nil
+	}
+	out := make([]Result[Out], len(in))
+	for i, v := range in {
+		out[i] = From(transformationFunc(v))
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+	"go.uber.org/multierr"
+)
+
+type Options struct {
+	// The maximum number of attempts (including the first one). If 0, it
+	// defaults to 3. If less than 0, it will be treated as unlimited, and only
+	// the errors from the last 10 attempts are kept.
+	MaxAttempts int
+	// OPTIONAL. The maximum amount of time to spend on all attempts, including
+	// waiting between them. No further attempts will be made once this is exceeded.
+	MaxElapsedTime time.Duration
+	// The minimum amount of time to wait between attempts. Defaults to 100ms.
+	WaitMin time.Duration
+	// The maximum amount of time to wait between attempts. Defaults to 10s.
+	WaitMax time.Duration
+	// OPTIONAL. A custom backoff function. If not provided, exponential
+	// backoff with full jitter will be used.
+	Backoff func(min, max time.Duration, attemptNum int) time.Duration
+	// OPTIONAL. A function that determines whether an error should be retried.
+	// If not provided, all errors other than context errors are retried.
+	IsRetryable func(err stackerr.Error) bool
+	// OPTIONAL. The logger to use for logging failed attempts. If not provided,
+	// the logger from the context (or the default logger) will be used.
+	Logger log.Logger
+}
+
+// Func is a function that can be retried.
+type Func[T any] func(ctx context.Context) (T, stackerr.Error)
+
+// Backoff returns an exponential backoff duration for the given attempt number
+// (starting at 0), without jitter, limited to the max.
+func Backoff(min, max time.Duration, attemptNum int) time.Duration {
+	return dateutils.BackoffDuration(min, max, attemptNum, dateutils.NoJitter)
+}
+
+// BackoffWithJitter returns an exponential backoff duration for the given attempt number
+// (starting at 0), with full jitter applied (a random duration between min and the
+// exponential backoff duration).
+func BackoffWithJitter(min, max time.Duration, attemptNum int) time.Duration {
+	return dateutils.BackoffDuration(min, max, attemptNum, dateutils.FullJitter)
+}
+
+// The number of attempt errors that are kept when the number of attempts is unlimited
+const maxUnlimitedAttemptErrors = 10
+
+// DefaultIsRetryable retries all errors other than context cancellations and deadlines.
+func DefaultIsRetryable(err stackerr.Error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Do will run the given function until it succeeds, the error is not retryable,
+// the maximum number of attempts or elapsed time is reached, or the context is done.
+// If it never succeeds, the returned error combines the errors from all attempts (or
+// the last 10 attempts, if the number of attempts is unlimited).
+func Do[T any](ctx context.Context, f Func[T], opts *Options) (T, stackerr.Error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	maxErrs := o.MaxAttempts
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = 3
+		maxErrs = o.MaxAttempts
+	} else if o.MaxAttempts < 0 {
+		o.MaxAttempts = math.MaxInt32
+		maxErrs = maxUnlimitedAttemptErrors
+	}
+	if o.WaitMin <= 0 {
+		o.WaitMin = 100 * time.Millisecond
+	}
+	if o.WaitMax <= 0 {
+		o.WaitMax = 10 * time.Second
+	}
+	if o.WaitMax < o.WaitMin {
+		o.WaitMax = o.WaitMin
+	}
+	if o.Backoff == nil {
+		o.Backoff = BackoffWithJitter
+	}
+	if o.IsRetryable == nil {
+		o.IsRetryable = DefaultIsRetryable
+	}
+	if o.Logger == nil {
+		o.Logger = log.FromContext(ctx)
+	}
+
+	start := time.Now()
+	errs := []error{}
+	attempts := 0
+	var zeroValue T
+
+	for attempt := 0; attempt < o.MaxAttempts; attempt++ {
+		v, err := f(ctx)
+		if err == nil {
+			return v, nil
+		}
+		attempts++
+		if len(errs) >= maxErrs {
+			// Drop the oldest error, so unlimited retries don't grow without bound
+			copy(errs, errs[1:])
+			errs = errs[:len(errs)-1]
+		}
+		errs = append(errs, err.WithSingle("attempt", attempt+1))
+
+		if !o.IsRetryable(err) || attempt+1 >= o.MaxAttempts {
+			break
+		}
+
+		wait := o.Backoff(o.WaitMin, o.WaitMax, attempt)
+		if o.MaxElapsedTime > 0 && time.Since(start)+wait > o.MaxElapsedTime {
+			break
+		}
+
+		o.Logger.Debugw("Operation failed, retrying",
+			"attempt_number", attempt+1,
+			"retry_wait", wait,
+			"error", err.Error(),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			if !timer.Stop() {
+				<-timer.C
+			}
+			errs = append(errs, ctx.Err())
+			return zeroValue, stackerr.Wrap(multierr.Combine(errs...))
+		case <-timer.C:
+		}
+	}
+
+	return zeroValue, stackerr.Wrap(multierr.Combine(errs...)).WithSingle("attempts", attempts)
+}
+
+// DoWithoutValue is the same as Do, but for functions that only return an error.
+func DoWithoutValue(ctx context.Context, f func(ctx context.Context) stackerr.Error, opts *Options) stackerr.Error {
+	_, err := Do(ctx, func(ctx context.Context) (struct{}, stackerr.Error) {
+		return struct{}{}, f(ctx)
+	}, opts)
+	return err
+}
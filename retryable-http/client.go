@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Invicton-Labs/go-common/ioutils"
 	"github.com/Invicton-Labs/go-common/log"
 	"github.com/Invicton-Labs/go-stackerr"
 	"github.com/die-net/lrucache"
@@ -89,10 +90,7 @@ func NewRoundTripper(input *NewClientInput) http.RoundTripper {
 		if resp == nil {
 			log.Debugw("Failed HTTP request, cause unknown (response is nil)")
 		} else {
-			body, _ := GetAndRewindHttpResponseBody(resp)
-			if body == nil {
-				body = []byte{}
-			}
+			body := getAndRewindHttpResponseBodyPrefix(resp, maxLoggedBodyBytes)
 			log.Debugw(
 				"Failed HTTP request",
 				"url", resp.Request.URL.String(),
@@ -169,6 +167,28 @@ func NewRoundTripper(input *NewClientInput) http.RoundTripper {
 	return retryableClient.StandardClient().Transport
 }
 
+// The maximum number of bytes of a failed response's body to log
+const maxLoggedBodyBytes = 16 * 1024
+
+// getAndRewindHttpResponseBodyPrefix reads up to `limit` bytes of the response body, and
+// rewinds it so that it can be read in full later. Only the part that was read is buffered.
+func getAndRewindHttpResponseBodyPrefix(resp *http.Response, limit int64) []byte {
+	if resp.Body == nil {
+		return []byte{}
+	}
+	body := ioutils.RewindableBody(resp.Body, limit)
+	resp.Body = body
+	b, _ := io.ReadAll(io.LimitReader(body, limit))
+	// This can't fail, since no more than the buffer size was read
+	_ = body.Rewind()
+	if b == nil {
+		b = []byte{}
+	}
+	return b
+}
+
+// GetAndRewindHttpResponseBody reads the entire response body, and replaces it
+// with an in-memory copy so that it can be read again.
 func GetAndRewindHttpResponseBody(resp *http.Response) ([]byte, stackerr.Error) {
 	if resp == nil || resp.Body == nil {
 		return nil, nil
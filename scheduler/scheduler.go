@@ -0,0 +1,356 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/gensync"
+	"github.com/Invicton-Labs/go-common/lock"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-common/metrics"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// MissedRunPolicy determines what happens when one or more scheduled runs of a job
+// were missed (e.g. because the previous run took longer than the schedule interval).
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip skips all missed runs and waits for the next scheduled time.
+	MissedRunSkip MissedRunPolicy = iota
+	// MissedRunOnce runs the job once immediately to make up for any missed runs,
+	// regardless of how many were missed.
+	MissedRunOnce
+)
+
+type JobFunc func(ctx context.Context) stackerr.Error
+
+type JobInput struct {
+	// The unique name of the job. This is also used in the distributed lock key.
+	Name string
+	// A cron expression, as accepted by dateutils.ParseCron (a standard 5-field
+	// expression, a 6-field expression with seconds, or a macro such as "@hourly").
+	Schedule string
+	// OPTIONAL. The location to evaluate the schedule in. Defaults to UTC.
+	Location *time.Location
+	// The function to run on each scheduled time.
+	Func JobFunc
+	// OPTIONAL. A maximum random delay to add to each scheduled run.
+	Jitter time.Duration
+	// OPTIONAL. The maximum amount of time that a single run may take.
+	Timeout time.Duration
+	// OPTIONAL. What to do when scheduled runs were missed. Defaults to MissedRunSkip.
+	MissedRunPolicy MissedRunPolicy
+	// OPTIONAL. If true, the job will run on every instance instead of using
+	// the distributed locker to run on only one.
+	Local bool
+}
+
+// JobStats are statistics for a single job, as observed by this process.
+type JobStats struct {
+	Name         string
+	Leader       bool
+	Runs         int64
+	Failures     int64
+	Panics       int64
+	MissedRuns   int64
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    stackerr.Error
+	NextRun      time.Time
+}
+
+type NewSchedulerInput struct {
+	// OPTIONAL. The distributed locker to use to ensure that only one instance across
+	// the fleet runs each job. If not provided, all jobs run locally.
+	Locker lock.DistributedLocker
+	// OPTIONAL. The prefix for distributed lock keys. Defaults to "scheduler/".
+	LockKeyPrefix string
+	// OPTIONAL. How often to try to become the leader for a job when another
+	// instance holds its lock. Defaults to 10 seconds.
+	LeaderRetryInterval time.Duration
+	// OPTIONAL. The clock to use for schedules and retries. If not
+	// provided, the real clock will be used.
+	Clock dateutils.Clock
+	// OPTIONAL. A metrics registry to also record each job's statistics in (with the
+	// job name as a label), e.g. so they can be flushed to CloudWatch with
+	// metrics.NewEMFExporter.
+	StatsRegistry metrics.Registry
+}
+
+// Scheduler runs jobs on cron schedules.
+type Scheduler interface {
+	// AddJob adds a job to the scheduler. If the scheduler has already been started,
+	// the job will start immediately.
+	AddJob(input JobInput) stackerr.Error
+	// Start starts running all jobs. Jobs stop when the context is done.
+	Start(ctx context.Context)
+	// Wait waits for all jobs to stop after the context given to Start is done,
+	// and returns any errors (e.g. panics outside of the jobs' functions).
+	Wait() stackerr.Error
+	// Stats returns the statistics for each job, by job name.
+	Stats() map[string]JobStats
+}
+
+type job struct {
+	JobInput
+	schedule  dateutils.CronSchedule
+	statsLock sync.Mutex
+	stats     JobStats
+}
+
+type scheduler struct {
+	input    NewSchedulerInput
+	clock    dateutils.Clock
+	metrics  *jobMetrics
+	lock     sync.Mutex
+	jobs     map[string]*job
+	ctx      context.Context
+	errGroup gensync.ErrGroup
+}
+
+// NewScheduler creates a new scheduler.
+func NewScheduler(input NewSchedulerInput) (Scheduler, stackerr.Error) {
+	if input.LockKeyPrefix == "" {
+		input.LockKeyPrefix = "scheduler/"
+	}
+	if input.LeaderRetryInterval <= 0 {
+		input.LeaderRetryInterval = 10 * time.Second
+	}
+	jm, err := newJobMetrics(input.StatsRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduler{
+		input:   input,
+		clock:   dateutils.ClockOrDefault(input.Clock),
+		metrics: jm,
+		jobs:    map[string]*job{},
+		errGroup: gensync.NewErrGroup(gensync.NewErrGroupInput{
+			CollectAllErrors: true,
+		}),
+	}, nil
+}
+
+func (s *scheduler) AddJob(input JobInput) stackerr.Error {
+	if input.Name == "" {
+		return stackerr.Errorf("the `input.Name` field must not be empty")
+	}
+	if input.Func == nil {
+		return stackerr.Errorf("the `input.Func` field must not be nil")
+	}
+	schedule, err := dateutils.ParseCronInLocation(input.Schedule, input.Location)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.jobs[input.Name]; ok {
+		return stackerr.Errorf("a job with name '%s' already exists", input.Name)
+	}
+	j := &job{
+		JobInput: input,
+		schedule: schedule,
+		stats: JobStats{
+			Name: input.Name,
+		},
+	}
+	s.jobs[input.Name] = j
+	if s.ctx != nil {
+		s.startJob(s.ctx, j)
+	}
+	return nil
+}
+
+func (s *scheduler) Start(ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.startJob(ctx, j)
+	}
+}
+
+func (s *scheduler) Wait() stackerr.Error {
+	return s.errGroup.Wait()
+}
+
+func (s *scheduler) Stats() map[string]JobStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stats := make(map[string]JobStats, len(s.jobs))
+	for name, j := range s.jobs {
+		j.statsLock.Lock()
+		stats[name] = j.stats
+		j.statsLock.Unlock()
+	}
+	return stats
+}
+
+func (s *scheduler) startJob(ctx context.Context, j *job) {
+	// Panics are recovered by the errgroup, and annotated with the job name
+	s.errGroup.GoNamed(j.Name, func() stackerr.Error {
+		if j.Local || s.input.Locker == nil {
+			s.setLeader(j, true)
+			s.runSchedule(ctx, j)
+			return nil
+		}
+		s.campaign(ctx, j)
+		return nil
+	})
+}
+
+// campaign repeatedly tries to acquire the distributed lock for the job, and
+// runs the job's schedule while the lock is held.
+func (s *scheduler) campaign(ctx context.Context, j *job) {
+	logger := log.With("job_name", j.Name)
+	// Back off further on consecutive errors, so a failing lock table isn't hammered
+	errBackoff := dateutils.NewBackoff(dateutils.NewBackoffInput{
+		Min:    s.input.LeaderRetryInterval,
+		Max:    10 * s.input.LeaderRetryInterval,
+		Jitter: dateutils.FullJitter,
+		Clock:  s.clock,
+	})
+	for ctx.Err() == nil {
+		lockCtx, heldLock, _, err := s.input.Locker.Lock(ctx, s.input.LockKeyPrefix+j.Name, map[string]any{
+			"job_name": j.Name,
+			"schedule": j.Schedule,
+		})
+		retryWait := s.input.LeaderRetryInterval
+		if err != nil {
+			logger.Error(err)
+			retryWait, _ = errBackoff.Next()
+		} else {
+			errBackoff.Reset()
+		}
+		if heldLock == nil {
+			select {
+			case <-ctx.Done():
+			case <-s.clock.After(retryWait):
+			}
+			continue
+		}
+
+		logger.Infow("Became leader for scheduled job")
+		s.setLeader(j, true)
+		s.runSchedule(lockCtx, j)
+		s.setLeader(j, false)
+
+		// Use a separate context for unlocking, since the original is likely done
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := heldLock.Unlock(unlockCtx); err != nil && ctx.Err() == nil {
+			logger.Error(err)
+		}
+		cancel()
+	}
+}
+
+// runSchedule runs the job on its schedule until the context is done.
+func (s *scheduler) runSchedule(ctx context.Context, j *job) {
+	next := j.schedule.Next(s.clock.Now())
+	for !next.IsZero() {
+		j.setNextRun(next)
+		wait := s.clock.Until(next)
+		if j.Jitter > 0 {
+			wait += numbers.RandomIntInRange(0, j.Jitter-1)
+		}
+		timer := s.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			if !timer.Stop() {
+				<-timer.C()
+			}
+			return
+		case <-timer.C():
+		}
+
+		s.runOnce(ctx, j)
+
+		following := j.schedule.Next(next)
+		now := s.clock.Now()
+		if !following.After(now) {
+			// Count how many runs were missed
+			missed := int64(0)
+			for t := following; !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+				missed++
+			}
+			switch j.MissedRunPolicy {
+			case MissedRunOnce:
+				// Run immediately, and don't count the one we're making up for
+				missed--
+				following = now
+			default:
+				following = j.schedule.Next(now)
+			}
+			if missed > 0 {
+				j.statsLock.Lock()
+				j.stats.MissedRuns += missed
+				j.statsLock.Unlock()
+				s.metrics.missed(j.Name, missed)
+				log.Warnw("Scheduled job missed runs", "job_name", j.Name, "missed_runs", missed)
+			}
+		}
+		next = following
+	}
+}
+
+// runOnce runs the job a single time, recording stats and recovering panics.
+func (s *scheduler) runOnce(ctx context.Context, j *job) {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	start := s.clock.Now()
+	panicked := false
+	err := func() (err stackerr.Error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+				err = stackerr.FromRecover(r)
+			}
+		}()
+		return j.Func(ctx)
+	}()
+	duration := s.clock.Since(start)
+
+	j.statsLock.Lock()
+	j.stats.Runs++
+	j.stats.LastRun = start
+	j.stats.LastDuration = duration
+	j.stats.LastError = err
+	if err != nil {
+		j.stats.Failures++
+	}
+	if panicked {
+		j.stats.Panics++
+	}
+	j.statsLock.Unlock()
+	s.metrics.ran(j.Name, duration, err != nil, panicked)
+
+	if err != nil {
+		log.With("job_name", j.Name, "job_duration", duration).Error(err)
+	} else {
+		log.Debugw("Scheduled job completed", "job_name", j.Name, "job_duration", duration)
+	}
+}
+
+// setLeader records whether this process is the leader for a job.
+func (s *scheduler) setLeader(j *job, leader bool) {
+	j.statsLock.Lock()
+	j.stats.Leader = leader
+	j.statsLock.Unlock()
+	s.metrics.setLeader(j.Name, leader)
+}
+
+func (j *job) setNextRun(next time.Time) {
+	j.statsLock.Lock()
+	defer j.statsLock.Unlock()
+	j.stats.NextRun = next
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/Invicton-Labs/go-common/metrics"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The histogram buckets for job run durations, from 10ms to about 3 hours
+var jobDurationBuckets, _ = numbers.ExponentialBuckets(0.01, 4, 11)
+
+// jobMetrics are the metrics that job statistics are recorded in. A nil
+// *jobMetrics is valid, and doesn't record anything.
+type jobMetrics struct {
+	runs            metrics.Counter
+	failures        metrics.Counter
+	panics          metrics.Counter
+	missedRuns      metrics.Counter
+	leader          metrics.Gauge
+	durationSeconds metrics.Histogram
+}
+
+// newJobMetrics creates the job metrics in a registry. It returns nil if there's no registry.
+func newJobMetrics(registry metrics.Registry) (*jobMetrics, stackerr.Error) {
+	if registry == nil {
+		return nil, nil
+	}
+	jm := &jobMetrics{}
+	var err stackerr.Error
+	for _, counter := range []struct {
+		counter *metrics.Counter
+		name    string
+		help    string
+	}{
+		{&jm.runs, "scheduler_job_runs_total", "The number of times a scheduled job was run"},
+		{&jm.failures, "scheduler_job_failures_total", "The number of scheduled job runs that returned an error or panicked"},
+		{&jm.panics, "scheduler_job_panics_total", "The number of scheduled job runs that panicked"},
+		{&jm.missedRuns, "scheduler_job_missed_runs_total", "The number of scheduled job runs that were missed"},
+	} {
+		if *counter.counter, err = registry.Counter(counter.name, counter.help); err != nil {
+			return nil, err
+		}
+	}
+	if jm.leader, err = registry.Gauge("scheduler_job_leader", "Whether this process is the leader for a scheduled job (1) or not (0)"); err != nil {
+		return nil, err
+	}
+	if jm.durationSeconds, err = registry.Histogram("scheduler_job_duration_seconds", "The time a scheduled job run took", jobDurationBuckets); err != nil {
+		return nil, err
+	}
+	return jm, nil
+}
+
+// ran records a run of a job.
+func (jm *jobMetrics) ran(name string, duration time.Duration, failed bool, panicked bool) {
+	if jm == nil {
+		return
+	}
+	labels := metrics.Labels{"job": name}
+	jm.runs.Inc(labels)
+	if failed {
+		jm.failures.Inc(labels)
+	}
+	if panicked {
+		jm.panics.Inc(labels)
+	}
+	jm.durationSeconds.Observe(duration.Seconds(), labels)
+}
+
+// missed records runs of a job that were missed.
+func (jm *jobMetrics) missed(name string, missed int64) {
+	if jm == nil {
+		return
+	}
+	jm.missedRuns.Add(float64(missed), metrics.Labels{"job": name})
+}
+
+// setLeader records whether this process is the leader for a job.
+func (jm *jobMetrics) setLeader(name string, leader bool) {
+	if jm == nil {
+		return
+	}
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	jm.leader.Set(value, metrics.Labels{"job": name})
+}
@@ -0,0 +1,65 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+const lambdaExtensionApiVersion = "2020-01-01"
+
+// RegisterLambdaExtension registers an internal Lambda extension for the current
+// process. The Lambda runtime only sends SIGTERM to the function process before
+// shutting down an execution environment if at least one extension is registered,
+// so this must be called (during init, before the Lambda handler is started) for
+// ListenForSignals to see Lambda shutdowns. It does nothing and returns nil if the
+// process is not running in Lambda.
+//
+// The extension doesn't subscribe to any events; it only exists so that the
+// runtime sends the shutdown signal.
+func RegisterLambdaExtension(ctx context.Context) stackerr.Error {
+	runtimeApi := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if runtimeApi == "" {
+		return nil
+	}
+	baseUrl := fmt.Sprintf("http://%s/%s/extension", runtimeApi, lambdaExtensionApiVersion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseUrl+"/register", bytes.NewReader([]byte(`{"events":[]}`)))
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	req.Header.Set("Lambda-Extension-Name", filepath.Base(os.Args[0]))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return stackerr.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return stackerr.Errorf("failed to register Lambda extension (status %d): %s", resp.StatusCode, string(body))
+	}
+	extensionId := resp.Header.Get("Lambda-Extension-Identifier")
+
+	// An extension must request the next event to signal that it has finished
+	// initializing. Since it isn't subscribed to any events, this call blocks
+	// until the execution environment is shut down.
+	go func() {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, baseUrl+"/event/next", nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Lambda-Extension-Identifier", extensionId)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+	}()
+	return nil
+}
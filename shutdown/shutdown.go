@@ -0,0 +1,289 @@
+package shutdown
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/lock"
+	"github.com/Invicton-Labs/go-common/log"
+	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/google/uuid"
+	"go.uber.org/multierr"
+)
+
+// Hook is a function that is called when the process is shutting down.
+type Hook func(ctx context.Context) stackerr.Error
+
+type HookInput struct {
+	// The name of the hook, used for logging and errors.
+	Name string
+	// Hooks are run in ascending order of priority. Hooks with
+	// equal priority are run in the order they were registered.
+	Priority int
+	// OPTIONAL. The maximum amount of time the hook may run for. If
+	// not provided, the manager's default hook timeout will be used.
+	Timeout time.Duration
+	// The function to run.
+	Hook Hook
+}
+
+// Registration is a registered shutdown hook.
+type Registration interface {
+	// Close will deregister the hook so it won't be run on shutdown.
+	Close()
+}
+
+type registration struct {
+	id      string
+	manager *manager
+}
+
+func (r registration) Close() {
+	r.manager.lock.Lock()
+	defer r.manager.lock.Unlock()
+	delete(r.manager.hooks, r.id)
+}
+
+type registeredHook struct {
+	HookInput
+	sequence int
+}
+
+// Manager coordinates a graceful shutdown of the process.
+type Manager interface {
+	// Register will register a hook to be run on shutdown.
+	Register(input HookInput) (Registration, stackerr.Error)
+
+	// ListenForSignals will start a routine that waits for a SIGINT or SIGTERM
+	// and then runs the shutdown. The routine exits without shutting down
+	// if the context is done before a signal is received.
+	//
+	// The Lambda runtime only sends SIGTERM before shutting down an execution
+	// environment if an extension is registered; call RegisterLambdaExtension
+	// during init to make sure it does.
+	ListenForSignals(ctx context.Context)
+
+	// Shutdown will run all registered hooks in priority order, then release
+	// all distributed locks held by this process, then drain the log write hooks.
+	// It only runs once; subsequent calls wait for the first to finish and return
+	// the same result.
+	Shutdown(ctx context.Context) stackerr.Error
+
+	// Done returns a channel that is closed once a shutdown has completed.
+	Done() <-chan struct{}
+
+	// Err returns the error (if any) of a completed shutdown.
+	Err() stackerr.Error
+}
+
+type NewManagerInput struct {
+	// OPTIONAL. The default maximum amount of time that each hook may run for.
+	// Defaults to 5 seconds.
+	DefaultHookTimeout time.Duration
+	// OPTIONAL. The maximum amount of time that an entire signal-triggered
+	// shutdown may take. Defaults to 30 seconds.
+	SignalShutdownTimeout time.Duration
+	// OPTIONAL. Whether to skip releasing distributed locks held by this process.
+	SkipLockRelease bool
+	// OPTIONAL. Whether to skip draining the log write hooks.
+	SkipLogDrain bool
+}
+
+type manager struct {
+	input    NewManagerInput
+	lock     sync.Mutex
+	hooks    map[string]registeredHook
+	sequence int
+	once     sync.Once
+	done     chan struct{}
+	err      stackerr.Error
+}
+
+// NewManager creates a new shutdown manager.
+func NewManager(input NewManagerInput) Manager {
+	if input.DefaultHookTimeout <= 0 {
+		input.DefaultHookTimeout = 5 * time.Second
+	}
+	if input.SignalShutdownTimeout <= 0 {
+		input.SignalShutdownTimeout = 30 * time.Second
+	}
+	return &manager{
+		input: input,
+		hooks: map[string]registeredHook{},
+		done:  make(chan struct{}),
+	}
+}
+
+func (m *manager) Register(input HookInput) (Registration, stackerr.Error) {
+	if input.Hook == nil {
+		return nil, stackerr.Errorf("the `input.Hook` field must not be nil")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	select {
+	case <-m.done:
+		return nil, stackerr.Errorf("cannot register shutdown hook '%s', shutdown has already completed", input.Name)
+	default:
+	}
+	reg := registration{
+		id:      uuid.NewString(),
+		manager: m,
+	}
+	m.hooks[reg.id] = registeredHook{
+		HookInput: input,
+		sequence:  m.sequence,
+	}
+	m.sequence++
+	return reg, nil
+}
+
+func (m *manager) ListenForSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			log.Infow("Shutdown signal received", "signal", sig.String())
+		}
+		// Use a fresh context, since the one we were given
+		// may be cancelled as part of the shutdown.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), m.input.SignalShutdownTimeout)
+		defer cancel()
+		if err := m.Shutdown(shutdownCtx); err != nil {
+			log.Error(err)
+		}
+	}()
+}
+
+// sortedHooks returns the registered hooks in the order they should be run.
+func (m *manager) sortedHooks() []registeredHook {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	hooks := make([]registeredHook, 0, len(m.hooks))
+	for _, hook := range m.hooks {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].Priority != hooks[j].Priority {
+			return hooks[i].Priority < hooks[j].Priority
+		}
+		return hooks[i].sequence < hooks[j].sequence
+	})
+	return hooks
+}
+
+// runHook runs a single hook with its timeout, converting any panic to an error.
+func (m *manager) runHook(ctx context.Context, hook registeredHook) (err stackerr.Error) {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = m.input.DefaultHookTimeout
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan stackerr.Error, 1)
+	go func() {
+		var err stackerr.Error
+		defer func() {
+			if r := recover(); r != nil {
+				err = stackerr.FromRecover(r)
+			}
+			result <- err
+		}()
+		err = hook.Hook(hookCtx)
+	}()
+
+	// Don't wait on a hook that ignores its context
+	select {
+	case err = <-result:
+	case <-hookCtx.Done():
+		err = stackerr.Errorf("shutdown hook timed out")
+	}
+	if err != nil {
+		return err.With(map[string]any{
+			"shutdown_hook":     hook.Name,
+			"shutdown_priority": hook.Priority,
+		})
+	}
+	return nil
+}
+
+func (m *manager) Shutdown(ctx context.Context) stackerr.Error {
+	m.once.Do(func() {
+		defer close(m.done)
+		errs := []error{}
+
+		for _, hook := range m.sortedHooks() {
+			log.Debugw("Running shutdown hook", "shutdown_hook", hook.Name, "shutdown_priority", hook.Priority)
+			if err := m.runHook(ctx, hook); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if !m.input.SkipLockRelease {
+			if err := lock.UnlockAll(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			m.err = stackerr.Wrap(multierr.Combine(errs...))
+			log.Error(m.err)
+		}
+
+		if !m.input.SkipLogDrain {
+			// Drain after logging any errors, so that alerts for them are sent
+			if err := log.DrainWriteHooks(ctx); err != nil && m.err == nil {
+				m.err = err
+			}
+			// Ignore Sync errors, since syncing stdout/stderr
+			// fails on some platforms.
+			_ = log.Sync()
+		}
+	})
+	<-m.done
+	return m.err
+}
+
+func (m *manager) Done() <-chan struct{} {
+	return m.done
+}
+
+func (m *manager) Err() stackerr.Error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
+
+var defaultManager = NewManager(NewManagerInput{})
+
+// Register will register a hook with the default manager.
+func Register(input HookInput) (Registration, stackerr.Error) {
+	return defaultManager.Register(input)
+}
+
+// ListenForSignals will start listening for shutdown signals with the default manager.
+func ListenForSignals(ctx context.Context) {
+	defaultManager.ListenForSignals(ctx)
+}
+
+// Shutdown will run a shutdown with the default manager.
+func Shutdown(ctx context.Context) stackerr.Error {
+	return defaultManager.Shutdown(ctx)
+}
+
+// Done returns a channel that is closed once the default manager has completed a shutdown.
+func Done() <-chan struct{} {
+	return defaultManager.Done()
+}
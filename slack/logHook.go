@@ -11,9 +11,10 @@ import (
 	"github.com/Invicton-Labs/go-common/collections"
 	"github.com/Invicton-Labs/go-common/dateutils"
 	"github.com/Invicton-Labs/go-common/log"
-	"github.com/Invicton-Labs/go-common/numbers"
 	retryablehttp "github.com/Invicton-Labs/go-common/retryable-http"
 	"github.com/Invicton-Labs/go-common/slack/links"
+	"github.com/Invicton-Labs/go-common/strutils"
+	"github.com/Invicton-Labs/go-common/textutils"
 	"github.com/Invicton-Labs/go-stackerr"
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/slack-go/slack"
@@ -39,7 +40,38 @@ func formatLevel(level zapcore.Level) string {
 	}
 }
 
-func formatStackErr(err log.StackError) []slack.Block {
+// formatFieldBlock formats a field for a section block, escaping the value
+// unless it's already formatted for Slack, and truncating it to the limit.
+func formatFieldBlock(key string, value string, slackFormatted bool, limit int) (*slack.TextBlockObject, stackerr.Error) {
+	if !slackFormatted {
+		value = textutils.SlackEscape(value)
+	}
+	msg, err := textutils.Execute(fieldBlockTemplate, map[string]string{
+		"Key":   key,
+		"Value": value,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slack.NewTextBlockObject(slack.MarkdownType, strutils.Truncate(msg, limit), false, false), nil
+}
+
+// formatValue formats an arbitrary field value, and returns whether
+// it's already formatted for Slack (and must not be escaped).
+func formatValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return formatTime(v), true
+	case *time.Time:
+		return formatTime(*v), true
+	case links.SlackLink:
+		return v.SlackFormat(), true
+	default:
+		return fmt.Sprintf("%v", value), false
+	}
+}
+
+func formatStackErr(err log.StackError, blockLengthLimit int) ([]slack.Block, stackerr.Error) {
 	blocks := make([]slack.Block, 0, 5)
 
 	// Start with a divider
@@ -47,19 +79,29 @@ func formatStackErr(err log.StackError) []slack.Block {
 
 	var errHeader string
 	if err.Key != "" {
-		errHeader = fmt.Sprintf("*Error:* %s", err.Key)
+		errHeader = fmt.Sprintf("*Error:* %s", textutils.SlackEscape(err.Key))
 	} else {
 		errHeader = "*Error*"
 	}
-	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, errHeader, false, false), nil, nil))
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strutils.Truncate(errHeader, blockLengthLimit), false, false), nil, nil))
 
 	fields := make([]*slack.TextBlockObject, 0, len(err.Fields)+1)
 	if err.Key != "" {
-		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Key*\n%s", err.Key), false, false))
+		field, ferr := formatFieldBlock("Key", err.Key, false, blockLengthLimit)
+		if ferr != nil {
+			return nil, ferr
+		}
+		fields = append(fields, field)
+	}
+	gen := collections.MapAscending(err.Fields)
+	for key, value, ok := gen(); ok; key, value, ok = gen() {
+		val, slackFormatted := formatValue(value)
+		field, ferr := formatFieldBlock(key, val, slackFormatted, blockLengthLimit)
+		if ferr != nil {
+			return nil, ferr
+		}
+		fields = append(fields, field)
 	}
-	fields = append(fields, collections.TransformMapToSlice(err.Fields, func(key string, value any) *slack.TextBlockObject {
-		return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%v", key, value), false, false)
-	})...)
 
 	// If there are fields, add them
 	if len(fields) > 0 {
@@ -72,7 +114,7 @@ func formatStackErr(err log.StackError) []slack.Block {
 	// Add the stack traces
 	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "```\n"+err.Stacktraces.Format()+"\n```", false, false), nil, nil))
 
-	return blocks
+	return blocks, nil
 }
 
 // Formats a time as a
@@ -83,6 +125,13 @@ func formatTime(t time.Time) string {
 	return fmt.Sprintf("<!date^%d^{date_num} {time_secs}|%s>", t.Unix(), t.Format(time.RFC3339))
 }
 
+// The templates for the markdown blocks of an alert. Values that are already formatted
+// for Slack (times and links) are passed as-is, and everything else is escaped first.
+var (
+	fieldBlockTemplate   = textutils.MustParse("slack_field_block", "*{{ slackEscape .Key }}*\n{{ .Value }}")
+	messageBlockTemplate = textutils.MustParse("slack_message_block", "*Message*\n{{ slackEscape .Message }}")
+)
+
 func NewSlackHook(ctx context.Context, params *SlackParameter, level zapcore.Level) log.ZapWriteHook {
 
 	httpClient := &http.Client{
@@ -113,6 +162,8 @@ func NewSlackHook(ctx context.Context, params *SlackParameter, level zapcore.Lev
 		gen := collections.MapAscending(fields)
 		for _, field, ok := gen(); ok; _, field, ok = gen() {
 			var val string
+			// Whether the value is already formatted for Slack, and must not be escaped
+			slackFormatted := false
 			switch field.Type {
 			case zapcore.BoolType:
 				val = fmt.Sprintf("%t", field.Integer == 1)
@@ -144,9 +195,11 @@ func NewSlackHook(ctx context.Context, params *SlackParameter, level zapcore.Lev
 				// representable by a UnixNano() stored as an int64.
 			case zapcore.TimeType:
 				val = formatTime(dateutils.TimeFromUnix(field.Integer))
+				slackFormatted = true
 				// TimeFullType indicates that the field carries a time.Time stored as-is.
 			case zapcore.TimeFullType:
 				val = formatTime(field.Interface.(time.Time))
+				slackFormatted = true
 				// Uint64Type indicates that the field carries a uint64.
 			case zapcore.Uint64Type:
 				val = fmt.Sprintf("%d", field.Integer)
@@ -172,37 +225,40 @@ func NewSlackHook(ctx context.Context, params *SlackParameter, level zapcore.Lev
 				if field.String != "" {
 					val = field.String
 				} else if field.Interface != nil {
-					switch v := field.Interface.(type) {
-					case time.Time:
-						val = formatTime(v)
-					case *time.Time:
-						val = formatTime(*v)
-					case links.SlackLink:
-						val = v.SlackFormat()
-					default:
-						val = fmt.Sprintf("%v", field.Interface)
-					}
+					val, slackFormatted = formatValue(field.Interface)
 				} else if field.Integer != 0 {
 					val = fmt.Sprint(field.Integer)
 				} else {
 					val = "N/A"
 				}
 			}
-			msg := fmt.Sprintf("*%s*\n%s", field.Key, val)
-			payloadFields = append(payloadFields, slack.NewTextBlockObject(slack.MarkdownType, msg[0:numbers.Min(len(msg), blockLengthLimit)], false, false))
+			block, err := formatFieldBlock(field.Key, val, slackFormatted, blockLengthLimit)
+			if err != nil {
+				return err
+			}
+			payloadFields = append(payloadFields, block)
 		}
 		blocks := []slack.Block{
 			slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf(":rotating_light: Monitoring Alert: %s", e.LoggerName), false, false)),
 			slack.NewSectionBlock(nil, payloadFields, nil),
 		}
 		if len(e.Message) > 0 {
-			msg := "*Message*\n" + e.Message
-			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg[0:numbers.Min(len(msg), blockLengthLimit)], false, false), nil, nil))
+			msg, err := textutils.Execute(messageBlockTemplate, map[string]string{
+				"Message": e.Message,
+			})
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strutils.Truncate(msg, blockLengthLimit), false, false), nil, nil))
 		}
 
 		// Add fields for each error
 		for _, err := range errs {
-			blocks = append(blocks, formatStackErr(err)...)
+			errBlocks, ferr := formatStackErr(err, blockLengthLimit)
+			if ferr != nil {
+				return ferr
+			}
+			blocks = append(blocks, errBlocks...)
 		}
 		blocks = append(blocks, slack.NewDividerBlock())
 
@@ -213,9 +269,10 @@ func NewSlackHook(ctx context.Context, params *SlackParameter, level zapcore.Lev
 			msgLines := strings.Split(stacktraces.Format(), "\n")
 			msg := ""
 			for _, l := range msgLines {
-				// A single line can never have more than the block length
+				// A single line can never have more than the block length. The limit is
+				// in characters, but the checks below use bytes, which is more conservative.
 				if len(l) > stackBlockLengthLimit {
-					l = l[0:stackBlockLengthLimit]
+					l = strutils.Truncate(l, stackBlockLengthLimit)
 				}
 				// Check if we would go over the limit if we append a newline and this line
 				if len(msg)+1+len(l) > stackBlockLengthLimit {
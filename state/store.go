@@ -0,0 +1,409 @@
+// Package state provides a small DynamoDB-backed key-value store for bits of
+// state (e.g. cursors, watermarks, and last-run timestamps), with optimistic
+// concurrency and optional expiry.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/conversions"
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-stackerr"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrVersionConflict is returned when a conditional write fails because the
+// stored version isn't the expected one (i.e. something else changed it).
+var ErrVersionConflict = errors.New("state version conflict")
+
+// Encoding is how values are stored in the value attribute.
+type Encoding int
+
+const (
+	// Values are stored as native DynamoDB attributes (see attributevalue.Marshal),
+	// so they can be read and queried by other DynamoDB tools.
+	EncodingAttributeValue Encoding = iota
+	// Values are stored as a JSON string (see json.Marshal), so they can use
+	// custom JSON marshaling.
+	EncodingJSON
+)
+
+// Item is a stored value, along with its metadata.
+type Item[T any] struct {
+	// The stored value
+	Value T
+	// The version of the value, which starts at 1 and is incremented on each write
+	Version int64
+	// When the value expires, or the zero time if it doesn't
+	Expires time.Time
+}
+
+// Store is a key-value store for values of a single type.
+type Store[T any] interface {
+	// Get gets the value for the key. The boolean is false if there isn't
+	// one (or it has expired).
+	Get(ctx context.Context, key string) (Item[T], bool, stackerr.Error)
+
+	// Put stores the value for the key, regardless of the current version, and
+	// returns the new version.
+	Put(ctx context.Context, key string, value T) (int64, stackerr.Error)
+
+	// PutIfVersion stores the value for the key only if the current version is the
+	// expected one (use 0 to require that there's no current value), and returns the
+	// new version. If the version doesn't match, it returns an error wrapping
+	// ErrVersionConflict.
+	PutIfVersion(ctx context.Context, key string, value T, expectedVersion int64) (int64, stackerr.Error)
+
+	// UpdateWith reads the current value for the key (found is false if there isn't
+	// one), calls the update function with it, and stores the result if the value
+	// hasn't changed in the meantime. On a version conflict, it retries (with a backoff)
+	// with the new current value. It returns the stored item.
+	UpdateWith(ctx context.Context, key string, update func(current T, found bool) (T, stackerr.Error)) (Item[T], stackerr.Error)
+
+	// Delete deletes the value for the key. It doesn't return an error if there isn't one.
+	Delete(ctx context.Context, key string) stackerr.Error
+}
+
+type NewStoreInput struct {
+	// The ARN of the DynamoDB table, which must have a string partition key (and no sort key).
+	TableArn string
+	// The name of the table's partition key.
+	KeyColumn string
+	// OPTIONAL. A prefix to add to all keys, so that multiple stores can share a table.
+	KeyPrefix string
+	// OPTIONAL. The attribute to store values in. Defaults to "Value".
+	ValueColumn string
+	// OPTIONAL. The attribute to store versions in. Defaults to "Version".
+	VersionColumn string
+	// OPTIONAL. How values are encoded. Defaults to EncodingAttributeValue.
+	Encoding Encoding
+	// OPTIONAL. How long values are kept after each write. If provided, TtlColumn must
+	// also be provided. Expired values are never returned, even if DynamoDB hasn't
+	// deleted them yet.
+	TTL time.Duration
+	// OPTIONAL. The attribute to store the expiry in, as Unix seconds. This should be the
+	// table's TTL attribute, so that DynamoDB deletes expired values.
+	TtlColumn string
+	// OPTIONAL. The backoff to use between UpdateWith attempts after version conflicts. If
+	// not provided, it starts at 25ms and increases up to 1s, with full jitter, for up to
+	// 10 attempts.
+	UpdateBackoff *dateutils.NewBackoffInput
+	// OPTIONAL. An AWS config to use. If not provided,
+	// the default config will be used.
+	AwsConfig *aws.Config
+	// OPTIONAL. The clock to use for expiry times. If not provided,
+	// the real clock will be used.
+	Clock dateutils.Clock
+}
+
+type store[T any] struct {
+	input     NewStoreInput
+	client    *dynamodb.Client
+	tableName string
+	clock     dateutils.Clock
+}
+
+// NewStore creates a new DynamoDB-based state store.
+func NewStore[T any](ctx context.Context, input NewStoreInput) (Store[T], stackerr.Error) {
+	if input.TableArn == "" {
+		return nil, stackerr.Errorf("the `input.TableArn` field must not be empty")
+	}
+	if input.KeyColumn == "" {
+		return nil, stackerr.Errorf("the `input.KeyColumn` field must not be empty")
+	}
+	if input.TTL < 0 {
+		return nil, stackerr.Errorf("the `input.TTL` field must not be negative")
+	}
+	if input.TTL > 0 && input.TtlColumn == "" {
+		return nil, stackerr.Errorf("the `input.TtlColumn` field must be provided if `input.TTL` is")
+	}
+	if input.Encoding != EncodingAttributeValue && input.Encoding != EncodingJSON {
+		return nil, stackerr.Errorf("unknown encoding: %d", input.Encoding)
+	}
+	if input.ValueColumn == "" {
+		input.ValueColumn = "Value"
+	}
+	if input.VersionColumn == "" {
+		input.VersionColumn = "Version"
+	}
+	if input.UpdateBackoff == nil {
+		input.UpdateBackoff = &dateutils.NewBackoffInput{
+			Min:         25 * time.Millisecond,
+			Max:         time.Second,
+			Jitter:      dateutils.FullJitter,
+			MaxAttempts: 10,
+		}
+	}
+
+	a, cerr := arn.Parse(input.TableArn)
+	if cerr != nil {
+		return nil, stackerr.Wrap(cerr)
+	}
+
+	var cfg aws.Config
+	if input.AwsConfig != nil {
+		cfg = *input.AwsConfig
+	} else {
+		var err error
+		cfg, err = config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		// Set the region to match the DynamoDB table's region
+		cfg.Region = a.Region
+	}
+
+	clock := dateutils.ClockOrDefault(input.Clock)
+	// Copy the backoff input, so the caller's isn't modified
+	updateBackoff := *input.UpdateBackoff
+	input.UpdateBackoff = &updateBackoff
+	if input.UpdateBackoff.Clock == nil {
+		input.UpdateBackoff.Clock = clock
+	}
+
+	return &store[T]{
+		input:     input,
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: strings.TrimPrefix(a.Resource, "table/"),
+		clock:     clock,
+	}, nil
+}
+
+// itemKey gets the DynamoDB key for a state key.
+func (s *store[T]) itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		s.input.KeyColumn: &types.AttributeValueMemberS{
+			Value: s.input.KeyPrefix + key,
+		},
+	}
+}
+
+func (s *store[T]) encode(value T) (types.AttributeValue, stackerr.Error) {
+	if s.input.Encoding == EncodingJSON {
+		j, err := json.Marshal(value)
+		if err != nil {
+			return nil, stackerr.Wrap(err)
+		}
+		return &types.AttributeValueMemberS{
+			Value: string(j),
+		}, nil
+	}
+	av, err := attributevalue.Marshal(value)
+	if err != nil {
+		return nil, stackerr.Wrap(err)
+	}
+	return av, nil
+}
+
+func (s *store[T]) decode(av types.AttributeValue) (T, stackerr.Error) {
+	var value T
+	if s.input.Encoding == EncodingJSON {
+		j, ok := av.(*types.AttributeValueMemberS)
+		if !ok {
+			return value, stackerr.Errorf("value attribute is not a string, so it can't be decoded as JSON")
+		}
+		if err := json.Unmarshal([]byte(j.Value), &value); err != nil {
+			return value, stackerr.Wrap(err)
+		}
+		return value, nil
+	}
+	if err := attributevalue.Unmarshal(av, &value); err != nil {
+		return value, stackerr.Wrap(err)
+	}
+	return value, nil
+}
+
+func (s *store[T]) Get(ctx context.Context, key string) (Item[T], bool, stackerr.Error) {
+	var item Item[T]
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &s.tableName,
+		Key:            s.itemKey(key),
+		ConsistentRead: conversions.GetPtr(true),
+	})
+	if err != nil {
+		return item, false, stackerr.Wrap(err).WithSingle("state_key", key)
+	}
+	if len(output.Item) == 0 {
+		return item, false, nil
+	}
+
+	if versionValue, ok := output.Item[s.input.VersionColumn]; ok {
+		if err := attributevalue.Unmarshal(versionValue, &item.Version); err != nil {
+			return item, false, stackerr.Errorf("Version attribute is not of expected type").WithSingle("state_key", key)
+		}
+	}
+	if s.input.TtlColumn != "" {
+		if ttlValue, ok := output.Item[s.input.TtlColumn]; ok {
+			var expiresUnix int64
+			if err := attributevalue.Unmarshal(ttlValue, &expiresUnix); err != nil {
+				return item, false, stackerr.Errorf("TTL attribute is not of expected type").WithSingle("state_key", key)
+			}
+			item.Expires = dateutils.TimeFromUnixSeconds(expiresUnix)
+			// DynamoDB can take a while to delete expired items
+			if !item.Expires.After(s.clock.Now()) {
+				return Item[T]{}, false, nil
+			}
+		}
+	}
+	if valueAttribute, ok := output.Item[s.input.ValueColumn]; ok {
+		value, err := s.decode(valueAttribute)
+		if err != nil {
+			return item, false, err.WithSingle("state_key", key)
+		}
+		item.Value = value
+	}
+	return item, true, nil
+}
+
+// put stores the value. If conditional is true, it only stores it if the current
+// version is the expected one (or, if the expected version is 0, there is no current
+// value or it has expired).
+func (s *store[T]) put(ctx context.Context, key string, value T, conditional bool, expectedVersion int64) (Item[T], stackerr.Error) {
+	encoded, err := s.encode(value)
+	if err != nil {
+		return Item[T]{}, err.WithSingle("state_key", key)
+	}
+
+	names := map[string]string{
+		"#value_column":   s.input.ValueColumn,
+		"#version_column": s.input.VersionColumn,
+	}
+	values := map[string]types.AttributeValue{
+		":value": encoded,
+		":one": &types.AttributeValueMemberN{
+			Value: "1",
+		},
+	}
+	updateExpression := "SET #value_column = :value ADD #version_column :one"
+
+	var expires time.Time
+	if s.input.TtlColumn != "" {
+		names["#ttl_column"] = s.input.TtlColumn
+		if s.input.TTL > 0 {
+			expires = s.clock.Now().Add(s.input.TTL)
+			values[":expires"] = &types.AttributeValueMemberN{
+				Value: fmt.Sprintf("%d", expires.Unix()),
+			}
+			updateExpression = "SET #value_column = :value, #ttl_column = :expires ADD #version_column :one"
+		} else {
+			updateExpression += " REMOVE #ttl_column"
+		}
+	}
+
+	var conditionExpression *string
+	if conditional {
+		if expectedVersion == 0 {
+			// An expired value that hasn't been deleted yet counts as not existing. Its
+			// version keeps increasing, so the returned version is still unique.
+			condition := "attribute_not_exists(#version_column)"
+			if s.input.TtlColumn != "" {
+				names["#ttl_column"] = s.input.TtlColumn
+				values[":now"] = &types.AttributeValueMemberN{
+					Value: fmt.Sprintf("%d", s.clock.Now().Unix()),
+				}
+				condition += " OR #ttl_column <= :now"
+			}
+			conditionExpression = conversions.GetPtr(condition)
+		} else {
+			values[":expected_version"] = &types.AttributeValueMemberN{
+				Value: fmt.Sprintf("%d", expectedVersion),
+			}
+			conditionExpression = conversions.GetPtr("#version_column = :expected_version")
+		}
+	}
+
+	output, cerr := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &s.tableName,
+		Key:                       s.itemKey(key),
+		UpdateExpression:          conversions.GetPtr(updateExpression),
+		ConditionExpression:       conditionExpression,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if cerr != nil {
+		var ccfe *types.ConditionalCheckFailedException
+		if errors.As(cerr, &ccfe) {
+			return Item[T]{}, stackerr.Wrap(ErrVersionConflict).With(map[string]any{
+				"state_key":        key,
+				"expected_version": expectedVersion,
+			})
+		}
+		return Item[T]{}, stackerr.Wrap(cerr).WithSingle("state_key", key)
+	}
+
+	item := Item[T]{
+		Value:   value,
+		Expires: expires,
+	}
+	if err := attributevalue.Unmarshal(output.Attributes[s.input.VersionColumn], &item.Version); err != nil {
+		return Item[T]{}, stackerr.Wrap(err).WithSingle("state_key", key)
+	}
+	return item, nil
+}
+
+func (s *store[T]) Put(ctx context.Context, key string, value T) (int64, stackerr.Error) {
+	item, err := s.put(ctx, key, value, false, 0)
+	return item.Version, err
+}
+
+func (s *store[T]) PutIfVersion(ctx context.Context, key string, value T, expectedVersion int64) (int64, stackerr.Error) {
+	if expectedVersion < 0 {
+		return 0, stackerr.Errorf("the expected version must not be negative, got %d", expectedVersion)
+	}
+	item, err := s.put(ctx, key, value, true, expectedVersion)
+	return item.Version, err
+}
+
+func (s *store[T]) UpdateWith(ctx context.Context, key string, update func(current T, found bool) (T, stackerr.Error)) (Item[T], stackerr.Error) {
+	backoff := dateutils.NewBackoff(*s.input.UpdateBackoff)
+	for attempt := 0; ; attempt++ {
+		current, found, err := s.Get(ctx, key)
+		if err != nil {
+			return Item[T]{}, err
+		}
+		updated, err := update(current.Value, found)
+		if err != nil {
+			return Item[T]{}, err
+		}
+		// An expired value is replaced as if there was none
+		expectedVersion := current.Version
+		if !found {
+			expectedVersion = 0
+		}
+		item, err := s.put(ctx, key, updated, true, expectedVersion)
+		if err == nil {
+			return item, nil
+		}
+		if !errors.Is(err, ErrVersionConflict) {
+			return Item[T]{}, err
+		}
+		if werr := backoff.Wait(ctx, attempt); werr != nil {
+			// If we've run out of attempts, return the conflict itself
+			if errors.Is(werr, dateutils.ErrBackoffExhausted) {
+				return Item[T]{}, err.WithSingle("attempts", attempt+1)
+			}
+			return Item[T]{}, werr.WithSingle("state_key", key)
+		}
+	}
+}
+
+func (s *store[T]) Delete(ctx context.Context, key string) stackerr.Error {
+	if _, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &s.tableName,
+		Key:       s.itemKey(key),
+	}); err != nil {
+		return stackerr.Wrap(err).WithSingle("state_key", key)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package strutils provides string manipulation helpers. All of them operate on runes
+// (not bytes), so they never split a multi-byte character.
+package strutils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The ellipsis that's used to mark truncated text
+const ellipsis = "…"
+
+// Truncate shortens a string to at most the given number of runes, replacing the end
+// with an ellipsis (which counts towards the limit) if it was shortened.
+func Truncate(s string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	runes := 0
+	for i := range s {
+		if runes == maxRunes-1 {
+			return s[:i] + ellipsis
+		}
+		runes++
+	}
+	return s
+}
+
+// TruncateMiddle shortens a string to at most the given number of runes, replacing the
+// middle with an ellipsis (which counts towards the limit) if it was shortened. This
+// keeps both ends, which is useful for things like paths and IDs.
+func TruncateMiddle(s string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	keep := maxRunes - 1
+	// If the kept runes can't be split evenly, keep more of the start
+	head := keep - keep/2
+	tail := keep / 2
+	return string(runes[:head]) + ellipsis + string(runes[len(runes)-tail:])
+}
+
+// SnakeToCamel converts a snake_case string to camelCase, e.g. "user_id" to "userId".
+// Leading, trailing, and repeated underscores are dropped.
+func SnakeToCamel(s string) string {
+	sb := strings.Builder{}
+	sb.Grow(len(s))
+	upperNext := false
+	for _, r := range s {
+		if r == '_' {
+			// Don't capitalize the first letter of the result
+			upperNext = sb.Len() > 0
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// CamelToSnake converts a camelCase or PascalCase string to snake_case, e.g. "userId" to
+// "user_id". Runs of capitals are treated as a single word, so "HTTPServerID" becomes
+// "http_server_id".
+func CamelToSnake(s string) string {
+	runes := []rune(s)
+	sb := strings.Builder{}
+	sb.Grow(len(s) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				previousLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				// The last capital of a run starts a new word if a lowercase letter follows it
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					sb.WriteByte('_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// MaskSecret masks all but the last `visible` runes of a secret with asterisks, e.g.
+// "sk_live_1234" with 4 visible runes becomes "********1234". If the secret is too short
+// for that to hide at least as much as it shows, the whole secret is masked.
+func MaskSecret(s string, visible int) string {
+	runes := []rune(s)
+	if visible < 0 || len(runes)-visible < visible {
+		visible = 0
+	}
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
+
+// SplitAndTrim splits a string by the separator, trims the whitespace around each
+// part, and drops empty parts, e.g. " a, b,,c " becomes ["a", "b", "c"].
+func SplitAndTrim(s string, sep string) []string {
+	parts := strings.Split(s, sep)
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// ContainsAnyFold returns whether the string contains any of the substrings,
+// ignoring case (using Unicode case folding).
+func ContainsAnyFold(s string, substrs ...string) bool {
+	folded := foldString(s)
+	for _, substr := range substrs {
+		if strings.Contains(folded, foldString(substr)) {
+			return true
+		}
+	}
+	return false
+}
+
+// foldString maps each rune to the smallest rune that's equivalent to it under
+// Unicode case folding, so that folded strings can be compared with strings.Contains.
+func foldString(s string) string {
+	return strings.Map(func(r rune) rune {
+		smallest := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < smallest {
+				smallest = f
+			}
+		}
+		return smallest
+	}, s)
+}
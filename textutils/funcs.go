@@ -0,0 +1,123 @@
+// Package textutils provides helpers for building text messages (such as alerts and
+// emails) from templates, so that values are consistently formatted and escaped.
+package textutils
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Invicton-Labs/go-common/dateutils"
+	"github.com/Invicton-Labs/go-common/numbers"
+	"github.com/Invicton-Labs/go-common/strutils"
+)
+
+// The characters that Slack requires to be escaped in message text
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackEscape escapes the control characters (&, <, and >) in text that will be
+// included in a Slack message, so that the text can't be interpreted as a link,
+// a mention, or other formatting.
+func SlackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// toInt64 converts any integer value to an int64, for template functions that
+// accept any integer type.
+func toInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	case time.Duration:
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", v)
+	}
+}
+
+// toDuration converts a value to a duration, for template functions. Durations are used
+// as-is, and integers are interpreted as a number of nanoseconds.
+func toDuration(v any) (time.Duration, error) {
+	if d, ok := v.(time.Duration); ok {
+		return d, nil
+	}
+	n, err := toInt64(v)
+	return time.Duration(n), err
+}
+
+// Funcs returns the functions that are available to templates parsed by this package,
+// so they can be added to other templates as well. They are:
+//   - bytes: formats a number of bytes in binary units (see numbers.FormatBytes)
+//   - bytesSI: formats a number of bytes in decimal units (see numbers.FormatBytesSI)
+//   - count: formats a count in a short form (see numbers.FormatCount)
+//   - duration: formats a duration in a short form (see dateutils.FormatDuration)
+//   - since: formats the duration since a time (see dateutils.FormatDuration)
+//   - time: formats a time as RFC 3339 in UTC
+//   - slackEscape: escapes text for a Slack message (see SlackEscape)
+//   - truncate: shortens text to a number of runes, e.g. `{{ truncate 100 .Message }}` (see strutils.Truncate)
+//   - default: returns the fallback if the value is empty, e.g. `{{ default "N/A" .Name }}`
+//   - upper, lower, trim, join: the strings package functions of the same name
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"bytes": func(v any) (string, error) {
+			n, err := toInt64(v)
+			return numbers.FormatBytes(n), err
+		},
+		"bytesSI": func(v any) (string, error) {
+			n, err := toInt64(v)
+			return numbers.FormatBytesSI(n), err
+		},
+		"count": func(v any) (string, error) {
+			n, err := toInt64(v)
+			return numbers.FormatCount(n), err
+		},
+		"duration": func(v any) (string, error) {
+			d, err := toDuration(v)
+			return dateutils.FormatDuration(d), err
+		},
+		"since": func(t time.Time) string {
+			return dateutils.FormatDuration(time.Since(t))
+		},
+		"time": func(t time.Time) string {
+			return t.UTC().Format(time.RFC3339)
+		},
+		"slackEscape": SlackEscape,
+		"truncate": func(maxRunes int, s string) string {
+			return strutils.Truncate(s, maxRunes)
+		},
+		"default": func(fallback any, v any) any {
+			if v == nil {
+				return fallback
+			}
+			if s, ok := v.(string); ok && s == "" {
+				return fallback
+			}
+			return v
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package textutils
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Invicton-Labs/go-stackerr"
+)
+
+// The key that parsed templates are cached by
+type templateKey struct {
+	name string
+	text string
+}
+
+// Templates that have already been parsed, by name and text
+var templateCache sync.Map
+
+// Parse parses a template, with the helper functions (see Funcs) available to it. Missing
+// map keys are an error when executing the template, instead of silently printing "<no value>".
+// Parsed templates are cached, so parsing the same name and text again is cheap; this means
+// the returned template must not be modified (e.g. with Funcs or New).
+func Parse(name string, text string) (*template.Template, stackerr.Error) {
+	key := templateKey{name: name, text: text}
+	if cached, ok := templateCache.Load(key); ok {
+		return cached.(*template.Template), nil
+	}
+	tmpl, err := template.New(name).Funcs(Funcs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, stackerr.Wrap(err).WithSingle("template_name", name)
+	}
+	cached, _ := templateCache.LoadOrStore(key, tmpl)
+	return cached.(*template.Template), nil
+}
+
+// MustParse is like Parse, but it panics if the template can't be parsed. It's
+// intended for templates that are declared as package variables.
+func MustParse(name string, text string) *template.Template {
+	tmpl, err := Parse(name, text)
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// Execute executes a parsed template with the given data, and returns the result.
+func Execute(tmpl *template.Template, data any) (string, stackerr.Error) {
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", stackerr.Wrap(err).WithSingle("template_name", tmpl.Name())
+	}
+	return sb.String(), nil
+}
+
+// Render parses (or gets from the cache) a template and executes it with the given
+// data. See Parse for details.
+func Render(name string, text string, data any) (string, stackerr.Error) {
+	tmpl, err := Parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	return Execute(tmpl, data)
+}
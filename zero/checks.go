@@ -0,0 +1,60 @@
+package zero
+
+import "reflect"
+
+// IsZero returns whether the value is the zero value of its type.
+func IsZero[T comparable](v T) bool {
+	var zero T
+	return v == zero
+}
+
+// IsZeroDeep returns whether the value is "empty". This is like reflect.Value.IsZero, except that:
+//   - Empty (including non-nil) slices and maps are zero
+//   - Structs and arrays are zero if all of their fields/elements are (deeply) zero
+//   - Interfaces are zero if they're nil or hold a (deeply) zero value
+//
+// Non-nil pointers are never zero, even if they point to a zero value, since a pointer
+// is usually used to distinguish "not set" from "set to the zero value".
+func IsZeroDeep(v any) bool {
+	return isZeroDeepValue(reflect.ValueOf(v))
+}
+
+func isZeroDeepValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Interface:
+		return v.IsNil() || isZeroDeepValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZeroDeepValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZeroDeepValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return v.IsZero()
+	}
+}
+
+// Coalesce returns the first value that isn't the zero value of its type, or
+// the zero value if they all are.
+func Coalesce[T comparable](vals ...T) T {
+	var zero T
+	for _, v := range vals {
+		if v != zero {
+			return v
+		}
+	}
+	return zero
+}